@@ -0,0 +1,163 @@
+// Package classifier предлагает категории для новых операций по истории пользователя.
+// Используется наивный байесовский классификатор по словам описания и порядку суммы,
+// который обучается на транзакциях самого пользователя без обращения к внешним сервисам.
+package classifier
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stemLength до скольких букв обрезаются слова: грубая замена стемминга, чтобы
+// "продукты" и "продуктов" считались одним словом
+const stemLength = 6
+
+// amountBucketsPerDecade на сколько корзин делится каждый порядок суммы (от 100 до 1000 и т.д.)
+const amountBucketsPerDecade = 3
+
+// Example операция с известной категорией, на которой обучается классификатор
+type Example struct {
+	Description string
+	Amount      float64
+	CategoryID  uint
+}
+
+// Suggestion предложенная категория и уверенность в ней от 0 до 1
+type Suggestion struct {
+	CategoryID uint    `json:"categoryId"`
+	Confidence float64 `json:"confidence"`
+}
+
+// classStats статистика слов и сумм одной категории
+type classStats struct {
+	examples    int
+	tokens      map[string]int
+	totalTokens int
+	amounts     map[int]int // количество операций по корзинам суммы
+}
+
+// Model обученный классификатор. Слова описания учитываются мультиномиальной моделью,
+// а корзина суммы - отдельным признаком, чтобы длинные описания не заглушали сумму.
+type Model struct {
+	classes    map[uint]*classStats
+	vocabulary map[string]bool
+	buckets    map[int]bool
+	examples   int
+}
+
+// Train обучает классификатор на операциях с известными категориями
+func Train(examples []Example) *Model {
+	m := &Model{
+		classes:    make(map[uint]*classStats),
+		vocabulary: make(map[string]bool),
+		buckets:    make(map[int]bool),
+	}
+	for _, example := range examples {
+		class, ok := m.classes[example.CategoryID]
+		if !ok {
+			class = &classStats{tokens: make(map[string]int), amounts: make(map[int]int)}
+			m.classes[example.CategoryID] = class
+		}
+		class.examples++
+		m.examples++
+		for _, token := range Tokenize(example.Description) {
+			class.tokens[token]++
+			class.totalTokens++
+			m.vocabulary[token] = true
+		}
+		if bucket, ok := amountBucket(example.Amount); ok {
+			class.amounts[bucket]++
+			m.buckets[bucket] = true
+		}
+	}
+	return m
+}
+
+// Examples возвращает количество операций, на которых обучен классификатор
+func (m *Model) Examples() int {
+	return m.examples
+}
+
+// Suggest возвращает не больше limit категорий, наиболее вероятных для операции,
+// в порядке убывания уверенности. Уверенность - апостериорная вероятность категории.
+func (m *Model) Suggest(description string, amount float64, limit int) []Suggestion {
+	if m.examples == 0 || limit <= 0 {
+		return nil
+	}
+
+	// Словарь и корзины сумм дополняются одним значением для незнакомых слов и сумм:
+	// иначе при обучении на описаниях без слов знаменатель сглаживания становится нулевым
+	tokens := Tokenize(description)
+	vocabulary := float64(len(m.vocabulary) + 1)
+	bucket, hasAmount := amountBucket(amount)
+	buckets := float64(len(m.buckets) + 1)
+
+	// Логарифмы вероятностей с аддитивным сглаживанием Лапласа
+	scores := make(map[uint]float64, len(m.classes))
+	maxScore := math.Inf(-1)
+	for id, class := range m.classes {
+		score := math.Log(float64(class.examples) / float64(m.examples))
+		for _, token := range tokens {
+			score += math.Log((float64(class.tokens[token]) + 1) / (float64(class.totalTokens) + vocabulary))
+		}
+		if hasAmount {
+			score += math.Log((float64(class.amounts[bucket]) + 1) / (float64(class.examples) + buckets))
+		}
+		scores[id] = score
+		if score > maxScore {
+			maxScore = score
+		}
+	}
+
+	// Переводим логарифмы в вероятности, вычитая максимум для устойчивости
+	var sum float64
+	suggestions := make([]Suggestion, 0, len(scores))
+	for id, score := range scores {
+		p := math.Exp(score - maxScore)
+		sum += p
+		suggestions = append(suggestions, Suggestion{CategoryID: id, Confidence: p})
+	}
+	for i := range suggestions {
+		suggestions[i].Confidence = math.Round(suggestions[i].Confidence/sum*1000) / 1000
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].CategoryID < suggestions[j].CategoryID
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// Tokenize разбивает описание на слова из букв: цифры в описаниях банков - это номера карт и дат
+func Tokenize(description string) []string {
+	var tokens []string
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(strings.ReplaceAll(word, "ё", "е"))
+		if len(runes) < 2 {
+			continue
+		}
+		if len(runes) > stemLength {
+			runes = runes[:stemLength]
+		}
+		tokens = append(tokens, string(runes))
+	}
+	return tokens
+}
+
+// amountBucket возвращает корзину порядка суммы: соседние корзины отличаются примерно в 2 раза
+func amountBucket(amount float64) (int, bool) {
+	if amount <= 0 {
+		return 0, false
+	}
+	return int(math.Floor(math.Log10(amount) * amountBucketsPerDecade)), true
+}
@@ -0,0 +1,144 @@
+package classifier
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// history операции, на которых обучаются тесты: продукты, кафе, такси и зарплата
+var history = []Example{
+	{"Пятёрочка", 1250, 1},
+	{"ПЯТЕРОЧКА MOSCOW", 830, 1},
+	{"Магнит у дома", 640, 1},
+	{"Перекресток продукты", 2100, 1},
+	{"Кофейня Шоколадница", 350, 2},
+	{"Кофе с собой", 250, 2},
+	{"Кофейня у метро", 300, 2},
+	{"Яндекс Такси", 450, 3},
+	{"Такси до аэропорта", 1500, 3},
+	{"Зарплата за март", 120000, 4},
+}
+
+func TestSuggestTopThree(t *testing.T) {
+	model := Train(history)
+	if model.Examples() != len(history) {
+		t.Fatalf("обучено на %d операциях, ожидалось %d", model.Examples(), len(history))
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		amount      float64
+		want        uint // ожидаемая первая категория
+	}{
+		{"знакомое слово", "пятерочка на углу", 900, 1},
+		{"форма слова", "Кофейни центра", 280, 2},
+		{"слово и сумма", "Такси", 450, 3},
+		{"зарплата", "Зарплата за апрель", 120000, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := model.Suggest(tt.description, tt.amount, 3)
+			if len(suggestions) != 3 {
+				t.Fatalf("получено %d предложений, ожидалось 3", len(suggestions))
+			}
+			if suggestions[0].CategoryID != tt.want {
+				t.Errorf("первая категория %d, ожидалась %d: %+v", suggestions[0].CategoryID, tt.want, suggestions)
+			}
+			for i := 1; i < len(suggestions); i++ {
+				if suggestions[i].Confidence > suggestions[i-1].Confidence {
+					t.Errorf("предложения не упорядочены по уверенности: %+v", suggestions)
+				}
+			}
+			if suggestions[0].Confidence <= suggestions[1].Confidence {
+				t.Errorf("первая категория не выделяется по уверенности: %+v", suggestions)
+			}
+		})
+	}
+}
+
+func TestSuggestConfidence(t *testing.T) {
+	model := Train(history)
+
+	// Уверенность по всем категориям - распределение вероятностей
+	all := model.Suggest("Кофейня", 300, 10)
+	if len(all) != 4 {
+		t.Fatalf("получено %d предложений, ожидалось 4", len(all))
+	}
+	var sum float64
+	for _, suggestion := range all {
+		if suggestion.Confidence < 0 || suggestion.Confidence > 1 {
+			t.Errorf("уверенность %v вне диапазона от 0 до 1", suggestion.Confidence)
+		}
+		sum += suggestion.Confidence
+	}
+	if math.Abs(sum-1) > 0.005 {
+		t.Errorf("сумма уверенностей %v, ожидалась 1", sum)
+	}
+
+	// Ограничение количества не меняет порядок и значения
+	top := model.Suggest("Кофейня", 300, 3)
+	if !reflect.DeepEqual(top, all[:3]) {
+		t.Errorf("первые три предложения %+v, ожидались %+v", top, all[:3])
+	}
+
+	// Больше подтверждающих слов - выше уверенность
+	weak := model.Suggest("Кофе", 0, 1)
+	strong := model.Suggest("Кофейня кофе у метро", 300, 1)
+	if weak[0].CategoryID != 2 || strong[0].CategoryID != 2 || strong[0].Confidence <= weak[0].Confidence {
+		t.Errorf("уверенность %+v должна быть выше, чем %+v", strong, weak)
+	}
+
+	// Описание без слов и без суммы оценивается только по частоте категорий
+	unknown := model.Suggest("1234", 0, 1)
+	if unknown[0].CategoryID != 1 || math.Abs(unknown[0].Confidence-0.4) > 0.001 {
+		t.Errorf("для незнакомого описания %+v, ожидалась категория 1 с уверенностью 0.4", unknown)
+	}
+}
+
+func TestSuggestStableOrder(t *testing.T) {
+	// При равной уверенности категории упорядочены по идентификатору, результат не зависит от обхода map
+	model := Train([]Example{{"аптека", 0, 3}, {"бензин", 0, 1}, {"вокзал", 0, 2}})
+	want := []Suggestion{{1, 0.333}, {2, 0.333}, {3, 0.333}}
+	for i := 0; i < 20; i++ {
+		if got := model.Suggest("что-то новое", 0, 3); !reflect.DeepEqual(got, want) {
+			t.Fatalf("предложения %+v, ожидались %+v", got, want)
+		}
+	}
+}
+
+func TestSuggestWithoutVocabulary(t *testing.T) {
+	// Описания обучающих операций без слов не должны приводить к делению на ноль
+	model := Train([]Example{{"1234", 100, 1}, {"*", 100, 1}})
+	got := model.Suggest("Кофейня", 100, 3)
+	if len(got) != 1 || got[0].CategoryID != 1 || got[0].Confidence != 1 {
+		t.Errorf("предложения %+v, ожидалась категория 1 с уверенностью 1", got)
+	}
+}
+
+func TestSuggestEmpty(t *testing.T) {
+	if got := Train(nil).Suggest("Пятёрочка", 100, 3); got != nil {
+		t.Errorf("необученная модель вернула %+v", got)
+	}
+	if got := Train(history).Suggest("Пятёрочка", 100, 0); got != nil {
+		t.Errorf("при нулевом ограничении возвращено %+v", got)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		description string
+		want        []string
+	}{
+		{"Пятёрочка #1234", []string{"пятеро"}},
+		{"YANDEX*GO Москва", []string{"yandex", "go", "москва"}},
+		{"Оплата по карте ****1234 в 12:30", []string{"оплата", "по", "карте"}},
+		{"1234 / *", nil},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.description); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, ожидалось %q", tt.description, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package classifier
+
+import (
+	"strings"
+
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// historyLimit сколько последних операций пользователя используется для обучения.
+// Недавние операции лучше отражают текущие привычки, а обучение остается быстрым.
+const historyLimit = 5000
+
+// TrainForUser обучает классификатор на последних операциях пользователя.
+// Переводы и транзакции в корзине не учитываются, разделенные транзакции дают пример на каждую часть
+// (к описанию добавляется заметка части). Если categoryType не пуст, используются
+// только категории этого типа.
+func TrainForUser(userID uint, categoryType models.CategoryType) (*Model, error) {
+	type historyRow struct {
+		CategoryID  uint
+		Description string
+		Note        string
+		Amount      float64
+	}
+
+	query := db.DB.Table("transactions t").
+		Select("COALESCE(s.category_id, t.category_id) AS category_id, t.description, COALESCE(s.note, '') AS note, COALESCE(s.amount, t.amount) AS amount").
+		Joins("LEFT JOIN transaction_splits s ON s.transaction_id = t.id").
+		Joins("JOIN categories c ON c.id = COALESCE(s.category_id, t.category_id)").
+		Where("t.user_id = ? AND t.kind = ? AND t.deleted_at IS NULL", userID, models.KindRegular)
+	if categoryType != "" {
+		query = query.Where("c.type = ?", categoryType)
+	}
+
+	var rows []historyRow
+	if err := query.Order("t.date DESC, t.id DESC").Limit(historyLimit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	examples := make([]Example, 0, len(rows))
+	for _, row := range rows {
+		examples = append(examples, Example{
+			Description: strings.TrimSpace(row.Description + " " + row.Note),
+			Amount:      row.Amount,
+			CategoryID:  row.CategoryID,
+		})
+	}
+	return Train(examples), nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -24,6 +25,13 @@ type Config struct {
 	SMTPFrom     string
 	// Фронтенд
 	FrontendURL string
+	// Файл с курсами валют в формате ЦБ РФ, загружается при старте
+	ExchangeRatesFile string
+	// Хранилище вложений: драйвер (пока только local) и каталог для локального хранилища
+	StorageDriver string
+	StoragePath   string
+	// Сколько дней удаленные транзакции, категории, бюджеты и регулярные платежи хранятся в корзине
+	TrashRetentionDays int
 }
 
 // LoadConfig загружает конфигурацию из .env файла
@@ -49,6 +57,12 @@ func LoadConfig() *Config {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		SMTPFrom:     getEnv("SMTP_FROM", "noreply@financehub.example.com"),
 		FrontendURL:  getEnv("FRONTEND_URL", "http://localhost:3001"),
+
+		ExchangeRatesFile: getEnv("EXCHANGE_RATES_FILE", ""),
+		StorageDriver:     getEnv("STORAGE_DRIVER", "local"),
+		StoragePath:       getEnv("STORAGE_PATH", "./uploads"),
+
+		TrashRetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
 	}
 }
 
@@ -60,3 +74,12 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt получает целочисленное значение переменной окружения или возвращает значение по умолчанию
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,449 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// AccountController контроллер для счетов (кошельков)
+type AccountController struct{}
+
+// NewAccountController создает новый контроллер счетов
+func NewAccountController() *AccountController {
+	return &AccountController{}
+}
+
+// GetAllAccounts получает все счета пользователя вместе с текущими остатками
+func (ac *AccountController) GetAllAccounts(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	query := db.DB.Where("user_id = ?", userID)
+	if c.Query("include_archived") != "true" {
+		query = query.Where("is_archived = ?", false)
+	}
+
+	var accounts []models.Account
+	if err := query.Order("name").Find(&accounts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить счета",
+			"error":   err.Error(),
+		})
+	}
+
+	type accountWithBalance struct {
+		models.Account
+		Balance float64 `json:"balance"`
+	}
+
+	now := time.Now()
+	result := make([]accountWithBalance, 0, len(accounts))
+	for _, account := range accounts {
+		balance, err := calculateAccountBalance(account, now)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось рассчитать остаток по счету",
+				"error":   err.Error(),
+			})
+		}
+		result = append(result, accountWithBalance{Account: account, Balance: balance.Balance})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// GetAccountByID получает счет по ID
+func (ac *AccountController) GetAccountByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var account models.Account
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   account,
+	})
+}
+
+// CreateAccount создает новый счет
+func (ac *AccountController) CreateAccount(c *fiber.Ctx) error {
+	var input models.AccountDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	account := models.Account{
+		UserID:         userID,
+		Name:           input.Name,
+		Type:           input.Type,
+		Currency:       resolveCurrency(input.Currency, nil, userID),
+		OpeningBalance: input.OpeningBalance,
+		Description:    input.Description,
+		Color:          input.Color,
+		Icon:           input.Icon,
+		IsArchived:     input.IsArchived,
+	}
+
+	if err := db.DB.Create(&account).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать счет",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityAccount, account.ID, models.ActionCreate, nil, account)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Счет успешно создан",
+		"data":    account,
+	})
+}
+
+// UpdateAccount обновляет счет
+func (ac *AccountController) UpdateAccount(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var account models.Account
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+			"error":   err.Error(),
+		})
+	}
+	oldAccount := account
+
+	var input models.AccountDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	account.Name = input.Name
+	account.Type = input.Type
+	// Если валюта не передана, сохраняем текущую валюту счета
+	if input.Currency != "" {
+		account.Currency = resolveCurrency(input.Currency, nil, userID)
+	}
+	account.OpeningBalance = input.OpeningBalance
+	account.Description = input.Description
+	account.Color = input.Color
+	account.Icon = input.Icon
+	account.IsArchived = input.IsArchived
+
+	if err := db.DB.Save(&account).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить счет",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityAccount, account.ID, models.ActionUpdate, oldAccount, account)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Счет успешно обновлен",
+		"data":    account,
+	})
+}
+
+// DeleteAccount удаляет счет
+func (ac *AccountController) DeleteAccount(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var account models.Account
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	if accountHasTransactions(account.ID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Невозможно удалить счет, так как существуют связанные с ним транзакции (в том числе в корзине). Архивируйте его вместо удаления",
+		})
+	}
+
+	if err := deleteAccount(account); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить счет",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityAccount, account.ID, models.ActionDelete, account, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Счет успешно удален",
+	})
+}
+
+// accountHasTransactions проверяет, есть ли транзакции по счету, в том числе в корзине
+func accountHasTransactions(accountID uint) bool {
+	var transactionCount int64
+	db.DB.Unscoped().Model(&models.Transaction{}).Where("account_id = ? OR to_account_id = ?", accountID, accountID).Count(&transactionCount)
+	return transactionCount > 0
+}
+
+// deleteAccount удаляет счет без транзакций вместе с его сверками и отвязывает от него
+// регулярные платежи, включая платежи в корзине
+func deleteAccount(account models.Account) error {
+	if err := db.DB.Unscoped().Model(&models.RecurringRule{}).Where("account_id = ?", account.ID).Update("account_id", nil).Error; err != nil {
+		return err
+	}
+	if err := db.DB.Where("account_id = ?", account.ID).Delete(&models.Reconciliation{}).Error; err != nil {
+		return err
+	}
+	return db.DB.Delete(&account).Error
+}
+
+// GetAccountBalance получает остаток по счету на дату (по умолчанию на текущий момент)
+func (ac *AccountController) GetAccountBalance(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var account models.Account
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	date := time.Now()
+	if dateStr := c.Query("date"); dateStr != "" {
+		date = parseDateParam(dateStr, false)
+	}
+
+	balance, err := calculateAccountBalance(account, date)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать остаток по счету",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   balance,
+	})
+}
+
+// GetAccountHistory получает историю операций по счету с остатком после каждой операции
+func (ac *AccountController) GetAccountHistory(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var account models.Account
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	startDate := parseDateParam(c.Query("start_date"), true)
+	endDate := parseDateParam(c.Query("end_date"), false)
+
+	// Остаток на начало периода
+	opening, err := calculateAccountBalance(account, startDate.Add(-time.Nanosecond))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать остаток по счету",
+			"error":   err.Error(),
+		})
+	}
+
+	var transactions []models.Transaction
+	if err := db.DB.Where("user_id = ? AND (account_id = ? OR to_account_id = ?) AND date BETWEEN ? AND ?", userID, account.ID, account.ID, startDate, endDate).
+		Preload("Category").
+		Preload("Account").
+		Preload("ToAccount").
+		Order("date ASC, id ASC").
+		Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить операции по счету",
+			"error":   err.Error(),
+		})
+	}
+
+	runningBalance := opening.Balance
+	history := make([]models.AccountHistoryEntry, 0, len(transactions))
+	rates := rateCache{}
+	for _, t := range transactions {
+		// Изменение остатка пересчитывается в валюту счета
+		rate, err := rates.get(t.Currency, account.Currency, t.Date)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось получить курс валюты",
+				"error":   err.Error(),
+			})
+		}
+		change := accountBalanceChange(t, account.ID) * rate
+		runningBalance += change
+		history = append(history, models.AccountHistoryEntry{
+			Transaction: t,
+			Change:      change,
+			Balance:     runningBalance,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"account":         account,
+			"history":         history,
+			"opening_balance": opening.Balance,
+			"closing_balance": runningBalance,
+			"start_date":      startDate,
+			"end_date":        endDate,
+		},
+	})
+}
+
+// calculateAccountBalance рассчитывает остаток по счету на указанную дату включительно
+func calculateAccountBalance(account models.Account, date time.Time) (models.AccountBalance, error) {
+	return filteredAccountBalance(account, date, "")
+}
+
+// filteredAccountBalance рассчитывает остаток по счету на дату только по транзакциям,
+// удовлетворяющим дополнительному SQL-условию над псевдонимом t (пустое условие - по всем)
+func filteredAccountBalance(account models.Account, date time.Time, condition string, args ...interface{}) (models.AccountBalance, error) {
+	var totals struct {
+		TotalIncome  float64
+		TotalExpense float64
+		TransfersIn  float64
+		TransfersOut float64
+	}
+
+	// Переводы учитываются отдельно: сумма зачисляется на счет получателя,
+	// а сумма вместе с комиссией списывается со счета отправителя.
+	// Возвраты уменьшают расходы счета, на который вернулись деньги.
+	// Суммы в другой валюте пересчитываются в валюту счета по курсу на дату транзакции.
+	query := `
+		SELECT
+			COALESCE(SUM(CASE WHEN t.kind = 'regular' AND t.account_id = ? AND c.type = 'income' THEN t.amount * t.rate ELSE 0 END), 0) as total_income,
+			COALESCE(SUM(CASE WHEN t.kind = 'regular' AND t.account_id = ? AND c.type = 'expense' THEN t.amount * t.rate
+				WHEN t.kind = 'refund' AND t.account_id = ? THEN -t.amount * t.rate ELSE 0 END), 0) as total_expense,
+			COALESCE(SUM(CASE WHEN t.kind = 'transfer' AND t.to_account_id = ? THEN t.amount * t.rate ELSE 0 END), 0) as transfers_in,
+			COALESCE(SUM(CASE WHEN t.kind = 'transfer' AND t.account_id = ? THEN (t.amount + t.fee) * t.rate ELSE 0 END), 0) as transfers_out
+		FROM (
+			SELECT tr.*, ` + convertedAmountSQL("1", "tr.currency", "?", "tr.date") + ` AS rate
+			FROM transactions tr
+			WHERE tr.deleted_at IS NULL
+		) t
+		LEFT JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = ? AND (t.account_id = ? OR t.to_account_id = ?) AND t.date <= ?
+	`
+	queryArgs := []interface{}{account.ID, account.ID, account.ID, account.ID, account.ID, account.Currency,
+		account.UserID, account.ID, account.ID, date}
+	if condition != "" {
+		query += " AND (" + condition + ")"
+		queryArgs = append(queryArgs, args...)
+	}
+	if err := db.DB.Raw(query, queryArgs...).Scan(&totals).Error; err != nil {
+		return models.AccountBalance{}, err
+	}
+
+	return models.AccountBalance{
+		AccountID:      account.ID,
+		OpeningBalance: account.OpeningBalance,
+		TotalIncome:    totals.TotalIncome,
+		TotalExpense:   totals.TotalExpense,
+		TransfersIn:    totals.TransfersIn,
+		TransfersOut:   totals.TransfersOut,
+		Balance:        account.OpeningBalance + totals.TotalIncome - totals.TotalExpense + totals.TransfersIn - totals.TransfersOut,
+		Currency:       account.Currency,
+		Date:           date,
+	}, nil
+}
+
+// accountBalanceChange возвращает изменение остатка счета от транзакции (доход со знаком плюс, расход со знаком минус)
+func accountBalanceChange(t models.Transaction, accountID uint) float64 {
+	if t.IsTransfer() {
+		change := 0.0
+		if t.ToAccountID != nil && *t.ToAccountID == accountID {
+			change += t.Amount
+		}
+		if t.AccountID != nil && *t.AccountID == accountID {
+			change -= t.Amount + t.Fee
+		}
+		return change
+	}
+	if t.AccountID == nil || *t.AccountID != accountID {
+		return 0
+	}
+	if t.IsRefund() {
+		return t.Amount
+	}
+	if t.Category == nil {
+		return 0
+	}
+	if t.Category.Type == models.Income {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+// checkAccountOwnership проверяет, что счет существует и принадлежит пользователю
+func checkAccountOwnership(accountID *uint, userID uint) error {
+	if accountID == nil {
+		return nil
+	}
+	var account models.Account
+	return db.DB.Where("id = ? AND user_id = ?", *accountID, userID).First(&account).Error
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/storage"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// AttachmentController контроллер для вложений транзакций (чеки, счета, гарантийные талоны)
+type AttachmentController struct{}
+
+// NewAttachmentController создает новый контроллер вложений
+func NewAttachmentController() *AttachmentController {
+	return &AttachmentController{}
+}
+
+// GetAttachments возвращает вложения транзакции
+func (ac *AttachmentController) GetAttachments(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	transaction, err := findUserTransaction(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Транзакция не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	var attachments []models.Attachment
+	if err := db.DB.Where("transaction_id = ? AND user_id = ?", transaction.ID, userID).Order("created_at").Find(&attachments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить вложения",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   attachments,
+	})
+}
+
+// UploadAttachment прикрепляет файл из поля file формы к транзакции.
+// Суммарный объем вложений пользователя с новым файлом не может превышать квоту его плана.
+func (ac *AttachmentController) UploadAttachment(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	transaction, err := findUserTransaction(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Транзакция не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Файл не передан",
+			"error":   err.Error(),
+		})
+	}
+	if fileHeader.Size > models.MaxAttachmentSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("Размер файла не должен превышать %d МБ", models.MaxAttachmentSize/(1024*1024)),
+		})
+	}
+
+	var used int64
+	if err := db.DB.Model(&models.Attachment{}).Where("user_id = ?", userID).Select("COALESCE(SUM(size), 0)").Scan(&used).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить квоту на вложения",
+			"error":   err.Error(),
+		})
+	}
+	if quota := models.AttachmentQuotas[middlewares.GetSubscriptionPlan(c)]; used+fileHeader.Size > quota {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("Превышена квота на вложения для вашего плана: занято %.1f МБ из %.0f МБ. Удалите ненужные файлы или перейдите на план выше.", float64(used)/(1024*1024), float64(quota)/(1024*1024)),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось прочитать файл",
+			"error":   err.Error(),
+		})
+	}
+	defer file.Close()
+
+	// Тип определяется по первым байтам файла, чтобы под видом чека нельзя было загрузить что угодно
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось прочитать файл",
+			"error":   err.Error(),
+		})
+	}
+	head = head[:n]
+
+	contentType := detectAttachmentType(head)
+	extension, ok := models.AttachmentContentTypes[contentType]
+	if !ok {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Поддерживаются только изображения (JPEG, PNG, WebP, HEIC) и документы PDF",
+		})
+	}
+
+	attachment := models.Attachment{
+		UserID:        userID,
+		TransactionID: transaction.ID,
+		FileName:      attachmentFileName(fileHeader.Filename, extension),
+		ContentType:   contentType,
+		Size:          fileHeader.Size,
+		StorageKey:    fmt.Sprintf("%d/%d/%s%s", userID, transaction.ID, utils.GenerateRandomToken(16), extension),
+	}
+
+	if err := storage.Files.Save(attachment.StorageKey, io.MultiReader(bytes.NewReader(head), file)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить файл",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Create(&attachment).Error; err != nil {
+		// Файл без записи в базе никому не доступен, удаляем его
+		if deleteErr := storage.Files.Delete(attachment.StorageKey); deleteErr != nil {
+			log.Printf("Не удалось удалить файл %s: %v", attachment.StorageKey, deleteErr)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить вложение",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityAttachment, attachment.ID, models.ActionCreate, nil, attachment)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Файл успешно прикреплен",
+		"data":    attachment,
+	})
+}
+
+// DownloadAttachment отдает файл вложения. Изображения и PDF открываются в браузере,
+// параметр download=true отдает файл на скачивание.
+func (ac *AttachmentController) DownloadAttachment(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	attachment, err := findTransactionAttachment(c.Params("id"), c.Params("attachmentId"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Вложение не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	file, err := storage.Files.Open(attachment.StorageKey)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if err == storage.ErrNotFound {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось открыть файл вложения",
+			"error":   err.Error(),
+		})
+	}
+
+	disposition := "inline"
+	if c.QueryBool("download") {
+		disposition = "attachment"
+	}
+	c.Set("Content-Type", attachment.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf("%s; filename*=UTF-8''%s", disposition, urlPathEscape(attachment.FileName)))
+	c.Set("X-Content-Type-Options", "nosniff")
+
+	// Fiber закрывает поток после отправки ответа
+	return c.SendStream(file, int(attachment.Size))
+}
+
+// DeleteAttachment удаляет вложение и его файл
+func (ac *AttachmentController) DeleteAttachment(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	attachment, err := findTransactionAttachment(c.Params("id"), c.Params("attachmentId"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Вложение не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Delete(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить вложение",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityAttachment, attachment.ID, models.ActionDelete, attachment, nil)
+	deleteAttachmentFiles([]models.Attachment{attachment})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Вложение успешно удалено",
+	})
+}
+
+// findUserTransaction находит транзакцию пользователя по ID из параметра маршрута
+func findUserTransaction(id string, userID uint) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&transaction).Error
+	return transaction, err
+}
+
+// findTransactionAttachment находит вложение транзакции пользователя
+func findTransactionAttachment(transactionID, attachmentID string, userID uint) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := db.DB.Where("id = ? AND transaction_id = ? AND user_id = ?", attachmentID, transactionID, userID).First(&attachment).Error
+	return attachment, err
+}
+
+// transactionAttachments возвращает вложения транзакций, чтобы удалить их файлы вместе с транзакциями
+func transactionAttachments(transactionIDs []uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := db.DB.Where("transaction_id IN ?", transactionIDs).Find(&attachments).Error
+	return attachments, err
+}
+
+// deleteAttachmentFiles удаляет файлы вложений из хранилища после удаления записей.
+// Ошибки только логируются: записей уже нет, и файлы недоступны пользователю.
+func deleteAttachmentFiles(attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		if err := storage.Files.Delete(attachment.StorageKey); err != nil {
+			log.Printf("Не удалось удалить файл вложения %s: %v", attachment.StorageKey, err)
+		}
+	}
+}
+
+// detectAttachmentType определяет тип файла по содержимому. HEIC стандартная библиотека
+// не распознает, поэтому он проверяется по сигнатуре ftyp отдельно.
+func detectAttachmentType(head []byte) string {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "heic", "heix", "mif1", "msf1":
+			return "image/heic"
+		}
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return contentType
+}
+
+// attachmentFileName возвращает имя файла без пути; расширение приводится к типу содержимого
+func attachmentFileName(name, extension string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "attachment"
+	}
+	if ext := strings.ToLower(filepath.Ext(name)); ext != extension && !(ext == ".jpeg" && extension == ".jpg") {
+		name += extension
+	}
+	return name
+}
+
+// urlPathEscape кодирует имя файла для заголовка Content-Disposition (RFC 5987)
+func urlPathEscape(name string) string {
+	var b strings.Builder
+	for _, c := range []byte(name) {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
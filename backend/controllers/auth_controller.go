@@ -420,6 +420,10 @@ func (a *AuthController) UpdateMe(c *fiber.Ctx) error {
 	user.FirstName = encFirstName
 	user.LastName = encLastName
 	user.TelegramChatID = input.TelegramChatID
+	// Статистика пересчитывается в новую базовую валюту на лету, поэтому достаточно сохранить ее
+	if input.BaseCurrency != "" {
+		user.BaseCurrency = resolveCurrency(input.BaseCurrency, nil, userID)
+	}
 
 	if err := db.DB.Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
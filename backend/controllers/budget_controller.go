@@ -91,6 +91,7 @@ func (bc *BudgetController) CreateBudget(c *fiber.Ctx) error {
 	budget := models.Budget{
 		Name:       input.Name,
 		Amount:     input.Amount,
+		Currency:   resolveCurrency(input.Currency, nil, userID),
 		Period:     input.Period,
 		StartDate:  input.StartDate,
 		EndDate:    input.EndDate,
@@ -98,6 +99,17 @@ func (bc *BudgetController) CreateBudget(c *fiber.Ctx) error {
 		UserID:     userID,
 	}
 
+	// Потраченная сумма считается в валюте бюджета
+	spent, err := calculateBudgetSpent(budget)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать потраченную сумму",
+			"error":   err.Error(),
+		})
+	}
+	budget.Spent = spent
+
 	if err := db.DB.Create(&budget).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -105,6 +117,7 @@ func (bc *BudgetController) CreateBudget(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityBudget, budget.ID, models.ActionCreate, nil, budget)
 
 	// Загружаем связанную категорию для ответа (если есть)
 	db.DB.Preload("Category").First(&budget, budget.ID)
@@ -129,6 +142,7 @@ func (bc *BudgetController) UpdateBudget(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	oldBudget := budget
 
 	var input models.BudgetDTO
 	if err := c.BodyParser(&input); err != nil {
@@ -165,6 +179,21 @@ func (bc *BudgetController) UpdateBudget(c *fiber.Ctx) error {
 	budget.StartDate = input.StartDate
 	budget.EndDate = input.EndDate
 	budget.CategoryID = input.CategoryID
+	// Если валюта не передана, сохраняем текущую валюту бюджета
+	if input.Currency != "" {
+		budget.Currency = resolveCurrency(input.Currency, nil, userID)
+	}
+
+	// Пересчитываем потраченную сумму с учетом нового периода, категории и валюты
+	spent, err := calculateBudgetSpent(budget)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать потраченную сумму",
+			"error":   err.Error(),
+		})
+	}
+	budget.Spent = spent
 
 	if err := db.DB.Save(&budget).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -173,6 +202,7 @@ func (bc *BudgetController) UpdateBudget(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityBudget, budget.ID, models.ActionUpdate, oldBudget, budget)
 
 	// Загружаем связанную категорию для ответа (если есть)
 	db.DB.Preload("Category").First(&budget, budget.ID)
@@ -184,7 +214,7 @@ func (bc *BudgetController) UpdateBudget(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteBudget удаляет бюджет
+// DeleteBudget перемещает бюджет в корзину
 func (bc *BudgetController) DeleteBudget(c *fiber.Ctx) error {
 	id := c.Params("id")
 	userID := middlewares.GetUserID(c)
@@ -205,9 +235,10 @@ func (bc *BudgetController) DeleteBudget(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityBudget, budget.ID, models.ActionDelete, budget, nil)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Бюджет успешно удален",
+		"message": "Бюджет перемещен в корзину",
 	})
 }
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/nikitagorchakov/finance-hub/backend/db"
 	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
 	"github.com/nikitagorchakov/finance-hub/backend/models"
 	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
 )
 
 // CategoryController контроллер для категорий
@@ -35,6 +39,37 @@ func (ct *CategoryController) GetAllCategories(c *fiber.Ctx) error {
 	})
 }
 
+// CategoryNode категория вместе с подкатегориями в дереве категорий
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// GetCategoryTree получает категории пользователя в виде дерева. Параметр type ограничивает
+// дерево расходными или доходными категориями.
+func (ct *CategoryController) GetCategoryTree(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	query := db.DB.Where("user_id = ?", userID).Order("categories.name")
+	if categoryType := c.Query("type"); categoryType != "" {
+		query = query.Where("type = ?", categoryType)
+	}
+
+	var categories []models.Category
+	if err := query.Find(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить категории",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   buildCategoryTree(categories),
+	})
+}
+
 // GetCategoryByID получает категорию по ID
 func (ct *CategoryController) GetCategoryByID(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -80,11 +115,27 @@ func (ct *CategoryController) CreateCategory(c *fiber.Ctx) error {
 		Name:        input.Name,
 		Description: input.Description,
 		Type:        input.Type,
+		ParentID:    input.ParentID,
 		Color:       input.Color,
 		Icon:        input.Icon,
 		UserID:      userID,
 	}
 
+	message, err := checkCategoryParent(category)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить родительскую категорию",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": []utils.ValidationError{{Field: "parentId", Message: message}},
+		})
+	}
+
 	if err := db.DB.Create(&category).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -92,6 +143,7 @@ func (ct *CategoryController) CreateCategory(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityCategory, category.ID, models.ActionCreate, nil, category)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"status":  "success",
@@ -113,6 +165,7 @@ func (ct *CategoryController) UpdateCategory(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	oldCategory := category
 
 	var input models.CategoryDTO
 	if err := c.BodyParser(&input); err != nil {
@@ -134,9 +187,43 @@ func (ct *CategoryController) UpdateCategory(c *fiber.Ctx) error {
 	category.Name = input.Name
 	category.Description = input.Description
 	category.Type = input.Type
+	category.ParentID = input.ParentID
 	category.Color = input.Color
 	category.Icon = input.Icon
 
+	message, err := checkCategoryParent(category)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить родительскую категорию",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": []utils.ValidationError{{Field: "parentId", Message: message}},
+		})
+	}
+
+	// Подкатегории должны оставаться того же типа, что и родительская категория
+	if category.Type != oldCategory.Type {
+		var childCount int64
+		if err := db.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&childCount).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось проверить подкатегории",
+				"error":   err.Error(),
+			})
+		}
+		if childCount > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"errors": []utils.ValidationError{{Field: "type", Message: "Нельзя изменить тип категории, у которой есть подкатегории"}},
+			})
+		}
+	}
+
 	if err := db.DB.Save(&category).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -144,6 +231,14 @@ func (ct *CategoryController) UpdateCategory(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityCategory, category.ID, models.ActionUpdate, oldCategory, category)
+
+	// При переносе категории ее расходы переходят из бюджетов прежних родительских категорий в бюджеты новых
+	if !sameCategoryParent(oldCategory.ParentID, category.ParentID) {
+		if err := refreshCategoryBudgets(userID, oldCategory.ParentID, category.ParentID); err != nil {
+			logError(err, "Ошибка пересчета бюджетов после переноса категории")
+		}
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
@@ -152,7 +247,7 @@ func (ct *CategoryController) UpdateCategory(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteCategory удаляет категорию
+// DeleteCategory перемещает категорию в корзину
 func (ct *CategoryController) DeleteCategory(c *fiber.Ctx) error {
 	id := c.Params("id")
 	userID := middlewares.GetUserID(c)
@@ -166,31 +261,391 @@ func (ct *CategoryController) DeleteCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	// Проверяем, есть ли транзакции с этой категорией
-	var transactionCount int64
-	db.DB.Model(&models.Transaction{}).Where("category_id = ?", id).Count(&transactionCount)
-
-	if transactionCount > 0 {
+	if message := categoryUsage(category.ID); message != "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Невозможно удалить категорию, так как существуют связанные с ней транзакции",
+			"message": message,
 		})
 	}
 
-	if err := db.DB.Delete(&category).Error; err != nil {
+	if err := deleteCategory(category); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось удалить категорию",
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityCategory, category.ID, models.ActionDelete, category, nil)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Категория успешно удалена",
+		"message": "Категория перемещена в корзину",
 	})
 }
 
+// MergeCategory объединяет категорию с целевой категорией того же типа: транзакции (в том числе
+// в корзине), части разделенных транзакций, регулярные платежи, бюджеты, правила, получатели,
+// подкатегории и сохраненные представления переходят в целевую категорию, а исходная
+// категория перемещается в корзину. Все изменения выполняются в одной транзакции БД.
+func (ct *CategoryController) MergeCategory(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var source, target models.Category
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&source).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Категория не найдена",
+			"error":   err.Error(),
+		})
+	}
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Params("targetId"), userID).First(&target).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Целевая категория не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	if source.ID == target.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Категорию нельзя объединить саму с собой",
+		})
+	}
+	if source.Type != target.Type {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Объединять можно только категории одного типа",
+		})
+	}
+	// Подкатегории исходной категории переходят в целевую, поэтому целевая не может быть среди них
+	var nestedCount int64
+	if err := db.DB.Raw("SELECT COUNT(*) FROM ("+categorySubtreeSQL+") AS subtree WHERE id = ?", source.ID, target.ID).Scan(&nestedCount).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить подкатегории",
+			"error":   err.Error(),
+		})
+	}
+	if nestedCount > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Категорию нельзя объединить с ее подкатегорией",
+		})
+	}
+
+	// Загружаем записи, которые перейдут в целевую категорию, для истории изменений
+	var transactions []models.Transaction
+	var rules []models.RecurringRule
+	var budgets []models.Budget
+	var children []models.Category
+	var views []models.SavedView
+	splitTransactionIDs := db.DB.Model(&models.TransactionSplit{}).Select("transaction_id").Where("category_id = ?", source.ID)
+	err := db.DB.Unscoped().Preload("Splits").
+		Where("user_id = ? AND (category_id = ? OR id IN (?))", userID, source.ID, splitTransactionIDs).
+		Find(&transactions).Error
+	if err == nil {
+		err = db.DB.Unscoped().Where("user_id = ? AND category_id = ?", userID, source.ID).Find(&rules).Error
+	}
+	if err == nil {
+		err = db.DB.Unscoped().Where("user_id = ? AND category_id = ?", userID, source.ID).Find(&budgets).Error
+	}
+	if err == nil {
+		err = db.DB.Unscoped().Where("user_id = ? AND parent_id = ?", userID, source.ID).Find(&children).Error
+	}
+	if err == nil {
+		err = db.DB.Where("user_id = ?", userID).Find(&views).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить связанные с категорией записи",
+			"error":   err.Error(),
+		})
+	}
+
+	for _, t := range transactions {
+		if reconciledLocked(c, t) {
+			return sendReconciledLocked(c)
+		}
+	}
+
+	var changeLogs []models.ChangeLog
+	for _, t := range transactions {
+		old := t
+		t.Splits = append([]models.TransactionSplit(nil), old.Splits...)
+		if t.CategoryID != nil && *t.CategoryID == source.ID {
+			t.CategoryID = &target.ID
+		}
+		for i := range t.Splits {
+			if t.Splits[i].CategoryID == source.ID {
+				t.Splits[i].CategoryID = target.ID
+			}
+		}
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionUpdate, old, t))
+	}
+	for _, rule := range rules {
+		old := rule
+		rule.CategoryID = target.ID
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionUpdate, old, rule))
+	}
+	for _, budget := range budgets {
+		old := budget
+		budget.CategoryID = &target.ID
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityBudget, budget.ID, models.ActionUpdate, old, budget))
+	}
+	for _, child := range children {
+		old := child
+		child.ParentID = &target.ID
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityCategory, child.ID, models.ActionUpdate, old, child))
+	}
+	changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityCategory, source.ID, models.ActionDelete, source, nil))
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Transaction{}).Where("user_id = ? AND category_id = ?", userID, source.ID).
+			Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TransactionSplit{}).Where("category_id = ?", source.ID).Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.RecurringRule{}).Where("user_id = ? AND category_id = ?", userID, source.ID).
+			Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Budget{}).Where("user_id = ? AND category_id = ?", userID, source.ID).
+			Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TransactionRule{}).Where("category_id = ?", source.ID).Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Payee{}).Where("default_category_id = ?", source.ID).Update("default_category_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Category{}).Where("user_id = ? AND parent_id = ?", userID, source.ID).
+			Update("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		for _, view := range views {
+			categoryIDs, included := replaceCategoryID(view.CategoryIDs, source.ID, target.ID)
+			excludeIDs, excluded := replaceCategoryID(view.ExcludeCategoryIDs, source.ID, target.ID)
+			if !included && !excluded {
+				continue
+			}
+			view.CategoryIDs, view.ExcludeCategoryIDs = categoryIDs, excludeIDs
+			if err := tx.Model(&view).Select("CategoryIDs", "ExcludeCategoryIDs").Updates(&view).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&source).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось объединить категории",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+
+	// Расходы исходной категории переходят в бюджеты целевой категории и ее родителей
+	if err := refreshCategoryBudgets(userID, &target.ID, source.ParentID); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после объединения категорий")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Категории успешно объединены",
+		"data": fiber.Map{
+			"category":       target,
+			"transactions":   len(transactions),
+			"recurringRules": len(rules),
+			"budgets":        len(budgets),
+		},
+	})
+}
+
+// categoryUsage проверяет, можно ли удалить категорию, и возвращает причину, по которой нельзя,
+// или пустую строку
+func categoryUsage(categoryID uint) string {
+	// Проверяем, есть ли транзакции с этой категорией
+	var transactionCount int64
+	db.DB.Model(&models.Transaction{}).Where("category_id = ?", categoryID).Count(&transactionCount)
+
+	// Учитываем части разделенных транзакций с этой категорией (кроме транзакций в корзине)
+	var splitCount int64
+	db.DB.Model(&models.TransactionSplit{}).
+		Joins("JOIN transactions ON transactions.id = transaction_splits.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transaction_splits.category_id = ?", categoryID).Count(&splitCount)
+	transactionCount += splitCount
+
+	if transactionCount > 0 {
+		return "Невозможно удалить категорию, так как существуют связанные с ней транзакции"
+	}
+
+	// Подкатегории остались бы без родителя
+	var childCount int64
+	db.DB.Model(&models.Category{}).Where("parent_id = ?", categoryID).Count(&childCount)
+	if childCount > 0 {
+		return "Невозможно удалить категорию, так как у нее есть подкатегории"
+	}
+
+	// Бюджеты и регулярные платежи продолжили бы работать с удаленной категорией
+	var budgetCount, recurringCount int64
+	db.DB.Model(&models.Budget{}).Where("category_id = ?", categoryID).Count(&budgetCount)
+	db.DB.Model(&models.RecurringRule{}).Where("category_id = ?", categoryID).Count(&recurringCount)
+	if budgetCount > 0 || recurringCount > 0 {
+		return "Невозможно удалить категорию, так как она используется в бюджетах или регулярных платежах"
+	}
+	return ""
+}
+
+// deleteCategory перемещает категорию в корзину. Правила и получатели больше не назначают
+// эту категорию, как это происходило при окончательном удалении категории.
+func deleteCategory(category models.Category) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TransactionRule{}).Where("category_id = ?", category.ID).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Payee{}).Where("default_category_id = ?", category.ID).Update("default_category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+}
+
+// categorySubtreeSQL подзапрос с ID категории, переданной параметром, и всех ее подкатегорий
+// на любой глубине вложенности
+const categorySubtreeSQL = `WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION
+		SELECT c.id FROM categories c JOIN subtree ON c.parent_id = subtree.id
+	) SELECT id FROM subtree`
+
+// withAncestorCategories возвращает указанные категории вместе со всеми их родительскими категориями
+func withAncestorCategories(categoryIDs []uint) ([]uint, error) {
+	if len(categoryIDs) == 0 {
+		return categoryIDs, nil
+	}
+	var ids []uint
+	err := db.DB.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM categories WHERE id IN ?
+		UNION
+		SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+	) SELECT id FROM ancestors`, categoryIDs).Scan(&ids).Error
+	return ids, err
+}
+
+// userCategoryTypes возвращает типы категорий пользователя по их ID
+func userCategoryTypes(userID uint) (map[uint]models.CategoryType, error) {
+	var categories []models.Category
+	if err := db.DB.Select("id", "type").Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	types := make(map[uint]models.CategoryType, len(categories))
+	for _, category := range categories {
+		types[category.ID] = category.Type
+	}
+	return types, nil
+}
+
+// checkCategoryParent проверяет родительскую категорию и возвращает описание ошибки
+// или пустую строку. Родитель должен принадлежать пользователю, иметь тот же тип
+// и не быть самой категорией или ее подкатегорией, чтобы в дереве не появлялись циклы.
+// Ошибка возвращается, только если проверку не удалось выполнить.
+func checkCategoryParent(category models.Category) (string, error) {
+	if category.ParentID == nil {
+		return "", nil
+	}
+
+	var parent models.Category
+	if err := db.DB.Where("id = ? AND user_id = ?", *category.ParentID, category.UserID).First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "Родительская категория не найдена", nil
+		}
+		return "", err
+	}
+	if parent.Type != category.Type {
+		return "Родительская категория должна быть того же типа", nil
+	}
+
+	if category.ID != 0 {
+		var cycleCount int64
+		if err := db.DB.Raw("SELECT COUNT(*) FROM ("+categorySubtreeSQL+") AS subtree WHERE id = ?", category.ID, parent.ID).Scan(&cycleCount).Error; err != nil {
+			return "", err
+		}
+		if cycleCount > 0 {
+			return "Категорию нельзя вложить в саму себя или в ее подкатегорию", nil
+		}
+	}
+	return "", nil
+}
+
+// sameCategoryParent проверяет, совпадают ли родительские категории
+func sameCategoryParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// refreshCategoryBudgets пересчитывает за все время бюджеты указанных родительских категорий
+// и их предков
+func refreshCategoryBudgets(userID uint, parentIDs ...*uint) error {
+	var categoryIDs []uint
+	for _, id := range parentIDs {
+		if id != nil {
+			categoryIDs = append(categoryIDs, *id)
+		}
+	}
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	return refreshBudgets(userID, categoryIDs, time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+}
+
+// replaceCategoryID заменяет категорию в списке и возвращает список без повторов.
+// Второе значение false, если категории в списке не было.
+func replaceCategoryID(ids []uint, from, to uint) ([]uint, bool) {
+	replaced := false
+	result := make([]uint, len(ids))
+	for i, id := range ids {
+		if id == from {
+			id, replaced = to, true
+		}
+		result[i] = id
+	}
+	if !replaced {
+		return ids, false
+	}
+	return uniqueIDs(result), true
+}
+
+// buildCategoryTree собирает дерево из списка категорий. Категория, родитель которой
+// отсутствует в списке (например, находится в корзине), становится корневой.
+func buildCategoryTree(categories []models.Category) []*CategoryNode {
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
 // GetAllUsersCategories получает категории всех пользователей (только для администраторов)
 func (ct *CategoryController) GetAllUsersCategories(c *fiber.Ctx) error {
 	// Получаем необязательный параметр userId для фильтрации
@@ -2,12 +2,15 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nikitagorchakov/finance-hub/backend/db"
 	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
 	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
 )
 
 type ChangeLogController struct{}
@@ -31,26 +34,7 @@ func (cc *ChangeLogController) GetEntityHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	var entries []models.ChangeLogEntry
-	for _, log := range changeLogs {
-		var changes map[string]interface{}
-		if log.Changes != "" {
-			json.Unmarshal([]byte(log.Changes), &changes)
-		}
-
-		// Получаем название связанного объекта
-		entityName := getEntityName(log.EntityType, log.EntityID, userID)
-
-		entries = append(entries, models.ChangeLogEntry{
-			ID:         log.ID,
-			EntityType: log.EntityType,
-			EntityID:   log.EntityID,
-			EntityName: entityName,
-			Action:     log.Action,
-			Changes:    changes,
-			CreatedAt:  log.CreatedAt,
-		})
-	}
+	entries := buildChangeLogEntries(changeLogs, userID)
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -80,11 +64,53 @@ func (cc *ChangeLogController) GetUserHistory(c *fiber.Ctx) error {
 	var changeLogs []models.ChangeLog
 	query := db.DB.Where("user_id = ?", userID)
 
+	var errors []utils.ValidationError
+
 	// Фильтр по типу сущности
 	if entityType := c.Query("entityType"); entityType != "" {
+		if !models.ChangeLogEntityTypes[models.ChangeLogEntityType(entityType)] {
+			errors = append(errors, utils.ValidationError{Field: "entityType", Message: "Неизвестный тип сущности"})
+		}
 		query = query.Where("entity_type = ?", entityType)
 	}
 
+	// Фильтр по действию
+	if action := c.Query("action"); action != "" {
+		if !models.ChangeLogActions[models.ChangeLogAction(action)] {
+			errors = append(errors, utils.ValidationError{Field: "action", Message: "Неизвестное действие"})
+		}
+		query = query.Where("action = ?", action)
+	}
+
+	// Фильтр по периоду
+	var startDate, endDate *time.Time
+	if value := c.Query("start_date"); value != "" {
+		if date, err := parseFilterDate(value, true); err == nil {
+			startDate = &date
+			query = query.Where("created_at >= ?", date)
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "start_date", Message: err.Error()})
+		}
+	}
+	if value := c.Query("end_date"); value != "" {
+		if date, err := parseFilterDate(value, false); err == nil {
+			endDate = &date
+			query = query.Where("created_at <= ?", date)
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "end_date", Message: err.Error()})
+		}
+	}
+	if startDate != nil && endDate != nil && endDate.Before(*startDate) {
+		errors = append(errors, utils.ValidationError{Field: "end_date", Message: "Дата окончания не может быть раньше даты начала"})
+	}
+
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
 	if err := query.Order("created_at desc").
 		Limit(limit).Offset(offset).Find(&changeLogs).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -93,7 +119,48 @@ func (cc *ChangeLogController) GetUserHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	var entries []models.ChangeLogEntry
+	entries := buildChangeLogEntries(changeLogs, userID)
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   entries,
+		"pagination": fiber.Map{
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// RevertChange отменяет изменение из истории: возвращает объект к состоянию до изменения,
+// в том числе создает заново удаленный объект
+func (cc *ChangeLogController) RevertChange(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var changeLog models.ChangeLog
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&changeLog).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Запись истории не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	revert, rerr := revertChange(changeLog)
+	if rerr != nil {
+		return rerr.send(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Изменение отменено",
+		"data":    buildChangeLogEntries([]models.ChangeLog{revert}, userID)[0],
+	})
+}
+
+// buildChangeLogEntries преобразует записи истории в ответ с разобранными изменениями
+// и названиями связанных объектов
+func buildChangeLogEntries(changeLogs []models.ChangeLog, userID uint) []models.ChangeLogEntry {
+	entries := make([]models.ChangeLogEntry, 0, len(changeLogs))
 	for _, log := range changeLogs {
 		var changes map[string]interface{}
 		if log.Changes != "" {
@@ -102,6 +169,10 @@ func (cc *ChangeLogController) GetUserHistory(c *fiber.Ctx) error {
 
 		// Получаем название связанного объекта
 		entityName := getEntityName(log.EntityType, log.EntityID, userID)
+		if entityName == unknownEntityName {
+			// Объект удален окончательно - берем название из сохраненного снимка
+			entityName = snapshotEntityName(log)
+		}
 
 		entries = append(entries, models.ChangeLogEntry{
 			ID:         log.ID,
@@ -110,21 +181,18 @@ func (cc *ChangeLogController) GetUserHistory(c *fiber.Ctx) error {
 			EntityName: entityName,
 			Action:     log.Action,
 			Changes:    changes,
+			RevertedID: log.RevertedID,
 			CreatedAt:  log.CreatedAt,
 		})
 	}
-
-	return c.JSON(fiber.Map{
-		"status": "success",
-		"data":   entries,
-		"pagination": fiber.Map{
-			"limit":  limit,
-			"offset": offset,
-		},
-	})
+	return entries
 }
 
-// getEntityName получает название объекта по его типу и ID
+// unknownEntityName название объекта, который не удалось найти
+const unknownEntityName = "Неизвестный объект"
+
+// getEntityName получает название объекта по его типу и ID.
+// Объекты в корзине тоже ищутся, чтобы история удаленных записей оставалась читаемой.
 func getEntityName(entityType models.ChangeLogEntityType, entityID uint, userID uint) string {
 	switch entityType {
 	case models.EntityProject:
@@ -155,6 +223,76 @@ func getEntityName(entityType models.ChangeLogEntityType, entityID uint, userID
 				return investment.Name
 			}
 		}
+	case models.EntityTransaction:
+		var transaction models.Transaction
+		if err := db.DB.Unscoped().Where("id = ? AND user_id = ?", entityID, userID).First(&transaction).Error; err == nil {
+			if transaction.Description != "" {
+				return transaction.Description
+			}
+			return fmt.Sprintf("%.2f %s от %s", transaction.Amount, transaction.Currency, transaction.Date.Format("02.01.2006"))
+		}
+	case models.EntityRecurringRule:
+		var rule models.RecurringRule
+		if err := db.DB.Unscoped().Where("id = ? AND user_id = ?", entityID, userID).First(&rule).Error; err == nil {
+			if rule.Description != "" {
+				return rule.Description
+			}
+			return fmt.Sprintf("%.2f %s с %s", rule.Amount, rule.Currency, rule.StartDate.Format("02.01.2006"))
+		}
+	case models.EntityCategory:
+		var category models.Category
+		if err := db.DB.Unscoped().Where("id = ? AND user_id = ?", entityID, userID).First(&category).Error; err == nil {
+			return category.Name
+		}
+	case models.EntityBudget:
+		var budget models.Budget
+		if err := db.DB.Unscoped().Where("id = ? AND user_id = ?", entityID, userID).First(&budget).Error; err == nil {
+			return budget.Name
+		}
+	case models.EntityAccount:
+		var account models.Account
+		if err := db.DB.Where("id = ? AND user_id = ?", entityID, userID).First(&account).Error; err == nil {
+			return account.Name
+		}
+	case models.EntityTag:
+		var tag models.Tag
+		if err := db.DB.Where("id = ? AND user_id = ?", entityID, userID).First(&tag).Error; err == nil {
+			return tag.Name
+		}
+	case models.EntityPayee:
+		var payee models.Payee
+		if err := db.DB.Where("id = ? AND user_id = ?", entityID, userID).First(&payee).Error; err == nil {
+			return payee.Name
+		}
+	case models.EntityTransactionRule:
+		var rule models.TransactionRule
+		if err := db.DB.Where("id = ? AND user_id = ?", entityID, userID).First(&rule).Error; err == nil {
+			return rule.Name
+		}
+	case models.EntityAttachment:
+		var attachment models.Attachment
+		if err := db.DB.Where("id = ? AND user_id = ?", entityID, userID).First(&attachment).Error; err == nil {
+			return attachment.FileName
+		}
+	}
+	return unknownEntityName
+}
+
+// snapshotEntityName достает название объекта из снимка данных записи истории
+func snapshotEntityName(log models.ChangeLog) string {
+	for _, data := range []string{log.OldData, log.NewData} {
+		if data == "" {
+			continue
+		}
+		var snapshot map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			continue
+		}
+		for _, field := range []string{"name", "description", "fileName"} {
+			if value, ok := snapshot[field].(string); ok && value != "" {
+				return value
+			}
+		}
 	}
-	return "Неизвестный объект"
-} 
\ No newline at end of file
+	return unknownEntityName
+}
@@ -0,0 +1,773 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revertChange возвращает объект из записи истории к состоянию до изменения.
+// Если в записи есть снимок прежнего состояния, он записывается поверх текущего, а удаленный
+// объект создается заново с тем же ID. Если снимка нет (запись о создании или восстановлении
+// из корзины), объект удаляется так же, как при обычном удалении. Зависящие от объекта суммы
+// (бюджеты, текущая сумма проекта, сумма инвестиции) пересчитываются.
+// Отмена записывается в историю отдельной записью, поэтому ее тоже можно отменить.
+func revertChange(changeLog models.ChangeLog) (models.ChangeLog, *requestError) {
+	var before, after interface{}
+	var rerr *requestError
+
+	switch changeLog.EntityType {
+	case models.EntityTransaction:
+		before, after, rerr = revertTransaction(changeLog)
+	case models.EntityCategory:
+		before, after, rerr = revertCategory(changeLog)
+	case models.EntityBudget:
+		before, after, rerr = revertBudget(changeLog)
+	case models.EntityRecurringRule:
+		before, after, rerr = revertRecurringRule(changeLog)
+	case models.EntityAccount:
+		before, after, rerr = revertAccount(changeLog)
+	case models.EntityTag:
+		before, after, rerr = revertTag(changeLog)
+	case models.EntityPayee:
+		before, after, rerr = revertPayee(changeLog)
+	case models.EntityTransactionRule:
+		before, after, rerr = revertTransactionRule(changeLog)
+	case models.EntityAttachment:
+		before, after, rerr = revertAttachment(changeLog)
+	case models.EntityProject:
+		before, after, rerr = revertProject(changeLog)
+	case models.EntityInvestment:
+		before, after, rerr = revertInvestment(changeLog)
+	case models.EntityProjectPayment:
+		before, after, rerr = revertProjectPayment(changeLog)
+	case models.EntityInvestmentOperation:
+		before, after, rerr = revertInvestmentOperation(changeLog)
+	default:
+		rerr = &requestError{fiber.StatusBadRequest, "Изменения этого типа нельзя отменить", nil}
+	}
+	if rerr != nil {
+		return models.ChangeLog{}, rerr
+	}
+
+	revert := models.NewChangeLog(changeLog.UserID, changeLog.EntityType, changeLog.EntityID, models.ActionRevert, before, after)
+	revert.RevertedID = &changeLog.ID
+	if err := db.DB.Create(&revert).Error; err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+	return revert, nil
+}
+
+// revertTransaction отменяет изменение транзакции или перевода. Разбивка и метки заменяются
+// сохраненными, но метки, удаленные с тех пор, не восстанавливаются. Возвраты и расходы
+// с возвратами проверяются так же, как при обычном изменении и удалении.
+func revertTransaction(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Transaction
+	exists, rerr := findRevertEntity(changeLog, &current, "Splits", "Tags")
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if exists && current.ClearedStatus == models.ClearedReconciled {
+		return nil, nil, &requestError{fiber.StatusConflict, "Транзакция сверена с выпиской, поэтому изменение нельзя отменить", nil}
+	}
+
+	if !restore {
+		if !exists || current.DeletedAt.Valid {
+			return nil, nil, errAlreadyDeleted()
+		}
+		// Расход нельзя удалить, пока по нему есть возвраты
+		refunds, err := activeRefundsOutside([]uint{current.ID})
+		if err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось проверить возвраты", err}
+		}
+		if refunds > 0 {
+			return nil, nil, &requestError{fiber.StatusConflict, "По транзакции есть возвраты, сначала удалите их", nil}
+		}
+		if err := db.DB.Delete(&current).Error; err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить транзакцию", err}
+		}
+		refreshRevertedBudgets(changeLog.UserID, current)
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	target.DeletedAt = gorm.DeletedAt{}
+	// Отметка сверки не относится к истории изменений и сохраняется текущей
+	target.ClearedStatus = models.ClearedNone
+	target.ReconciliationID = nil
+	if exists {
+		target.ClearedStatus = current.ClearedStatus
+		target.ReconciliationID = current.ReconciliationID
+	}
+	if rerr := checkRevertCategories(transactionCategoryIDs(target), changeLog.UserID); rerr != nil {
+		return nil, nil, rerr
+	}
+	for _, accountID := range []*uint{target.AccountID, target.ToAccountID} {
+		if err := checkAccountOwnership(accountID, changeLog.UserID); err != nil {
+			return nil, nil, &requestError{fiber.StatusConflict, "Счет транзакции удален, поэтому изменение нельзя отменить", err}
+		}
+	}
+
+	// При удалении получателя, правила регулярного платежа и исходного расхода транзакции отвязываются от них
+	if checkPayeeOwnership(target.PayeeID, changeLog.UserID) != nil {
+		target.PayeeID = nil
+	}
+	if target.RecurringRuleID != nil {
+		var rule models.RecurringRule
+		if db.DB.Unscoped().Where("id = ? AND user_id = ?", *target.RecurringRuleID, changeLog.UserID).First(&rule).Error != nil {
+			target.RecurringRuleID = nil
+		}
+	}
+
+	// Возврат проверяется так же, как при изменении, по текущему состоянию исходного расхода,
+	// который должен быть активен. Расход с возвратами должен остаться расходом не меньше их суммы.
+	switch target.Kind {
+	case models.KindRefund:
+		var original models.Transaction
+		if target.RefundOfID == nil || db.DB.Where("id = ? AND user_id = ?", *target.RefundOfID, changeLog.UserID).Preload("Splits").First(&original).Error != nil {
+			return nil, nil, &requestError{fiber.StatusConflict, "Исходный расход возврата удален, поэтому изменение нельзя отменить", nil}
+		}
+		if rerr := applyRefundInput(&target, original, refundInput(target, original), 0); rerr != nil {
+			return nil, nil, rerr
+		}
+	case models.KindRegular:
+		if target.CategoryID != nil {
+			var category models.Category
+			if err := db.DB.Where("id = ? AND user_id = ?", *target.CategoryID, changeLog.UserID).First(&category).Error; err != nil {
+				return nil, nil, &requestError{fiber.StatusConflict, "Категория транзакции удалена, поэтому изменение нельзя отменить", err}
+			}
+			if rerr := checkRefundedExpense(target, target.Amount, category); rerr != nil {
+				return nil, nil, rerr
+			}
+		}
+	}
+
+	tagIDs := make([]uint, 0, len(target.Tags))
+	for _, tag := range target.Tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	var tags []models.Tag
+	if len(tagIDs) > 0 {
+		if err := db.DB.Where("id IN ? AND user_id = ?", tagIDs, changeLog.UserID).Find(&tags).Error; err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось получить метки", err}
+		}
+	}
+
+	splits := target.Splits
+	for i := range splits {
+		splits[i].ID = 0
+		splits[i].TransactionID = target.ID
+		splits[i].Category = nil
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveRevertSnapshot(tx, &target); err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ?", target.ID).Delete(&models.TransactionSplit{}).Error; err != nil {
+			return err
+		}
+		if len(splits) > 0 {
+			if err := tx.Create(&splits).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&target).Association("Tags").Replace(tags)
+	}); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить транзакцию", err}
+	}
+
+	db.DB.Preload("Category").Preload("Splits.Category").Preload("Tags").First(&target, target.ID)
+	if exists {
+		refreshRevertedBudgets(changeLog.UserID, current, target)
+	} else {
+		refreshRevertedBudgets(changeLog.UserID, target)
+	}
+
+	// Возвраты следуют за валютой и категорией расхода
+	if target.Kind == models.KindRegular {
+		var oldCategoryIDs []uint
+		if exists {
+			oldCategoryIDs = transactionCategoryIDs(current)
+		}
+		if err := syncRefunds(target, oldCategoryIDs); err != nil {
+			logError(err, "Ошибка при обновлении возвратов")
+		}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertCategory отменяет изменение категории
+func revertCategory(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Category
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists || current.DeletedAt.Valid {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if message := categoryUsage(current.ID); message != "" {
+			return nil, nil, &requestError{fiber.StatusConflict, message, nil}
+		}
+		if err := deleteCategory(current); err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить категорию", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	target.DeletedAt = gorm.DeletedAt{}
+	// Родительская категория могла быть удалена или перенесена внутрь этой категории
+	message, err := checkCategoryParent(target)
+	if err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось проверить родительскую категорию", err}
+	}
+	if message != "" {
+		target.ParentID = nil
+	}
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить категорию", err}
+	}
+	if !sameCategoryParent(current.ParentID, target.ParentID) {
+		if err := refreshCategoryBudgets(changeLog.UserID, current.ParentID, target.ParentID); err != nil {
+			logError(err, "Ошибка пересчета бюджетов после отмены изменения категории")
+		}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertBudget отменяет изменение бюджета и пересчитывает потраченную по нему сумму
+func revertBudget(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Budget
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists || current.DeletedAt.Valid {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if err := db.DB.Delete(&current).Error; err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить бюджет", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	target.DeletedAt = gorm.DeletedAt{}
+	if target.CategoryID != nil {
+		if rerr := checkRevertCategories([]uint{*target.CategoryID}, changeLog.UserID); rerr != nil {
+			return nil, nil, rerr
+		}
+	}
+
+	// Пока бюджет был изменен или удален, транзакции могли измениться
+	if spent, err := calculateBudgetSpent(target); err == nil {
+		target.Spent = spent
+	} else {
+		logError(err, "Ошибка при пересчете бюджета после отмены изменения")
+	}
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить бюджет", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertRecurringRule отменяет изменение регулярного платежа. Дата следующего выполнения
+// не возвращается назад, чтобы уже созданные по правилу транзакции не создавались повторно.
+func revertRecurringRule(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.RecurringRule
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists || current.DeletedAt.Valid {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if err := db.DB.Delete(&current).Error; err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить регулярный платеж", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	target.DeletedAt = gorm.DeletedAt{}
+	if rerr := checkRevertCategories([]uint{target.CategoryID}, changeLog.UserID); rerr != nil {
+		return nil, nil, rerr
+	}
+	// При удалении счета регулярные платежи отвязываются от него
+	if checkAccountOwnership(target.AccountID, changeLog.UserID) != nil {
+		target.AccountID = nil
+	}
+	if exists && current.NextExecuteDate.After(target.NextExecuteDate) {
+		target.NextExecuteDate = current.NextExecuteDate
+	}
+
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить регулярный платеж", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertAccount отменяет изменение счета. Счет с транзакциями удалить нельзя, как и при обычном удалении.
+func revertAccount(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Account
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if accountHasTransactions(current.ID) {
+			return nil, nil, &requestError{fiber.StatusConflict, "Невозможно удалить счет, так как существуют связанные с ним транзакции (в том числе в корзине). Архивируйте его вместо удаления", nil}
+		}
+		if err := deleteAccount(current); err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить счет", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить счет", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertTag отменяет изменение метки. Восстановленная после удаления метка не привязывается
+// к транзакциям заново: связи с транзакциями в истории не хранятся.
+func revertTag(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Tag
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM transaction_tags WHERE tag_id = ?", current.ID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&current).Error
+		}); err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить метку", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	var count int64
+	db.DB.Model(&models.Tag{}).Where("user_id = ? AND name = ? AND id <> ?", target.UserID, target.Name, target.ID).Count(&count)
+	if count > 0 {
+		return nil, nil, &requestError{fiber.StatusConflict, "Метка с таким названием уже существует", nil}
+	}
+
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить метку", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertPayee отменяет изменение получателя вместе с его псевдонимами
+func revertPayee(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Payee
+	exists, rerr := findRevertEntity(changeLog, &current, "Aliases")
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if err := deletePayee(current); err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить получателя", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	aliases := target.Aliases
+	patterns := make([]string, 0, len(aliases))
+	for i := range aliases {
+		aliases[i].ID = 0
+		aliases[i].PayeeID = target.ID
+		aliases[i].UserID = target.UserID
+		patterns = append(patterns, aliases[i].Pattern)
+	}
+
+	var count int64
+	db.DB.Model(&models.Payee{}).Where("user_id = ? AND name = ? AND id <> ?", target.UserID, target.Name, target.ID).Count(&count)
+	if count == 0 && len(patterns) > 0 {
+		db.DB.Model(&models.PayeeAlias{}).Where("user_id = ? AND pattern IN ? AND payee_id <> ?", target.UserID, patterns, target.ID).Count(&count)
+	}
+	if count > 0 {
+		return nil, nil, &requestError{fiber.StatusConflict, "Название или псевдоним получателя уже используется другим получателем", nil}
+	}
+
+	// При удалении категории получатели отвязываются от нее
+	if target.DefaultCategoryID != nil && checkRevertCategories([]uint{*target.DefaultCategoryID}, target.UserID) != nil {
+		target.DefaultCategoryID = nil
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveRevertSnapshot(tx, &target); err != nil {
+			return err
+		}
+		if err := tx.Where("payee_id = ?", target.ID).Delete(&models.PayeeAlias{}).Error; err != nil {
+			return err
+		}
+		if len(aliases) > 0 {
+			return tx.Create(&aliases).Error
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить получателя", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertTransactionRule отменяет изменение правила обработки транзакций
+func revertTransactionRule(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.TransactionRule
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if !restore {
+		if !exists {
+			return nil, nil, errAlreadyDeleted()
+		}
+		if err := db.DB.Delete(&current).Error; err != nil {
+			return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить правило", err}
+		}
+		return current, nil, nil
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	// При удалении категории правила отвязываются от нее
+	if target.CategoryID != nil && checkRevertCategories([]uint{*target.CategoryID}, target.UserID) != nil {
+		target.CategoryID = nil
+	}
+
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить правило", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertAttachment отменяет загрузку вложения. Удаленное вложение восстановить нельзя:
+// его файл удаляется из хранилища сразу.
+func revertAttachment(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Attachment
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	if restore {
+		return nil, nil, &requestError{fiber.StatusBadRequest, "Удаленное вложение нельзя восстановить: его файл удален", nil}
+	}
+	if !exists {
+		return nil, nil, errAlreadyDeleted()
+	}
+	if err := db.DB.Delete(&current).Error; err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось удалить вложение", err}
+	}
+	deleteAttachmentFiles([]models.Attachment{current})
+	return current, nil, nil
+}
+
+// revertProject отменяет изменение проекта. Проекты не удаляются, поэтому создание проекта
+// отменить нельзя.
+func revertProject(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Project
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if !restore {
+		return nil, nil, &requestError{fiber.StatusBadRequest, "Создание проекта нельзя отменить: используйте архивирование", nil}
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить проект", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertInvestment отменяет изменение инвестиции. Инвестиции не удаляются, поэтому создание
+// инвестиции отменить нельзя.
+func revertInvestment(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.Investment
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if !restore {
+		return nil, nil, &requestError{fiber.StatusBadRequest, "Создание инвестиции нельзя отменить: используйте архивирование", nil}
+	}
+
+	target.ID = changeLog.EntityID
+	target.UserID = changeLog.UserID
+	if err := saveRevertSnapshot(db.DB, &target); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось восстановить инвестицию", err}
+	}
+	return revertState(exists, current), target, nil
+}
+
+// revertProjectPayment отменяет создание или удаление платежа и пересчитывает текущую сумму проекта
+func revertProjectPayment(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.ProjectPayment
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if !restore && !exists {
+		return nil, nil, errAlreadyDeleted()
+	}
+
+	projectID := current.ProjectID
+	if restore {
+		target.ID = changeLog.EntityID
+		target.UserID = changeLog.UserID
+		projectID = target.ProjectID
+	}
+	var project models.Project
+	if err := db.DB.Where("id = ? AND user_id = ?", projectID, changeLog.UserID).First(&project).Error; err != nil {
+		return nil, nil, &requestError{fiber.StatusConflict, "Проект платежа не найден", err}
+	}
+	oldProject := project
+	if exists && current.ProjectID == project.ID {
+		applyProjectPayment(&project, current, true)
+	}
+	if restore {
+		applyProjectPayment(&project, target, false)
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if restore {
+			if err := saveRevertSnapshot(tx, &target); err != nil {
+				return err
+			}
+		} else if err := tx.Delete(&current).Error; err != nil {
+			return err
+		}
+		return tx.Save(&project).Error
+	}); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось отменить изменение платежа", err}
+	}
+	utils.CreateChangeLog(changeLog.UserID, models.EntityProject, project.ID, models.ActionUpdate, oldProject, project)
+
+	return revertState(exists, current), revertState(restore, target), nil
+}
+
+// revertInvestmentOperation отменяет создание или удаление операции и пересчитывает сумму инвестиции
+func revertInvestmentOperation(changeLog models.ChangeLog) (interface{}, interface{}, *requestError) {
+	var current, target models.InvestmentOperation
+	exists, rerr := findRevertEntity(changeLog, &current)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	restore, rerr := revertSnapshot(changeLog, &target)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	if !restore && !exists {
+		return nil, nil, errAlreadyDeleted()
+	}
+
+	investmentID := current.InvestmentID
+	if restore {
+		target.ID = changeLog.EntityID
+		target.UserID = changeLog.UserID
+		investmentID = target.InvestmentID
+	}
+	var investment models.Investment
+	if err := db.DB.Where("id = ? AND user_id = ?", investmentID, changeLog.UserID).First(&investment).Error; err != nil {
+		return nil, nil, &requestError{fiber.StatusConflict, "Инвестиция операции не найдена", err}
+	}
+	oldInvestment := investment
+	if exists && current.InvestmentID == investment.ID {
+		applyInvestmentOperation(&investment, current, true)
+	}
+	if restore {
+		applyInvestmentOperation(&investment, target, false)
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if restore {
+			if err := saveRevertSnapshot(tx, &target); err != nil {
+				return err
+			}
+		} else if err := tx.Delete(&current).Error; err != nil {
+			return err
+		}
+		return tx.Save(&investment).Error
+	}); err != nil {
+		return nil, nil, &requestError{fiber.StatusInternalServerError, "Не удалось отменить изменение операции", err}
+	}
+	utils.CreateChangeLog(changeLog.UserID, models.EntityInvestment, investment.ID, models.ActionUpdate, oldInvestment, investment)
+
+	return revertState(exists, current), revertState(restore, target), nil
+}
+
+// findRevertEntity загружает текущее состояние объекта, в том числе из корзины.
+// Возвращает false, если объекта нет.
+func findRevertEntity(changeLog models.ChangeLog, dest interface{}, preloads ...string) (bool, *requestError) {
+	query := db.DB.Unscoped().Where("id = ? AND user_id = ?", changeLog.EntityID, changeLog.UserID)
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	err := query.First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &requestError{fiber.StatusInternalServerError, "Не удалось получить объект", err}
+	}
+	return true, nil
+}
+
+// revertSnapshot разбирает сохраненное состояние объекта до изменения. Возвращает false,
+// если состояния нет и отмена изменения означает удаление объекта.
+func revertSnapshot(changeLog models.ChangeLog, dest interface{}) (bool, *requestError) {
+	if changeLog.OldData == "" || changeLog.OldData == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(changeLog.OldData), dest); err != nil {
+		return false, &requestError{fiber.StatusUnprocessableEntity, "Не удалось разобрать сохраненное состояние объекта", err}
+	}
+	return true, nil
+}
+
+// saveRevertSnapshot записывает прежнее состояние объекта без связанных записей.
+// Объект из корзины восстанавливается, а удаленный окончательно создается заново с тем же ID.
+func saveRevertSnapshot(tx *gorm.DB, value interface{}) error {
+	return tx.Unscoped().Omit(clause.Associations).Save(value).Error
+}
+
+// revertState возвращает состояние объекта для записи в историю: nil, если объекта не было
+func revertState(exists bool, value interface{}) interface{} {
+	if !exists {
+		return nil
+	}
+	return value
+}
+
+// errAlreadyDeleted ошибка отмены создания объекта, который уже удален
+func errAlreadyDeleted() *requestError {
+	return &requestError{fiber.StatusConflict, "Объект уже удален", nil}
+}
+
+// checkRevertCategories проверяет, что категории прежнего состояния существуют и не находятся в корзине
+func checkRevertCategories(categoryIDs []uint, userID uint) *requestError {
+	categoryIDs = uniqueIDs(categoryIDs)
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	var count int64
+	if err := db.DB.Model(&models.Category{}).Where("id IN ? AND user_id = ?", categoryIDs, userID).Count(&count).Error; err != nil {
+		return &requestError{fiber.StatusInternalServerError, "Не удалось проверить категории", err}
+	}
+	if int(count) != len(categoryIDs) {
+		return &requestError{fiber.StatusConflict, "Категория удалена: восстановите ее из корзины, чтобы отменить изменение", nil}
+	}
+	return nil
+}
+
+// refreshRevertedBudgets пересчитывает бюджеты по категориям и датам транзакций до и после отмены
+func refreshRevertedBudgets(userID uint, transactions ...models.Transaction) {
+	var categoryIDs []uint
+	var from, to time.Time
+	for _, t := range transactions {
+		categoryIDs = append(categoryIDs, transactionCategoryIDs(t)...)
+		if from.IsZero() || t.Date.Before(from) {
+			from = t.Date
+		}
+		if t.Date.After(to) {
+			to = t.Date
+		}
+	}
+	if err := refreshBudgets(userID, uniqueIDs(categoryIDs), from, to); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после отмены изменения")
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// CurrencyController контроллер для валют и курсов
+type CurrencyController struct{}
+
+// NewCurrencyController создает новый контроллер валют
+func NewCurrencyController() *CurrencyController {
+	return &CurrencyController{}
+}
+
+// GetRates получает курсы валют к рублю, действующие на указанную дату (по умолчанию на сегодня)
+func (cc *CurrencyController) GetRates(c *fiber.Ctx) error {
+	date := parseDateParam(c.Query("date", time.Now().Format("2006-01-02")), false)
+
+	// Для каждой валюты берем последний курс на дату или раньше
+	var rates []models.ExchangeRate
+	if err := db.DB.Raw(`
+		SELECT DISTINCT ON (currency) *
+		FROM exchange_rates
+		WHERE date <= ?
+		ORDER BY currency, date DESC
+	`, date.Format("2006-01-02")).Scan(&rates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить курсы валют",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"base":  models.DefaultCurrency,
+			"date":  date,
+			"rates": rates,
+		},
+	})
+}
+
+// Convert пересчитывает сумму из одной валюты в другую по курсу на дату.
+// Если целевая валюта не указана, используется базовая валюта пользователя.
+func (cc *CurrencyController) Convert(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректная сумма",
+			"error":   err.Error(),
+		})
+	}
+
+	from := strings.ToUpper(c.Query("from"))
+	if !utils.IsKnownCurrency(from) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректный код исходной валюты или для нее не загружены курсы",
+		})
+	}
+	to := resolveCurrency(c.Query("to"), nil, userID)
+	if !utils.IsKnownCurrency(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректный код целевой валюты или для нее не загружены курсы",
+		})
+	}
+	date := parseDateParam(c.Query("date", time.Now().Format("2006-01-02")), true)
+
+	rate, err := exchangeRate(from, to, date)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить курс валюты",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": models.ConversionResult{
+			Amount:          amount,
+			From:            from,
+			To:              to,
+			Date:            date,
+			Rate:            rate,
+			ConvertedAmount: amount * rate,
+		},
+	})
+}
+
+// ImportRates загружает курсы валют из файла в формате ежедневных курсов ЦБ РФ (только для администраторов)
+func (cc *CurrencyController) ImportRates(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Файл с курсами не передан",
+			"error":   err.Error(),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось открыть файл",
+			"error":   err.Error(),
+		})
+	}
+	defer file.Close()
+
+	rates, err := utils.ParseCBRRates(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось разобрать файл с курсами",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := utils.SaveExchangeRates(rates); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить курсы валют",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Курсы валют успешно загружены",
+		"data": fiber.Map{
+			"count": len(rates),
+		},
+	})
+}
@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxDuplicateDateWindow максимальное окно дат в днях, которое можно задать при поиске дубликатов
+const maxDuplicateDateWindow = 30
+
+// DuplicateController контроллер для поиска и объединения дубликатов транзакций
+type DuplicateController struct{}
+
+// NewDuplicateController создает новый контроллер дубликатов
+func NewDuplicateController() *DuplicateController {
+	return &DuplicateController{}
+}
+
+// GetDuplicates возвращает группы вероятных дубликатов среди транзакций пользователя.
+// Параметр days задает допустимую разницу дат (по умолчанию utils.DuplicateDateWindow).
+// Пары, отклоненные пользователем, не предлагаются.
+func (dc *DuplicateController) GetDuplicates(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	window := utils.DuplicateDateWindow
+	if value := c.Query("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 || days > maxDuplicateDateWindow {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Некорректные параметры запроса",
+				"errors": []utils.ValidationError{{
+					Field:   "days",
+					Message: fmt.Sprintf("Должен быть целым числом от 0 до %d", maxDuplicateDateWindow),
+				}},
+			})
+		}
+		window = days
+	}
+
+	// Кандидаты подбираются в базе по сумме, валюте, категории и дате,
+	// а описания сравниваются уже после нормализации
+	type duplicatePair struct {
+		TransactionID uint
+		DuplicateID   uint
+	}
+	var pairs []duplicatePair
+	if err := db.DB.Raw(`
+		SELECT a.id AS transaction_id, b.id AS duplicate_id
+		FROM transactions a
+		JOIN transactions b ON b.user_id = a.user_id AND b.id > a.id
+			AND b.kind = a.kind
+			AND ROUND(b.amount * 100) = ROUND(a.amount * 100)
+			AND b.currency = a.currency
+			AND b.category_id = a.category_id
+			AND ABS(b.date::date - a.date::date) <= ?
+		WHERE a.user_id = ? AND a.kind = ?
+			AND a.deleted_at IS NULL AND b.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM duplicate_dismissals d
+				WHERE d.user_id = a.user_id AND d.transaction_id = a.id AND d.duplicate_id = b.id
+			)
+	`, window, userID, models.KindRegular).Scan(&pairs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось найти дубликаты",
+			"error":   err.Error(),
+		})
+	}
+
+	ids := make([]uint, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		ids = append(ids, pair.TransactionID, pair.DuplicateID)
+	}
+	var transactions []models.Transaction
+	if len(ids) > 0 {
+		if err := db.DB.Where("id IN ? AND user_id = ?", ids, userID).
+			Preload("Category").Preload("Splits.Category").Preload("Tags").Preload("Account").
+			Find(&transactions).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось загрузить транзакции",
+				"error":   err.Error(),
+			})
+		}
+	}
+	byID := make(map[uint]models.Transaction, len(transactions))
+	for _, t := range transactions {
+		byID[t.ID] = t
+	}
+
+	// Связанные пары объединяются в группы: если A похожа на B, а B на C, все три в одной группе
+	parent := make(map[uint]uint)
+	var find func(id uint) uint
+	find = func(id uint) uint {
+		if parent[id] == 0 || parent[id] == id {
+			parent[id] = id
+			return id
+		}
+		parent[id] = find(parent[id])
+		return parent[id]
+	}
+	for _, pair := range pairs {
+		a, b := byID[pair.TransactionID], byID[pair.DuplicateID]
+		if !utils.IsLikelyDuplicate(transactionFingerprint(a), transactionFingerprint(b), window) {
+			continue
+		}
+		parent[find(b.ID)] = find(a.ID)
+	}
+
+	members := make(map[uint][]models.Transaction)
+	for id := range parent {
+		root := find(id)
+		members[root] = append(members[root], byID[id])
+	}
+
+	groups := make([]models.DuplicateGroup, 0, len(members))
+	for _, group := range members {
+		sort.Slice(group, func(i, j int) bool {
+			if !group[i].Date.Equal(group[j].Date) {
+				return group[i].Date.Before(group[j].Date)
+			}
+			return group[i].ID < group[j].ID
+		})
+		groups = append(groups, models.DuplicateGroup{Transactions: group})
+	}
+	// Сначала самые свежие группы
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Transactions[0], groups[j].Transactions[0]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.ID > b.ID
+	})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   groups,
+		"meta": fiber.Map{
+			"total": len(groups),
+			"days":  window,
+		},
+	})
+}
+
+// MergeDuplicates объединяет дубликаты: оставляет одну транзакцию и удаляет остальные.
+// Метки удаляемых транзакций добавляются к оставшейся; если у нее нет внешнего идентификатора,
+// она получает идентификатор удаляемой, чтобы повторный импорт файла не создал дубликат снова.
+func (dc *DuplicateController) MergeDuplicates(c *fiber.Ctx) error {
+	var input models.DuplicateMergeDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	duplicateIDs := uniqueIDs(input.DuplicateIDs)
+	for _, id := range duplicateIDs {
+		if id == input.KeepID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Оставляемая транзакция не может быть в списке удаляемых",
+			})
+		}
+	}
+
+	var keep models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ?", input.KeepID, userID).Preload("Splits").Preload("Tags").First(&keep).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Транзакция не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	var duplicates []models.Transaction
+	if err := db.DB.Where("id IN ? AND user_id = ?", duplicateIDs, userID).Preload("Splits").Preload("Tags").Find(&duplicates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось найти транзакции",
+			"error":   err.Error(),
+		})
+	}
+	if len(duplicates) != len(duplicateIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некоторые транзакции не найдены или не принадлежат пользователю",
+		})
+	}
+
+	// Сверенные транзакции удаляются и изменяются только с явным снятием защиты
+	for _, t := range append([]models.Transaction{keep}, duplicates...) {
+		if reconciledLocked(c, t) {
+			return sendReconciledLocked(c)
+		}
+	}
+
+	// Переводы затрагивают балансы двух счетов, а возвраты привязаны к своему расходу,
+	// поэтому объединяются только обычные операции
+	for _, t := range append([]models.Transaction{keep}, duplicates...) {
+		if t.Kind != models.KindRegular {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Переводы между счетами и возвраты нельзя объединять как дубликаты",
+			})
+		}
+	}
+
+	var tags []models.Tag
+	externalID := keep.ExternalID
+	budgetCategoryIDs := make(map[uint]bool)
+	from, to := keep.Date, keep.Date
+	for _, t := range duplicates {
+		tags = append(tags, t.Tags...)
+		if externalID == nil {
+			externalID = t.ExternalID
+		}
+		for _, id := range transactionCategoryIDs(t) {
+			budgetCategoryIDs[id] = true
+		}
+		if t.Date.Before(from) {
+			from = t.Date
+		}
+		if t.Date.After(to) {
+			to = t.Date
+		}
+	}
+
+	oldKeep := keep
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Чеки и документы дубликатов остаются у объединенной транзакции
+		if err := tx.Model(&models.Attachment{}).Where("transaction_id IN ? AND user_id = ?", duplicateIDs, userID).
+			Update("transaction_id", keep.ID).Error; err != nil {
+			return err
+		}
+		// Возвраты по дубликатам относятся к объединенной транзакции
+		if err := tx.Model(&models.Transaction{}).Where("refund_of_id IN ? AND user_id = ?", duplicateIDs, userID).
+			Update("refund_of_id", keep.ID).Error; err != nil {
+			return err
+		}
+		// Объединенные дубликаты удаляются окончательно, минуя корзину: их данные уже перенесены
+		if err := tx.Unscoped().Where("id IN ? AND user_id = ?", duplicateIDs, userID).Delete(&models.Transaction{}).Error; err != nil {
+			return err
+		}
+		// Внешний идентификатор переносится после удаления, так как он уникален для пользователя
+		if externalID != keep.ExternalID {
+			if err := tx.Model(&keep).Update("external_id", externalID).Error; err != nil {
+				return err
+			}
+		}
+		if len(tags) > 0 {
+			return tx.Model(&keep).Association("Tags").Append(tags)
+		}
+		return nil
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось объединить транзакции",
+			"error":   err.Error(),
+		})
+	}
+
+	// Удаленные дубликаты завышали расходы, поэтому бюджеты пересчитываются
+	ids := make([]uint, 0, len(budgetCategoryIDs))
+	for id := range budgetCategoryIDs {
+		ids = append(ids, id)
+	}
+	if err := refreshBudgets(userID, ids, from, to); err != nil {
+		// Логируем ошибку, но продолжаем выполнение
+		logError(err, "Ошибка при обновлении бюджетов после объединения дубликатов")
+	}
+
+	db.DB.Preload("Category").Preload("Splits.Category").Preload("Tags").First(&keep, keep.ID)
+
+	changeLogs := []models.ChangeLog{models.NewChangeLog(userID, models.EntityTransaction, keep.ID, models.ActionUpdate, oldKeep, keep)}
+	for _, t := range duplicates {
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionDelete, t, nil))
+	}
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("Удалено дубликатов: %d", len(duplicates)),
+		"data":    keep,
+	})
+}
+
+// DismissDuplicates отмечает транзакции группы как разные операции.
+// Все пары из группы больше не предлагаются как дубликаты.
+func (dc *DuplicateController) DismissDuplicates(c *fiber.Ctx) error {
+	var input models.DuplicateDismissDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	ids := uniqueIDs(input.TransactionIDs)
+	var count int64
+	if err := db.DB.Model(&models.Transaction{}).Where("id IN ? AND user_id = ?", ids, userID).Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось найти транзакции",
+			"error":   err.Error(),
+		})
+	}
+	if int(count) != len(ids) || len(ids) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некоторые транзакции не найдены или не принадлежат пользователю",
+		})
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	dismissals := make([]models.DuplicateDismissal, 0, len(ids)*(len(ids)-1)/2)
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			dismissals = append(dismissals, models.DuplicateDismissal{UserID: userID, TransactionID: ids[i], DuplicateID: ids[j]})
+		}
+	}
+
+	// Пара могла быть отклонена раньше в составе другой группы
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&dismissals).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить решение",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Транзакции отмечены как разные операции",
+	})
+}
+
+// findLikelyDuplicates для каждой новой операции находит похожую существующую транзакцию пользователя.
+// Возвращает ID найденной транзакции или nil для каждой операции в том же порядке.
+func findLikelyDuplicates(candidates []utils.TransactionFingerprint, userID uint) ([]*uint, error) {
+	result := make([]*uint, len(candidates))
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	// Существующие транзакции выбираются одним запросом по периоду и суммам кандидатов
+	var from, to time.Time
+	amounts := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		if from.IsZero() || candidate.Date.Before(from) {
+			from = candidate.Date
+		}
+		if candidate.Date.After(to) {
+			to = candidate.Date
+		}
+		amounts = append(amounts, int64(math.Round(candidate.Amount*100)))
+	}
+	from = from.AddDate(0, 0, -utils.DuplicateDateWindow-1)
+	to = to.AddDate(0, 0, utils.DuplicateDateWindow+1)
+
+	var existing []models.Transaction
+	if err := db.DB.Select("id", "amount", "currency", "date", "description", "category_id").
+		Where("user_id = ? AND kind = ? AND date BETWEEN ? AND ? AND ROUND(amount * 100) IN ?", userID, models.KindRegular, from, to, amounts).
+		Order("date, id").
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	for i, candidate := range candidates {
+		for _, t := range existing {
+			if utils.IsLikelyDuplicate(candidate, transactionFingerprint(t), utils.DuplicateDateWindow) {
+				id := t.ID
+				result[i] = &id
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// transactionFingerprint возвращает признаки сохраненной транзакции для поиска дубликатов
+func transactionFingerprint(t models.Transaction) utils.TransactionFingerprint {
+	return utils.TransactionFingerprint{
+		Amount:      t.Amount,
+		Currency:    t.Currency,
+		Date:        t.Date,
+		Description: t.Description,
+		CategoryID:  t.CategoryID,
+	}
+}
+
+// uniqueIDs убирает повторы из списка ID, сохраняя порядок
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,909 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/importers"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// importPreviewRows количество строк, которые показываются в предпросмотре импорта
+const importPreviewRows = 50
+
+// ImportController контроллер для импорта транзакций из файлов
+type ImportController struct{}
+
+// NewImportController создает новый контроллер импорта
+func NewImportController() *ImportController {
+	return &ImportController{}
+}
+
+// PreviewCSV разбирает CSV-файл без сохранения и возвращает определенные параметры файла,
+// предложенное сопоставление колонок, категории из файла и первые строки.
+// Параметры из поля options (если переданы) заменяют автоматически определенные,
+// что позволяет уточнять сопоставление и повторно смотреть результат.
+func (ic *ImportController) PreviewCSV(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var input models.CSVImportDTO
+	if err := parseImportOptions(c, &input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать параметры импорта",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	data, rerr := readImportFile(c)
+	if rerr != nil {
+		return rerr.send(c)
+	}
+
+	file, rows, err := parseCSVImport(data, &input)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось разобрать CSV-файл",
+			"error":   err.Error(),
+		})
+	}
+
+	categories, err := assignImportCategories(rows, input.ImportOptions, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректное сопоставление категорий",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := flagPossibleDuplicates(rows, input.ImportOptions, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить дубликаты",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"settings":    file.Settings,
+			"header":      file.Header,
+			"mapping":     input.Mapping,
+			"dateFormats": importDateFormatNames(),
+			"categories":  categories,
+			"summary":     importSummary(rows),
+			"rows":        previewRows(rows),
+		},
+	})
+}
+
+// ImportCSV импортирует транзакции из CSV-файла с подтвержденными пользователем
+// параметрами, сопоставлением колонок и категорий
+func (ic *ImportController) ImportCSV(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var input models.CSVImportDTO
+	if err := parseImportOptions(c, &input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать параметры импорта",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	// Сохранение возможно только с подтвержденным сопоставлением колонок
+	if input.Mapping.Date == nil || (input.Mapping.Amount == nil && input.Mapping.Income == nil && input.Mapping.Expense == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Подтвердите сопоставление колонок: нужны как минимум дата и сумма",
+		})
+	}
+
+	data, rerr := readImportFile(c)
+	if rerr != nil {
+		return rerr.send(c)
+	}
+
+	_, rows, err := parseCSVImport(data, &input)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось разобрать CSV-файл",
+			"error":   err.Error(),
+		})
+	}
+
+	return saveImport(c, rows, input.ImportOptions, userID)
+}
+
+// GetBankFormats возвращает поддерживаемые форматы банковских выписок
+func (ic *ImportController) GetBankFormats(c *fiber.Ctx) error {
+	formats := make([]fiber.Map, 0, len(importers.List()))
+	for _, parser := range importers.List() {
+		formats = append(formats, fiber.Map{
+			"name":  parser.Name(),
+			"title": parser.Title(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   formats,
+	})
+}
+
+// PreviewBankStatement разбирает банковскую выписку без сохранения и показывает операции
+// с предложенными категориями: по категории банка, по контрагенту и по прошлым операциям
+func (ic *ImportController) PreviewBankStatement(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var input models.BankImportDTO
+	if err := parseImportOptions(c, &input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать параметры импорта",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	parser, rows, parseErr := parseBankStatement(c, input.Format)
+	if parseErr != nil {
+		return parseErr.send(c)
+	}
+
+	// Операции, импортированные раньше, показываются, но при импорте будут пропущены
+	if err := markImportDuplicates(rows, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить ранее импортированные операции",
+			"error":   err.Error(),
+		})
+	}
+
+	categories, err := assignImportCategories(rows, input.ImportOptions, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректное сопоставление категорий",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := flagPossibleDuplicates(rows, input.ImportOptions, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить дубликаты",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"format":     parser.Name(),
+			"title":      parser.Title(),
+			"categories": categories,
+			"summary":    importSummary(rows),
+			"rows":       previewRows(rows),
+		},
+	})
+}
+
+// ImportBankStatement импортирует операции банковской выписки с подтвержденным сопоставлением категорий
+func (ic *ImportController) ImportBankStatement(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var input models.BankImportDTO
+	if err := parseImportOptions(c, &input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать параметры импорта",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	_, rows, parseErr := parseBankStatement(c, input.Format)
+	if parseErr != nil {
+		return parseErr.send(c)
+	}
+
+	return saveImport(c, rows, input.ImportOptions, userID)
+}
+
+// parseBankStatement читает выписку из запроса и разбирает ее разборщиком указанного
+// или автоматически определенного формата
+func parseBankStatement(c *fiber.Ctx, format string) (importers.Parser, []models.ImportRow, *requestError) {
+	data, rerr := readImportFile(c)
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+
+	var parser importers.Parser
+	var ok bool
+	if format != "" {
+		if parser, ok = importers.Get(format); !ok {
+			return nil, nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Неизвестный формат выписки: %s", format), nil}
+		}
+	} else if parser, ok = importers.Detect(data); !ok {
+		return nil, nil, &requestError{fiber.StatusBadRequest, "Не удалось определить формат выписки. Укажите его явно или воспользуйтесь импортом CSV", nil}
+	}
+
+	rows, err := parser.Parse(data)
+	if err != nil {
+		return nil, nil, &requestError{fiber.StatusBadRequest, "Не удалось разобрать выписку", err}
+	}
+	return parser, rows, nil
+}
+
+// parseImportOptions разбирает JSON из поля options формы.
+// Отсутствующее поле означает параметры по умолчанию.
+func parseImportOptions(c *fiber.Ctx, input interface{}) error {
+	options := c.FormValue("options")
+	if options == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(options), input)
+}
+
+// readImportFile читает загруженный файл из поля file формы, не больше models.MaxImportFileSize
+func readImportFile(c *fiber.Ctx) ([]byte, *requestError) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, &requestError{fiber.StatusBadRequest, "Не удалось прочитать файл для импорта", err}
+	}
+	if fileHeader.Size > models.MaxImportFileSize {
+		return nil, &requestError{fiber.StatusRequestEntityTooLarge, fmt.Sprintf("Размер файла для импорта не должен превышать %d МБ", models.MaxImportFileSize/(1024*1024)), nil}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, &requestError{fiber.StatusBadRequest, "Не удалось прочитать файл для импорта", err}
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, models.MaxImportFileSize))
+	if err != nil {
+		return nil, &requestError{fiber.StatusBadRequest, "Не удалось прочитать файл для импорта", err}
+	}
+	return data, nil
+}
+
+// parseCSVImport разбирает CSV-файл. Если сопоставление колонок не передано,
+// используется предложенное по заголовкам, и оно записывается в input.Mapping.
+func parseCSVImport(data []byte, input *models.CSVImportDTO) (*utils.CSVFile, []models.ImportRow, error) {
+	file, err := utils.ReadCSVFile(data, input.Settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Mapping == (models.ImportColumnMapping{}) {
+		input.Mapping = utils.SuggestCSVMapping(file)
+	}
+
+	rows, err := utils.ParseCSVRows(file, input.Mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, rows, nil
+}
+
+// assignImportCategories назначает строкам категории пользователя: по сопоставлению из options,
+// затем по совпадению названия с категорией пользователя того же типа, затем категорию
+// по умолчанию для типа операции. Строки без категории получают ошибку.
+// Возвращает сводку по категориям, встреченным в файле.
+func assignImportCategories(rows []models.ImportRow, options models.ImportOptions, userID uint) ([]models.ImportCategory, error) {
+	var userCategories []models.Category
+	if err := db.DB.Where("user_id = ?", userID).Find(&userCategories).Error; err != nil {
+		return nil, err
+	}
+
+	categoriesByID := make(map[uint]models.Category)
+	categoriesByName := make(map[string]uint)
+	for _, category := range userCategories {
+		categoriesByID[category.ID] = category
+		categoriesByName[string(category.Type)+":"+strings.ToLower(category.Name)] = category.ID
+	}
+
+	for name, id := range options.CategoryMapping {
+		if _, ok := categoriesByID[id]; !ok {
+			return nil, fmt.Errorf("категория с ID %d для «%s» не найдена или не принадлежит пользователю", id, name)
+		}
+	}
+	defaults := map[models.CategoryType]*uint{
+		models.Income:  options.DefaultIncomeCategoryID,
+		models.Expense: options.DefaultExpenseCategoryID,
+	}
+	for categoryType, id := range defaults {
+		if id == nil {
+			continue
+		}
+		if category, ok := categoriesByID[*id]; !ok || category.Type != categoryType {
+			return nil, fmt.Errorf("категория по умолчанию с ID %d не найдена или не подходит для типа %s", *id, categoryType)
+		}
+	}
+
+	history, err := counterpartyCategories(rows, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	payees, patterns, err := utils.LoadPayees(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := utils.LoadTransactionRules(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// mapped ищет категорию по явному сопоставлению из options
+	mapped := func(name string, categoryType models.CategoryType) (*uint, string) {
+		id, ok := options.CategoryMapping[name]
+		if !ok || name == "" {
+			return nil, ""
+		}
+		if categoriesByID[id].Type != categoryType {
+			return nil, fmt.Sprintf("категория «%s» не подходит для типа операции %s", categoriesByID[id].Name, categoryType)
+		}
+		return &id, ""
+	}
+	// byName ищет категорию пользователя того же типа с совпадающим названием
+	byName := func(name string, categoryType models.CategoryType) *uint {
+		if id, ok := categoriesByName[string(categoryType)+":"+strings.ToLower(name)]; ok && name != "" {
+			return &id
+		}
+		return nil
+	}
+
+	var summary []models.ImportCategory
+	summaryIndex := make(map[string]int)
+	count := func(name string, categoryType models.CategoryType, categoryID *uint) {
+		if name == "" {
+			return
+		}
+		key := string(categoryType) + ":" + name
+		index, ok := summaryIndex[key]
+		if !ok {
+			index = len(summary)
+			summaryIndex[key] = index
+			summary = append(summary, models.ImportCategory{Name: name, Type: categoryType, CategoryID: categoryID})
+		}
+		summary[index].Count++
+	}
+	missing := func(name string) string {
+		if name != "" {
+			return fmt.Sprintf("не назначена категория для «%s»", name)
+		}
+		return "не назначена категория"
+	}
+	// Валюты из файла проверяются один раз: суммы в валюте без курсов нельзя пересчитать
+	knownCurrencies := make(map[string]bool)
+
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			continue
+		}
+		if row.Currency != "" {
+			known, checked := knownCurrencies[row.Currency]
+			if !checked {
+				known = utils.IsKnownCurrency(row.Currency)
+				knownCurrencies[row.Currency] = known
+			}
+			if !known {
+				row.Error = fmt.Sprintf("неизвестная валюта %s: для нее не загружены курсы", row.Currency)
+				continue
+			}
+		}
+
+		// Получатель ищется сначала по контрагенту, затем по описанию
+		for _, text := range []string{row.Counterparty, row.Description} {
+			if id, ok := utils.MatchPayee(text, patterns); ok {
+				row.PayeeID = &id
+				break
+			}
+		}
+
+		// Правила пользователя задают заметку и отметку о проверке, а категорию - после явного сопоставления
+		rulesResult := models.EvaluateRules(rules, row.Description, row.Amount, row.Date, row.Type)
+		if rulesResult.Note != nil {
+			row.Note = *rulesResult.Note
+		}
+		row.Reviewed = rulesResult.Reviewed
+
+		// Части разделенной операции сопоставляются по своим категориям
+		if len(row.Splits) > 0 {
+			for j := range row.Splits {
+				split := &row.Splits[j]
+				if split.CategoryID == nil {
+					id, message := mapped(split.Category, row.Type)
+					if message != "" {
+						row.Error = message
+						break
+					}
+					split.CategoryID = id
+				}
+				if split.CategoryID == nil {
+					split.CategoryID = byName(split.Category, row.Type)
+				}
+				if split.CategoryID == nil {
+					split.CategoryID = defaults[row.Type]
+				}
+				count(split.Category, row.Type, split.CategoryID)
+				if split.CategoryID == nil {
+					row.Error = missing(split.Category)
+					break
+				}
+			}
+			continue
+		}
+
+		// Категория из файла и контрагент сопоставляются сначала явно, затем правилами пользователя,
+		// по названию категории пользователя, по категории получателя по умолчанию
+		// и по прошлым операциям с этим контрагентом
+		if row.CategoryID == nil {
+			for _, name := range []string{row.Category, row.Counterparty} {
+				id, message := mapped(name, row.Type)
+				if message != "" {
+					row.Error = message
+					break
+				}
+				if id != nil {
+					row.CategoryID = id
+					break
+				}
+			}
+			if row.Error != "" {
+				continue
+			}
+		}
+		if row.CategoryID == nil && rulesResult.CategoryID != nil {
+			row.CategoryID = rulesResult.CategoryID
+		}
+		if row.CategoryID == nil {
+			row.CategoryID = byName(row.Category, row.Type)
+		}
+		// Категория получателя по умолчанию, если она подходит по типу операции
+		if row.CategoryID == nil && row.PayeeID != nil {
+			if id := payees[*row.PayeeID].DefaultCategoryID; id != nil && categoriesByID[*id].Type == row.Type {
+				row.CategoryID = id
+			}
+		}
+		if row.CategoryID == nil && row.Counterparty != "" {
+			if id, ok := history[string(row.Type)+":"+strings.ToLower(row.Counterparty)]; ok {
+				row.CategoryID = &id
+			}
+		}
+		if row.CategoryID == nil {
+			row.CategoryID = defaults[row.Type]
+		}
+
+		count(row.Category, row.Type, row.CategoryID)
+
+		if row.CategoryID == nil {
+			row.Error = missing(row.Category)
+		}
+	}
+	return summary, nil
+}
+
+// markImportDuplicates отмечает строки, внешний идентификатор которых уже встречался:
+// у транзакций пользователя (файл импортируется повторно) или выше в самом файле
+func markImportDuplicates(rows []models.ImportRow, userID uint) error {
+	var ids []string
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			ids = append(ids, row.ExternalID)
+		}
+	}
+
+	existing, err := existingExternalIDs(ids, userID)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		if id := rows[i].ExternalID; id != "" {
+			rows[i].Duplicate = existing[id]
+			existing[id] = true
+		}
+	}
+	return nil
+}
+
+// counterpartyCategories находит категории, в которые пользователь чаще всего относил
+// операции с контрагентами из файла. Описание транзакции сравнивается с названием контрагента
+// до разделителя " — ", которым импорт отделяет контрагента от назначения платежа.
+// Ключ результата - тип операции и название контрагента в нижнем регистре.
+func counterpartyCategories(rows []models.ImportRow, userID uint) (map[string]uint, error) {
+	result := make(map[string]uint)
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range rows {
+		name := strings.ToLower(row.Counterparty)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	type counterpartyCategory struct {
+		Name       string
+		Type       models.CategoryType
+		CategoryID uint
+	}
+
+	var matches []counterpartyCategory
+	if err := db.DB.Raw(`
+		SELECT split_part(lower(t.description), ' — ', 1) AS name, c.type, t.category_id, COUNT(*) AS uses
+		FROM transactions t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.user_id = ? AND t.kind = ? AND t.deleted_at IS NULL AND split_part(lower(t.description), ' — ', 1) IN ?
+		GROUP BY 1, 2, 3
+		ORDER BY uses DESC
+	`, userID, models.KindRegular, names).Scan(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	// Строки отсортированы по частоте, поэтому берем первую категорию для каждого контрагента
+	for _, match := range matches {
+		key := string(match.Type) + ":" + match.Name
+		if _, ok := result[key]; !ok {
+			result[key] = match.CategoryID
+		}
+	}
+	return result, nil
+}
+
+// saveImport назначает категории и сохраняет импортируемые строки как транзакции.
+// Если есть строки с ошибками и не разрешен их пропуск, импорт отменяется целиком.
+// Ранее импортированные операции (с тем же внешним идентификатором) пропускаются.
+func saveImport(c *fiber.Ctx, rows []models.ImportRow, options models.ImportOptions, userID uint) error {
+	if err := checkAccountOwnership(options.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := markImportDuplicates(rows, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить ранее импортированные операции",
+			"error":   err.Error(),
+		})
+	}
+
+	if _, err := assignImportCategories(rows, options, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректное сопоставление категорий",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := flagPossibleDuplicates(rows, options, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить дубликаты",
+			"error":   err.Error(),
+		})
+	}
+
+	var invalid, possibleDuplicates []models.ImportRow
+	duplicates := 0
+	for _, row := range rows {
+		switch {
+		case row.Duplicate:
+			duplicates++
+		case row.Error != "":
+			invalid = append(invalid, row)
+		case row.PossibleDuplicateOf != nil && options.SkipPossibleDuplicates:
+			possibleDuplicates = append(possibleDuplicates, row)
+		}
+	}
+	if len(invalid) > 0 && !options.SkipInvalid {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Файл содержит строки с ошибками. Исправьте их или разрешите пропуск",
+			"errors":  invalid,
+		})
+	}
+
+	// Строки превращаются в обычные транзакции и сохраняются тем же путем, что и при массовом создании
+	imported := make([]models.ImportRow, 0, len(rows))
+	inputs := make([]models.TransactionDTO, 0, len(rows))
+	for _, row := range rows {
+		if row.Error != "" || row.Duplicate || (row.PossibleDuplicateOf != nil && options.SkipPossibleDuplicates) {
+			continue
+		}
+
+		currency := row.Currency
+		if currency == "" {
+			currency = options.Currency
+		}
+
+		input := models.TransactionDTO{
+			Amount:      row.Amount,
+			Currency:    currency,
+			Description: row.Description,
+			Date:        row.Date,
+			AccountID:   options.AccountID,
+			PayeeID:     row.PayeeID,
+			Note:        row.Note,
+			Reviewed:    row.Reviewed,
+			SkipRules:   true, // правила уже применены при сопоставлении категорий
+		}
+		if row.ExternalID != "" {
+			externalID := row.ExternalID
+			input.ExternalID = &externalID
+		}
+		if len(row.Splits) > 0 {
+			for _, split := range row.Splits {
+				input.Splits = append(input.Splits, models.TransactionSplitDTO{
+					CategoryID: *split.CategoryID,
+					Amount:     split.Amount,
+					Note:       split.Note,
+				})
+			}
+		} else {
+			input.CategoryID = *row.CategoryID
+		}
+
+		imported = append(imported, row)
+		inputs = append(inputs, input)
+	}
+
+	if len(inputs) == 0 {
+		message := "В файле нет транзакций для импорта"
+		if duplicates+len(possibleDuplicates) > 0 {
+			message = "Все операции файла уже есть среди транзакций"
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+		})
+	}
+
+	transactions, createErr := createTransactions(inputs, userID, middlewares.GetSubscriptionPlan(c))
+	if createErr != nil {
+		return createErr.send(c)
+	}
+
+	rules, err := saveImportedRecurringRules(imported, transactions, options.AccountID, userID)
+	if err != nil {
+		// Транзакции уже сохранены, поэтому импорт не отменяется
+		logError(err, "Ошибка при сохранении правил регулярных платежей из импорта")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("Успешно импортировано %d транзакций", len(transactions)),
+		"data": fiber.Map{
+			"imported":           len(transactions),
+			"duplicates":         duplicates,
+			"possibleDuplicates": possibleDuplicates,
+			"recurringRules":     rules,
+			"skipped":            invalid,
+		},
+	})
+}
+
+// saveImportedRecurringRules находит или создает правила регулярных платежей, описанные в файле,
+// и привязывает к ним импортированные транзакции. Строки и транзакции идут в одном порядке.
+// Возвращает количество созданных правил.
+func saveImportedRecurringRules(rows []models.ImportRow, transactions []models.Transaction, accountID *uint, userID uint) (int, error) {
+	var keys []string
+	first := make(map[string]int)
+	ruleTransactions := make(map[string][]uint)
+	for i, row := range rows {
+		if row.Recurring == nil {
+			continue
+		}
+		key := row.Recurring.Key
+		if _, ok := first[key]; !ok {
+			first[key] = i
+			keys = append(keys, key)
+		}
+		ruleTransactions[key] = append(ruleTransactions[key], transactions[i].ID)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	created := 0
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			recurring := rows[first[key]].Recurring
+			transaction := transactions[first[key]]
+
+			// Правило относится к категории транзакции (для разделенной - к категории первой части).
+			// Повторный импорт того же файла находит правило, созданное в прошлый раз.
+			rule := models.RecurringRule{
+				UserID:          userID,
+				Amount:          recurring.Amount,
+				Currency:        transaction.Currency,
+				Description:     recurring.Description,
+				CategoryID:      *transaction.CategoryID,
+				AccountID:       accountID,
+				Frequency:       recurring.Frequency,
+				StartDate:       recurring.StartDate,
+				EndDate:         recurring.EndDate,
+				NextExecuteDate: recurring.NextExecuteDate,
+				IsActive:        recurring.IsActive,
+			}
+			var existing models.RecurringRule
+			result := tx.Where("user_id = ? AND category_id = ? AND frequency = ? AND amount = ? AND description = ? AND start_date = ?",
+				userID, rule.CategoryID, rule.Frequency, rule.Amount, rule.Description, rule.StartDate).Limit(1).Find(&existing)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				rule = existing
+			} else {
+				if err := tx.Create(&rule).Error; err != nil {
+					return err
+				}
+				created++
+				// Значение по умолчанию для is_active - true, поэтому неактивное правило выключаем отдельно
+				if !recurring.IsActive {
+					if err := tx.Model(&rule).Update("is_active", false).Error; err != nil {
+						return err
+					}
+				}
+				changeLog := models.NewChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionCreate, nil, rule)
+				if err := tx.Create(&changeLog).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Model(&models.Transaction{}).
+				Where("id IN ? AND user_id = ?", ruleTransactions[key], userID).
+				Updates(map[string]interface{}{"recurring_rule_id": rule.ID, "is_recurring": true}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// flagPossibleDuplicates отмечает строки, похожие на существующие транзакции пользователя.
+// Вызывается после назначения категорий, чтобы при сравнении учитывалась и категория.
+func flagPossibleDuplicates(rows []models.ImportRow, options models.ImportOptions, userID uint) error {
+	var candidates []utils.TransactionFingerprint
+	var indexes []int
+	for i, row := range rows {
+		if row.Error != "" || row.Duplicate {
+			continue
+		}
+
+		currency := row.Currency
+		if currency == "" {
+			currency = options.Currency
+		}
+		// Разделенная транзакция сохраняется с категорией первой части
+		categoryID := row.CategoryID
+		if len(row.Splits) > 0 {
+			categoryID = row.Splits[0].CategoryID
+		}
+
+		candidates = append(candidates, utils.TransactionFingerprint{
+			Amount:      row.Amount,
+			Currency:    currency,
+			Date:        row.Date,
+			Description: row.Description,
+			CategoryID:  categoryID,
+		})
+		indexes = append(indexes, i)
+	}
+
+	matches, err := findLikelyDuplicates(candidates, userID)
+	if err != nil {
+		return err
+	}
+	for k, i := range indexes {
+		rows[i].PossibleDuplicateOf = matches[k]
+	}
+	return nil
+}
+
+// importSummary считает строки файла, готовые к импорту (в том числе похожие на существующие транзакции),
+// ранее импортированные и содержащие ошибки
+func importSummary(rows []models.ImportRow) fiber.Map {
+	valid, duplicates, possibleDuplicates, income, expense := 0, 0, 0, 0.0, 0.0
+	for _, row := range rows {
+		if row.Duplicate {
+			duplicates++
+			continue
+		}
+		if row.Error != "" {
+			continue
+		}
+		valid++
+		if row.PossibleDuplicateOf != nil {
+			possibleDuplicates++
+		}
+		if row.Type == models.Income {
+			income += row.Amount
+		} else {
+			expense += row.Amount
+		}
+	}
+	return fiber.Map{
+		"total":              len(rows),
+		"valid":              valid,
+		"invalid":            len(rows) - valid - duplicates,
+		"duplicates":         duplicates,
+		"possibleDuplicates": possibleDuplicates,
+		"totalIncome":        income,
+		"totalExpense":       expense,
+	}
+}
+
+// previewRows возвращает первые строки файла и все строки с ошибками
+func previewRows(rows []models.ImportRow) []models.ImportRow {
+	result := make([]models.ImportRow, 0, importPreviewRows)
+	for i, row := range rows {
+		if i < importPreviewRows || row.Error != "" {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// importDateFormatNames возвращает названия поддерживаемых форматов дат
+func importDateFormatNames() []string {
+	names := make([]string, 0, len(utils.ImportDateFormats))
+	for _, format := range utils.ImportDateFormats {
+		names = append(names, format.Name)
+	}
+	return names
+}
@@ -38,6 +38,10 @@ func (ct *InvestmentController) Create(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Некорректные данные"})
 	}
 	input.UserID = userID
+	input.Currency = resolveCurrency(input.Currency, nil, userID)
+	if !utils.IsKnownCurrency(input.Currency) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Неизвестная валюта: для нее не загружены курсы"})
+	}
 	if err := db.DB.Create(&input).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Не удалось создать инвестицию"})
 	}
@@ -65,6 +69,12 @@ func (ct *InvestmentController) Update(c *fiber.Ctx) error {
 	}
 	input.ID = investment.ID
 	input.UserID = userID
+	if input.Currency != "" {
+		input.Currency = resolveCurrency(input.Currency, nil, userID)
+		if !utils.IsKnownCurrency(input.Currency) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Неизвестная валюта: для нее не загружены курсы"})
+		}
+	}
 	if err := db.DB.Model(&investment).Updates(input).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Не удалось обновить инвестицию"})
 	}
@@ -100,17 +100,7 @@ func (ct *InvestmentOperationController) Create(c *fiber.Ctx) error {
 	if err := db.DB.First(&investment, investmentIDInt).Error; err == nil {
 		oldInvestment := investment
 		
-		switch input.Type {
-		case "deposit":
-			investment.Amount += operation.Amount
-		case "withdrawal":
-			investment.Amount -= operation.Amount
-			if investment.Amount < 0 {
-				investment.Amount = 0 // Не позволяем отрицательные значения
-			}
-		case "capitalization":
-			investment.Capitalization += operation.Amount
-		}
+		applyInvestmentOperation(&investment, operation, false)
 		
 		if err := db.DB.Save(&investment).Error; err == nil {
 			// Логируем обновление инвестиции
@@ -137,17 +127,7 @@ func (ct *InvestmentOperationController) Delete(c *fiber.Ctx) error {
 	if err := db.DB.First(&investment, op.InvestmentID).Error; err == nil {
 		oldInvestment := investment
 		
-		switch op.Type {
-		case "deposit":
-			investment.Amount -= op.Amount
-			if investment.Amount < 0 {
-				investment.Amount = 0
-			}
-		case "withdrawal":
-			investment.Amount += op.Amount
-		case "capitalization":
-			investment.Capitalization -= op.Amount
-		}
+		applyInvestmentOperation(&investment, op, true)
 		
 		if err := db.DB.Save(&investment).Error; err == nil {
 			// Логируем обновление инвестиции
@@ -162,4 +142,25 @@ func (ct *InvestmentOperationController) Delete(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Не удалось удалить операцию"})
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Операция удалена"})
-} 
\ No newline at end of file
+}
+
+// applyInvestmentOperation учитывает операцию в сумме или капитализации инвестиции,
+// а с revert - выполняет обратную операцию
+func applyInvestmentOperation(investment *models.Investment, op models.InvestmentOperation, revert bool) {
+	amount := op.Amount
+	if revert {
+		amount = -amount
+	}
+
+	switch op.Type {
+	case "deposit":
+		investment.Amount += amount
+	case "withdrawal":
+		investment.Amount -= amount
+	case "capitalization":
+		investment.Capitalization += amount
+	}
+	if investment.Amount < 0 {
+		investment.Amount = 0 // Не позволяем отрицательные значения
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// ledgerTable возвращает подзапрос с транзакциями, которые учитываются в доходах и расходах,
+// под указанным псевдонимом. Используется в статистике и при пересчете бюджетов вместо
+// таблицы transactions, чтобы все отчеты одинаково трактовали операции.
+// Переводы между счетами не являются ни доходом, ни расходом и в подзапрос не попадают,
+// как и транзакции в корзине. Исключение - комиссия перевода: она попадает в подзапрос
+// отдельной строкой с суммой комиссии в категории расходов, указанной у перевода.
+// Разделенная транзакция представлена своими частями: по строке на каждую часть
+// с категорией и суммой части (split_id указывает на часть, у обычных транзакций он NULL).
+// Возврат попадает в подзапрос с отрицательной суммой в категории исходного расхода,
+// поэтому уменьшает расходы по ней.
+// Колонка amount пересчитана в базовую валюту пользователя по курсу на дату транзакции,
+// исходная сумма и валюта доступны в колонках original_amount и currency.
+func ledgerTable(alias string) string {
+	amount := fmt.Sprintf("COALESCE(s.amount, tx.amount) * (CASE WHEN tx.kind = '%s' THEN -1 ELSE 1 END)", models.KindRefund)
+	return fmt.Sprintf(`(SELECT tx.id, s.id AS split_id, tx.user_id,
+		COALESCE(s.category_id, tx.category_id) AS category_id, tx.account_id, tx.payee_id, tx.date, tx.description, s.note,
+		tx.currency, %s AS original_amount, %s AS amount
+		FROM transactions tx
+		JOIN users u ON u.id = tx.user_id
+		LEFT JOIN transaction_splits s ON s.transaction_id = tx.id
+		WHERE tx.kind <> '%s' AND tx.deleted_at IS NULL
+		UNION ALL
+		SELECT tx.id, NULL, tx.user_id, tx.category_id, tx.account_id, tx.payee_id, tx.date, tx.description, NULL,
+		tx.currency, tx.fee, %s
+		FROM transactions tx
+		JOIN users u ON u.id = tx.user_id
+		WHERE tx.kind = '%s' AND tx.fee > 0 AND tx.category_id IS NOT NULL AND tx.deleted_at IS NULL) AS %s`,
+		amount, convertedAmountSQL(amount, "tx.currency", "u.base_currency", "tx.date"), models.KindTransfer,
+		convertedAmountSQL("tx.fee", "tx.currency", "u.base_currency", "tx.date"), models.KindTransfer, alias)
+}
+
+// convertedAmountSQL возвращает SQL-выражение суммы, пересчитанной в другую валюту по курсу на дату.
+// Если курсов валюты нет, запрос завершается ошибкой, а не учитывает сумму без пересчета.
+func convertedAmountSQL(amount, from, to, date string) string {
+	return fmt.Sprintf("%s * fx_rate_required(%s, %s, (%s)::date)", amount, from, to, date)
+}
+
+// ledgerAmountIn возвращает SQL-выражение суммы транзакции из ledgerTable, пересчитанной
+// в валюту, переданную параметром запроса
+func ledgerAmountIn(alias string) string {
+	return convertedAmountSQL(alias+".original_amount", alias+".currency", "?", alias+".date")
+}
+
+// baseAmountSelect возвращает список колонок для выборки транзакций вместе с суммой
+// в валюте, переданной параметром запроса (заполняет поле BaseAmount)
+func baseAmountSelect() string {
+	return "transactions.*, " + convertedAmountSQL("transactions.amount", "transactions.currency", "?", "transactions.date") + " AS base_amount"
+}
+
+// calculateBudgetSpent считает потраченную по бюджету сумму в валюте бюджета
+func calculateBudgetSpent(budget models.Budget) (float64, error) {
+	var sum float64
+	query := db.DB.Table(ledgerTable("transactions")).
+		Select("COALESCE(SUM("+ledgerAmountIn("transactions")+"), 0)", budget.Currency).
+		Joins("JOIN categories ON transactions.category_id = categories.id").
+		Where("transactions.user_id = ? AND transactions.date BETWEEN ? AND ?", budget.UserID, budget.StartDate, budget.EndDate)
+
+	// Если в бюджете указана категория, учитываем только транзакции с этой категорией
+	// и всеми ее подкатегориями, иначе - все расходы пользователя
+	if budget.CategoryID != nil {
+		query = query.Where("transactions.category_id IN ("+categorySubtreeSQL+")", *budget.CategoryID)
+	} else {
+		query = query.Where("categories.type = ?", models.Expense)
+	}
+
+	if err := query.Row().Scan(&sum); err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// refreshBudgets пересчитывает потраченные суммы бюджетов пользователя, пересекающихся с периодом,
+// по указанным категориям, их родительским категориям и бюджетов без категории. Используется при массовых изменениях транзакций,
+// чтобы не пересчитывать бюджеты отдельно для каждой транзакции.
+func refreshBudgets(userID uint, categoryIDs []uint, from, to time.Time) error {
+	// Бюджеты родительских категорий учитывают расходы подкатегорий
+	categoryIDs, err := withAncestorCategories(categoryIDs)
+	if err != nil {
+		return err
+	}
+
+	var budgets []models.Budget
+	query := db.DB.Where("user_id = ? AND start_date <= ? AND end_date >= ?", userID, to, from)
+	if len(categoryIDs) > 0 {
+		query = query.Where("category_id IN ? OR category_id IS NULL", categoryIDs)
+	} else {
+		query = query.Where("category_id IS NULL")
+	}
+	if err := query.Find(&budgets).Error; err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		sum, err := calculateBudgetSpent(budget)
+		if err != nil {
+			return err
+		}
+		if err := db.DB.Model(&budget).Update("spent", sum).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(budgets) > 0 {
+		if err := CheckBudgetThresholds(userID); err != nil {
+			log.Printf("Ошибка проверки превышения бюджетов для пользователя %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// exchangeRate возвращает курс пересчета из одной валюты в другую на дату.
+// Если курсов нет, возвращает ошибку, как и SQL-выражения пересчета.
+func exchangeRate(from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	var rate *float64
+	if err := db.DB.Raw("SELECT fx_rate(?, ?, ?::date)", from, to, date.Format("2006-01-02")).Row().Scan(&rate); err != nil {
+		return 0, err
+	}
+	if rate == nil {
+		return 0, fmt.Errorf("нет курса для пересчета %s в %s: загрузите курсы валют", from, to)
+	}
+	return *rate, nil
+}
+
+// rateCache кэширует курсы пересчета в рамках одного запроса
+type rateCache map[string]float64
+
+// get возвращает курс пересчета из кэша или загружает его из базы
+func (rc rateCache) get(from, to string, date time.Time) (float64, error) {
+	key := from + to + date.Format("2006-01-02")
+	if rate, ok := rc[key]; ok {
+		return rate, nil
+	}
+	rate, err := exchangeRate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	rc[key] = rate
+	return rate, nil
+}
+
+// getUserBaseCurrency возвращает базовую валюту пользователя
+func getUserBaseCurrency(userID uint) string {
+	var user models.User
+	if err := db.DB.Select("base_currency").First(&user, userID).Error; err != nil || user.BaseCurrency == "" {
+		return models.DefaultCurrency
+	}
+	return user.BaseCurrency
+}
+
+// resolveCurrency определяет валюту новой записи: явно указанная, иначе валюта счета,
+// иначе базовая валюта пользователя
+func resolveCurrency(currency string, accountID *uint, userID uint) string {
+	if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+		return currency
+	}
+	if accountID != nil {
+		var account models.Account
+		if err := db.DB.Select("currency").Where("id = ? AND user_id = ?", *accountID, userID).First(&account).Error; err == nil && account.Currency != "" {
+			return account.Currency
+		}
+	}
+	return getUserBaseCurrency(userID)
+}
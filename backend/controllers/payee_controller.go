@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// PayeeController контроллер для получателей платежей
+type PayeeController struct{}
+
+// NewPayeeController создает новый контроллер получателей
+func NewPayeeController() *PayeeController {
+	return &PayeeController{}
+}
+
+// GetAllPayees получает всех получателей пользователя с псевдонимами и количеством транзакций
+func (pc *PayeeController) GetAllPayees(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	type payeeWithUsage struct {
+		models.Payee
+		TransactionCount int `json:"transactionCount"`
+	}
+
+	var payees []models.Payee
+	if err := db.DB.Where("user_id = ?", userID).Preload("Aliases").Preload("DefaultCategory").Order("name").Find(&payees).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить получателей",
+			"error":   err.Error(),
+		})
+	}
+
+	type payeeCount struct {
+		PayeeID uint
+		Count   int
+	}
+
+	var counts []payeeCount
+	if err := db.DB.Model(&models.Transaction{}).
+		Select("payee_id, COUNT(*) AS count").
+		Where("user_id = ? AND payee_id IS NOT NULL", userID).
+		Group("payee_id").
+		Scan(&counts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить получателей",
+			"error":   err.Error(),
+		})
+	}
+
+	usage := make(map[uint]int)
+	for _, count := range counts {
+		usage[count.PayeeID] = count.Count
+	}
+
+	result := make([]payeeWithUsage, 0, len(payees))
+	for _, payee := range payees {
+		result = append(result, payeeWithUsage{Payee: payee, TransactionCount: usage[payee.ID]})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// GetPayeeByID получает получателя по ID
+func (pc *PayeeController) GetPayeeByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var payee models.Payee
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Aliases").Preload("DefaultCategory").First(&payee).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Получатель не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   payee,
+	})
+}
+
+// CreatePayee создает получателя и привязывает к нему подходящие транзакции без получателя
+func (pc *PayeeController) CreatePayee(c *fiber.Ctx) error {
+	var input models.PayeeDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	payee := models.Payee{
+		UserID:            userID,
+		Name:              strings.TrimSpace(input.Name),
+		DefaultCategoryID: input.DefaultCategoryID,
+	}
+	aliases, reqErr := buildPayeeAliases(input, 0, userID)
+	if reqErr != nil {
+		return reqErr.send(c)
+	}
+	payee.Aliases = aliases
+
+	// Псевдонимы сохраняются вместе с получателем
+	if err := db.DB.Create(&payee).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать получателя",
+			"error":   err.Error(),
+		})
+	}
+
+	linked, err := linkPayeeTransactions(userID)
+	if err != nil {
+		logError(err, "Ошибка при привязке транзакций к получателю")
+	}
+
+	db.DB.Preload("Aliases").Preload("DefaultCategory").First(&payee, payee.ID)
+	utils.CreateChangeLog(userID, models.EntityPayee, payee.ID, models.ActionCreate, nil, payee)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":             "success",
+		"message":            "Получатель успешно создан",
+		"data":               payee,
+		"linkedTransactions": linked,
+	})
+}
+
+// UpdatePayee обновляет получателя. Псевдонимы заменяются целиком; уже привязанные
+// транзакции остаются у получателя, а подходящие транзакции без получателя привязываются.
+func (pc *PayeeController) UpdatePayee(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var payee models.Payee
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Aliases").First(&payee).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Получатель не найден",
+			"error":   err.Error(),
+		})
+	}
+	oldPayee := payee
+
+	var input models.PayeeDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	aliases, reqErr := buildPayeeAliases(input, payee.ID, userID)
+	if reqErr != nil {
+		return reqErr.send(c)
+	}
+
+	payee.Name = strings.TrimSpace(input.Name)
+	payee.DefaultCategoryID = input.DefaultCategoryID
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("payee_id = ?", payee.ID).Delete(&models.PayeeAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Aliases").Save(&payee).Error; err != nil {
+			return err
+		}
+		for i := range aliases {
+			aliases[i].PayeeID = payee.ID
+		}
+		return tx.Create(&aliases).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить получателя",
+			"error":   err.Error(),
+		})
+	}
+
+	linked, err := linkPayeeTransactions(userID)
+	if err != nil {
+		logError(err, "Ошибка при привязке транзакций к получателю")
+	}
+
+	db.DB.Preload("Aliases").Preload("DefaultCategory").First(&payee, payee.ID)
+	utils.CreateChangeLog(userID, models.EntityPayee, payee.ID, models.ActionUpdate, oldPayee, payee)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":             "success",
+		"message":            "Получатель успешно обновлен",
+		"data":               payee,
+		"linkedTransactions": linked,
+	})
+}
+
+// DeletePayee удаляет получателя и отвязывает его от транзакций
+func (pc *PayeeController) DeletePayee(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var payee models.Payee
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Aliases").First(&payee).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Получатель не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := deletePayee(payee); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить получателя",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityPayee, payee.ID, models.ActionDelete, payee, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Получатель успешно удален",
+	})
+}
+
+// deletePayee удаляет получателя вместе с псевдонимами и отвязывает от него транзакции
+func deletePayee(payee models.Payee) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).Where("payee_id = ?", payee.ID).Update("payee_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("payee_id = ?", payee.ID).Delete(&models.PayeeAlias{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&payee).Error
+	})
+}
+
+// LinkTransactions привязывает к получателям транзакции без получателя, например созданные
+// регулярными платежами или через Telegram-бот до появления подходящего псевдонима
+func (pc *PayeeController) LinkTransactions(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	linked, err := linkPayeeTransactions(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось привязать транзакции к получателям",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("Привязано транзакций: %d", linked),
+		"data": fiber.Map{
+			"linkedTransactions": linked,
+		},
+	})
+}
+
+// buildPayeeAliases проверяет данные получателя и собирает его псевдонимы: название и дополнительные
+// варианты написания. Ни название, ни псевдонимы не должны совпадать с другими получателями пользователя.
+func buildPayeeAliases(input models.PayeeDTO, payeeID uint, userID uint) ([]models.PayeeAlias, *requestError) {
+	name := strings.TrimSpace(input.Name)
+
+	var existing models.Payee
+	if err := db.DB.Where("user_id = ? AND lower(name) = lower(?) AND id <> ?", userID, name, payeeID).First(&existing).Error; err == nil {
+		return nil, &requestError{fiber.StatusBadRequest, "Получатель с таким названием уже существует", nil}
+	}
+
+	if input.DefaultCategoryID != nil {
+		var category models.Category
+		if err := db.DB.Where("id = ? AND user_id = ?", *input.DefaultCategoryID, userID).First(&category).Error; err != nil {
+			return nil, &requestError{fiber.StatusBadRequest, "Категория не найдена или не принадлежит пользователю", err}
+		}
+	}
+
+	var aliases []models.PayeeAlias
+	seen := make(map[string]bool)
+	for _, alias := range append([]string{name}, input.Aliases...) {
+		alias = strings.TrimSpace(alias)
+		pattern := utils.NormalizePayeeName(alias)
+		if pattern == "" {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Псевдоним «%s» не содержит букв, по которым можно узнать получателя", alias), nil}
+		}
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		aliases = append(aliases, models.PayeeAlias{UserID: userID, Alias: alias, Pattern: pattern})
+	}
+
+	patterns := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		patterns = append(patterns, alias.Pattern)
+	}
+	var conflict models.PayeeAlias
+	if err := db.DB.Where("user_id = ? AND pattern IN ? AND payee_id <> ?", userID, patterns, payeeID).First(&conflict).Error; err == nil {
+		var owner models.Payee
+		db.DB.First(&owner, conflict.PayeeID)
+		return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Псевдоним «%s» уже используется получателем «%s»", conflict.Alias, owner.Name), nil}
+	}
+
+	return aliases, nil
+}
+
+// checkPayeeOwnership проверяет, что получатель существует и принадлежит пользователю
+func checkPayeeOwnership(payeeID *uint, userID uint) error {
+	if payeeID == nil {
+		return nil
+	}
+	var payee models.Payee
+	return db.DB.Where("id = ? AND user_id = ?", *payeeID, userID).First(&payee).Error
+}
+
+// matchPayee определяет получателя по описанию операции. Явно указанный получатель не меняется.
+// Сопоставление не обязательно для сохранения транзакции, поэтому ошибки только логируются.
+func matchPayee(payeeID *uint, description string, userID uint) *uint {
+	if payeeID != nil {
+		return payeeID
+	}
+	id, err := utils.MatchUserPayee(description, userID)
+	if err != nil {
+		logError(err, "Ошибка при загрузке получателей")
+	}
+	return id
+}
+
+// linkPayeeTransactions привязывает к получателям обычные транзакции пользователя без получателя,
+// описание которых подходит под псевдонимы. Возвращает количество привязанных транзакций.
+func linkPayeeTransactions(userID uint) (int64, error) {
+	_, patterns, err := utils.LoadPayees(userID)
+	if err != nil || len(patterns) == 0 {
+		return 0, err
+	}
+
+	matched := make(map[uint][]uint)
+	var batch []models.Transaction
+	if err := db.DB.Select("id", "description").
+		Where("user_id = ? AND kind = ? AND payee_id IS NULL AND description <> ''", userID, models.KindRegular).
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, t := range batch {
+				if id, ok := utils.MatchPayee(t.Description, patterns); ok {
+					matched[id] = append(matched[id], t.ID)
+				}
+			}
+			return nil
+		}).Error; err != nil {
+		return 0, err
+	}
+
+	var linked int64
+	for payeeID, ids := range matched {
+		result := db.DB.Model(&models.Transaction{}).Where("id IN ? AND user_id = ?", ids, userID).Update("payee_id", payeeID)
+		if result.Error != nil {
+			return linked, result.Error
+		}
+		linked += result.RowsAffected
+	}
+	return linked, nil
+}
@@ -88,7 +88,7 @@ func (ct *ProjectPaymentController) Create(c *fiber.Ctx) error {
 	var project models.Project
 	if err := db.DB.First(&project, projectIDInt).Error; err == nil {
 		oldProject := project
-		project.CurrentAmount += payment.Amount
+		applyProjectPayment(&project, payment, false)
 		if err := db.DB.Save(&project).Error; err == nil {
 			// Логируем обновление проекта
 			utils.CreateChangeLog(userID, models.EntityProject, project.ID, models.ActionUpdate, oldProject, project)
@@ -113,10 +113,7 @@ func (ct *ProjectPaymentController) Delete(c *fiber.Ctx) error {
 	var project models.Project
 	if err := db.DB.First(&project, payment.ProjectID).Error; err == nil {
 		oldProject := project
-		project.CurrentAmount -= payment.Amount
-		if project.CurrentAmount < 0 {
-			project.CurrentAmount = 0 // Не позволяем отрицательные значения
-		}
+		applyProjectPayment(&project, payment, true)
 		if err := db.DB.Save(&project).Error; err == nil {
 			// Логируем обновление проекта
 			utils.CreateChangeLog(userID, models.EntityProject, project.ID, models.ActionUpdate, oldProject, project)
@@ -130,4 +127,16 @@ func (ct *ProjectPaymentController) Delete(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Не удалось удалить платеж"})
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Платеж удален"})
-} 
\ No newline at end of file
+}
+
+// applyProjectPayment учитывает платеж в текущей сумме проекта, а с revert - отменяет его
+func applyProjectPayment(project *models.Project, payment models.ProjectPayment, revert bool) {
+	if revert {
+		project.CurrentAmount -= payment.Amount
+	} else {
+		project.CurrentAmount += payment.Amount
+	}
+	if project.CurrentAmount < 0 {
+		project.CurrentAmount = 0 // Не позволяем отрицательные значения
+	}
+}
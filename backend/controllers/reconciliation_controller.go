@@ -0,0 +1,470 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// ReconciliationController контроллер для сверки счетов с банковскими выписками
+type ReconciliationController struct{}
+
+// NewReconciliationController создает новый контроллер сверок
+func NewReconciliationController() *ReconciliationController {
+	return &ReconciliationController{}
+}
+
+// GetReconciliations получает сверки пользователя, начиная с последних (можно отфильтровать по account_id)
+func (rc *ReconciliationController) GetReconciliations(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	query := db.DB.Where("user_id = ?", userID)
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+
+	var reconciliations []models.Reconciliation
+	if err := query.Preload("Account").Order("end_date DESC, id DESC").Find(&reconciliations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить сверки",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   reconciliations,
+	})
+}
+
+// GetReconciliation получает сверку с остатком по отмеченным транзакциям, разницей с выпиской
+// и транзакциями, которые можно отметить
+func (rc *ReconciliationController) GetReconciliation(c *fiber.Ctx) error {
+	reconciliation, err := findReconciliation(c.Params("id"), middlewares.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	return sendReconciliationSummary(c, fiber.StatusOK, "", reconciliation)
+}
+
+// CreateReconciliation начинает сверку счета с выпиской за период
+func (rc *ReconciliationController) CreateReconciliation(c *fiber.Ctx) error {
+	var input models.ReconciliationDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	if errors := validateReconciliation(input); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if err := checkAccountOwnership(&input.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	var openCount int64
+	db.DB.Model(&models.Reconciliation{}).Where("account_id = ? AND status = ?", input.AccountID, models.ReconciliationOpen).Count(&openCount)
+	if openCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "По счету уже есть незавершенная сверка",
+		})
+	}
+
+	startDate, endDate := reconciliationPeriod(input)
+	reconciliation := models.Reconciliation{
+		UserID:         userID,
+		AccountID:      input.AccountID,
+		StartDate:      startDate,
+		EndDate:        endDate,
+		ClosingBalance: input.ClosingBalance,
+		Status:         models.ReconciliationOpen,
+	}
+
+	if err := db.DB.Create(&reconciliation).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось начать сверку",
+			"error":   err.Error(),
+		})
+	}
+
+	return sendReconciliationSummary(c, fiber.StatusCreated, "Сверка успешно начата", reconciliation)
+}
+
+// UpdateReconciliation изменяет период и остаток по выписке незавершенной сверки.
+// Отметки транзакций, которые оказались позже новой даты окончания, снимаются.
+func (rc *ReconciliationController) UpdateReconciliation(c *fiber.Ctx) error {
+	var input models.ReconciliationDTO
+	userID := middlewares.GetUserID(c)
+
+	reconciliation, err := findReconciliation(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	if reconciliation.Status != models.ReconciliationOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Завершенную сверку нельзя изменить",
+		})
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	// Счет сверки не меняется
+	input.AccountID = reconciliation.AccountID
+	if errors := validateReconciliation(input); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	reconciliation.StartDate, reconciliation.EndDate = reconciliationPeriod(input)
+	reconciliation.ClosingBalance = input.ClosingBalance
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).
+			Where("reconciliation_id = ? AND cleared_status = ? AND date > ?", reconciliation.ID, models.ClearedPending, reconciliation.EndDate).
+			Updates(map[string]interface{}{"cleared_status": models.ClearedNone, "reconciliation_id": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&reconciliation).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить сверку",
+			"error":   err.Error(),
+		})
+	}
+
+	return sendReconciliationSummary(c, fiber.StatusOK, "Сверка успешно обновлена", reconciliation)
+}
+
+// MarkTransactions отмечает транзакции, найденные в выписке, или снимает с них отметку.
+// Отметить можно несверенные транзакции счета не позже даты окончания выписки.
+func (rc *ReconciliationController) MarkTransactions(c *fiber.Ctx) error {
+	var input models.ReconciliationMarkDTO
+	userID := middlewares.GetUserID(c)
+
+	reconciliation, err := findReconciliation(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	if reconciliation.Status != models.ReconciliationOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка уже завершена",
+		})
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	transactionIDs := uniqueIDs(input.TransactionIDs)
+	var transactions []models.Transaction
+	if err := db.DB.Where("id IN ? AND user_id = ?", transactionIDs, userID).Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось найти транзакции",
+			"error":   err.Error(),
+		})
+	}
+	if len(transactions) != len(transactionIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некоторые транзакции не найдены или не принадлежат пользователю",
+		})
+	}
+
+	for _, t := range transactions {
+		onAccount := (t.AccountID != nil && *t.AccountID == reconciliation.AccountID) ||
+			(t.ToAccountID != nil && *t.ToAccountID == reconciliation.AccountID)
+		if !onAccount || t.Date.After(reconciliation.EndDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция %d не относится к счету сверки или проведена после окончания выписки", t.ID),
+			})
+		}
+		if t.ClearedStatus == models.ClearedReconciled {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция %d уже сверена", t.ID),
+			})
+		}
+		// Перевод может быть отмечен в сверке другого счета
+		if t.ReconciliationID != nil && *t.ReconciliationID != reconciliation.ID {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция %d отмечена в другой сверке", t.ID),
+			})
+		}
+	}
+
+	updates := map[string]interface{}{"cleared_status": models.ClearedNone, "reconciliation_id": nil}
+	if input.Cleared {
+		updates = map[string]interface{}{"cleared_status": models.ClearedPending, "reconciliation_id": reconciliation.ID}
+	}
+	if err := db.DB.Model(&models.Transaction{}).Where("id IN ? AND user_id = ?", transactionIDs, userID).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось отметить транзакции",
+			"error":   err.Error(),
+		})
+	}
+
+	return sendReconciliationSummary(c, fiber.StatusOK, "", reconciliation)
+}
+
+// FinishReconciliation завершает сверку, если остаток по отмеченным транзакциям совпал с выпиской.
+// Отмеченные транзакции становятся сверенными и защищаются от изменений.
+func (rc *ReconciliationController) FinishReconciliation(c *fiber.Ctx) error {
+	reconciliation, err := findReconciliation(c.Params("id"), middlewares.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	if reconciliation.Status != models.ReconciliationOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка уже завершена",
+		})
+	}
+
+	summary, err := reconciliationSummary(reconciliation)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать остаток по сверке",
+			"error":   err.Error(),
+		})
+	}
+	if summary.Difference != 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("Остаток по отмеченным транзакциям отличается от выписки на %.2f", summary.Difference),
+		})
+	}
+
+	now := time.Now()
+	reconciliation.Status = models.ReconciliationCompleted
+	reconciliation.CompletedAt = &now
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).
+			Where("reconciliation_id = ? AND cleared_status = ?", reconciliation.ID, models.ClearedPending).
+			Update("cleared_status", models.ClearedReconciled).Error; err != nil {
+			return err
+		}
+		return tx.Save(&reconciliation).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось завершить сверку",
+			"error":   err.Error(),
+		})
+	}
+
+	return sendReconciliationSummary(c, fiber.StatusOK, "Сверка успешно завершена", reconciliation)
+}
+
+// DeleteReconciliation отменяет незавершенную сверку и снимает отметки с ее транзакций
+func (rc *ReconciliationController) DeleteReconciliation(c *fiber.Ctx) error {
+	reconciliation, err := findReconciliation(c.Params("id"), middlewares.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Сверка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	if reconciliation.Status != models.ReconciliationOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Завершенную сверку нельзя отменить",
+		})
+	}
+
+	// Отметки снимаются и с транзакций в корзине, чтобы на сверку не осталось ссылок
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Transaction{}).Where("reconciliation_id = ?", reconciliation.ID).
+			Updates(map[string]interface{}{"cleared_status": models.ClearedNone, "reconciliation_id": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&reconciliation).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось отменить сверку",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Сверка отменена",
+	})
+}
+
+// findReconciliation находит сверку пользователя по ID из параметра маршрута
+func findReconciliation(id string, userID uint) (models.Reconciliation, error) {
+	var reconciliation models.Reconciliation
+	err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Account").First(&reconciliation).Error
+	return reconciliation, err
+}
+
+// validateReconciliation проверяет данные сверки
+func validateReconciliation(input models.ReconciliationDTO) []utils.ValidationError {
+	errors := utils.ValidateStruct(input)
+	if !input.StartDate.IsZero() && input.EndDate.Before(input.StartDate) {
+		errors = append(errors, utils.ValidationError{Field: "endDate", Message: "Дата окончания не может быть раньше даты начала"})
+	}
+	return errors
+}
+
+// reconciliationPeriod возвращает период выписки с начала первого до конца последнего дня
+func reconciliationPeriod(input models.ReconciliationDTO) (time.Time, time.Time) {
+	year, month, day := input.StartDate.Date()
+	startDate := time.Date(year, month, day, 0, 0, 0, 0, input.StartDate.Location())
+	year, month, day = input.EndDate.Date()
+	endDate := time.Date(year, month, day, 0, 0, 0, 0, input.EndDate.Location()).Add(24*time.Hour - time.Nanosecond)
+	return startDate, endDate
+}
+
+// reconciliationSummary рассчитывает остаток по сверенным и отмеченным транзакциям счета на конец
+// выписки и загружает транзакции сверки: для незавершенной - все несверенные до конца выписки,
+// для завершенной - сверенные в ней
+func reconciliationSummary(reconciliation models.Reconciliation) (models.ReconciliationSummary, error) {
+	summary := models.ReconciliationSummary{Reconciliation: reconciliation}
+
+	var account models.Account
+	if err := db.DB.First(&account, reconciliation.AccountID).Error; err != nil {
+		return summary, err
+	}
+
+	balance, err := filteredAccountBalance(account, reconciliation.EndDate,
+		"t.cleared_status = ? OR t.reconciliation_id = ?", models.ClearedReconciled, reconciliation.ID)
+	if err != nil {
+		return summary, err
+	}
+	summary.ClearedBalance = math.Round(balance.Balance*100) / 100
+	summary.Difference = math.Round((reconciliation.ClosingBalance-balance.Balance)*100) / 100
+
+	query := db.DB.Where("user_id = ?", reconciliation.UserID)
+	if reconciliation.Status == models.ReconciliationOpen {
+		query = query.Where("(account_id = ? OR to_account_id = ?) AND date <= ? AND cleared_status <> ?",
+			account.ID, account.ID, reconciliation.EndDate, models.ClearedReconciled)
+	} else {
+		query = query.Where("reconciliation_id = ?", reconciliation.ID)
+	}
+
+	var transactions []models.Transaction
+	if err := query.Preload("Category").Preload("Account").Preload("ToAccount").
+		Order("date ASC, id ASC").Find(&transactions).Error; err != nil {
+		return summary, err
+	}
+
+	// Изменение остатка пересчитывается в валюту счета, как в истории операций по счету
+	summary.Transactions = make([]models.ReconciliationEntry, 0, len(transactions))
+	rates := rateCache{}
+	for _, t := range transactions {
+		rate, err := rates.get(t.Currency, account.Currency, t.Date)
+		if err != nil {
+			return summary, err
+		}
+		summary.Transactions = append(summary.Transactions, models.ReconciliationEntry{
+			Transaction: t,
+			Change:      accountBalanceChange(t, account.ID) * rate,
+		})
+	}
+	return summary, nil
+}
+
+// sendReconciliationSummary отправляет сверку с остатками и транзакциями
+func sendReconciliationSummary(c *fiber.Ctx, status int, message string, reconciliation models.Reconciliation) error {
+	summary, err := reconciliationSummary(reconciliation)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось рассчитать остаток по сверке",
+			"error":   err.Error(),
+		})
+	}
+
+	response := fiber.Map{
+		"status": "success",
+		"data":   summary,
+	}
+	if message != "" {
+		response["message"] = message
+	}
+	return c.Status(status).JSON(response)
+}
+
+// reconciledLocked проверяет, защищена ли транзакция от изменений завершенной сверкой.
+// Защиту снимает только явный параметр запроса override_lock=true.
+func reconciledLocked(c *fiber.Ctx, t models.Transaction) bool {
+	return t.ClearedStatus == models.ClearedReconciled && !c.QueryBool("override_lock")
+}
+
+// sendReconciledLocked отправляет ошибку изменения сверенной транзакции
+func sendReconciledLocked(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Транзакция сверена с выпиской и защищена от изменений. Чтобы изменить ее, передайте override_lock=true",
+	})
+}
@@ -89,11 +89,21 @@ func (rc *RecurringController) CreateRule(c *fiber.Ctx) error {
 		})
 	}
 
+	// Проверяем счет, если он указан
+	if err := checkAccountOwnership(input.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+		})
+	}
+
 	rule := models.RecurringRule{
 		UserID:          userID,
 		Amount:          input.Amount,
+		Currency:        resolveCurrency(input.Currency, input.AccountID, userID),
 		Description:     input.Description,
 		CategoryID:      input.CategoryID,
+		AccountID:       input.AccountID,
 		Frequency:       input.Frequency,
 		StartDate:       input.StartDate,
 		EndDate:         input.EndDate,
@@ -108,6 +118,7 @@ func (rc *RecurringController) CreateRule(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionCreate, nil, rule)
 
 	// Загружаем категорию для ответа
 	db.DB.Preload("Category").First(&rule, rule.ID)
@@ -148,11 +159,25 @@ func (rc *RecurringController) UpdateRule(c *fiber.Ctx) error {
 			"message": "Правило не найдено",
 		})
 	}
+	oldRule := rule
+
+	// Проверяем счет, если он указан
+	if err := checkAccountOwnership(input.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден",
+		})
+	}
 
 	// Обновляем поля
 	rule.Amount = input.Amount
+	// Если валюта не передана, сохраняем текущую валюту правила
+	if input.Currency != "" {
+		rule.Currency = resolveCurrency(input.Currency, nil, userID)
+	}
 	rule.Description = input.Description
 	rule.CategoryID = input.CategoryID
+	rule.AccountID = input.AccountID
 	rule.Frequency = input.Frequency
 	rule.StartDate = input.StartDate
 	rule.EndDate = input.EndDate
@@ -164,6 +189,7 @@ func (rc *RecurringController) UpdateRule(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionUpdate, oldRule, rule)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
@@ -184,6 +210,7 @@ func (rc *RecurringController) ToggleRule(c *fiber.Ctx) error {
 			"message": "Правило не найдено",
 		})
 	}
+	oldRule := rule
 
 	rule.IsActive = !rule.IsActive
 
@@ -194,6 +221,7 @@ func (rc *RecurringController) ToggleRule(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionUpdate, oldRule, rule)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
@@ -202,7 +230,7 @@ func (rc *RecurringController) ToggleRule(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteRule удаляет правило
+// DeleteRule перемещает правило регулярного платежа в корзину
 func (rc *RecurringController) DeleteRule(c *fiber.Ctx) error {
 	id := c.Params("id")
 	userID := middlewares.GetUserID(c)
@@ -222,10 +250,11 @@ func (rc *RecurringController) DeleteRule(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionDelete, rule, nil)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Правило удалено",
+		"message": "Правило перемещено в корзину",
 	})
 }
 
@@ -250,9 +279,12 @@ func (rc *RecurringController) ProcessRecurringTransactions(c *fiber.Ctx) error
 			// Создаем транзакцию
 			transaction := models.Transaction{
 				Amount:          rule.Amount,
+				Currency:        rule.Currency,
 				Description:     rule.Description,
 				Date:            rule.NextExecuteDate,
-				CategoryID:      rule.CategoryID,
+				Kind:            models.KindRegular,
+				CategoryID:      &rule.CategoryID,
+				AccountID:       rule.AccountID,
 				UserID:          rule.UserID,
 				RecurringRuleID: &rule.ID,
 				IsRecurring:     true,
@@ -261,6 +293,7 @@ func (rc *RecurringController) ProcessRecurringTransactions(c *fiber.Ctx) error
 			if err := db.DB.Create(&transaction).Error; err != nil {
 				continue // Пропускаем если не удалось создать
 			}
+			utils.CreateChangeLog(rule.UserID, models.EntityTransaction, transaction.ID, models.ActionCreate, nil, transaction)
 
 			// Обновляем следующую дату выполнения
 			rule.NextExecuteDate = rule.CalculateNextExecuteDate()
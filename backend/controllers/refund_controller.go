@@ -0,0 +1,509 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// RefundController контроллер для возвратов по расходам
+type RefundController struct{}
+
+// NewRefundController создает новый контроллер возвратов
+func NewRefundController() *RefundController {
+	return &RefundController{}
+}
+
+// GetAllRefunds получает возвраты пользователя с возможностью фильтрации по расходу, счету и периоду
+func (rfc *RefundController) GetAllRefunds(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	query := db.DB.Where("user_id = ? AND kind = ?", userID, models.KindRefund)
+
+	if transactionID := c.Query("transaction_id"); transactionID != "" {
+		query = query.Where("refund_of_id = ?", transactionID)
+	}
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		query = query.Where("date >= ?", parseDateParam(startDateStr, true))
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		query = query.Where("date <= ?", parseDateParam(endDateStr, false))
+	}
+
+	var refunds []models.Transaction
+	if err := query.Preload("Category").Preload("Account").
+		Order("date DESC").
+		Find(&refunds).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить возвраты",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   refunds,
+	})
+}
+
+// CreateRefund создает полный или частичный возврат по расходу
+func (rfc *RefundController) CreateRefund(c *fiber.Ctx) error {
+	var input models.RefundDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	var original models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ?", input.TransactionID, userID).Preload("Splits").First(&original).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Исходный расход не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	refund := models.Transaction{
+		Kind:       models.KindRefund,
+		RefundOfID: &original.ID,
+		UserID:     userID,
+	}
+	if rerr := applyRefundInput(&refund, original, input, 0); rerr != nil {
+		return rerr.send(c)
+	}
+
+	if err := db.DB.Create(&refund).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать возврат",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, refund.ID, models.ActionCreate, nil, refund)
+
+	if err := refreshBudgets(userID, transactionCategoryIDs(refund), refund.Date, refund.Date); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после возврата")
+	}
+
+	// Загружаем категорию и счет для ответа
+	db.DB.Preload("Category").Preload("Account").First(&refund, refund.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Возврат успешно создан",
+		"data":    refund,
+	})
+}
+
+// UpdateRefund обновляет возврат. Исходный расход возврата не меняется.
+func (rfc *RefundController) UpdateRefund(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var refund models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ? AND kind = ?", id, userID, models.KindRefund).First(&refund).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Возврат не найден",
+			"error":   err.Error(),
+		})
+	}
+	oldRefund := refund
+
+	if reconciledLocked(c, refund) {
+		return sendReconciledLocked(c)
+	}
+
+	var input models.RefundDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	var original models.Transaction
+	if refund.RefundOfID == nil || db.DB.Where("id = ? AND user_id = ?", *refund.RefundOfID, userID).Preload("Splits").First(&original).Error != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Исходный расход удален, возврат можно только удалить",
+		})
+	}
+	input.TransactionID = original.ID
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if rerr := applyRefundInput(&refund, original, input, 0); rerr != nil {
+		return rerr.send(c)
+	}
+
+	if err := db.DB.Save(&refund).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить возврат",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, refund.ID, models.ActionUpdate, oldRefund, refund)
+
+	// Пересчитываем бюджеты по старой и новой категории и дате возврата
+	if err := refreshBudgets(userID, transactionCategoryIDs(oldRefund), oldRefund.Date, oldRefund.Date); err != nil {
+		logError(err, "Ошибка при обновлении старых бюджетов")
+	}
+	if err := refreshBudgets(userID, transactionCategoryIDs(refund), refund.Date, refund.Date); err != nil {
+		logError(err, "Ошибка при обновлении новых бюджетов")
+	}
+
+	// Загружаем категорию и счет для ответа
+	db.DB.Preload("Category").Preload("Account").First(&refund, refund.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Возврат успешно обновлен",
+		"data":    refund,
+	})
+}
+
+// DeleteRefund перемещает возврат в корзину
+func (rfc *RefundController) DeleteRefund(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var refund models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ? AND kind = ?", id, userID, models.KindRefund).First(&refund).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Возврат не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	if reconciledLocked(c, refund) {
+		return sendReconciledLocked(c)
+	}
+
+	if err := db.DB.Delete(&refund).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить возврат",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, refund.ID, models.ActionDelete, refund, nil)
+
+	if err := refreshBudgets(userID, transactionCategoryIDs(refund), refund.Date, refund.Date); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после удаления возврата")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Возврат перемещен в корзину",
+	})
+}
+
+// applyRefundInput проверяет данные возврата по исходному расходу и переносит их в транзакцию возврата.
+// Возврат получает валюту и получателя расхода и его категорию (для разделенного расхода - одну из
+// категорий частей). Сумма всех возвратов не может превышать сумму расхода, а для разделенного
+// расхода - сумму части с категорией возврата. pending - сумма других возвратов по тому же
+// расходу и категории, которые сохраняются вместе с этим и еще не записаны в базу.
+func applyRefundInput(refund *models.Transaction, original models.Transaction, input models.RefundDTO, pending float64) *requestError {
+	if original.Kind != models.KindRegular {
+		return &requestError{fiber.StatusBadRequest, "Возврат можно оформить только по обычному расходу", nil}
+	}
+
+	categoryID := input.CategoryID
+	limit := original.Amount
+	var limitCategoryID *uint
+	if original.IsSplit() {
+		if categoryID == 0 {
+			return &requestError{fiber.StatusBadRequest, "Для разделенного расхода укажите категорию возврата", nil}
+		}
+		found := false
+		limit, limitCategoryID = 0, &categoryID
+		for _, split := range original.Splits {
+			if split.CategoryID == categoryID {
+				found = true
+				limit += split.Amount
+			}
+		}
+		if !found {
+			return &requestError{fiber.StatusBadRequest, "Категория возврата должна быть одной из категорий расхода", nil}
+		}
+	} else {
+		if original.CategoryID == nil || (categoryID != 0 && categoryID != *original.CategoryID) {
+			return &requestError{fiber.StatusBadRequest, "Категория возврата должна совпадать с категорией расхода", nil}
+		}
+		categoryID = *original.CategoryID
+	}
+
+	var category models.Category
+	if err := db.DB.Where("id = ? AND user_id = ?", categoryID, original.UserID).First(&category).Error; err != nil {
+		return &requestError{fiber.StatusBadRequest, "Категория не найдена или не принадлежит пользователю", err}
+	}
+	if category.Type != models.Expense {
+		return &requestError{fiber.StatusBadRequest, "Возврат можно оформить только по расходу", nil}
+	}
+
+	accountID := input.AccountID
+	if accountID == nil {
+		accountID = original.AccountID
+	}
+	if err := checkAccountOwnership(accountID, original.UserID); err != nil {
+		return &requestError{fiber.StatusBadRequest, "Счет не найден или не принадлежит пользователю", err}
+	}
+
+	// Устанавливаем время на 12:00 дня, сохраняя дату
+	year, month, day := input.Date.Date()
+	date := time.Date(year, month, day, 12, 0, 0, 0, input.Date.Location())
+	if date.Before(original.Date) {
+		return &requestError{fiber.StatusBadRequest, "Дата возврата не может быть раньше даты расхода", nil}
+	}
+
+	refunded, err := refundedAmount(original.ID, refund.ID, limitCategoryID)
+	if err != nil {
+		return &requestError{fiber.StatusInternalServerError, "Не удалось посчитать сумму возвратов", err}
+	}
+	if remaining := limit - refunded - pending; input.Amount > remaining+0.005 {
+		if limitCategoryID != nil {
+			return &requestError{fiber.StatusBadRequest,
+				fmt.Sprintf("Сумма возвратов превышает сумму расхода в категории «%s»: можно вернуть не больше %.2f %s", category.Name, remaining, original.Currency), nil}
+		}
+		return &requestError{fiber.StatusBadRequest,
+			fmt.Sprintf("Сумма возвратов превышает сумму расхода: можно вернуть не больше %.2f %s", remaining, original.Currency), nil}
+	}
+
+	description := input.Description
+	if description == "" {
+		description = original.Description
+	}
+
+	refund.Amount = input.Amount
+	refund.Currency = original.Currency
+	refund.Description = description
+	refund.Note = input.Note
+	refund.Date = date
+	refund.CategoryID = &categoryID
+	refund.AccountID = accountID
+	refund.PayeeID = original.PayeeID
+	return nil
+}
+
+// refundInput возвращает данные сохраненного возврата в виде запроса, чтобы проверить их
+// через applyRefundInput при восстановлении возврата из корзины или истории изменений.
+// Категория сохраняется только у возврата по разделенному расходу, иначе возврат получает
+// текущую категорию расхода.
+func refundInput(refund, original models.Transaction) models.RefundDTO {
+	input := models.RefundDTO{
+		TransactionID: original.ID,
+		Amount:        refund.Amount,
+		Date:          refund.Date,
+		Description:   refund.Description,
+		AccountID:     refund.AccountID,
+		Note:          refund.Note,
+	}
+	if original.IsSplit() && refund.CategoryID != nil {
+		input.CategoryID = *refund.CategoryID
+	}
+	return input
+}
+
+// refundedAmount возвращает сумму возвратов по расходу без учета указанного возврата
+// (0 - учитываются все возвраты). Если указана категория, учитываются только возвраты по ней.
+func refundedAmount(originalID, excludeID uint, categoryID *uint) (float64, error) {
+	query := db.DB.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("refund_of_id = ? AND kind = ? AND id <> ?", originalID, models.KindRefund, excludeID)
+	if categoryID != nil {
+		query = query.Where("category_id = ?", *categoryID)
+	}
+
+	var sum float64
+	err := query.Row().Scan(&sum)
+	return sum, err
+}
+
+// fillRefundTotals заполняет у расходов с возвратами сумму возвратов и сумму за вычетом возвратов
+func fillRefundTotals(transactions []models.Transaction) error {
+	ids := make([]uint, 0, len(transactions))
+	for _, t := range transactions {
+		if t.Kind == models.KindRegular {
+			ids = append(ids, t.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var totals []struct {
+		RefundOfID uint
+		Sum        float64
+	}
+	if err := db.DB.Model(&models.Transaction{}).
+		Select("refund_of_id, SUM(amount) AS sum").
+		Where("refund_of_id IN ? AND kind = ?", ids, models.KindRefund).
+		Group("refund_of_id").
+		Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	sums := make(map[uint]float64, len(totals))
+	for _, total := range totals {
+		sums[total.RefundOfID] = total.Sum
+	}
+	for i := range transactions {
+		if sum, ok := sums[transactions[i].ID]; ok {
+			refunded := sum
+			net := transactions[i].Amount - sum
+			transactions[i].RefundedAmount = &refunded
+			transactions[i].NetAmount = &net
+		}
+	}
+	return nil
+}
+
+// checkRefundedExpense проверяет, что изменение расхода не противоречит его возвратам:
+// расход остается расходом и его сумма не меньше суммы возвратов
+func checkRefundedExpense(transaction models.Transaction, amount float64, category models.Category) *requestError {
+	refunded, err := refundedAmount(transaction.ID, 0, nil)
+	if err != nil {
+		return &requestError{fiber.StatusInternalServerError, "Не удалось посчитать сумму возвратов", err}
+	}
+	if refunded == 0 {
+		return nil
+	}
+	if category.Type != models.Expense {
+		return &requestError{fiber.StatusBadRequest, "По транзакции есть возвраты, поэтому она должна оставаться расходом", nil}
+	}
+	if amount < refunded-0.005 {
+		return &requestError{fiber.StatusBadRequest,
+			fmt.Sprintf("Сумма расхода не может быть меньше суммы возвратов по нему (%.2f)", refunded), nil}
+	}
+	return nil
+}
+
+// syncRefunds переносит на возвраты валюту измененного расхода и его категорию (если расход
+// не разделен) и пересчитывает бюджеты за период возвратов
+func syncRefunds(original models.Transaction, oldCategoryIDs []uint) error {
+	var refunds []models.Transaction
+	if err := db.DB.Where("refund_of_id = ? AND kind = ?", original.ID, models.KindRefund).Find(&refunds).Error; err != nil {
+		return err
+	}
+	if len(refunds) == 0 {
+		return nil
+	}
+
+	updates := map[string]interface{}{"currency": original.Currency}
+	if !original.IsSplit() && original.CategoryID != nil {
+		updates["category_id"] = *original.CategoryID
+	}
+	if err := db.DB.Model(&models.Transaction{}).Where("refund_of_id = ? AND kind = ?", original.ID, models.KindRefund).
+		Updates(updates).Error; err != nil {
+		return err
+	}
+
+	from, to := refunds[0].Date, refunds[0].Date
+	categoryIDs := append(oldCategoryIDs, transactionCategoryIDs(original)...)
+	for _, refund := range refunds {
+		if refund.Date.Before(from) {
+			from = refund.Date
+		}
+		if refund.Date.After(to) {
+			to = refund.Date
+		}
+		categoryIDs = append(categoryIDs, transactionCategoryIDs(refund)...)
+	}
+	return refreshBudgets(original.UserID, uniqueIDs(categoryIDs), from, to)
+}
+
+// checkShiftedRefunds проверяет, что сдвиг дат транзакций на days дней не ставит возврат раньше
+// его расхода. Проверяются сдвигаемые возвраты и возвраты сдвигаемых расходов, причем транзакции,
+// которые сдвигаются вместе, сравниваются по новым датам.
+func checkShiftedRefunds(transactions []models.Transaction, days int) *requestError {
+	shifted := make(map[uint]bool, len(transactions))
+	byID := make(map[uint]models.Transaction, len(transactions))
+	ids := make([]uint, 0, len(transactions))
+	var originalIDs []uint
+	for _, t := range transactions {
+		shifted[t.ID] = true
+		byID[t.ID] = t
+		ids = append(ids, t.ID)
+		if t.IsRefund() && t.RefundOfID != nil {
+			originalIDs = append(originalIDs, *t.RefundOfID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var refunds, originals []models.Transaction
+	if err := db.DB.Where("refund_of_id IN ? AND kind = ? AND id NOT IN ?", ids, models.KindRefund, ids).Find(&refunds).Error; err != nil {
+		return &requestError{fiber.StatusInternalServerError, "Не удалось найти возвраты", err}
+	}
+	if len(originalIDs) > 0 {
+		if err := db.DB.Where("id IN ? AND id NOT IN ?", uniqueIDs(originalIDs), ids).Find(&originals).Error; err != nil {
+			return &requestError{fiber.StatusInternalServerError, "Не удалось найти исходные расходы", err}
+		}
+	}
+	for _, original := range originals {
+		byID[original.ID] = original
+	}
+
+	date := func(t models.Transaction) time.Time {
+		if shifted[t.ID] {
+			return t.Date.AddDate(0, 0, days)
+		}
+		return t.Date
+	}
+	for _, refund := range append(refunds, transactions...) {
+		if !refund.IsRefund() || refund.RefundOfID == nil {
+			continue
+		}
+		original, ok := byID[*refund.RefundOfID]
+		if ok && date(refund).Before(date(original)) {
+			return &requestError{fiber.StatusBadRequest,
+				fmt.Sprintf("После сдвига даты возврат %d окажется раньше своего расхода %d", refund.ID, original.ID), nil}
+		}
+	}
+	return nil
+}
+
+// activeRefundsOutside считает возвраты по указанным расходам, которые сами не входят в список
+func activeRefundsOutside(ids []uint) (int64, error) {
+	var count int64
+	err := db.DB.Model(&models.Transaction{}).
+		Where("refund_of_id IN ? AND kind = ? AND id NOT IN ?", ids, models.KindRefund, ids).
+		Count(&count).Error
+	return count, err
+}
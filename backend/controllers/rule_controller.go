@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// maxRuleChanges сколько изменений возвращается в ответе при применении правил к истории
+const maxRuleChanges = 500
+
+// RuleController контроллер для правил автоматической обработки транзакций
+type RuleController struct{}
+
+// NewRuleController создает новый контроллер правил
+func NewRuleController() *RuleController {
+	return &RuleController{}
+}
+
+// RuleChange изменение транзакции при применении правил
+type RuleChange struct {
+	TransactionID uint      `json:"transactionId"`
+	Date          time.Time `json:"date"`
+	Description   string    `json:"description"`
+	Amount        float64   `json:"amount"`
+	RuleIDs       []uint    `json:"ruleIds"`
+	OldCategoryID *uint     `json:"oldCategoryId"`
+	NewCategoryID *uint     `json:"newCategoryId,omitempty"` // только если категория меняется
+	Note          *string   `json:"note,omitempty"`          // только если заметка меняется
+	Reviewed      bool      `json:"reviewed,omitempty"`      // транзакция будет отмечена как проверенная
+}
+
+// GetAllRules получает правила пользователя в порядке выполнения
+func (rc *RuleController) GetAllRules(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var rules []models.TransactionRule
+	if err := db.DB.Where("user_id = ?", userID).Preload("Category").Order("priority, id").Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить правила",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   rules,
+	})
+}
+
+// GetRuleByID получает правило по ID
+func (rc *RuleController) GetRuleByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var rule models.TransactionRule
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Category").First(&rule).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Правило не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   rule,
+	})
+}
+
+// CreateRule создает правило
+func (rc *RuleController) CreateRule(c *fiber.Ctx) error {
+	var input models.TransactionRuleDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	rule := models.TransactionRule{UserID: userID}
+	if reqErr := fillTransactionRule(&rule, input); reqErr != nil {
+		return reqErr.send(c)
+	}
+
+	if err := db.DB.Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать правило",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransactionRule, rule.ID, models.ActionCreate, nil, rule)
+
+	db.DB.Preload("Category").First(&rule, rule.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Правило успешно создано",
+		"data":    rule,
+	})
+}
+
+// UpdateRule обновляет правило. Уже обработанные транзакции не меняются,
+// для этого правило нужно применить к истории.
+func (rc *RuleController) UpdateRule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var rule models.TransactionRule
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Правило не найдено",
+			"error":   err.Error(),
+		})
+	}
+	oldRule := rule
+
+	var input models.TransactionRuleDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if reqErr := fillTransactionRule(&rule, input); reqErr != nil {
+		return reqErr.send(c)
+	}
+	rule.Category = nil
+
+	if err := db.DB.Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить правило",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransactionRule, rule.ID, models.ActionUpdate, oldRule, rule)
+
+	db.DB.Preload("Category").First(&rule, rule.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Правило успешно обновлено",
+		"data":    rule,
+	})
+}
+
+// DeleteRule удаляет правило. Изменения, уже внесенные правилом в транзакции, сохраняются.
+func (rc *RuleController) DeleteRule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var rule models.TransactionRule
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Правило не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Delete(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить правило",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransactionRule, rule.ID, models.ActionDelete, rule, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Правило успешно удалено",
+	})
+}
+
+// ApplyRules применяет правила к уже существующим транзакциям. С dryRun изменения только
+// возвращаются, что позволяет проверить правило (в том числе выключенное) перед сохранением.
+// Переводы и проверенные транзакции (если не указан includeReviewed) не меняются,
+// у разделенных транзакций категория не меняется. Сверенные транзакции без override_lock
+// пропускаются, их количество возвращается в skippedLocked. Категория правила назначается только
+// транзакциям того же типа, а возвраты получают новую категорию вместе со своим расходом.
+func (rc *RuleController) ApplyRules(c *fiber.Ctx) error {
+	var input models.ApplyRulesDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	var rules []models.TransactionRule
+	if len(input.RuleIDs) > 0 {
+		ruleIDs := uniqueIDs(input.RuleIDs)
+		if err := db.DB.Where("id IN ? AND user_id = ?", ruleIDs, userID).Preload("Category").Order("priority, id").Find(&rules).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось получить правила",
+				"error":   err.Error(),
+			})
+		}
+		if len(rules) != len(ruleIDs) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Некоторые правила не найдены или не принадлежат пользователю",
+			})
+		}
+		// Явно выбранные правила применяются, даже если они выключены
+		for i := range rules {
+			rules[i].IsActive = true
+		}
+	} else {
+		var err error
+		if rules, err = utils.LoadTransactionRules(userID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось получить правила",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	query := db.DB.Where("user_id = ? AND kind = ?", userID, models.KindRegular)
+	if input.StartDate != nil {
+		query = query.Where("date >= ?", *input.StartDate)
+	}
+	if input.EndDate != nil {
+		query = query.Where("date <= ?", *input.EndDate)
+	}
+	if !input.IncludeReviewed {
+		query = query.Where("reviewed = ?", false)
+	}
+
+	var changes []RuleChange
+	var originals []models.Transaction
+	var batch []models.Transaction
+	skippedLocked := 0
+	if err := query.Preload("Category").Preload("Splits").Preload("Tags").Order("date, id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, t := range batch {
+			change, ok := ruleChange(rules, t)
+			if !ok {
+				continue
+			}
+			if reconciledLocked(c, t) {
+				skippedLocked++
+				continue
+			}
+			changes = append(changes, change)
+			originals = append(originals, t)
+		}
+		return nil
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить транзакции",
+			"error":   err.Error(),
+		})
+	}
+
+	// Правило меняет категорию только на категорию того же типа, поэтому расход с возвратами
+	// остается расходом, а его возвраты получают новую категорию вместе с ним
+	if err := fillRefundTotals(originals); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось посчитать возвраты",
+			"error":   err.Error(),
+		})
+	}
+
+	var changeLogs []models.ChangeLog
+	var refundOfIDs []uint
+	for i, change := range changes {
+		t := originals[i]
+		if change.NewCategoryID != nil && t.RefundedAmount != nil {
+			refundOfIDs = append(refundOfIDs, t.ID)
+		}
+		t.RefundedAmount, t.NetAmount, t.Category = nil, nil, nil
+		if !input.DryRun {
+			changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionUpdate, t, change.apply(t)))
+		}
+	}
+
+	if !input.DryRun && len(changes) > 0 {
+		budgetCategoryIDs := make(map[uint]bool)
+		from, to := changes[0].Date, changes[len(changes)-1].Date
+
+		// Возвраты следуют за категорией расхода, поэтому их периоды тоже пересчитываются
+		var refunds []models.Transaction
+		if len(refundOfIDs) > 0 {
+			if err := db.DB.Where("refund_of_id IN ? AND kind = ?", refundOfIDs, models.KindRefund).Find(&refunds).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Не удалось найти возвраты",
+					"error":   err.Error(),
+				})
+			}
+			for _, refund := range refunds {
+				for _, id := range transactionCategoryIDs(refund) {
+					budgetCategoryIDs[id] = true
+				}
+				if refund.Date.Before(from) {
+					from = refund.Date
+				}
+				if refund.Date.After(to) {
+					to = refund.Date
+				}
+			}
+		}
+		refunded := make(map[uint]bool, len(refundOfIDs))
+		for _, id := range refundOfIDs {
+			refunded[id] = true
+		}
+
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			for _, change := range changes {
+				updates := make(map[string]interface{})
+				if change.NewCategoryID != nil {
+					updates["category_id"] = *change.NewCategoryID
+					budgetCategoryIDs[*change.NewCategoryID] = true
+					if change.OldCategoryID != nil {
+						budgetCategoryIDs[*change.OldCategoryID] = true
+					}
+				}
+				if change.Note != nil {
+					updates["note"] = *change.Note
+				}
+				if change.Reviewed {
+					updates["reviewed"] = true
+				}
+				if err := tx.Model(&models.Transaction{}).Where("id = ? AND user_id = ?", change.TransactionID, userID).Updates(updates).Error; err != nil {
+					return err
+				}
+				if change.NewCategoryID != nil && refunded[change.TransactionID] {
+					if err := tx.Model(&models.Transaction{}).Where("refund_of_id = ? AND kind = ?", change.TransactionID, models.KindRefund).
+						Update("category_id", *change.NewCategoryID).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось применить правила",
+				"error":   err.Error(),
+			})
+		}
+		if err := utils.CreateChangeLogs(changeLogs); err != nil {
+			logError(err, "Ошибка при записи истории изменений")
+		}
+
+		if len(budgetCategoryIDs) > 0 {
+			ids := make([]uint, 0, len(budgetCategoryIDs))
+			for id := range budgetCategoryIDs {
+				ids = append(ids, id)
+			}
+			if err := refreshBudgets(userID, ids, from, to); err != nil {
+				logError(err, "Ошибка при обновлении бюджетов после применения правил")
+			}
+		}
+	}
+
+	total := len(changes)
+	if len(changes) > maxRuleChanges {
+		changes = changes[:maxRuleChanges]
+	}
+	if changes == nil {
+		changes = []RuleChange{}
+	}
+
+	message := fmt.Sprintf("Правила изменили %d транзакций", total)
+	if input.DryRun {
+		message = fmt.Sprintf("Правила изменят %d транзакций", total)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data": fiber.Map{
+			"dryRun":        input.DryRun,
+			"total":         total,
+			"changes":       changes,
+			"skippedLocked": skippedLocked,
+		},
+	})
+}
+
+// fillTransactionRule переносит данные из запроса в правило и проверяет их: у правила должно быть
+// хотя бы одно условие и одно действие, регулярное выражение должно компилироваться,
+// а категория - принадлежать пользователю
+func fillTransactionRule(rule *models.TransactionRule, input models.TransactionRuleDTO) *requestError {
+	if input.DescriptionContains == "" && input.DescriptionRegex == "" && input.MinAmount == nil && input.MaxAmount == nil && len(input.Weekdays) == 0 {
+		return &requestError{fiber.StatusBadRequest, "Укажите хотя бы одно условие правила", nil}
+	}
+	if input.CategoryID == nil && input.Note == "" && !input.MarkReviewed {
+		return &requestError{fiber.StatusBadRequest, "Укажите хотя бы одно действие правила", nil}
+	}
+	if input.MinAmount != nil && input.MaxAmount != nil && *input.MaxAmount < *input.MinAmount {
+		return &requestError{fiber.StatusBadRequest, "Максимальная сумма не может быть меньше минимальной", nil}
+	}
+	if input.CategoryID != nil {
+		var category models.Category
+		if err := db.DB.Where("id = ? AND user_id = ?", *input.CategoryID, rule.UserID).First(&category).Error; err != nil {
+			return &requestError{fiber.StatusBadRequest, "Категория не найдена или не принадлежит пользователю", err}
+		}
+	}
+
+	rule.Name = input.Name
+	rule.Priority = input.Priority
+	rule.IsActive = input.IsActive == nil || *input.IsActive
+	rule.DescriptionContains = input.DescriptionContains
+	rule.DescriptionRegex = input.DescriptionRegex
+	rule.MinAmount = input.MinAmount
+	rule.MaxAmount = input.MaxAmount
+	rule.Weekdays = input.Weekdays
+	rule.CategoryID = input.CategoryID
+	rule.Note = input.Note
+	rule.MarkReviewed = input.MarkReviewed
+
+	if err := rule.Compile(); err != nil {
+		return &requestError{fiber.StatusBadRequest, "Некорректное регулярное выражение", err}
+	}
+	return nil
+}
+
+// ruleChange вычисляет, как правила изменят существующую транзакцию с загруженной категорией.
+// Категория меняется только на категорию того же типа, заметка записывается только в транзакции без заметки.
+func ruleChange(rules []models.TransactionRule, t models.Transaction) (RuleChange, bool) {
+	var categoryType models.CategoryType
+	if t.Category != nil {
+		categoryType = t.Category.Type
+	}
+	result := models.EvaluateRules(rules, t.Description, t.Amount, t.Date, categoryType)
+	change := RuleChange{
+		TransactionID: t.ID,
+		Date:          t.Date,
+		Description:   t.Description,
+		Amount:        t.Amount,
+		RuleIDs:       result.RuleIDs,
+		OldCategoryID: t.CategoryID,
+	}
+
+	changed := false
+	if result.CategoryID != nil && !t.IsSplit() && (t.CategoryID == nil || *t.CategoryID != *result.CategoryID) {
+		change.NewCategoryID = result.CategoryID
+		changed = true
+	}
+	if result.Note != nil && t.Note == "" {
+		change.Note = result.Note
+		changed = true
+	}
+	if result.Reviewed && !t.Reviewed {
+		change.Reviewed = true
+		changed = true
+	}
+	return change, changed
+}
+
+// apply возвращает копию транзакции с изменениями, которые вносят правила
+func (change RuleChange) apply(t models.Transaction) models.Transaction {
+	if change.NewCategoryID != nil {
+		t.CategoryID = change.NewCategoryID
+	}
+	if change.Note != nil {
+		t.Note = *change.Note
+	}
+	if change.Reviewed {
+		t.Reviewed = true
+	}
+	return t
+}
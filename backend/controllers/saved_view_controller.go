@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// SavedViewController контроллер для сохраненных представлений списка транзакций
+type SavedViewController struct{}
+
+// NewSavedViewController создает новый контроллер сохраненных представлений
+func NewSavedViewController() *SavedViewController {
+	return &SavedViewController{}
+}
+
+// GetSavedViews получает все сохраненные представления пользователя
+func (svc *SavedViewController) GetSavedViews(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var views []models.SavedView
+	if err := db.DB.Where("user_id = ?", userID).Order("name").Find(&views).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить представления",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   views,
+	})
+}
+
+// GetSavedView получает сохраненное представление вместе с периодом, рассчитанным на сегодня
+func (svc *SavedViewController) GetSavedView(c *fiber.Ctx) error {
+	view, err := findSavedView(c.Params("id"), middlewares.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Представление не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	startDate, endDate := view.Period(time.Now())
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   view,
+		"period": fiber.Map{
+			"start_date": startDate,
+			"end_date":   endDate,
+		},
+	})
+}
+
+// CreateSavedView создает сохраненное представление
+func (svc *SavedViewController) CreateSavedView(c *fiber.Ctx) error {
+	var input models.SavedViewDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	view := models.SavedView{UserID: userID}
+	if errors := applySavedViewInput(&view, input); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if err := db.DB.Create(&view).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать представление",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Представление успешно создано",
+		"data":    view,
+	})
+}
+
+// UpdateSavedView обновляет сохраненное представление. Набор фильтров заменяется целиком.
+func (svc *SavedViewController) UpdateSavedView(c *fiber.Ctx) error {
+	var input models.SavedViewDTO
+	userID := middlewares.GetUserID(c)
+
+	view, err := findSavedView(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Представление не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	if errors := applySavedViewInput(&view, input); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if err := db.DB.Save(&view).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить представление",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Представление успешно обновлено",
+		"data":    view,
+	})
+}
+
+// DeleteSavedView удаляет сохраненное представление
+func (svc *SavedViewController) DeleteSavedView(c *fiber.Ctx) error {
+	view, err := findSavedView(c.Params("id"), middlewares.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Представление не найдено",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Delete(&view).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить представление",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Представление успешно удалено",
+	})
+}
+
+// findSavedView находит сохраненное представление пользователя по ID
+func findSavedView(id string, userID uint) (models.SavedView, error) {
+	var view models.SavedView
+	err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&view).Error
+	return view, err
+}
+
+// applySavedViewInput проверяет данные представления и переносит их в модель
+func applySavedViewInput(view *models.SavedView, input models.SavedViewDTO) []utils.ValidationError {
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return errors
+	}
+
+	var existing models.SavedView
+	if err := db.DB.Where("user_id = ? AND name = ? AND id <> ?", view.UserID, input.Name, view.ID).First(&existing).Error; err == nil {
+		errors = append(errors, utils.ValidationError{Field: "name", Message: "Представление с таким названием уже существует"})
+	}
+
+	categoryIDs := uniqueIDs(append(append([]uint{}, input.CategoryIDs...), input.ExcludeCategoryIDs...))
+	if len(categoryIDs) > 0 {
+		var count int64
+		db.DB.Model(&models.Category{}).Where("id IN ? AND user_id = ?", categoryIDs, view.UserID).Count(&count)
+		if int(count) != len(categoryIDs) {
+			errors = append(errors, utils.ValidationError{Field: "categoryIds", Message: "Некоторые категории не найдены или не принадлежат пользователю"})
+		}
+	}
+	if err := checkAccountOwnership(input.AccountID, view.UserID); err != nil {
+		errors = append(errors, utils.ValidationError{Field: "accountId", Message: "Счет не найден или не принадлежит пользователю"})
+	}
+
+	if input.RelativeRange != "" && (input.StartDate != nil || input.EndDate != nil) {
+		errors = append(errors, utils.ValidationError{Field: "relativeRange", Message: "Укажите либо относительный период, либо даты начала и окончания"})
+	}
+	if input.StartDate != nil && input.EndDate != nil && input.EndDate.Before(*input.StartDate) {
+		errors = append(errors, utils.ValidationError{Field: "endDate", Message: "Дата окончания не может быть раньше даты начала"})
+	}
+	if input.MinAmount != nil && input.MaxAmount != nil && *input.MaxAmount < *input.MinAmount {
+		errors = append(errors, utils.ValidationError{Field: "maxAmount", Message: "Максимальная сумма не может быть меньше минимальной"})
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	var tags []string
+	for _, name := range input.Tags {
+		if name = normalizeTagName(name); name != "" {
+			tags = append(tags, name)
+		}
+	}
+
+	// Даты хранятся с начала первого до конца последнего дня, как в фильтре списка
+	var startDate, endDate *time.Time
+	if input.StartDate != nil {
+		date := parseDateParam(input.StartDate.Format("2006-01-02"), true)
+		startDate = &date
+	}
+	if input.EndDate != nil {
+		date := parseDateParam(input.EndDate.Format("2006-01-02"), false)
+		endDate = &date
+	}
+
+	view.Name = input.Name
+	view.CategoryIDs = input.CategoryIDs
+	view.ExcludeCategoryIDs = input.ExcludeCategoryIDs
+	view.AccountID = input.AccountID
+	view.Type = input.Type
+	view.Tags = tags
+	view.TagMode = input.TagMode
+	view.Search = input.Search
+	view.MinAmount = input.MinAmount
+	view.MaxAmount = input.MaxAmount
+	view.StartDate = startDate
+	view.EndDate = endDate
+	view.RelativeRange = input.RelativeRange
+	return nil
+}
+
+// statsFilter период, тип операций и дополнительное SQL-условие над строками ledgerTable
+// для запросов статистики. Условие и его параметры задаются сохраненным представлением.
+type statsFilter struct {
+	StartDate     time.Time
+	EndDate       time.Time
+	Type          string
+	condition     string
+	conditionArgs []interface{}
+}
+
+// parseStatsFilter разбирает период и тип статистики из параметров запроса. Если передан view_id,
+// сохраненное представление задает период и тип, не указанные явно, и ограничивает операции
+// своими фильтрами.
+func parseStatsFilter(c *fiber.Ctx, userID uint) (statsFilter, *requestError) {
+	f := statsFilter{
+		StartDate: parseDateParam(c.Query("start_date"), true),
+		EndDate:   parseDateParam(c.Query("end_date"), false),
+		Type:      c.Query("type", "expense"), // По умолчанию смотрим расходы
+	}
+
+	viewID := c.Query("view_id")
+	if viewID == "" {
+		return f, nil
+	}
+	view, err := findSavedView(viewID, userID)
+	if err != nil {
+		return f, &requestError{fiber.StatusNotFound, "Представление не найдено", err}
+	}
+
+	if c.Query("start_date") == "" && c.Query("end_date") == "" {
+		startDate, endDate := view.Period(time.Now())
+		if startDate != nil {
+			f.StartDate = *startDate
+		}
+		if endDate != nil {
+			f.EndDate = *endDate
+		}
+	}
+	if c.Query("type") == "" && view.Type != "" {
+		f.Type = string(view.Type)
+		f.where("c.type = ?", view.Type)
+	}
+
+	// Категории проверяются по строкам ledgerTable, чтобы части разделенной транзакции
+	// из других категорий не попадали в статистику
+	if len(view.CategoryIDs) > 0 {
+		f.where("t.category_id IN ?", view.CategoryIDs)
+	}
+	if len(view.ExcludeCategoryIDs) > 0 {
+		f.where("t.category_id NOT IN ?", view.ExcludeCategoryIDs)
+	}
+
+	// Остальные фильтры представления применяются к транзакциям так же, как в списке
+	filter := transactionFilter{
+		AccountID: view.AccountID,
+		Tags:      view.Tags,
+		TagMode:   view.TagMode,
+		Search:    view.Search,
+		MinAmount: view.MinAmount,
+		MaxAmount: view.MaxAmount,
+	}
+	if filter.AccountID != nil || len(filter.Tags) > 0 || filter.Search != "" || filter.MinAmount != nil || filter.MaxAmount != nil {
+		ids := filter.applyWhere(db.DB.Model(&models.Transaction{}).Select("transactions.id").Where("transactions.user_id = ?", userID), userID)
+		f.where("t.id IN (?)", ids)
+	}
+	return f, nil
+}
+
+// where добавляет условие к фильтру статистики
+func (f *statsFilter) where(condition string, args ...interface{}) {
+	f.condition += " AND " + condition
+	f.conditionArgs = append(f.conditionArgs, args...)
+}
+
+// args возвращает параметры запроса статистики вместе с параметрами условия представления,
+// которое добавляется в конец WHERE
+func (f statsFilter) args(args ...interface{}) []interface{} {
+	return append(args, f.conditionArgs...)
+}
@@ -77,6 +77,26 @@ type CategoryStats struct {
 	Percentage   float64 `json:"percentage"`
 }
 
+// TagStats структура для хранения статистики по метке с разбивкой по категориям
+type TagStats struct {
+	TagID            uint            `json:"tagId"`
+	TagName          string          `json:"tagName"`
+	Amount           float64         `json:"amount"`
+	TransactionCount int             `json:"transactionCount"`
+	Categories       []CategoryStats `json:"categories"`
+}
+
+// PayeeStats структура для хранения статистики по получателю
+type PayeeStats struct {
+	PayeeID          uint      `json:"payeeId"`
+	PayeeName        string    `json:"payeeName"`
+	Amount           float64   `json:"amount"`
+	TransactionCount int       `json:"transactionCount"`
+	AverageAmount    float64   `json:"averageAmount"`
+	Percentage       float64   `json:"percentage"` // доля в сумме операций всех получателей и операций без получателя
+	LastDate         time.Time `json:"lastDate"`
+}
+
 // BalanceStats структура для хранения статистики баланса
 type BalanceStats struct {
 	TotalIncome  float64 `json:"totalIncome"`
@@ -95,13 +115,12 @@ type DynamicsPoint struct {
 // GetCategorySummary получает сводку по категориям
 func (sc *StatsController) GetCategorySummary(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
-	transactionType := c.Query("type", "expense") // По умолчанию смотрим расходы
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	// Парсим даты с помощью вспомогательной функции
-	startDate := parseDateParam(startDateStr, true)
-	endDate := parseDateParam(endDateStr, false)
+	// Период и тип берутся из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	transactionType, startDate, endDate := filter.Type, filter.StartDate, filter.EndDate
 
 	// Получаем все категории пользователя с указанным типом
 	var categories []models.Category
@@ -128,12 +147,12 @@ func (sc *StatsController) GetCategorySummary(c *fiber.Ctx) error {
 	var categorySums []CategorySum
 	query := `
 		SELECT t.category_id, SUM(t.amount) as sum
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?` + filter.condition + `
 		GROUP BY t.category_id
 	`
-	if err := db.DB.Raw(query, userID, transactionType, startDate, endDate).Scan(&categorySums).Error; err != nil {
+	if err := db.DB.Raw(query, filter.args(userID, transactionType, startDate, endDate)...).Scan(&categorySums).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить статистику по категориям",
@@ -141,6 +160,37 @@ func (sc *StatsController) GetCategorySummary(c *fiber.Ctx) error {
 		})
 	}
 
+	// С параметром rollup=true суммы подкатегорий сворачиваются в категории верхнего уровня,
+	// а если указан parent_id - в прямые подкатегории этой категории (расходы в самой категории
+	// parent_id показываются отдельной строкой)
+	if c.QueryBool("rollup") {
+		var rootID *uint
+		if parentID := c.QueryInt("parent_id"); parentID > 0 {
+			id := uint(parentID)
+			rootID = &id
+		}
+		parents := make(map[uint]*uint, len(categories))
+		for _, cat := range categories {
+			parents[cat.ID] = cat.ParentID
+		}
+
+		var rolledUp []CategorySum
+		index := make(map[uint]int)
+		for _, cs := range categorySums {
+			categoryID, ok := rollupCategory(cs.CategoryID, parents, rootID)
+			if !ok {
+				continue
+			}
+			if i, exists := index[categoryID]; exists {
+				rolledUp[i].Sum += cs.Sum
+				continue
+			}
+			index[categoryID] = len(rolledUp)
+			rolledUp = append(rolledUp, CategorySum{CategoryID: categoryID, Sum: cs.Sum})
+		}
+		categorySums = rolledUp
+	}
+
 	// Считаем общую сумму
 	var totalAmount float64
 	for _, cs := range categorySums {
@@ -170,6 +220,219 @@ func (sc *StatsController) GetCategorySummary(c *fiber.Ctx) error {
 			"total":      totalAmount,
 			"start_date": startDate,
 			"end_date":   endDate,
+			"currency":   getUserBaseCurrency(userID),
+		},
+	})
+}
+
+// rollupCategory возвращает категорию, в которую сворачивается сумма категории: корневую
+// категорию ее ветки или, если указан rootID, прямую подкатегорию rootID (либо сам rootID).
+// Второе значение false, если категория не входит в ветку rootID.
+func rollupCategory(categoryID uint, parents map[uint]*uint, rootID *uint) (uint, bool) {
+	// Число шагов ограничено количеством категорий на случай некорректных данных
+	for i := 0; i <= len(parents); i++ {
+		if rootID != nil && categoryID == *rootID {
+			return categoryID, true
+		}
+		parentID, known := parents[categoryID]
+		if !known || parentID == nil {
+			return categoryID, rootID == nil
+		}
+		if _, ok := parents[*parentID]; !ok {
+			// Родитель в корзине или другого типа - категория считается корневой
+			return categoryID, rootID == nil
+		}
+		if rootID != nil && *parentID == *rootID {
+			return categoryID, true
+		}
+		categoryID = *parentID
+	}
+	return categoryID, rootID == nil
+}
+
+// GetTagSummary получает сводку по меткам: сумму отмеченных транзакций по каждой метке
+// и ее распределение по категориям. Транзакция с несколькими метками учитывается в каждой из них.
+func (sc *StatsController) GetTagSummary(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+	// Период и тип берутся из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	transactionType, startDate, endDate := filter.Type, filter.StartDate, filter.EndDate
+
+	// Получаем суммы по меткам и категориям внутри них
+	type TagCategorySum struct {
+		TagID        uint
+		TagName      string
+		CategoryID   uint
+		CategoryName string
+		Sum          float64
+	}
+
+	var sums []TagCategorySum
+	query := `
+		SELECT tg.id AS tag_id, tg.name AS tag_name, c.id AS category_id, c.name AS category_name,
+			SUM(t.amount) AS sum
+		FROM ` + ledgerTable("t") + `
+		JOIN transaction_tags tt ON tt.transaction_id = t.id
+		JOIN tags tg ON tg.id = tt.tag_id
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?` + filter.condition + `
+		GROUP BY tg.id, tg.name, c.id, c.name
+		ORDER BY tg.name, sum DESC
+	`
+	if err := db.DB.Raw(query, filter.args(userID, transactionType, startDate, endDate)...).Scan(&sums).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику по меткам",
+			"error":   err.Error(),
+		})
+	}
+
+	// Количество транзакций по метке считаем отдельно: разделенная транзакция
+	// может попасть в несколько категорий одной метки
+	type TagCount struct {
+		TagID uint
+		Count int
+	}
+
+	var counts []TagCount
+	countQuery := `
+		SELECT tt.tag_id, COUNT(DISTINCT t.id) AS count
+		FROM ` + ledgerTable("t") + `
+		JOIN transaction_tags tt ON tt.transaction_id = t.id
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?` + filter.condition + `
+		GROUP BY tt.tag_id
+	`
+	if err := db.DB.Raw(countQuery, filter.args(userID, transactionType, startDate, endDate)...).Scan(&counts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику по меткам",
+			"error":   err.Error(),
+		})
+	}
+
+	tagCounts := make(map[uint]int)
+	for _, tc := range counts {
+		tagCounts[tc.TagID] = tc.Count
+	}
+
+	// Группируем строки по меткам, сохраняя порядок сортировки
+	result := []TagStats{}
+	tagIndex := make(map[uint]int)
+	for _, s := range sums {
+		i, ok := tagIndex[s.TagID]
+		if !ok {
+			i = len(result)
+			tagIndex[s.TagID] = i
+			result = append(result, TagStats{
+				TagID:            s.TagID,
+				TagName:          s.TagName,
+				TransactionCount: tagCounts[s.TagID],
+			})
+		}
+		result[i].Amount += s.Sum
+		result[i].Categories = append(result[i].Categories, CategoryStats{
+			CategoryID:   s.CategoryID,
+			CategoryName: s.CategoryName,
+			Amount:       s.Sum,
+		})
+	}
+
+	// Проценты категорий считаем от суммы по метке
+	for i := range result {
+		for j := range result[i].Categories {
+			if result[i].Amount > 0 {
+				result[i].Categories[j].Percentage = (result[i].Categories[j].Amount / result[i].Amount) * 100
+			}
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"tags":       result,
+			"start_date": startDate,
+			"end_date":   endDate,
+			"currency":   getUserBaseCurrency(userID),
+		},
+	})
+}
+
+// GetPayeeSummary получает сводку по получателям: сумму, количество и среднюю сумму операций
+// с каждым получателем. Операции без получателя возвращаются отдельной суммой.
+func (sc *StatsController) GetPayeeSummary(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+	// Период и тип берутся из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	transactionType, startDate, endDate := filter.Type, filter.StartDate, filter.EndDate
+
+	// Разделенная транзакция попадает в ledgerTable несколькими строками, поэтому
+	// количество считается по уникальным транзакциям
+	var payees []PayeeStats
+	query := `
+		SELECT p.id AS payee_id, p.name AS payee_name, SUM(t.amount) AS amount,
+			COUNT(DISTINCT t.id) AS transaction_count, MAX(t.date) AS last_date
+		FROM ` + ledgerTable("t") + `
+		JOIN payees p ON p.id = t.payee_id
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?` + filter.condition + `
+		GROUP BY p.id, p.name
+		ORDER BY amount DESC
+	`
+	if err := db.DB.Raw(query, filter.args(userID, transactionType, startDate, endDate)...).Scan(&payees).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику по получателям",
+			"error":   err.Error(),
+		})
+	}
+
+	var unassigned float64
+	unassignedQuery := `
+		SELECT COALESCE(SUM(t.amount), 0)
+		FROM ` + ledgerTable("t") + `
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = ? AND c.type = ? AND t.date BETWEEN ? AND ?` + filter.condition + ` AND t.payee_id IS NULL
+	`
+	if err := db.DB.Raw(unassignedQuery, filter.args(userID, transactionType, startDate, endDate)...).Row().Scan(&unassigned); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику по получателям",
+			"error":   err.Error(),
+		})
+	}
+
+	total := unassigned
+	for _, p := range payees {
+		total += p.Amount
+	}
+	for i := range payees {
+		if payees[i].TransactionCount > 0 {
+			payees[i].AverageAmount = payees[i].Amount / float64(payees[i].TransactionCount)
+		}
+		if total > 0 {
+			payees[i].Percentage = (payees[i].Amount / total) * 100
+		}
+	}
+	if payees == nil {
+		payees = []PayeeStats{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"payees":     payees,
+			"unassigned": unassigned,
+			"total":      total,
+			"start_date": startDate,
+			"end_date":   endDate,
+			"currency":   getUserBaseCurrency(userID),
 		},
 	})
 }
@@ -177,32 +440,44 @@ func (sc *StatsController) GetCategorySummary(c *fiber.Ctx) error {
 // GetBalanceSummary получает сводку по балансу
 func (sc *StatsController) GetBalanceSummary(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	// Парсим даты с помощью вспомогательной функции
-	startDate := parseDateParam(startDateStr, true)
-	endDate := parseDateParam(endDateStr, false)
+	// Период берется из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	startDate, endDate := filter.StartDate, filter.EndDate
 
 	// Получаем сумму доходов
 	var totalIncome float64
 	incomeQuery := `
 		SELECT COALESCE(SUM(t.amount), 0) as total
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 	`
-	db.DB.Raw(incomeQuery, userID, startDate, endDate).Scan(&totalIncome)
+	if err := db.DB.Raw(incomeQuery, filter.args(userID, startDate, endDate)...).Scan(&totalIncome).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить сводку по балансу",
+			"error":   err.Error(),
+		})
+	}
 
 	// Получаем сумму расходов
 	var totalExpense float64
 	expenseQuery := `
 		SELECT COALESCE(SUM(t.amount), 0) as total
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 	`
-	db.DB.Raw(expenseQuery, userID, startDate, endDate).Scan(&totalExpense)
+	if err := db.DB.Raw(expenseQuery, filter.args(userID, startDate, endDate)...).Scan(&totalExpense).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить сводку по балансу",
+			"error":   err.Error(),
+		})
+	}
 
 	// Вычисляем баланс
 	balance := totalIncome - totalExpense
@@ -219,6 +494,7 @@ func (sc *StatsController) GetBalanceSummary(c *fiber.Ctx) error {
 			"stats":      stats,
 			"start_date": startDate,
 			"end_date":   endDate,
+			"currency":   getUserBaseCurrency(userID),
 		},
 	})
 }
@@ -248,24 +524,16 @@ func (sc *StatsController) GetBudgetProgress(c *fiber.Ctx) error {
 	var result []BudgetProgress
 
 	for _, budget := range budgets {
-		var spentAmount float64
-		query := db.DB.Model(&models.Transaction{}).
-			Joins("JOIN categories ON transactions.category_id = categories.id")
-
-		// Если бюджет для конкретной категории
-		if budget.CategoryID != nil {
-			query = query.Where("transactions.category_id = ?", *budget.CategoryID)
-		} else {
-			// Иначе учитываем все расходы
-			query = query.Where("categories.type = ?", models.Expense)
+		// Потраченная сумма считается в валюте бюджета
+		spentAmount, err := calculateBudgetSpent(budget)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось рассчитать прогресс бюджета",
+				"error":   err.Error(),
+			})
 		}
 
-		// Учитываем только транзакции в период действия бюджета
-		query = query.Where("transactions.user_id = ? AND transactions.date BETWEEN ? AND ?",
-			userID, budget.StartDate, budget.EndDate)
-
-		query.Select("COALESCE(SUM(transactions.amount), 0)").Scan(&spentAmount)
-
 		// Рассчитываем оставшиеся дни
 		remainingDays := int(budget.EndDate.Sub(time.Now()).Hours() / 24)
 		if remainingDays < 0 {
@@ -292,32 +560,44 @@ func (sc *StatsController) GetBudgetProgress(c *fiber.Ctx) error {
 // ExportStatsToPDF экспортирует статистику в PDF
 func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	// Парсим даты с помощью вспомогательной функции
-	startDate := parseDateParam(startDateStr, true)
-	endDate := parseDateParam(endDateStr, false)
+	// Период берется из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	startDate, endDate := filter.StartDate, filter.EndDate
 
 	// Получаем данные о балансе
 	var totalIncome float64
 	incomeQuery := `
 		SELECT COALESCE(SUM(t.amount), 0) as total
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 	`
-	db.DB.Raw(incomeQuery, userID, startDate, endDate).Scan(&totalIncome)
+	if err := db.DB.Raw(incomeQuery, filter.args(userID, startDate, endDate)...).Scan(&totalIncome).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику для отчета",
+			"error":   err.Error(),
+		})
+	}
 
 	// Получаем сумму расходов
 	var totalExpense float64
 	expenseQuery := `
 		SELECT COALESCE(SUM(t.amount), 0) as total
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 	`
-	db.DB.Raw(expenseQuery, userID, startDate, endDate).Scan(&totalExpense)
+	if err := db.DB.Raw(expenseQuery, filter.args(userID, startDate, endDate)...).Scan(&totalExpense).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику для отчета",
+			"error":   err.Error(),
+		})
+	}
 
 	// Вычисляем баланс
 	balance := totalIncome - totalExpense
@@ -326,9 +606,9 @@ func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 	expenseCategories := []CategoryStats{}
 	expenseQuery = `
 		SELECT t.category_id, c.name as category_name, SUM(t.amount) as amount
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 		GROUP BY t.category_id, c.name
 	`
 	type CategorySum struct {
@@ -337,7 +617,13 @@ func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 		Amount       float64
 	}
 	var expenseSums []CategorySum
-	db.DB.Raw(expenseQuery, userID, startDate, endDate).Scan(&expenseSums)
+	if err := db.DB.Raw(expenseQuery, filter.args(userID, startDate, endDate)...).Scan(&expenseSums).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику для отчета",
+			"error":   err.Error(),
+		})
+	}
 
 	// Рассчитываем проценты для расходов
 	for _, cs := range expenseSums {
@@ -357,13 +643,19 @@ func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 	incomeCategories := []CategoryStats{}
 	incomeQuery = `
 		SELECT t.category_id, c.name as category_name, SUM(t.amount) as amount
-		FROM transactions t
+		FROM ` + ledgerTable("t") + `
 		JOIN categories c ON t.category_id = c.id
-		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+		WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 		GROUP BY t.category_id, c.name
 	`
 	var incomeSums []CategorySum
-	db.DB.Raw(incomeQuery, userID, startDate, endDate).Scan(&incomeSums)
+	if err := db.DB.Raw(incomeQuery, filter.args(userID, startDate, endDate)...).Scan(&incomeSums).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить статистику для отчета",
+			"error":   err.Error(),
+		})
+	}
 
 	// Рассчитываем проценты для доходов
 	for _, cs := range incomeSums {
@@ -394,6 +686,7 @@ func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 		TotalIncome:  totalIncome,
 		TotalExpense: totalExpense,
 		Balance:      balance,
+		Currency:     user.BaseCurrency,
 		StartDate:    startDate,
 		EndDate:      endDate,
 		Categories:   []utils.CategorySummary{},
@@ -445,12 +738,12 @@ func (sc *StatsController) ExportStatsToPDF(c *fiber.Ctx) error {
 // GetBalanceDynamics получает динамику баланса по дням или часам
 func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	// Парсим даты с помощью вспомогательной функции
-	startDate := parseDateParam(startDateStr, true)
-	endDate := parseDateParam(endDateStr, false)
+	// Период берется из параметров запроса или сохраненного представления (view_id)
+	filter, ferr := parseStatsFilter(c, userID)
+	if ferr != nil {
+		return ferr.send(c)
+	}
+	startDate, endDate := filter.StartDate, filter.EndDate
 
 	// Определяем интервал группировки в зависимости от длительности периода
 	daysDiff := int(endDate.Sub(startDate).Hours() / 24)
@@ -497,9 +790,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				DATE_TRUNC('hour', t.date) as date,
 				COALESCE(SUM(t.amount), 0) as income,
 				0 as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY DATE_TRUNC('hour', t.date)
 			ORDER BY date
 		`
@@ -508,9 +801,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				DATE_TRUNC('hour', t.date) as date,
 				0 as income,
 				COALESCE(SUM(t.amount), 0) as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY DATE_TRUNC('hour', t.date)
 			ORDER BY date
 		`
@@ -522,9 +815,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				INTERVAL '6 hour' * FLOOR(EXTRACT(HOUR FROM t.date) / 6) as date,
 				COALESCE(SUM(t.amount), 0) as income,
 				0 as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY date
 			ORDER BY date
 		`
@@ -534,9 +827,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				INTERVAL '6 hour' * FLOOR(EXTRACT(HOUR FROM t.date) / 6) as date,
 				0 as income,
 				COALESCE(SUM(t.amount), 0) as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY date
 			ORDER BY date
 		`
@@ -547,9 +840,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				DATE_TRUNC('` + interval + `', t.date) as date,
 				COALESCE(SUM(t.amount), 0) as income,
 				0 as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'income' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY DATE_TRUNC('` + interval + `', t.date)
 			ORDER BY date
 		`
@@ -558,9 +851,9 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 				DATE_TRUNC('` + interval + `', t.date) as date,
 				0 as income,
 				COALESCE(SUM(t.amount), 0) as expense
-			FROM transactions t
+			FROM ` + ledgerTable("t") + `
 			JOIN categories c ON t.category_id = c.id
-			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?
+			WHERE t.user_id = ? AND c.type = 'expense' AND t.date BETWEEN ? AND ?` + filter.condition + `
 			GROUP BY DATE_TRUNC('` + interval + `', t.date)
 			ORDER BY date
 		`
@@ -568,7 +861,7 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 
 	// Выполняем запросы для получения доходов и расходов
 	var incomeDynamics, expenseDynamics []DynamicsData
-	if err := db.DB.Raw(incomeQuery, userID, startDate, endDate).Scan(&incomeDynamics).Error; err != nil {
+	if err := db.DB.Raw(incomeQuery, filter.args(userID, startDate, endDate)...).Scan(&incomeDynamics).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить динамику доходов",
@@ -576,7 +869,7 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.DB.Raw(expenseQuery, userID, startDate, endDate).Scan(&expenseDynamics).Error; err != nil {
+	if err := db.DB.Raw(expenseQuery, filter.args(userID, startDate, endDate)...).Scan(&expenseDynamics).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить динамику расходов",
@@ -665,6 +958,7 @@ func (sc *StatsController) GetBalanceDynamics(c *fiber.Ctx) error {
 			"dynamics":   result,
 			"start_date": startDate,
 			"end_date":   endDate,
+			"currency":   getUserBaseCurrency(userID),
 		},
 	})
 }
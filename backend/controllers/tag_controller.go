@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// TagController контроллер для меток транзакций
+type TagController struct{}
+
+// NewTagController создает новый контроллер меток
+func NewTagController() *TagController {
+	return &TagController{}
+}
+
+// GetAllTags получает все метки пользователя с количеством отмеченных транзакций
+func (tgc *TagController) GetAllTags(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	type tagWithUsage struct {
+		models.Tag
+		TransactionCount int `json:"transactionCount"`
+	}
+
+	var tags []tagWithUsage
+	if err := db.DB.Model(&models.Tag{}).
+		Select("tags.*, COUNT(transaction_tags.transaction_id) AS transaction_count").
+		Joins("LEFT JOIN transaction_tags ON transaction_tags.tag_id = tags.id").
+		Where("tags.user_id = ?", userID).
+		Group("tags.id").
+		Order("tags.name").
+		Scan(&tags).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить метки",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   tags,
+	})
+}
+
+// CreateTag создает новую метку
+func (tgc *TagController) CreateTag(c *fiber.Ctx) error {
+	var input models.TagDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	name := normalizeTagName(input.Name)
+	var existing models.Tag
+	if err := db.DB.Where("user_id = ? AND name = ?", userID, name).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Метка с таким названием уже существует",
+		})
+	}
+
+	tag := models.Tag{
+		UserID: userID,
+		Name:   name,
+		Color:  input.Color,
+	}
+
+	if err := db.DB.Create(&tag).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать метку",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTag, tag.ID, models.ActionCreate, nil, tag)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Метка успешно создана",
+		"data":    tag,
+	})
+}
+
+// UpdateTag обновляет метку
+func (tgc *TagController) UpdateTag(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var tag models.Tag
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Метка не найдена",
+			"error":   err.Error(),
+		})
+	}
+	oldTag := tag
+
+	var input models.TagDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	name := normalizeTagName(input.Name)
+	var existing models.Tag
+	if err := db.DB.Where("user_id = ? AND name = ? AND id <> ?", userID, name, tag.ID).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Метка с таким названием уже существует",
+		})
+	}
+
+	tag.Name = name
+	tag.Color = input.Color
+
+	if err := db.DB.Save(&tag).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить метку",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTag, tag.ID, models.ActionUpdate, oldTag, tag)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Метка успешно обновлена",
+		"data":    tag,
+	})
+}
+
+// DeleteTag удаляет метку и отвязывает ее от всех транзакций
+func (tgc *TagController) DeleteTag(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var tag models.Tag
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Метка не найдена",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Exec("DELETE FROM transaction_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось отвязать метку от транзакций",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := db.DB.Delete(&tag).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить метку",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTag, tag.ID, models.ActionDelete, tag, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Метка успешно удалена",
+	})
+}
+
+// normalizeTagName приводит название метки к единому виду, чтобы "Отпуск" и " отпуск" были одной меткой
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// resolveTags находит метки пользователя по названиям и создает отсутствующие
+func resolveTags(names []string, userID uint) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = normalizeTagName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tag := models.Tag{UserID: userID, Name: name}
+		result := db.DB.Where("user_id = ? AND name = ?", userID, name).FirstOrCreate(&tag)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			utils.CreateChangeLog(userID, models.EntityTag, tag.ID, models.ActionCreate, nil, tag)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -3,14 +3,18 @@ package controllers
 import (
 	"fmt"
 	"log"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/classifier"
 	"github.com/nikitagorchakov/finance-hub/backend/db"
 	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
 	"github.com/nikitagorchakov/finance-hub/backend/models"
 	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
 )
 
 // TransactionController контроллер для транзакций
@@ -25,77 +29,111 @@ func NewTransactionController() *TransactionController {
 func (tc *TransactionController) GetAllTransactions(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
 
-	// Параметры фильтрации
-	categoryID := c.Query("category_id")
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-	transactionType := c.Query("type") // expense или income
-	limitStr := c.Query("limit")       // ограничение количества записей
-	pageStr := c.Query("page")         // номер страницы
-	perPageStr := c.Query("per_page")  // количество записей на странице
-	
-	query := db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
-
-	// Применяем фильтры, если они указаны
-	if categoryID != "" {
-		query = query.Where("transactions.category_id = ?", categoryID)
-	}
-
-	if startDateStr != "" {
-		startDate, err := time.Parse(time.RFC3339, startDateStr)
-		if err == nil {
-			query = query.Where("transactions.date >= ?", startDate)
-		}
-	}
+	// Параметры фильтрации и сортировки
+	filter, errors := parseTransactionFilter(c)
 
-	if endDateStr != "" {
-		endDate, err := time.Parse(time.RFC3339, endDateStr)
-		if err == nil {
-			query = query.Where("transactions.date <= ?", endDate)
-		}
-	}
+	limitStr := c.Query("limit")      // ограничение количества записей
+	pageStr := c.Query("page")        // номер страницы
+	perPageStr := c.Query("per_page") // количество записей на странице
 
-	if transactionType != "" {
-		// Присоединяем категорию, чтобы фильтровать по типу
-		query = query.Joins("JOIN categories ON transactions.category_id = categories.id").
-			Where("categories.type = ?", transactionType)
-	}
-	
-	// Добавляем сортировку по дате (от новых к старым)
-	query = query.Order("transactions.date DESC")
-	
-	// Получаем общее количество записей для пагинации
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Не удалось посчитать общее количество транзакций",
-			"error":   err.Error(),
-		})
-	}
-	
 	// Параметры пагинации
 	page := 1
 	perPage := 10
-	
+
 	if pageStr != "" {
 		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
 			page = p
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "page", Message: "Должен быть положительным целым числом"})
 		}
 	}
-	
+
 	if perPageStr != "" {
 		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
 			perPage = pp
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "per_page", Message: "Должен быть целым числом от 1 до 100"})
 		}
 	}
-	
+
 	// Если указан лимит, он имеет приоритет над perPage
 	if limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
 			perPage = limit
 			page = 1 // Сбрасываем страницу на первую
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "limit", Message: "Должен быть положительным целым числом"})
+		}
+	}
+
+	// Курсорная пагинация включается параметром cursor (пустое значение - первая страница)
+	// и работает только с сортировкой по дате
+	useCursor := c.Context().QueryArgs().Has("cursor")
+	var cursor *transactionCursor
+	if useCursor {
+		if (filter.Sort != "" && filter.Sort != "date") || filter.Search != "" {
+			errors = append(errors, utils.ValidationError{Field: "cursor", Message: "Курсорная пагинация поддерживает только сортировку по дате без поиска"})
+		}
+		if value := c.Query("cursor"); value != "" {
+			if decoded, err := decodeTransactionCursor(value); err == nil {
+				cursor = &decoded
+			} else {
+				errors = append(errors, utils.ValidationError{Field: "cursor", Message: err.Error()})
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректные параметры запроса",
+			"errors":  errors,
+		})
+	}
+
+	// Суммы пересчитываем в базовую валюту пользователя, при поиске добавляем релевантность и подсветку
+	columns := baseAmountSelect()
+	args := []interface{}{getUserBaseCurrency(userID)}
+	if filter.Search != "" {
+		columns += ", ts_rank(transactions.search_vector, " + searchQuerySQL + ") AS rank" +
+			", ts_headline('russian', transactions.description, " + searchQuerySQL + ", 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight"
+		args = append(args, filter.Search, filter.Search)
+	}
+
+	if useCursor {
+		query := filter.applyWhere(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID).
+			Select(columns, args...).
+			Preload("Category").Preload("Account").Preload("ToAccount").Preload("Payee").Preload("Splits.Category").Preload("Tags")
+
+		transactions, meta, err := filter.findByCursor(query, cursor, perPage)
+		if err == nil {
+			err = fillRefundTotals(transactions)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось получить транзакции",
+				"error":   err.Error(),
+			})
 		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   transactions,
+			"meta":   meta,
+		})
+	}
+
+	query := filter.apply(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID)
+	
+	// Получаем общее количество записей для пагинации
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось посчитать общее количество транзакций",
+			"error":   err.Error(),
+		})
 	}
 	
 	// Применяем пагинацию
@@ -103,8 +141,10 @@ func (tc *TransactionController) GetAllTransactions(c *fiber.Ctx) error {
 	query = query.Offset(offset).Limit(perPage)
 
 	var transactions []models.Transaction
-	// Подгружаем связанные категории
-	if err := query.Preload("Category").Find(&transactions).Error; err != nil {
+	// Подгружаем связанные категории и счета
+	if err := query.Select(columns, args...).
+		Preload("Category").Preload("Account").Preload("ToAccount").Preload("Payee").Preload("Splits.Category").Preload("Tags").
+		Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить транзакции",
@@ -112,6 +152,15 @@ func (tc *TransactionController) GetAllTransactions(c *fiber.Ctx) error {
 		})
 	}
 
+	// Для расходов с возвратами показываем сумму за вычетом возвратов
+	if err := fillRefundTotals(transactions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось посчитать возвраты",
+			"error":   err.Error(),
+		})
+	}
+
 	// Вычисляем общее количество страниц
 	totalPages := int(total) / perPage
 	if int(total)%perPage > 0 {
@@ -139,7 +188,7 @@ func (tc *TransactionController) GetTransactionByID(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
 
 	var transaction models.Transaction
-	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Category").First(&transaction).Error; err != nil {
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Category").Preload("Account").Preload("ToAccount").Preload("Payee").Preload("Splits.Category").Preload("Tags").Preload("Attachments").First(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Транзакция не найдена",
@@ -147,9 +196,98 @@ func (tc *TransactionController) GetTransactionByID(c *fiber.Ctx) error {
 		})
 	}
 
+	transactions := []models.Transaction{transaction}
+	if err := fillRefundTotals(transactions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось посчитать возвраты",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   transactions[0],
+	})
+}
+
+// SuggestCategory предлагает до трех категорий для операции по описанию и сумме.
+// Классификатор обучается на истории пользователя при каждом запросе.
+func (tc *TransactionController) SuggestCategory(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	var errors []utils.ValidationError
+	description := strings.TrimSpace(c.Query("description"))
+	amount := 0.0
+	if value := c.Query("amount"); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 {
+			amount = parsed
+		} else {
+			errors = append(errors, utils.ValidationError{Field: "amount", Message: "Должна быть положительным числом"})
+		}
+	}
+	if description == "" && amount == 0 {
+		errors = append(errors, utils.ValidationError{Field: "description", Message: "Укажите описание или сумму операции"})
+	}
+	categoryType := models.CategoryType(c.Query("type"))
+	switch categoryType {
+	case "", models.Income, models.Expense:
+	default:
+		errors = append(errors, utils.ValidationError{Field: "type", Message: "Значение должно быть одним из: income expense"})
+	}
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	model, err := classifier.TrainForUser(userID, categoryType)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обучить классификатор",
+			"error":   err.Error(),
+		})
+	}
+
+	type categorySuggestion struct {
+		classifier.Suggestion
+		Category models.Category `json:"category"`
+	}
+
+	result := []categorySuggestion{}
+	suggestions := model.Suggest(description, amount, 3)
+	if len(suggestions) > 0 {
+		ids := make([]uint, 0, len(suggestions))
+		for _, s := range suggestions {
+			ids = append(ids, s.CategoryID)
+		}
+		var categories []models.Category
+		if err := db.DB.Where("id IN ? AND user_id = ?", ids, userID).Find(&categories).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось получить категории",
+				"error":   err.Error(),
+			})
+		}
+		byID := make(map[uint]models.Category, len(categories))
+		for _, category := range categories {
+			byID[category.ID] = category
+		}
+		for _, s := range suggestions {
+			if category, ok := byID[s.CategoryID]; ok {
+				result = append(result, categorySuggestion{Suggestion: s, Category: category})
+			}
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
-		"data":   transaction,
+		"data":   result,
+		"meta": fiber.Map{
+			"trainedOn": model.Examples(),
+		},
 	})
 }
 
@@ -174,15 +312,91 @@ func (tc *TransactionController) CreateTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Правила автоматической обработки могут заменить категорию на категорию того же типа,
+	// заполнить заметку и отметить проверку
+	rules, err := utils.LoadTransactionRules(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось загрузить правила",
+			"error":   err.Error(),
+		})
+	}
+	types, err := userCategoryTypes(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить категории",
+			"error":   err.Error(),
+		})
+	}
+	utils.ApplyTransactionRules(rules, &input, types[input.CategoryID])
+
+	// Проверяем разбивку по категориям, если она указана
+	splits, categoryID, err := buildTransactionSplits(input, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректная разбивка транзакции",
+			"error":   err.Error(),
+		})
+	}
+
 	// Проверяем, существует ли категория и принадлежит ли она пользователю
 	var category models.Category
-	if err := db.DB.Where("id = ? AND user_id = ?", input.CategoryID, userID).First(&category).Error; err != nil {
+	if err := db.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Категория не найдена или не принадлежит пользователю",
 			"error":   err.Error(),
 		})
 	}
+
+	// Проверяем счет, если он указан
+	if err := checkAccountOwnership(input.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	// Проверяем получателя, если он указан явно
+	if err := checkPayeeOwnership(input.PayeeID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Получатель не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	// Транзакция с тем же внешним идентификатором уже могла быть создана раньше
+	if input.ExternalID != nil {
+		existing, err := existingExternalIDs([]string{*input.ExternalID}, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось проверить внешний идентификатор",
+				"error":   err.Error(),
+			})
+		}
+		if existing[*input.ExternalID] {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция с внешним идентификатором %s уже существует", *input.ExternalID),
+			})
+		}
+	}
+
+	// Находим или создаем метки транзакции
+	tags, err := resolveTags(input.Tags, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить метки",
+			"error":   err.Error(),
+		})
+	}
 	
 	// Устанавливаем время на 12:00 дня, сохраняя дату
 	date := input.Date
@@ -191,12 +405,22 @@ func (tc *TransactionController) CreateTransaction(c *fiber.Ctx) error {
 
 	transaction := models.Transaction{
 		Amount:      input.Amount,
+		Currency:    resolveCurrency(input.Currency, input.AccountID, userID),
 		Description: input.Description,
+		Note:        input.Note,
+		Reviewed:    input.Reviewed,
 		Date:        normalizedDate,
-		CategoryID:  input.CategoryID,
+		Kind:        models.KindRegular,
+		CategoryID:  &categoryID,
+		AccountID:   input.AccountID,
+		PayeeID:     matchPayee(input.PayeeID, input.Description, userID),
+		Splits:      splits,
+		Tags:        tags,
 		UserID:      userID,
+		ExternalID:  input.ExternalID,
 	}
 
+	// Части разделенной транзакции и метки сохраняются вместе с ней
 	if err := db.DB.Create(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -204,6 +428,7 @@ func (tc *TransactionController) CreateTransaction(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, transaction.ID, models.ActionCreate, nil, transaction)
 
 	// Создаем регулярный платеж, если указан флаг
 	var recurringRule *models.RecurringRule
@@ -211,8 +436,10 @@ func (tc *TransactionController) CreateTransaction(c *fiber.Ctx) error {
 		rule := models.RecurringRule{
 			UserID:          userID,
 			Amount:          input.Amount,
+			Currency:        transaction.Currency,
 			Description:     input.Description,
-			CategoryID:      input.CategoryID,
+			CategoryID:      categoryID,
+			AccountID:       input.AccountID,
 			Frequency:       *input.Frequency,
 			StartDate:       normalizedDate,
 			EndDate:         input.EndDate,
@@ -222,17 +449,18 @@ func (tc *TransactionController) CreateTransaction(c *fiber.Ctx) error {
 
 		if err := db.DB.Create(&rule).Error; err == nil {
 			recurringRule = &rule
+			utils.CreateChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionCreate, nil, rule)
 		}
 	}
 
 	// Обновляем поле Spent в соответствующих бюджетах
-	if err := tc.updateBudgetSpent(transaction.CategoryID, transaction.Date, userID); err != nil {
+	if err := tc.updateBudgetSpent(transactionCategoryIDs(transaction), transaction.Date, userID); err != nil {
 		// Логируем ошибку, но не прерываем выполнение запроса
 		logError(err, "Ошибка при обновлении бюджетов")
 	}
 
 	// Загружаем связанную категорию для ответа
-	db.DB.Preload("Category").First(&transaction, transaction.ID)
+	db.DB.Preload("Category").Preload("Splits.Category").Preload("Tags").First(&transaction, transaction.ID)
 
 	response := fiber.Map{
 		"status":  "success",
@@ -270,16 +498,36 @@ func (tc *TransactionController) UpdateTransaction(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
 
 	var transaction models.Transaction
-	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&transaction).Error; err != nil {
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Splits").Preload("Tags").First(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Транзакция не найдена",
 			"error":   err.Error(),
 		})
 	}
+	oldTransaction := transaction
+
+	// Переводы между счетами редактируются отдельным методом
+	if transaction.IsTransfer() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Перевод между счетами необходимо изменять через /transfers",
+		})
+	}
+	if transaction.IsRefund() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Возврат необходимо изменять через /refunds",
+		})
+	}
+
+	// Сверенную с выпиской транзакцию можно изменить только явно сняв защиту
+	if reconciledLocked(c, transaction) {
+		return sendReconciledLocked(c)
+	}
 
 	// Сохраняем старые значения для последующего обновления бюджетов
-	oldCategoryID := transaction.CategoryID
+	oldCategoryIDs := transactionCategoryIDs(transaction)
 	oldDate := transaction.Date
 
 	var input models.TransactionDTO
@@ -299,15 +547,58 @@ func (tc *TransactionController) UpdateTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Проверяем разбивку по категориям, если она указана
+	splits, categoryID, err := buildTransactionSplits(input, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректная разбивка транзакции",
+			"error":   err.Error(),
+		})
+	}
+
 	// Проверяем, существует ли категория и принадлежит ли она пользователю
 	var category models.Category
-	if err := db.DB.Where("id = ? AND user_id = ?", input.CategoryID, userID).First(&category).Error; err != nil {
+	if err := db.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Категория не найдена или не принадлежит пользователю",
 			"error":   err.Error(),
 		})
 	}
+
+	// Расход с возвратами должен остаться расходом не меньше суммы возвратов
+	if rerr := checkRefundedExpense(transaction, input.Amount, category); rerr != nil {
+		return rerr.send(c)
+	}
+
+	// Проверяем счет, если он указан
+	if err := checkAccountOwnership(input.AccountID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	// Проверяем получателя, если он указан явно
+	if err := checkPayeeOwnership(input.PayeeID, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Получатель не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	// Находим или создаем метки транзакции
+	tags, err := resolveTags(input.Tags, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось сохранить метки",
+			"error":   err.Error(),
+		})
+	}
 	
 	// Устанавливаем время на 12:00 дня, сохраняя дату
 	date := input.Date
@@ -315,11 +606,29 @@ func (tc *TransactionController) UpdateTransaction(c *fiber.Ctx) error {
 	normalizedDate := time.Date(year, month, day, 12, 0, 0, 0, date.Location())
 
 	transaction.Amount = input.Amount
+	// Если валюта не передана, сохраняем текущую валюту транзакции
+	if input.Currency != "" {
+		transaction.Currency = resolveCurrency(input.Currency, nil, userID)
+	}
 	transaction.Description = input.Description
+	transaction.Note = input.Note
+	transaction.Reviewed = input.Reviewed
 	transaction.Date = normalizedDate
-	transaction.CategoryID = input.CategoryID
-
-	if err := db.DB.Save(&transaction).Error; err != nil {
+	transaction.CategoryID = &categoryID
+	transaction.AccountID = input.AccountID
+	transaction.PayeeID = matchPayee(input.PayeeID, input.Description, userID)
+	transaction.Splits = splits
+
+	// Разбивка и метки заменяются целиком: старые части удаляются, новые сохраняются вместе с транзакцией
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transaction_id = ?", transaction.ID).Delete(&models.TransactionSplit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&transaction).Error; err != nil {
+			return err
+		}
+		return tx.Model(&transaction).Association("Tags").Replace(tags)
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось обновить транзакцию",
@@ -327,18 +636,24 @@ func (tc *TransactionController) UpdateTransaction(c *fiber.Ctx) error {
 		})
 	}
 
-	// Обновляем поле Spent в бюджетах, связанных со старой категорией
-	if err := tc.updateBudgetSpent(oldCategoryID, oldDate, userID); err != nil {
+	// Обновляем поле Spent в бюджетах, связанных со старыми категориями
+	if err := tc.updateBudgetSpent(oldCategoryIDs, oldDate, userID); err != nil {
 		logError(err, "Ошибка при обновлении старых бюджетов")
 	}
 
-	// Обновляем поле Spent в бюджетах, связанных с новой категорией
-	if err := tc.updateBudgetSpent(transaction.CategoryID, transaction.Date, userID); err != nil {
+	// Обновляем поле Spent в бюджетах, связанных с новыми категориями
+	if err := tc.updateBudgetSpent(transactionCategoryIDs(transaction), transaction.Date, userID); err != nil {
 		logError(err, "Ошибка при обновлении новых бюджетов")
 	}
 
+	// Возвраты следуют за валютой и категорией расхода
+	if err := syncRefunds(transaction, oldCategoryIDs); err != nil {
+		logError(err, "Ошибка при обновлении возвратов")
+	}
+
 	// Загружаем связанную категорию для ответа
-	db.DB.Preload("Category").First(&transaction, transaction.ID)
+	db.DB.Preload("Category").Preload("Splits.Category").Preload("Tags").First(&transaction, transaction.ID)
+	utils.CreateChangeLog(userID, models.EntityTransaction, transaction.ID, models.ActionUpdate, oldTransaction, transaction)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
@@ -347,13 +662,13 @@ func (tc *TransactionController) UpdateTransaction(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteTransaction удаляет транзакцию
+// DeleteTransaction перемещает транзакцию в корзину
 func (tc *TransactionController) DeleteTransaction(c *fiber.Ctx) error {
 	id := c.Params("id")
 	userID := middlewares.GetUserID(c)
 
 	var transaction models.Transaction
-	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).First(&transaction).Error; err != nil {
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Splits").Preload("Tags").First(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Транзакция не найдена",
@@ -361,10 +676,31 @@ func (tc *TransactionController) DeleteTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	if reconciledLocked(c, transaction) {
+		return sendReconciledLocked(c)
+	}
+
+	// Расход нельзя удалить, пока по нему есть возвраты
+	refunds, err := activeRefundsOutside([]uint{transaction.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить возвраты",
+			"error":   err.Error(),
+		})
+	}
+	if refunds > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "По транзакции есть возвраты, сначала удалите их",
+		})
+	}
+
 	// Сохраняем значения для последующего обновления бюджетов
-	categoryID := transaction.CategoryID
+	categoryIDs := transactionCategoryIDs(transaction)
 	date := transaction.Date
 
+	// Транзакция помечается удаленной, части, метки и вложения остаются до очистки корзины
 	if err := db.DB.Delete(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -372,19 +708,20 @@ func (tc *TransactionController) DeleteTransaction(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, transaction.ID, models.ActionDelete, transaction, nil)
 
 	// Обновляем поле Spent в соответствующих бюджетах
-	if err := tc.updateBudgetSpent(categoryID, date, userID); err != nil {
+	if err := tc.updateBudgetSpent(categoryIDs, date, userID); err != nil {
 		logError(err, "Ошибка при обновлении бюджетов после удаления транзакции")
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Транзакция успешно удалена",
+		"message": "Транзакция перемещена в корзину",
 	})
 }
 
-// DeleteBulkTransactions удаляет несколько транзакций одним запросом
+// DeleteBulkTransactions перемещает несколько транзакций в корзину одним запросом
 func (tc *TransactionController) DeleteBulkTransactions(c *fiber.Ctx) error {
 	var input models.BulkDeleteDTO
 	userID := middlewares.GetUserID(c)
@@ -407,7 +744,7 @@ func (tc *TransactionController) DeleteBulkTransactions(c *fiber.Ctx) error {
 
 	// Находим все транзакции для удаления и проверяем, что они принадлежат пользователю
 	var transactions []models.Transaction
-	if err := db.DB.Where("id IN ? AND user_id = ?", input.TransactionIDs, userID).Find(&transactions).Error; err != nil {
+	if err := db.DB.Where("id IN ? AND user_id = ?", input.TransactionIDs, userID).Preload("Splits").Preload("Tags").Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось найти транзакции",
@@ -423,16 +760,38 @@ func (tc *TransactionController) DeleteBulkTransactions(c *fiber.Ctx) error {
 		})
 	}
 
+	for _, t := range transactions {
+		if reconciledLocked(c, t) {
+			return sendReconciledLocked(c)
+		}
+	}
+
+	// Расходы удаляются только вместе со всеми возвратами по ним
+	refunds, err := activeRefundsOutside(input.TransactionIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить возвраты",
+			"error":   err.Error(),
+		})
+	}
+	if refunds > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "По некоторым транзакциям есть возвраты, удалите их вместе с транзакциями",
+		})
+	}
+
 	// Сохраняем данные для обновления бюджетов
 	type transactionMeta struct {
-		categoryID uint
-		date       time.Time
+		categoryIDs []uint
+		date        time.Time
 	}
 	transactionsMeta := make([]transactionMeta, len(transactions))
 	for i, t := range transactions {
 		transactionsMeta[i] = transactionMeta{
-			categoryID: t.CategoryID,
-			date:       t.Date,
+			categoryIDs: transactionCategoryIDs(t),
+			date:        t.Date,
 		}
 	}
 
@@ -465,23 +824,38 @@ func (tc *TransactionController) DeleteBulkTransactions(c *fiber.Ctx) error {
 		})
 	}
 
+	changeLogs := make([]models.ChangeLog, 0, len(transactions))
+	for _, t := range transactions {
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionDelete, t, nil))
+	}
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+
 	// Обновляем бюджеты для каждой удаленной транзакции
 	for _, meta := range transactionsMeta {
-		if err := tc.updateBudgetSpent(meta.categoryID, meta.date, userID); err != nil {
+		if err := tc.updateBudgetSpent(meta.categoryIDs, meta.date, userID); err != nil {
 			// Логируем ошибку, но продолжаем выполнение
-			logError(err, fmt.Sprintf("Ошибка при обновлении бюджета для категории %d", meta.categoryID))
+			logError(err, "Ошибка при обновлении бюджетов после удаления транзакций")
 		}
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": fmt.Sprintf("Успешно удалено %d транзакций", len(transactions)),
+		"message": fmt.Sprintf("В корзину перемещено %d транзакций", len(transactions)),
 	})
 }
 
-// CreateBulkTransactions создает несколько транзакций одним запросом
-func (tc *TransactionController) CreateBulkTransactions(c *fiber.Ctx) error {
-	var input models.BulkTransactionDTO
+// maxBulkUpdate наибольшее количество транзакций, изменяемых одним запросом
+const maxBulkUpdate = 5000
+
+// UpdateBulkTransactions применяет одно изменение к нескольким транзакциям: новую категорию,
+// сдвиг даты и замену подстроки в описании (получатель при этом определяется заново). Транзакции
+// выбираются по списку ID или по фильтру из параметров запроса. Сдвиг даты, после которого возврат
+// оказался бы раньше своего расхода, отклоняется. Все изменения сохраняются в одной транзакции базы данных,
+// а бюджеты пересчитываются один раз за весь затронутый период.
+func (tc *TransactionController) UpdateBulkTransactions(c *fiber.Ctx) error {
+	var input models.BulkUpdateDTO
 	userID := middlewares.GetUserID(c)
 
 	if err := c.BodyParser(&input); err != nil {
@@ -493,6 +867,12 @@ func (tc *TransactionController) CreateBulkTransactions(c *fiber.Ctx) error {
 	}
 
 	errors := utils.ValidateStruct(input)
+	if input.UseFilter == (len(input.TransactionIDs) > 0) {
+		errors = append(errors, utils.ValidationError{Field: "transactionIds", Message: "Укажите либо список транзакций, либо useFilter"})
+	}
+	if input.CategoryID == nil && input.DateShiftDays == 0 && input.Find == "" {
+		errors = append(errors, utils.ValidationError{Field: "categoryId", Message: "Укажите хотя бы одно изменение: categoryId, dateShiftDays или find"})
+	}
 	if len(errors) > 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status": "error",
@@ -500,131 +880,344 @@ func (tc *TransactionController) CreateBulkTransactions(c *fiber.Ctx) error {
 		})
 	}
 
-	// Проверяем, что все категории существуют и принадлежат пользователю
-	categoryIDs := make(map[uint]bool)
-	for _, t := range input.Transactions {
-		categoryIDs[t.CategoryID] = true
-	}
-
-	for categoryID := range categoryIDs {
-		var category models.Category
-		if err := db.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
+	// Выбираем транзакции вместе с частями и метками, чтобы история изменений хранила полный снимок
+	query := db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
+	if input.UseFilter {
+		filter, filterErrors := parseTransactionFilter(c)
+		if len(filterErrors) > 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"status":  "error",
-				"message": fmt.Sprintf("Категория с ID %d не найдена или не принадлежит пользователю", categoryID),
-				"error":   err.Error(),
+				"message": "Некорректные параметры запроса",
+				"errors":  filterErrors,
 			})
 		}
+		query = filter.applyWhere(query, userID)
+	} else {
+		input.TransactionIDs = uniqueIDs(input.TransactionIDs)
+		query = query.Where("transactions.id IN ?", input.TransactionIDs)
 	}
 
-	// Создаем транзакции
-	transactions := make([]models.Transaction, 0, len(input.Transactions))
-	for _, t := range input.Transactions {
-		// Устанавливаем время на 12:00 дня, сохраняя дату
-		date := t.Date
-		year, month, day := date.Date()
-		normalizedDate := time.Date(year, month, day, 12, 0, 0, 0, date.Location())
-		
-		transaction := models.Transaction{
-			Amount:      t.Amount,
-			Description: t.Description,
-			Date:        normalizedDate,
-			CategoryID:  t.CategoryID,
-			UserID:      userID,
-		}
-		transactions = append(transactions, transaction)
-	}
-
-	// Выполняем транзакцию в базе данных
-	tx := db.DB.Begin()
-	if tx.Error != nil {
+	var transactions []models.Transaction
+	if err := query.Select("transactions.*").Preload("Splits").Preload("Tags").
+		Order("transactions.date, transactions.id").Limit(maxBulkUpdate + 1).Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Не удалось начать транзакцию в базе данных",
-			"error":   tx.Error.Error(),
+			"message": "Не удалось найти транзакции",
+			"error":   err.Error(),
 		})
 	}
 
-	// Создаем записи транзакций
-	if err := tx.Create(&transactions).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	if !input.UseFilter && len(transactions) != len(input.TransactionIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Не удалось создать транзакции",
-			"error":   err.Error(),
+			"message": "Некоторые транзакции не найдены или не принадлежат пользователю",
+		})
+	}
+	if len(transactions) > maxBulkUpdate {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("За один запрос можно изменить не больше %d транзакций, уточните фильтр", maxBulkUpdate),
 		})
 	}
 
-	// Обновляем бюджеты для каждой категории
-	for _, transaction := range transactions {
-		if err := tc.updateBudgetSpent(transaction.CategoryID, transaction.Date, userID); err != nil {
-			// Логируем ошибку, но продолжаем выполнение
-			logError(err, fmt.Sprintf("Ошибка при обновлении бюджета для категории %d", transaction.CategoryID))
+	var category models.Category
+	if input.CategoryID != nil {
+		if err := db.DB.Where("id = ? AND user_id = ?", *input.CategoryID, userID).First(&category).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Категория не найдена или не принадлежит пользователю",
+				"error":   err.Error(),
+			})
+		}
+		if err := fillRefundTotals(transactions); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось посчитать возвраты",
+				"error":   err.Error(),
+			})
 		}
 	}
 
-	// Подтверждаем транзакцию
-	if err := tx.Commit().Error; err != nil {
+	for _, t := range transactions {
+		if reconciledLocked(c, t) {
+			return sendReconciledLocked(c)
+		}
+		if input.CategoryID == nil {
+			continue
+		}
+		// Категория переводов и возвратов определяется иначе, а у разделенных транзакций - частями
+		if t.Kind != models.KindRegular || t.IsSplit() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция %d: категорию нельзя изменить у переводов, возвратов и разделенных транзакций", t.ID),
+			})
+		}
+		if t.RefundedAmount != nil && category.Type != models.Expense {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Транзакция %d: по ней есть возвраты, поэтому она должна оставаться расходом", t.ID),
+			})
+		}
+	}
+
+	// Сдвиг даты не должен ставить возврат раньше его расхода
+	if input.DateShiftDays != 0 {
+		if rerr := checkShiftedRefunds(transactions, input.DateShiftDays); rerr != nil {
+			return rerr.send(c)
+		}
+	}
+
+	// Получатель транзакций с измененным описанием определяется заново, как при обычном изменении
+	var patterns []utils.PayeePattern
+	if input.Find != "" {
+		var err error
+		if _, patterns, err = utils.LoadPayees(userID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось загрузить получателей",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	// Применяем изменения и запоминаем категории и даты для пересчета бюджетов
+	var changed []models.Transaction
+	var changeLogs []models.ChangeLog
+	var budgetCategoryIDs, refundOfIDs []uint
+	var from, to time.Time
+	trackPeriod := func(date time.Time) {
+		if from.IsZero() || date.Before(from) {
+			from = date
+		}
+		if to.IsZero() || date.After(to) {
+			to = date
+		}
+	}
+
+	for _, t := range transactions {
+		hasRefunds := t.RefundedAmount != nil
+		t.RefundedAmount, t.NetAmount = nil, nil
+		old := t
+		if input.CategoryID != nil {
+			t.CategoryID = input.CategoryID
+		}
+		if input.DateShiftDays != 0 {
+			t.Date = t.Date.AddDate(0, 0, input.DateShiftDays)
+		}
+		if input.Find != "" {
+			t.Description = strings.ReplaceAll(t.Description, input.Find, input.Replace)
+			// Получатель возврата следует за расходом
+			if t.Kind == models.KindRegular && t.Description != old.Description {
+				t.PayeeID = nil
+				if id, ok := utils.MatchPayee(t.Description, patterns); ok {
+					t.PayeeID = &id
+				}
+			}
+		}
+
+		categoryChanged := input.CategoryID != nil && (old.CategoryID == nil || *old.CategoryID != *input.CategoryID)
+		if !categoryChanged && t.Date.Equal(old.Date) && t.Description == old.Description {
+			continue
+		}
+
+		changed = append(changed, t)
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionUpdate, old, t))
+		budgetCategoryIDs = append(budgetCategoryIDs, transactionCategoryIDs(old)...)
+		budgetCategoryIDs = append(budgetCategoryIDs, transactionCategoryIDs(t)...)
+		trackPeriod(old.Date)
+		trackPeriod(t.Date)
+		if categoryChanged && hasRefunds {
+			refundOfIDs = append(refundOfIDs, t.ID)
+		}
+	}
+
+	if len(changed) == 0 {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "Нет транзакций для изменения",
+			"data":    fiber.Map{"updated": 0},
+		})
+	}
+
+	// Возвраты следуют за категорией расхода, поэтому их периоды тоже пересчитываются
+	var refunds []models.Transaction
+	if len(refundOfIDs) > 0 {
+		if err := db.DB.Where("refund_of_id IN ? AND kind = ?", refundOfIDs, models.KindRefund).Find(&refunds).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Не удалось найти возвраты",
+				"error":   err.Error(),
+			})
+		}
+		for _, refund := range refunds {
+			budgetCategoryIDs = append(budgetCategoryIDs, transactionCategoryIDs(refund)...)
+			trackPeriod(refund.Date)
+		}
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, t := range changed {
+			if err := tx.Model(&models.Transaction{}).Where("id = ?", t.ID).Updates(map[string]interface{}{
+				"category_id": t.CategoryID,
+				"date":        t.Date,
+				"description": t.Description,
+				"payee_id":    t.PayeeID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if len(refunds) > 0 {
+			return tx.Model(&models.Transaction{}).Where("refund_of_id IN ? AND kind = ?", refundOfIDs, models.KindRefund).
+				Update("category_id", *input.CategoryID).Error
+		}
+		return nil
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Не удалось завершить транзакцию в базе данных",
+			"message": "Не удалось изменить транзакции",
 			"error":   err.Error(),
 		})
 	}
 
-	// Загружаем созданные транзакции с данными категорий для ответа
-	for i := range transactions {
-		db.DB.Preload("Category").First(&transactions[i], transactions[i].ID)
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	if err := refreshBudgets(userID, uniqueIDs(budgetCategoryIDs), from, to); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после массового изменения транзакций")
+	}
+
+	ids := make([]uint, 0, len(changed))
+	for _, t := range changed {
+		ids = append(ids, t.ID)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": fmt.Sprintf("Успешно создано %d транзакций", len(transactions)),
-		"data":    transactions,
+		"message": fmt.Sprintf("Изменено %d транзакций", len(changed)),
+		"data": fiber.Map{
+			"updated":        len(changed),
+			"transactionIds": ids,
+		},
 	})
 }
 
-// ExportTransactionsToCSV экспортирует транзакции пользователя в CSV
-func (tc *TransactionController) ExportTransactionsToCSV(c *fiber.Ctx) error {
+// CreateBulkTransactions создает несколько транзакций одним запросом.
+// Вероятные дубликаты возвращаются в possibleDuplicates и создаются, только если не указан skipDuplicates.
+func (tc *TransactionController) CreateBulkTransactions(c *fiber.Ctx) error {
+	var input models.BulkTransactionDTO
 	userID := middlewares.GetUserID(c)
 
-	// Получаем такие же параметры фильтрации, как и в GetAllTransactions
-	categoryID := c.Query("category_id")
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-	transactionType := c.Query("type")
-	
-	query := db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
 
-	// Применяем фильтры, если они указаны
-	if categoryID != "" {
-		query = query.Where("transactions.category_id = ?", categoryID)
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
 	}
 
-	if startDateStr != "" {
-		startDate, err := time.Parse(time.RFC3339, startDateStr)
-		if err == nil {
-			query = query.Where("transactions.date >= ?", startDate)
+	// Ищем среди новых транзакций похожие на существующие и на предыдущие в том же запросе
+	candidates := make([]utils.TransactionFingerprint, len(input.Transactions))
+	for i, t := range input.Transactions {
+		categoryID := t.CategoryID
+		if len(t.Splits) > 0 {
+			categoryID = t.Splits[0].CategoryID
+		}
+		candidates[i] = utils.TransactionFingerprint{
+			Amount:      t.Amount,
+			Currency:    resolveCurrency(t.Currency, t.AccountID, userID),
+			Date:        t.Date,
+			Description: t.Description,
+			CategoryID:  &categoryID,
 		}
 	}
+	matches, err := findLikelyDuplicates(candidates, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить дубликаты",
+			"error":   err.Error(),
+		})
+	}
 
-	if endDateStr != "" {
-		endDate, err := time.Parse(time.RFC3339, endDateStr)
-		if err == nil {
-			query = query.Where("transactions.date <= ?", endDate)
+	var possibleDuplicates []fiber.Map
+	inputs := make([]models.TransactionDTO, 0, len(input.Transactions))
+	for i, t := range input.Transactions {
+		duplicate := fiber.Map{"index": i}
+		if matches[i] != nil {
+			duplicate["duplicateOf"] = *matches[i]
+		} else {
+			for j := 0; j < i; j++ {
+				if utils.IsLikelyDuplicate(candidates[i], candidates[j], utils.DuplicateDateWindow) {
+					duplicate["duplicateOfIndex"] = j
+					break
+				}
+			}
 		}
+
+		if len(duplicate) > 1 {
+			possibleDuplicates = append(possibleDuplicates, duplicate)
+			if input.SkipDuplicates {
+				continue
+			}
+		}
+		inputs = append(inputs, t)
 	}
 
-	if transactionType != "" {
-		query = query.Joins("JOIN categories ON transactions.category_id = categories.id").
-			Where("categories.type = ?", transactionType)
+	if len(inputs) == 0 {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":             "success",
+			"message":            "Все транзакции похожи на уже существующие и не были созданы",
+			"data":               []models.Transaction{},
+			"possibleDuplicates": possibleDuplicates,
+		})
+	}
+
+	transactions, createErr := createTransactions(inputs, userID, middlewares.GetSubscriptionPlan(c))
+	if createErr != nil {
+		return createErr.send(c)
+	}
+
+	// Загружаем созданные транзакции с данными категорий для ответа
+	for i := range transactions {
+		db.DB.Preload("Category").Preload("Splits.Category").Preload("Tags").First(&transactions[i], transactions[i].ID)
 	}
-	
-	// Сортировка от новых к старым
-	query = query.Order("transactions.date DESC")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":             "success",
+		"message":            fmt.Sprintf("Успешно создано %d транзакций", len(transactions)),
+		"data":               transactions,
+		"possibleDuplicates": possibleDuplicates,
+	})
+}
+
+// ExportTransactionsToCSV экспортирует транзакции пользователя в CSV
+func (tc *TransactionController) ExportTransactionsToCSV(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	// Применяем такие же параметры фильтрации и сортировки, как и в GetAllTransactions
+	filter, errors := parseTransactionFilter(c)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректные параметры запроса",
+			"errors":  errors,
+		})
+	}
+
+	query := filter.apply(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID)
 
 	var transactions []models.Transaction
-	if err := query.Preload("Category").Find(&transactions).Error; err != nil {
+	// Суммы дополнительно пересчитываются в базовую валюту пользователя
+	if err := query.Select(baseAmountSelect(), getUserBaseCurrency(userID)).Preload("Category").Preload("Splits.Category").Preload("Tags").Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить транзакции для экспорта",
@@ -656,43 +1249,21 @@ func (tc *TransactionController) ExportTransactionsToCSV(c *fiber.Ctx) error {
 func (tc *TransactionController) ExportTransactionsToExcel(c *fiber.Ctx) error {
 	userID := middlewares.GetUserID(c)
 
-	// Получаем такие же параметры фильтрации, как и в GetAllTransactions
-	categoryID := c.Query("category_id")
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-	transactionType := c.Query("type")
-	
-	query := db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID)
-
-	// Применяем фильтры, если они указаны
-	if categoryID != "" {
-		query = query.Where("transactions.category_id = ?", categoryID)
-	}
-
-	if startDateStr != "" {
-		startDate, err := time.Parse(time.RFC3339, startDateStr)
-		if err == nil {
-			query = query.Where("transactions.date >= ?", startDate)
-		}
-	}
-
-	if endDateStr != "" {
-		endDate, err := time.Parse(time.RFC3339, endDateStr)
-		if err == nil {
-			query = query.Where("transactions.date <= ?", endDate)
-		}
+	// Применяем такие же параметры фильтрации и сортировки, как и в GetAllTransactions
+	filter, errors := parseTransactionFilter(c)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректные параметры запроса",
+			"errors":  errors,
+		})
 	}
 
-	if transactionType != "" {
-		query = query.Joins("JOIN categories ON transactions.category_id = categories.id").
-			Where("categories.type = ?", transactionType)
-	}
-	
-	// Сортировка от новых к старым
-	query = query.Order("transactions.date DESC")
+	query := filter.apply(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID)
 
 	var transactions []models.Transaction
-	if err := query.Preload("Category").Find(&transactions).Error; err != nil {
+	// Суммы дополнительно пересчитываются в базовую валюту пользователя
+	if err := query.Select(baseAmountSelect(), getUserBaseCurrency(userID)).Preload("Category").Preload("Splits.Category").Preload("Tags").Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Не удалось получить транзакции для экспорта",
@@ -720,20 +1291,343 @@ func (tc *TransactionController) ExportTransactionsToExcel(c *fiber.Ctx) error {
 	return c.Send(excelData)
 }
 
+// ExportTransactionsToOFX экспортирует транзакции пользователя в OFX
+// вместе с категориями, разбивкой и правилами регулярных платежей.
+// Параметр version задает версию формата: 1 - SGML (по умолчанию), 2 - XML.
+func (tc *TransactionController) ExportTransactionsToOFX(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	// Применяем такие же параметры фильтрации и сортировки, как и в GetAllTransactions
+	filter, errors := parseTransactionFilter(c)
+	version := 1
+	switch c.Query("version", "1") {
+	case "1":
+	case "2":
+		version = 2
+	default:
+		errors = append(errors, utils.ValidationError{Field: "version", Message: "Допустимые значения: 1, 2"})
+	}
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректные параметры запроса",
+			"errors":  errors,
+		})
+	}
+
+	query := filter.apply(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID)
+
+	var transactions []models.Transaction
+	// Выписки формируются по счетам, поэтому загружаются и счета, и правила с их категориями
+	if err := query.Preload("Category").Preload("Splits.Category").Preload("Account").Preload("ToAccount").Preload("RecurringRule.Category").Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить транзакции для экспорта",
+			"error":   err.Error(),
+		})
+	}
+
+	ofxData, err := utils.ExportTransactionsToOFX(transactions, version)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать OFX файл",
+			"error":   err.Error(),
+		})
+	}
+
+	// Формируем имя файла с текущей датой
+	currentTime := time.Now().Format("2006-01-02_15-04-05")
+	fileName := fmt.Sprintf("transactions_%s.ofx", currentTime)
+
+	c.Set("Content-Type", "application/x-ofx")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+
+	return c.Send(ofxData)
+}
+
+// ExportTransactionsToQIF экспортирует транзакции пользователя в QIF
+// вместе с категориями, разбивкой и правилами регулярных платежей
+func (tc *TransactionController) ExportTransactionsToQIF(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	// Применяем такие же параметры фильтрации и сортировки, как и в GetAllTransactions
+	filter, errors := parseTransactionFilter(c)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некорректные параметры запроса",
+			"errors":  errors,
+		})
+	}
+
+	query := filter.apply(db.DB.Model(&models.Transaction{}).Where("transactions.user_id = ?", userID), userID)
+
+	var transactions []models.Transaction
+	// Выписки формируются по счетам, поэтому загружаются и счета, и правила с их категориями
+	if err := query.Preload("Category").Preload("Splits.Category").Preload("Account").Preload("ToAccount").Preload("RecurringRule.Category").Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить транзакции для экспорта",
+			"error":   err.Error(),
+		})
+	}
+
+	qifData, err := utils.ExportTransactionsToQIF(transactions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать QIF файл",
+			"error":   err.Error(),
+		})
+	}
+
+	// Формируем имя файла с текущей датой
+	currentTime := time.Now().Format("2006-01-02_15-04-05")
+	fileName := fmt.Sprintf("transactions_%s.qif", currentTime)
+
+	c.Set("Content-Type", "application/qif")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+
+	return c.Send(qifData)
+}
+
+// requestError ошибка обработки запроса с HTTP-статусом и сообщением для ответа
+type requestError struct {
+	status  int
+	message string
+	err     error
+}
+
+// send отправляет ошибку клиенту в стандартном формате
+func (e *requestError) send(c *fiber.Ctx) error {
+	response := fiber.Map{
+		"status":  "error",
+		"message": e.message,
+	}
+	if e.err != nil {
+		response["error"] = e.err.Error()
+	}
+	return c.Status(e.status).JSON(response)
+}
+
+// createTransactions проверяет категории и счета, создает транзакции в одной транзакции базы данных
+// и пересчитывает затронутые бюджеты. Общий путь для массового создания и импорта транзакций.
+// Лимит плана подписки проверяется с учетом всех создаваемых транзакций: middleware
+// CheckResourceLimits видит только уже существующие.
+func createTransactions(inputs []models.TransactionDTO, userID uint, plan models.SubscriptionPlan) ([]models.Transaction, *requestError) {
+	if limit, ok := models.TransactionLimits[plan]; ok {
+		var count int64
+		if err := db.DB.Model(&models.Transaction{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return nil, &requestError{fiber.StatusInternalServerError, "Не удалось проверить лимит транзакций", err}
+		}
+		if count+int64(len(inputs)) > limit {
+			return nil, &requestError{fiber.StatusForbidden, fmt.Sprintf("Превышен лимит транзакций для вашего плана: создано %d из %d, а в запросе %d. Перейдите на план выше или создайте меньше транзакций.", count, limit, len(inputs)), nil}
+		}
+	}
+
+	// Правила применяются до проверки, так как могут заменить категорию
+	rules, err := utils.LoadTransactionRules(userID)
+	if err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, "Не удалось загрузить правила", err}
+	}
+	types, err := userCategoryTypes(userID)
+	if err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, "Не удалось получить категории", err}
+	}
+	for i := range inputs {
+		utils.ApplyTransactionRules(rules, &inputs[i], types[inputs[i].CategoryID])
+	}
+
+	// Проверяем, что все категории и счета существуют и принадлежат пользователю
+	categoryIDs := make(map[uint]bool)
+	accountIDs := make(map[uint]bool)
+	payeeIDs := make(map[uint]bool)
+	for _, t := range inputs {
+		// Категории частей разделенных транзакций проверяются при разборе разбивки
+		if len(t.Splits) == 0 {
+			categoryIDs[t.CategoryID] = true
+		}
+		if t.AccountID != nil {
+			accountIDs[*t.AccountID] = true
+		}
+		if t.PayeeID != nil {
+			payeeIDs[*t.PayeeID] = true
+		}
+	}
+
+	for categoryID := range categoryIDs {
+		var category models.Category
+		if err := db.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Категория с ID %d не найдена или не принадлежит пользователю", categoryID), err}
+		}
+	}
+
+	for accountID := range accountIDs {
+		if err := checkAccountOwnership(&accountID, userID); err != nil {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Счет с ID %d не найден или не принадлежит пользователю", accountID), err}
+		}
+	}
+
+	payees, patterns, err := utils.LoadPayees(userID)
+	if err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, "Не удалось загрузить получателей", err}
+	}
+	for payeeID := range payeeIDs {
+		if _, ok := payees[payeeID]; !ok {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Получатель с ID %d не найден или не принадлежит пользователю", payeeID), nil}
+		}
+	}
+
+	// Внешние идентификаторы не должны повторяться ни в запросе, ни среди уже созданных транзакций
+	var externalIDs []string
+	seenExternalIDs := make(map[string]bool)
+	for _, t := range inputs {
+		if t.ExternalID == nil {
+			continue
+		}
+		if seenExternalIDs[*t.ExternalID] {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Внешний идентификатор %s указан несколько раз", *t.ExternalID), nil}
+		}
+		seenExternalIDs[*t.ExternalID] = true
+		externalIDs = append(externalIDs, *t.ExternalID)
+	}
+	existing, err := existingExternalIDs(externalIDs, userID)
+	if err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, "Не удалось проверить внешние идентификаторы", err}
+	}
+	for _, id := range externalIDs {
+		if existing[id] {
+			return nil, &requestError{fiber.StatusConflict, fmt.Sprintf("Транзакция с внешним идентификатором %s уже существует", id), nil}
+		}
+	}
+
+	// Создаем транзакции
+	transactions := make([]models.Transaction, 0, len(inputs))
+	budgetCategoryIDs := make(map[uint]bool)
+	var from, to time.Time
+	for i, t := range inputs {
+		splits, categoryID, err := buildTransactionSplits(t, userID)
+		if err != nil {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("Некорректная разбивка транзакции №%d", i+1), err}
+		}
+
+		tags, err := resolveTags(t.Tags, userID)
+		if err != nil {
+			return nil, &requestError{fiber.StatusInternalServerError, "Не удалось сохранить метки", err}
+		}
+
+		// Устанавливаем время на 12:00 дня, сохраняя дату
+		date := t.Date
+		year, month, day := date.Date()
+		normalizedDate := time.Date(year, month, day, 12, 0, 0, 0, date.Location())
+
+		// Получатель, не указанный явно, определяется по описанию
+		payeeID := t.PayeeID
+		if payeeID == nil {
+			if id, ok := utils.MatchPayee(t.Description, patterns); ok {
+				payeeID = &id
+			}
+		}
+
+		transaction := models.Transaction{
+			Amount:      t.Amount,
+			Currency:    resolveCurrency(t.Currency, t.AccountID, userID),
+			Description: t.Description,
+			Note:        t.Note,
+			Reviewed:    t.Reviewed,
+			Date:        normalizedDate,
+			Kind:        models.KindRegular,
+			CategoryID:  &categoryID,
+			AccountID:   t.AccountID,
+			PayeeID:     payeeID,
+			Splits:      splits,
+			Tags:        tags,
+			UserID:      userID,
+			ExternalID:  t.ExternalID,
+		}
+		transactions = append(transactions, transaction)
+
+		for _, id := range transactionCategoryIDs(transaction) {
+			budgetCategoryIDs[id] = true
+		}
+		if from.IsZero() || normalizedDate.Before(from) {
+			from = normalizedDate
+		}
+		if normalizedDate.After(to) {
+			to = normalizedDate
+		}
+	}
+
+	// Все транзакции создаются в одной транзакции базы данных
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&transactions, 100).Error
+	}); err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, "Не удалось создать транзакции", err}
+	}
+
+	changeLogs := make([]models.ChangeLog, 0, len(transactions))
+	for _, t := range transactions {
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionCreate, nil, t))
+	}
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+
+	// Бюджеты пересчитываем один раз за весь период после сохранения транзакций
+	ids := make([]uint, 0, len(budgetCategoryIDs))
+	for id := range budgetCategoryIDs {
+		ids = append(ids, id)
+	}
+	if err := refreshBudgets(userID, ids, from, to); err != nil {
+		// Логируем ошибку, но продолжаем выполнение
+		logError(err, "Ошибка при обновлении бюджетов после массового создания транзакций")
+	}
+
+	return transactions, nil
+}
+
+// existingExternalIDs возвращает внешние идентификаторы из списка, для которых у пользователя уже есть транзакции
+func existingExternalIDs(ids []string, userID uint) (map[string]bool, error) {
+	result := make(map[string]bool)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	// Транзакции в корзине тоже учитываются: повторный импорт не должен создавать их заново,
+	// а уникальный индекс по внешнему идентификатору распространяется и на них
+	var existing []string
+	if err := db.DB.Unscoped().Model(&models.Transaction{}).
+		Where("user_id = ? AND external_id IN ?", userID, ids).
+		Pluck("external_id", &existing).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range existing {
+		result[id] = true
+	}
+	return result, nil
+}
+
 // logError логирует ошибки
 func logError(err error, message string) {
 	fmt.Printf("[ERROR] %s: %v\n", message, err)
 }
 
-// updateBudgetSpent обновляет сумму потраченных средств в бюджете
-func (tc *TransactionController) updateBudgetSpent(categoryID uint, date time.Time, userID uint) error {
-	// Находим бюджеты, соответствующие категории и дате
+// updateBudgetSpent обновляет сумму потраченных средств в бюджетах указанных категорий
+func (tc *TransactionController) updateBudgetSpent(categoryIDs []uint, date time.Time, userID uint) error {
+	// Бюджеты родительских категорий учитывают расходы подкатегорий
+	categoryIDs, err := withAncestorCategories(categoryIDs)
+	if err != nil {
+		return err
+	}
+
+	// Находим бюджеты, соответствующие категориям и дате
 	var budgets []models.Budget
 	query := db.DB.Where("user_id = ? AND start_date <= ? AND end_date >= ?", userID, date, date)
 
-	// Если указана категория, ищем бюджеты по этой категории или без категории
-	if categoryID > 0 {
-		query = query.Where("category_id = ? OR category_id IS NULL", categoryID)
+	// Если указаны категории, ищем бюджеты по этим категориям или без категории
+	if len(categoryIDs) > 0 {
+		query = query.Where("category_id IN ? OR category_id IS NULL", categoryIDs)
 	} else {
 		query = query.Where("category_id IS NULL")
 	}
@@ -744,17 +1638,8 @@ func (tc *TransactionController) updateBudgetSpent(categoryID uint, date time.Ti
 
 	// Обновляем поле Spent для каждого бюджета
 	for _, budget := range budgets {
-		var sum float64
-		query := db.DB.Model(&models.Transaction{}).
-			Select("COALESCE(SUM(amount), 0)").
-			Where("user_id = ? AND date BETWEEN ? AND ?", userID, budget.StartDate, budget.EndDate)
-
-		// Если в бюджете указана категория, учитываем только транзакции с этой категорией
-		if budget.CategoryID != nil {
-			query = query.Where("category_id = ?", *budget.CategoryID)
-		}
-
-		if err := query.Row().Scan(&sum); err != nil {
+		sum, err := calculateBudgetSpent(budget)
+		if err != nil {
 			return err
 		}
 
@@ -765,10 +1650,65 @@ func (tc *TransactionController) updateBudgetSpent(categoryID uint, date time.Ti
 
 		// После обновления бюджета проверяем превышение пороговых значений
 		budget.Spent = sum // Обновляем локальное значение для проверки
-		if err := utils.CheckBudgetThresholds(userID); err != nil {
+		if err := CheckBudgetThresholds(userID); err != nil {
 			log.Printf("Ошибка проверки превышения бюджетов для пользователя %d: %v", userID, err)
 		}
 	}
 
 	return nil
 }
+
+// transactionCategoryIDs возвращает категории, на которые влияет транзакция:
+// категорию самой транзакции и категории всех ее частей
+func transactionCategoryIDs(t models.Transaction) []uint {
+	var categoryIDs []uint
+	seen := make(map[uint]bool)
+	if t.CategoryID != nil {
+		categoryIDs = append(categoryIDs, *t.CategoryID)
+		seen[*t.CategoryID] = true
+	}
+	for _, split := range t.Splits {
+		if !seen[split.CategoryID] {
+			categoryIDs = append(categoryIDs, split.CategoryID)
+			seen[split.CategoryID] = true
+		}
+	}
+	return categoryIDs
+}
+
+// buildTransactionSplits проверяет разбивку транзакции по категориям и возвращает части для сохранения
+// вместе с категорией самой транзакции. Все части должны относиться к категориям пользователя одного типа,
+// а их сумма - совпадать с суммой транзакции. Категорией разделенной транзакции становится категория первой части.
+func buildTransactionSplits(input models.TransactionDTO, userID uint) ([]models.TransactionSplit, uint, error) {
+	if len(input.Splits) == 0 {
+		return nil, input.CategoryID, nil
+	}
+
+	var total float64
+	var categoryType models.CategoryType
+	splits := make([]models.TransactionSplit, 0, len(input.Splits))
+	for i, split := range input.Splits {
+		var category models.Category
+		if err := db.DB.Where("id = ? AND user_id = ?", split.CategoryID, userID).First(&category).Error; err != nil {
+			return nil, 0, fmt.Errorf("категория с ID %d не найдена или не принадлежит пользователю", split.CategoryID)
+		}
+		if i > 0 && category.Type != categoryType {
+			return nil, 0, fmt.Errorf("все части должны относиться к категориям одного типа")
+		}
+		categoryType = category.Type
+		total += split.Amount
+
+		splits = append(splits, models.TransactionSplit{
+			CategoryID: split.CategoryID,
+			Amount:     split.Amount,
+			Note:       split.Note,
+		})
+	}
+
+	// Сравниваем суммы с точностью до копеек
+	if math.Round(total*100) != math.Round(input.Amount*100) {
+		return nil, 0, fmt.Errorf("сумма частей (%.2f) не совпадает с суммой транзакции (%.2f)", total, input.Amount)
+	}
+
+	return splits, input.Splits[0].CategoryID, nil
+}
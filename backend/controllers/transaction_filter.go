@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transactionFilter параметры фильтрации и сортировки списка транзакций.
+// Используется в списке транзакций и в экспорте, чтобы они выбирали одно и то же.
+type transactionFilter struct {
+	CategoryIDs        []uint
+	ExcludeCategoryIDs []uint
+	AccountID          *uint
+	PayeeIDs           []uint
+	StartDate          *time.Time
+	EndDate            *time.Time
+	Type               models.CategoryType
+	Kind               models.TransactionKind
+	ClearedStatus      models.ClearedStatus
+	Tags               []string
+	TagMode            string
+	Search             string
+	MinAmount          *float64
+	MaxAmount          *float64
+	Recurring          *bool
+	Sort               string
+	Order              string
+}
+
+// searchQuerySQL преобразует строку поиска пользователя в полнотекстовый запрос.
+// Поддерживается синтаксис поисковиков: фразы в кавычках, "or" и исключение через минус.
+const searchQuerySQL = "websearch_to_tsquery('russian', ?)"
+
+// transactionSortColumns колонки, по которым можно сортировать транзакции
+var transactionSortColumns = map[string]string{
+	"date":     "transactions.date",
+	"amount":   "transactions.amount",
+	"category": "categories.name",
+}
+
+// parseTransactionFilter разбирает параметры запроса списка транзакций.
+// В отличие от молчаливого игнорирования, каждый некорректный параметр попадает в список ошибок.
+func parseTransactionFilter(c *fiber.Ctx) (transactionFilter, []utils.ValidationError) {
+	var f transactionFilter
+	var errors []utils.ValidationError
+	addError := func(field, message string) {
+		errors = append(errors, utils.ValidationError{Field: field, Message: message})
+	}
+
+	var err error
+	if f.CategoryIDs, err = parseIDList(c.Query("category_id")); err != nil {
+		addError("category_id", err.Error())
+	}
+	if f.ExcludeCategoryIDs, err = parseIDList(c.Query("exclude_category_id")); err != nil {
+		addError("exclude_category_id", err.Error())
+	}
+
+	if f.PayeeIDs, err = parseIDList(c.Query("payee_id")); err != nil {
+		addError("payee_id", err.Error())
+	}
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		if id, err := strconv.ParseUint(accountID, 10, 64); err == nil && id > 0 {
+			uid := uint(id)
+			f.AccountID = &uid
+		} else {
+			addError("account_id", "Должен быть положительным целым числом")
+		}
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		if date, err := parseFilterDate(startDate, true); err == nil {
+			f.StartDate = &date
+		} else {
+			addError("start_date", err.Error())
+		}
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		if date, err := parseFilterDate(endDate, false); err == nil {
+			f.EndDate = &date
+		} else {
+			addError("end_date", err.Error())
+		}
+	}
+	if f.StartDate != nil && f.EndDate != nil && f.EndDate.Before(*f.StartDate) {
+		addError("end_date", "Дата окончания не может быть раньше даты начала")
+	}
+
+	switch transactionType := models.CategoryType(c.Query("type")); transactionType {
+	case "", models.Income, models.Expense:
+		f.Type = transactionType
+	default:
+		addError("type", "Значение должно быть одним из: income expense")
+	}
+
+	switch kind := models.TransactionKind(c.Query("kind")); kind {
+	case "", models.KindRegular, models.KindTransfer, models.KindRefund:
+		f.Kind = kind
+	default:
+		addError("kind", "Значение должно быть одним из: regular transfer refund")
+	}
+
+	switch clearedStatus := models.ClearedStatus(c.Query("cleared_status")); clearedStatus {
+	case "", models.ClearedNone, models.ClearedPending, models.ClearedReconciled:
+		f.ClearedStatus = clearedStatus
+	default:
+		addError("cleared_status", "Значение должно быть одним из: uncleared cleared reconciled")
+	}
+
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if name = normalizeTagName(name); name != "" {
+			f.Tags = append(f.Tags, name)
+		}
+	}
+	switch f.TagMode = c.Query("tag_mode", "any"); f.TagMode {
+	case "any", "all":
+	default:
+		addError("tag_mode", "Значение должно быть одним из: any all")
+	}
+
+	f.Search = strings.TrimSpace(c.Query("q"))
+
+	if minAmount := c.Query("min_amount"); minAmount != "" {
+		if amount, err := strconv.ParseFloat(minAmount, 64); err == nil && amount >= 0 {
+			f.MinAmount = &amount
+		} else {
+			addError("min_amount", "Должна быть неотрицательным числом")
+		}
+	}
+	if maxAmount := c.Query("max_amount"); maxAmount != "" {
+		if amount, err := strconv.ParseFloat(maxAmount, 64); err == nil && amount >= 0 {
+			f.MaxAmount = &amount
+		} else {
+			addError("max_amount", "Должна быть неотрицательным числом")
+		}
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MaxAmount < *f.MinAmount {
+		addError("max_amount", "Максимальная сумма не может быть меньше минимальной")
+	}
+
+	if recurring := c.Query("recurring"); recurring != "" {
+		if value, err := strconv.ParseBool(recurring); err == nil {
+			f.Recurring = &value
+		} else {
+			addError("recurring", "Значение должно быть true или false")
+		}
+	}
+
+	f.Sort = c.Query("sort")
+	if _, ok := transactionSortColumns[f.Sort]; f.Sort != "" && !ok {
+		addError("sort", "Значение должно быть одним из: date amount category")
+	}
+	switch f.Order = strings.ToLower(c.Query("order", "desc")); f.Order {
+	case "asc", "desc":
+	default:
+		addError("order", "Значение должно быть одним из: asc desc")
+	}
+
+	if viewID := c.Query("view_id"); viewID != "" {
+		if view, err := findSavedView(viewID, middlewares.GetUserID(c)); err == nil {
+			f.applySavedView(view, c)
+		} else {
+			addError("view_id", "Представление не найдено")
+		}
+	}
+
+	return f, errors
+}
+
+// applySavedView дополняет фильтр сохраненным представлением. Параметры, явно указанные
+// в запросе, имеют приоритет: например, start_date и end_date заменяют период представления.
+func (f *transactionFilter) applySavedView(view models.SavedView, c *fiber.Ctx) {
+	if c.Query("category_id") == "" {
+		f.CategoryIDs = view.CategoryIDs
+	}
+	if c.Query("exclude_category_id") == "" {
+		f.ExcludeCategoryIDs = view.ExcludeCategoryIDs
+	}
+	if c.Query("account_id") == "" {
+		f.AccountID = view.AccountID
+	}
+	if c.Query("type") == "" {
+		f.Type = view.Type
+	}
+	if c.Query("tags") == "" {
+		f.Tags = view.Tags
+	}
+	if c.Query("tag_mode") == "" && view.TagMode != "" {
+		f.TagMode = view.TagMode
+	}
+	if c.Query("q") == "" {
+		f.Search = view.Search
+	}
+	if c.Query("min_amount") == "" {
+		f.MinAmount = view.MinAmount
+	}
+	if c.Query("max_amount") == "" {
+		f.MaxAmount = view.MaxAmount
+	}
+	if c.Query("start_date") == "" && c.Query("end_date") == "" {
+		f.StartDate, f.EndDate = view.Period(time.Now())
+	}
+}
+
+// apply добавляет к запросу по транзакциям пользователя условия фильтра и сортировку
+func (f transactionFilter) apply(query *gorm.DB, userID uint) *gorm.DB {
+	return f.applyOrder(f.applyWhere(query, userID))
+}
+
+// applyWhere добавляет к запросу по транзакциям пользователя условия фильтра без сортировки
+func (f transactionFilter) applyWhere(query *gorm.DB, userID uint) *gorm.DB {
+	if len(f.CategoryIDs) > 0 {
+		// Разделенные транзакции находятся и по категориям своих частей
+		query = query.Where("(transactions.category_id IN ? OR transactions.id IN (SELECT transaction_id FROM transaction_splits WHERE category_id IN ?))", f.CategoryIDs, f.CategoryIDs)
+	}
+
+	if len(f.ExcludeCategoryIDs) > 0 {
+		// Разделенная транзакция исключается, если хотя бы одна ее часть относится к исключенной категории
+		query = query.Where("(transactions.category_id IS NULL OR transactions.category_id NOT IN ?)", f.ExcludeCategoryIDs).
+			Where("transactions.id NOT IN (SELECT transaction_id FROM transaction_splits WHERE category_id IN ?)", f.ExcludeCategoryIDs)
+	}
+
+	if f.AccountID != nil {
+		query = query.Where("(transactions.account_id = ? OR transactions.to_account_id = ?)", *f.AccountID, *f.AccountID)
+	}
+
+	if len(f.PayeeIDs) > 0 {
+		query = query.Where("transactions.payee_id IN ?", f.PayeeIDs)
+	}
+
+	if f.Kind != "" {
+		query = query.Where("transactions.kind = ?", f.Kind)
+	}
+	if f.ClearedStatus != "" {
+		query = query.Where("transactions.cleared_status = ?", f.ClearedStatus)
+	}
+
+	if len(f.Tags) > 0 {
+		tagQuery := db.DB.Table("transaction_tags").
+			Select("transaction_tags.transaction_id").
+			Joins("JOIN tags ON tags.id = transaction_tags.tag_id").
+			Where("tags.user_id = ? AND tags.name IN ?", userID, f.Tags)
+		if f.TagMode == "all" {
+			// Транзакция должна быть отмечена всеми указанными метками
+			tagQuery = tagQuery.Group("transaction_tags.transaction_id").
+				Having("COUNT(DISTINCT tags.id) = ?", len(f.Tags))
+		}
+		query = query.Where("transactions.id IN (?)", tagQuery)
+	}
+
+	if f.StartDate != nil {
+		query = query.Where("transactions.date >= ?", *f.StartDate)
+	}
+
+	if f.EndDate != nil {
+		query = query.Where("transactions.date <= ?", *f.EndDate)
+	}
+
+	// Суммы сравниваются в валюте транзакции
+	if f.MinAmount != nil {
+		query = query.Where("transactions.amount >= ?", *f.MinAmount)
+	}
+
+	if f.MaxAmount != nil {
+		query = query.Where("transactions.amount <= ?", *f.MaxAmount)
+	}
+
+	if f.Recurring != nil {
+		query = query.Where("transactions.is_recurring = ?", *f.Recurring)
+	}
+
+	if f.Search != "" {
+		query = query.Where("transactions.search_vector @@ "+searchQuerySQL, f.Search)
+	}
+
+	if f.Type != "" || f.Sort == "category" {
+		// Присоединяем категорию, чтобы фильтровать по типу или сортировать по названию
+		query = query.Joins("LEFT JOIN categories ON transactions.category_id = categories.id")
+	}
+
+	if f.Type != "" {
+		query = query.Where("categories.type = ?", f.Type)
+	}
+
+	return query
+}
+
+// applyOrder добавляет сортировку. По умолчанию транзакции идут от новых к старым,
+// а при поиске сначала показываются наиболее релевантные.
+func (f transactionFilter) applyOrder(query *gorm.DB) *gorm.DB {
+	direction := strings.ToUpper(f.Order)
+
+	if f.Sort == "" && f.Search != "" {
+		query = query.Order(clause.Expr{
+			SQL:                "ts_rank(transactions.search_vector, " + searchQuerySQL + ") DESC",
+			Vars:               []interface{}{f.Search},
+			WithoutParentheses: true,
+		})
+	}
+
+	column := transactionSortColumns["date"]
+	if f.Sort != "" {
+		column = transactionSortColumns[f.Sort]
+	}
+	// Транзакции без категории (переводы) при сортировке по категории всегда в конце
+	if f.Sort == "category" {
+		column += " " + direction + " NULLS LAST"
+	} else {
+		column += " " + direction
+	}
+
+	// Сортировка по id делает порядок однозначным при совпадении значений
+	return query.Order(column).Order("transactions.id " + direction)
+}
+
+// transactionCursor позиция в списке транзакций для курсорной пагинации.
+// Клиент получает курсор в закодированном виде и не должен разбирать его содержимое.
+type transactionCursor struct {
+	Date     time.Time `json:"d"`
+	ID       uint      `json:"i"`
+	Backward bool      `json:"b,omitempty"` // курсор на предыдущую страницу
+}
+
+// encodeTransactionCursor кодирует позицию транзакции в непрозрачный курсор
+func encodeTransactionCursor(t models.Transaction, backward bool) string {
+	data, _ := json.Marshal(transactionCursor{Date: t.Date, ID: t.ID, Backward: backward})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTransactionCursor разбирает курсор, полученный от клиента
+func decodeTransactionCursor(value string) (transactionCursor, error) {
+	var cursor transactionCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, fmt.Errorf("Некорректный курсор")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return cursor, fmt.Errorf("Некорректный курсор")
+	}
+	return cursor, nil
+}
+
+// findByCursor выбирает страницу транзакций после (или перед) курсором по ключу (date, id).
+// В отличие от OFFSET, страницы не съезжают при добавлении новых транзакций и не требуют COUNT(*).
+// Курсор nil означает первую страницу. Возвращает транзакции и курсоры соседних страниц.
+func (f transactionFilter) findByCursor(query *gorm.DB, cursor *transactionCursor, limit int) ([]models.Transaction, fiber.Map, error) {
+	descending := f.Order != "asc"
+	backward := cursor != nil && cursor.Backward
+
+	// При движении назад выбираем в обратном порядке, а затем разворачиваем страницу
+	comparison, direction := "<", "DESC"
+	if descending == backward {
+		comparison, direction = ">", "ASC"
+	}
+
+	if cursor != nil {
+		query = query.Where("(transactions.date, transactions.id) "+comparison+" (?, ?)", cursor.Date, cursor.ID)
+	}
+
+	// Берем на одну запись больше, чтобы узнать, есть ли следующая страница
+	var transactions []models.Transaction
+	if err := query.Order("transactions.date " + direction).Order("transactions.id " + direction).
+		Limit(limit + 1).Find(&transactions).Error; err != nil {
+		return nil, nil, err
+	}
+
+	hasMore := len(transactions) > limit
+	if hasMore {
+		transactions = transactions[:limit]
+	}
+	if backward {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
+
+	var nextCursor, prevCursor *string
+	if len(transactions) > 0 {
+		first, last := transactions[0], transactions[len(transactions)-1]
+		// Вперед можно идти, если есть еще записи или мы пришли с последующей страницы
+		if backward || hasMore {
+			next := encodeTransactionCursor(last, false)
+			nextCursor = &next
+		}
+		// Назад можно идти, если страница не первая
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			prev := encodeTransactionCursor(first, true)
+			prevCursor = &prev
+		}
+	}
+
+	meta := fiber.Map{
+		"per_page":    limit,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
+	}
+	return transactions, meta, nil
+}
+
+// parseIDList разбирает список идентификаторов через запятую
+func parseIDList(value string) ([]uint, error) {
+	var ids []uint
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("Некорректный идентификатор: %s", part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// parseFilterDate разбирает дату фильтра в формате YYYY-MM-DD или RFC3339.
+// Дата без времени для начала периода означает начало дня, для конца - конец дня.
+func parseFilterDate(value string, isStart bool) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if isStart {
+			return date, nil
+		}
+		return date.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Дата должна быть в формате YYYY-MM-DD или RFC3339")
+	}
+	return date, nil
+}
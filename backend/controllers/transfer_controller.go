@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// TransferController контроллер для переводов между счетами
+type TransferController struct{}
+
+// NewTransferController создает новый контроллер переводов
+func NewTransferController() *TransferController {
+	return &TransferController{}
+}
+
+// GetAllTransfers получает переводы пользователя с возможностью фильтрации по счету и периоду
+func (trc *TransferController) GetAllTransfers(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	query := db.DB.Where("user_id = ? AND kind = ?", userID, models.KindTransfer)
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ? OR to_account_id = ?", accountID, accountID)
+	}
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		query = query.Where("date >= ?", parseDateParam(startDateStr, true))
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		query = query.Where("date <= ?", parseDateParam(endDateStr, false))
+	}
+
+	var transfers []models.Transaction
+	if err := query.Preload("Account").Preload("ToAccount").Preload("Category").
+		Order("date DESC").
+		Find(&transfers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить переводы",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   transfers,
+	})
+}
+
+// CreateTransfer создает перевод между счетами
+func (trc *TransferController) CreateTransfer(c *fiber.Ctx) error {
+	var input models.TransferDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if err := checkTransferAccounts(input, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	feeCategoryID, message, err := transferFeeCategory(input, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить категорию комиссии",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": []utils.ValidationError{{Field: "feeCategoryId", Message: message}},
+		})
+	}
+
+	// Устанавливаем время на 12:00 дня, сохраняя дату
+	date := input.Date
+	year, month, day := date.Date()
+	normalizedDate := time.Date(year, month, day, 12, 0, 0, 0, date.Location())
+
+	transfer := models.Transaction{
+		Kind:        models.KindTransfer,
+		Amount:      input.Amount,
+		Currency:    resolveCurrency("", &input.FromAccountID, userID),
+		Fee:         input.Fee,
+		CategoryID:  feeCategoryID,
+		Description: input.Description,
+		Date:        normalizedDate,
+		AccountID:   &input.FromAccountID,
+		ToAccountID: &input.ToAccountID,
+		UserID:      userID,
+	}
+
+	if err := db.DB.Create(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось создать перевод",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, transfer.ID, models.ActionCreate, nil, transfer)
+	refreshTransferBudgets(transfer)
+
+	// Загружаем связанные счета и категорию комиссии для ответа
+	db.DB.Preload("Account").Preload("ToAccount").Preload("Category").First(&transfer, transfer.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Перевод успешно создан",
+		"data":    transfer,
+	})
+}
+
+// UpdateTransfer обновляет перевод между счетами
+func (trc *TransferController) UpdateTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var transfer models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ? AND kind = ?", id, userID, models.KindTransfer).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Перевод не найден",
+			"error":   err.Error(),
+		})
+	}
+	oldTransfer := transfer
+
+	if reconciledLocked(c, transfer) {
+		return sendReconciledLocked(c)
+	}
+
+	var input models.TransferDTO
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	if err := checkTransferAccounts(input, userID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Счет не найден или не принадлежит пользователю",
+			"error":   err.Error(),
+		})
+	}
+
+	feeCategoryID, message, err := transferFeeCategory(input, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось проверить категорию комиссии",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": []utils.ValidationError{{Field: "feeCategoryId", Message: message}},
+		})
+	}
+
+	// Устанавливаем время на 12:00 дня, сохраняя дату
+	date := input.Date
+	year, month, day := date.Date()
+	normalizedDate := time.Date(year, month, day, 12, 0, 0, 0, date.Location())
+
+	transfer.Amount = input.Amount
+	transfer.Currency = resolveCurrency("", &input.FromAccountID, userID)
+	transfer.Fee = input.Fee
+	transfer.CategoryID = feeCategoryID
+	transfer.Category = nil
+	transfer.Description = input.Description
+	transfer.Date = normalizedDate
+	transfer.AccountID = &input.FromAccountID
+	transfer.ToAccountID = &input.ToAccountID
+
+	if err := db.DB.Save(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обновить перевод",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, transfer.ID, models.ActionUpdate, oldTransfer, transfer)
+	refreshTransferBudgets(oldTransfer)
+	refreshTransferBudgets(transfer)
+
+	// Загружаем связанные счета и категорию комиссии для ответа
+	db.DB.Preload("Account").Preload("ToAccount").Preload("Category").First(&transfer, transfer.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Перевод успешно обновлен",
+		"data":    transfer,
+	})
+}
+
+// DeleteTransfer перемещает перевод между счетами в корзину
+func (trc *TransferController) DeleteTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := middlewares.GetUserID(c)
+
+	var transfer models.Transaction
+	if err := db.DB.Where("id = ? AND user_id = ? AND kind = ?", id, userID, models.KindTransfer).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Перевод не найден",
+			"error":   err.Error(),
+		})
+	}
+
+	if reconciledLocked(c, transfer) {
+		return sendReconciledLocked(c)
+	}
+
+	if err := db.DB.Delete(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось удалить перевод",
+			"error":   err.Error(),
+		})
+	}
+	utils.CreateChangeLog(userID, models.EntityTransaction, transfer.ID, models.ActionDelete, transfer, nil)
+	refreshTransferBudgets(transfer)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Перевод перемещен в корзину",
+	})
+}
+
+// checkTransferAccounts проверяет, что оба счета перевода принадлежат пользователю
+func checkTransferAccounts(input models.TransferDTO, userID uint) error {
+	if err := checkAccountOwnership(&input.FromAccountID, userID); err != nil {
+		return err
+	}
+	return checkAccountOwnership(&input.ToAccountID, userID)
+}
+
+// transferFeeCategory проверяет категорию комиссии перевода и возвращает ее для сохранения
+// в переводе. Комиссия учитывается в расходах, поэтому при ненулевой комиссии категория
+// обязательна и должна быть категорией расходов пользователя; без комиссии категория не сохраняется.
+// Описание ошибки возвращается для некорректной категории, ошибка - если проверку не удалось выполнить.
+func transferFeeCategory(input models.TransferDTO, userID uint) (*uint, string, error) {
+	if input.Fee == 0 {
+		return nil, "", nil
+	}
+	if input.FeeCategoryID == nil {
+		return nil, "Укажите категорию расходов, в которой учитывается комиссия", nil
+	}
+
+	var category models.Category
+	if err := db.DB.Where("id = ? AND user_id = ?", *input.FeeCategoryID, userID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "Категория не найдена или не принадлежит пользователю", nil
+		}
+		return nil, "", err
+	}
+	if category.Type != models.Expense {
+		return nil, "Комиссию можно учесть только в категории расходов", nil
+	}
+	return input.FeeCategoryID, "", nil
+}
+
+// refreshTransferBudgets пересчитывает бюджеты, в которых учитывается комиссия перевода
+func refreshTransferBudgets(transfer models.Transaction) {
+	if transfer.CategoryID == nil {
+		return
+	}
+	if err := refreshBudgets(transfer.UserID, []uint{*transfer.CategoryID}, transfer.Date, transfer.Date); err != nil {
+		logError(err, "Ошибка при обновлении бюджетов после изменения перевода")
+	}
+}
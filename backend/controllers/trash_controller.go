@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nikitagorchakov/finance-hub/backend/config"
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"gorm.io/gorm"
+)
+
+// TrashController контроллер корзины: удаленные транзакции, категории, бюджеты
+// и регулярные платежи хранятся в ней до окончательного удаления фоновой очисткой
+type TrashController struct {
+	Config *config.Config
+}
+
+// NewTrashController создает новый контроллер корзины
+func NewTrashController(config *config.Config) *TrashController {
+	return &TrashController{Config: config}
+}
+
+// trashTypes типы записей, которые можно запросить параметром type
+var trashTypes = map[string]bool{
+	"transactions":   true,
+	"categories":     true,
+	"budgets":        true,
+	"recurringRules": true,
+}
+
+// GetTrash возвращает записи пользователя в корзине, начиная с последних удаленных.
+// Параметр type ограничивает ответ одним типом записей.
+func (tc *TrashController) GetTrash(c *fiber.Ctx) error {
+	userID := middlewares.GetUserID(c)
+
+	itemType := c.Query("type")
+	if itemType != "" && !trashTypes[itemType] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Неизвестный тип записей. Допустимые значения: transactions, categories, budgets, recurringRules",
+		})
+	}
+
+	data := fiber.Map{}
+	var err error
+	if itemType == "" || itemType == "transactions" {
+		var transactions []models.Transaction
+		err = trashed(userID).Preload("Category").Preload("Splits.Category").Preload("Account").Preload("ToAccount").Find(&transactions).Error
+		data["transactions"] = transactions
+	}
+	if err == nil && (itemType == "" || itemType == "categories") {
+		var categories []models.Category
+		err = trashed(userID).Find(&categories).Error
+		data["categories"] = categories
+	}
+	if err == nil && (itemType == "" || itemType == "budgets") {
+		var budgets []models.Budget
+		err = trashed(userID).Preload("Category").Find(&budgets).Error
+		data["budgets"] = budgets
+	}
+	if err == nil && (itemType == "" || itemType == "recurringRules") {
+		var rules []models.RecurringRule
+		err = trashed(userID).Preload("Category").Preload("Account").Find(&rules).Error
+		data["recurringRules"] = rules
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить содержимое корзины",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   data,
+		"meta": fiber.Map{
+			"retentionDays": tc.Config.TrashRetentionDays,
+		},
+	})
+}
+
+// RestoreTrash восстанавливает записи из корзины. Удаленные категории, на которые ссылаются
+// восстанавливаемые записи, восстанавливаются вместе с ними. Возврат можно восстановить, только
+// если его расход активен или восстанавливается в том же запросе. Потраченные суммы бюджетов
+// пересчитываются с учетом восстановленных транзакций.
+func (tc *TrashController) RestoreTrash(c *fiber.Ctx) error {
+	var input models.TrashRestoreDTO
+	userID := middlewares.GetUserID(c)
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось обработать данные",
+			"error":   err.Error(),
+		})
+	}
+
+	errors := utils.ValidateStruct(input)
+	if input.IsEmpty() {
+		errors = append(errors, utils.ValidationError{Field: "transactionIds", Message: "Укажите хотя бы одну запись для восстановления"})
+	}
+	if len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": "error",
+			"errors": errors,
+		})
+	}
+
+	transactionIDs := uniqueIDs(input.TransactionIDs)
+	budgetIDs := uniqueIDs(input.BudgetIDs)
+	ruleIDs := uniqueIDs(input.RecurringRuleIDs)
+
+	var transactions []models.Transaction
+	var budgets []models.Budget
+	var rules []models.RecurringRule
+	var categories []models.Category
+	if err := findTrashed(userID, transactionIDs, &transactions, "Splits", "Tags"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить записи корзины",
+			"error":   err.Error(),
+		})
+	}
+	if err := findTrashed(userID, budgetIDs, &budgets); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить записи корзины",
+			"error":   err.Error(),
+		})
+	}
+	if err := findTrashed(userID, ruleIDs, &rules); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить записи корзины",
+			"error":   err.Error(),
+		})
+	}
+	categoryIDs := uniqueIDs(input.CategoryIDs)
+	if err := findTrashed(userID, categoryIDs, &categories); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить записи корзины",
+			"error":   err.Error(),
+		})
+	}
+
+	if len(transactions) != len(transactionIDs) || len(budgets) != len(budgetIDs) ||
+		len(rules) != len(ruleIDs) || len(categories) != len(categoryIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Некоторые записи не найдены в корзине или не принадлежат пользователю",
+		})
+	}
+
+	// Возврат восстанавливается, только если его расход активен или восстанавливается вместе с ним,
+	// и проверяется по текущему состоянию расхода так же, как при изменении: возвраты, восстановленные
+	// вместе, в сумме не превышают остаток расхода и получают его категорию и валюту
+	restoring := make(map[uint]bool, len(transactionIDs))
+	for _, id := range transactionIDs {
+		restoring[id] = true
+	}
+	var refunds []models.Transaction
+	pending := make(map[string]float64)
+	for i := range transactions {
+		refund := &transactions[i]
+		if refund.Kind != models.KindRefund || (refund.RefundOfID != nil && restoring[*refund.RefundOfID]) {
+			continue
+		}
+		var original models.Transaction
+		if refund.RefundOfID == nil || db.DB.Where("id = ? AND user_id = ?", *refund.RefundOfID, userID).Preload("Splits").First(&original).Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Исходный расход возврата %d удален: восстановите его вместе с возвратом", refund.ID),
+			})
+		}
+		input := refundInput(*refund, original)
+		key := fmt.Sprintf("%d:%d", original.ID, input.CategoryID)
+		if rerr := applyRefundInput(refund, original, input, pending[key]); rerr != nil {
+			rerr.message = fmt.Sprintf("Возврат %d: %s", refund.ID, rerr.message)
+			return rerr.send(c)
+		}
+		pending[key] += refund.Amount
+		refunds = append(refunds, *refund)
+	}
+
+	// Категории, на которые ссылаются восстанавливаемые записи, тоже должны быть восстановлены
+	var from, to time.Time
+	budgetCategoryIDs := make(map[uint]bool)
+	for _, t := range transactions {
+		for _, id := range transactionCategoryIDs(t) {
+			categoryIDs = append(categoryIDs, id)
+			budgetCategoryIDs[id] = true
+		}
+		if from.IsZero() || t.Date.Before(from) {
+			from = t.Date
+		}
+		if t.Date.After(to) {
+			to = t.Date
+		}
+	}
+	for _, budget := range budgets {
+		if budget.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *budget.CategoryID)
+		}
+	}
+	for _, rule := range rules {
+		categoryIDs = append(categoryIDs, rule.CategoryID)
+	}
+	categoryIDs = uniqueIDs(categoryIDs)
+
+	// Из категорий, на которые ссылаются записи, восстанавливаются только находящиеся в корзине
+	var restoredCategories []models.Category
+	if err := findTrashed(userID, categoryIDs, &restoredCategories); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось получить записи корзины",
+			"error":   err.Error(),
+		})
+	}
+	restoredCategoryIDs := make([]uint, 0, len(restoredCategories))
+	for _, category := range restoredCategories {
+		restoredCategoryIDs = append(restoredCategoryIDs, category.ID)
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := restoreTrashed(tx, userID, restoredCategoryIDs, &models.Category{}); err != nil {
+			return err
+		}
+		if err := restoreTrashed(tx, userID, transactionIDs, &models.Transaction{}); err != nil {
+			return err
+		}
+		for _, refund := range refunds {
+			if err := tx.Model(&models.Transaction{}).Where("id = ?", refund.ID).Updates(map[string]interface{}{
+				"currency":    refund.Currency,
+				"description": refund.Description,
+				"date":        refund.Date,
+				"category_id": refund.CategoryID,
+				"account_id":  refund.AccountID,
+				"payee_id":    refund.PayeeID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := restoreTrashed(tx, userID, budgetIDs, &models.Budget{}); err != nil {
+			return err
+		}
+		return restoreTrashed(tx, userID, ruleIDs, &models.RecurringRule{})
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Не удалось восстановить записи",
+			"error":   err.Error(),
+		})
+	}
+
+	var changeLogs []models.ChangeLog
+	for _, category := range restoredCategories {
+		category.DeletedAt = gorm.DeletedAt{}
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityCategory, category.ID, models.ActionRestore, nil, category))
+	}
+	for _, t := range transactions {
+		t.DeletedAt = gorm.DeletedAt{}
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityTransaction, t.ID, models.ActionRestore, nil, t))
+	}
+	for _, budget := range budgets {
+		budget.DeletedAt = gorm.DeletedAt{}
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityBudget, budget.ID, models.ActionRestore, nil, budget))
+	}
+	for _, rule := range rules {
+		rule.DeletedAt = gorm.DeletedAt{}
+		changeLogs = append(changeLogs, models.NewChangeLog(userID, models.EntityRecurringRule, rule.ID, models.ActionRestore, nil, rule))
+	}
+	if err := utils.CreateChangeLogs(changeLogs); err != nil {
+		logError(err, "Ошибка при записи истории изменений")
+	}
+
+	// Восстановленные транзакции снова учитываются в бюджетах, а бюджеты, пролежавшие в корзине,
+	// могли пропустить изменения транзакций, поэтому пересчитываются и те, и другие
+	if len(budgetCategoryIDs) > 0 {
+		ids := make([]uint, 0, len(budgetCategoryIDs))
+		for id := range budgetCategoryIDs {
+			ids = append(ids, id)
+		}
+		if err := refreshBudgets(userID, ids, from, to); err != nil {
+			logError(err, "Ошибка при обновлении бюджетов после восстановления транзакций")
+		}
+	}
+	for _, budget := range budgets {
+		spent, err := calculateBudgetSpent(budget)
+		if err != nil {
+			logError(err, "Ошибка при пересчете восстановленного бюджета")
+			continue
+		}
+		if err := db.DB.Model(&budget).Update("spent", spent).Error; err != nil {
+			logError(err, "Ошибка при пересчете восстановленного бюджета")
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("Восстановлено записей: %d", len(transactions)+len(budgets)+len(rules)+len(restoredCategories)),
+		"data": fiber.Map{
+			"transactions":   len(transactions),
+			"categories":     len(restoredCategories),
+			"budgets":        len(budgets),
+			"recurringRules": len(rules),
+		},
+	})
+}
+
+// trashed возвращает запрос к записям пользователя в корзине, начиная с последних удаленных
+func trashed(userID uint) *gorm.DB {
+	return db.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).Order("deleted_at DESC")
+}
+
+// findTrashed загружает записи пользователя из корзины по ID
+func findTrashed(userID uint, ids []uint, dest interface{}, preloads ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := trashed(userID).Where("id IN ?", ids)
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	return query.Find(dest).Error
+}
+
+// restoreTrashed снимает отметку об удалении с записей пользователя
+func restoreTrashed(tx *gorm.DB, userID uint, ids []uint, model interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Unscoped().Model(model).Where("id IN ? AND user_id = ?", ids, userID).Update("deleted_at", nil).Error
+}
+
+// PurgeTrash окончательно удаляет записи, которые пролежали в корзине дольше retention,
+// и возвращает количество удаленных записей. Категория удаляется, только когда на нее
+// не ссылаются другие записи, в том числе находящиеся в корзине.
+func PurgeTrash(retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	var purged int64
+
+	// Части, метки, вложения и отметки дубликатов удаляются каскадно, файлы вложений - после записей
+	var transactionIDs []uint
+	if err := db.DB.Unscoped().Model(&models.Transaction{}).Where("deleted_at < ?", cutoff).Pluck("id", &transactionIDs).Error; err != nil {
+		return purged, err
+	}
+	if len(transactionIDs) > 0 {
+		attachments, err := transactionAttachments(transactionIDs)
+		if err != nil {
+			return purged, err
+		}
+		result := db.DB.Unscoped().Where("id IN ?", transactionIDs).Delete(&models.Transaction{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		deleteAttachmentFiles(attachments)
+		purged += result.RowsAffected
+	}
+
+	result := db.DB.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Budget{})
+	if result.Error != nil {
+		return purged, result.Error
+	}
+	purged += result.RowsAffected
+
+	// Транзакции, созданные регулярным платежом, остаются и только теряют ссылку на него
+	var ruleIDs []uint
+	if err := db.DB.Unscoped().Model(&models.RecurringRule{}).Where("deleted_at < ?", cutoff).Pluck("id", &ruleIDs).Error; err != nil {
+		return purged, err
+	}
+	if len(ruleIDs) > 0 {
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Model(&models.Transaction{}).Where("recurring_rule_id IN ?", ruleIDs).Update("recurring_rule_id", nil).Error; err != nil {
+				return err
+			}
+			result := tx.Unscoped().Where("id IN ?", ruleIDs).Delete(&models.RecurringRule{})
+			purged += result.RowsAffected
+			return result.Error
+		}); err != nil {
+			return purged, err
+		}
+	}
+
+	result = db.DB.Unscoped().Where(`deleted_at < ?
+		AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.category_id = categories.id)
+		AND NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.category_id = categories.id)
+		AND NOT EXISTS (SELECT 1 FROM budgets b WHERE b.category_id = categories.id)
+		AND NOT EXISTS (SELECT 1 FROM recurring_rules r WHERE r.category_id = categories.id)`, cutoff).
+		Delete(&models.Category{})
+	if result.Error != nil {
+		return purged, result.Error
+	}
+	purged += result.RowsAffected
+
+	return purged, nil
+}
@@ -16,8 +16,18 @@ func MigrateDB() {
 	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.Category{},
+		&models.Account{},
+		&models.Reconciliation{},
 		&models.RecurringRule{},
+		&models.Tag{},
+		&models.Payee{},
+		&models.PayeeAlias{},
+		&models.TransactionRule{},
+		&models.SavedView{},
 		&models.Transaction{},
+		&models.TransactionSplit{},
+		&models.DuplicateDismissal{},
+		&models.Attachment{},
 		&models.Budget{},
 		&models.Subscription{},
 		&models.Payment{},
@@ -30,6 +40,7 @@ func MigrateDB() {
 		&models.Investment{},
 		&models.InvestmentOperation{},
 		&models.ChangeLog{},
+		&models.ExchangeRate{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -37,6 +48,12 @@ func MigrateDB() {
 	// Обновляем существующих пользователей, устанавливая роль по умолчанию
 	MigrateUserRoles()
 
+	// Создаем функции пересчета валют, которые используются в отчетах
+	MigrateCurrencyFunctions()
+
+	// Создаем полнотекстовый индекс по транзакциям
+	MigrateTransactionSearch()
+
 	log.Println("Database migration completed successfully")
 }
 
@@ -53,6 +70,115 @@ func MigrateUserRoles() {
 	}
 }
 
+// MigrateCurrencyFunctions создает SQL-функции для пересчета сумм между валютами.
+// Курсы хранятся относительно рубля, поэтому кросс-курс считается через рубль.
+// Берется последний курс на дату или раньше. Если операция старше первого загруженного курса
+// валюты, используется самый ранний из более поздних курсов: история курсов обычно загружается
+// не с самого начала, и такой курс ближе к настоящему, чем отказ от пересчета.
+// Если курсов валюты нет совсем, fx_rate возвращает NULL, а fx_rate_required завершает запрос
+// ошибкой, чтобы непересчитанные суммы не попадали в статистику и бюджеты.
+func MigrateCurrencyFunctions() {
+	log.Println("Migrating currency functions...")
+
+	functions := []string{
+		`CREATE OR REPLACE FUNCTION fx_rub_rate(currency_code varchar, on_date date)
+		RETURNS double precision AS $$
+			SELECT (CASE WHEN currency_code = 'RUB' THEN 1.0 ELSE (
+				SELECT er.rate FROM exchange_rates er
+				WHERE er.currency = currency_code
+				ORDER BY er.date > on_date, abs(er.date - on_date)
+				LIMIT 1
+			) END)::double precision
+		$$ LANGUAGE sql STABLE`,
+		`CREATE OR REPLACE FUNCTION fx_rate(from_currency varchar, to_currency varchar, on_date date)
+		RETURNS double precision AS $$
+			SELECT (CASE WHEN from_currency = to_currency THEN 1.0
+				ELSE fx_rub_rate(from_currency, on_date) / NULLIF(fx_rub_rate(to_currency, on_date), 0)
+			END)::double precision
+		$$ LANGUAGE sql STABLE`,
+		`CREATE OR REPLACE FUNCTION fx_rate_required(from_currency varchar, to_currency varchar, on_date date)
+		RETURNS double precision AS $$
+		DECLARE
+			rate double precision := fx_rate(from_currency, to_currency, on_date);
+		BEGIN
+			IF rate IS NULL THEN
+				RAISE EXCEPTION 'Нет курса для пересчета % в %: загрузите курсы валют', from_currency, to_currency;
+			END IF;
+			RETURN rate;
+		END
+		$$ LANGUAGE plpgsql STABLE`,
+	}
+
+	for _, function := range functions {
+		if err := DB.Exec(function).Error; err != nil {
+			log.Fatalf("Failed to create currency function: %v", err)
+		}
+	}
+}
+
+// MigrateTransactionSearch создает колонку search_vector с полнотекстовым индексом по транзакциям.
+// В документ входят описание транзакции, название ее категории, а также категории и заметки частей
+// разделенной транзакции. Конфигурация russian стеммит кириллицу русским стеммером,
+// а латиницу - английским, поэтому поиск работает на обоих языках.
+// Колонка поддерживается триггерами, в том числе при переименовании категории.
+func MigrateTransactionSearch() {
+	log.Println("Migrating transaction search index...")
+
+	statements := []string{
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_search_vector ON transactions USING GIN (search_vector)`,
+		`CREATE OR REPLACE FUNCTION transaction_search_vector(tx_id bigint, tx_description text, tx_category_id bigint)
+		RETURNS tsvector AS $$
+			SELECT setweight(to_tsvector('russian', COALESCE(tx_description, '')), 'A') ||
+				setweight(to_tsvector('russian', COALESCE((SELECT name FROM categories WHERE id = tx_category_id), '')), 'B') ||
+				setweight(to_tsvector('russian', COALESCE((
+					SELECT string_agg(COALESCE(c.name, '') || ' ' || COALESCE(s.note, ''), ' ')
+					FROM transaction_splits s
+					LEFT JOIN categories c ON c.id = s.category_id
+					WHERE s.transaction_id = tx_id
+				), '')), 'C')
+		$$ LANGUAGE sql STABLE`,
+		`CREATE OR REPLACE FUNCTION transactions_search_trigger() RETURNS trigger AS $$
+		BEGIN
+			NEW.search_vector := transaction_search_vector(NEW.id, NEW.description, NEW.category_id);
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS trg_transactions_search ON transactions`,
+		`CREATE TRIGGER trg_transactions_search BEFORE INSERT OR UPDATE ON transactions
+		FOR EACH ROW EXECUTE FUNCTION transactions_search_trigger()`,
+		`CREATE OR REPLACE FUNCTION transaction_splits_search_trigger() RETURNS trigger AS $$
+		BEGIN
+			UPDATE transactions SET search_vector = transaction_search_vector(id, description, category_id)
+			WHERE id = COALESCE(NEW.transaction_id, OLD.transaction_id);
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS trg_transaction_splits_search ON transaction_splits`,
+		`CREATE TRIGGER trg_transaction_splits_search AFTER INSERT OR UPDATE OR DELETE ON transaction_splits
+		FOR EACH ROW EXECUTE FUNCTION transaction_splits_search_trigger()`,
+		`CREATE OR REPLACE FUNCTION categories_search_trigger() RETURNS trigger AS $$
+		BEGIN
+			UPDATE transactions SET search_vector = transaction_search_vector(id, description, category_id)
+			WHERE category_id = NEW.id OR id IN (SELECT transaction_id FROM transaction_splits WHERE category_id = NEW.id);
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS trg_categories_search ON categories`,
+		`CREATE TRIGGER trg_categories_search AFTER UPDATE OF name ON categories
+		FOR EACH ROW EXECUTE FUNCTION categories_search_trigger()`,
+		// Заполняем индекс для транзакций, созданных до появления поиска
+		`UPDATE transactions SET search_vector = transaction_search_vector(id, description, category_id)
+		WHERE search_vector IS NULL`,
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to migrate transaction search: %v", err)
+		}
+	}
+}
+
 // SeedDefaultData заполняет базу начальными данными (только для разработки)
 func SeedDefaultData() {
 	log.Println("Seeding default data...")
@@ -0,0 +1,158 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ClientBankExchange текстовый формат обмена с системой «Клиент-Банк» 1С (1CClientBankExchange).
+// Выгружается большинством банков для расчетных счетов организаций и ИП.
+type ClientBankExchange struct{}
+
+// clientBankSignature первая строка файла формата
+const clientBankSignature = "1CClientBankExchange"
+
+// innPrefix префикс «ИНН 1234567890» перед названием контрагента
+var innPrefix = regexp.MustCompile(`^ИНН\s*\d+\s+`)
+
+// Name идентификатор формата
+func (ClientBankExchange) Name() string { return "1c_client_bank" }
+
+// Title название формата
+func (ClientBankExchange) Title() string { return "1С: Клиент-Банк (1CClientBankExchange)" }
+
+// Detect проверяет сигнатуру формата в первой строке
+func (ClientBankExchange) Detect(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), []byte(clientBankSignature))
+}
+
+// decode перекодирует файл в UTF-8 по значению поля «Кодировка»
+func (ClientBankExchange) decode(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	// Название кодировки записано латиницей и кириллицей, совпадающей во всех вариантах,
+	// поэтому определяем ее по байтам до перекодирования
+	switch {
+	case bytes.Contains(data, []byte("=DOS")):
+		return charmap.CodePage866.NewDecoder().String(string(data))
+	case bytes.Contains(data, []byte("=Windows")):
+		return charmap.Windows1251.NewDecoder().String(string(data))
+	}
+	return string(data), nil
+}
+
+// Parse разбирает секции документов. Направление платежа определяется по датам
+// списания и поступления, а если их нет - по расчетному счету владельца выписки.
+func (p ClientBankExchange) Parse(data []byte) ([]models.ImportRow, error) {
+	if !p.Detect(data) {
+		return nil, fmt.Errorf("файл не является выгрузкой 1CClientBankExchange")
+	}
+	text, err := p.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось перекодировать файл: %w", err)
+	}
+
+	ownAccounts := make(map[string]bool)
+	var rows []models.ImportRow
+	var document map[string]string
+	documentLine := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		key, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		value = strings.TrimSpace(value)
+
+		switch {
+		case key == "РасчСчет" && document == nil:
+			ownAccounts[value] = true
+		case key == "СекцияДокумент":
+			document = make(map[string]string)
+			documentLine = line
+		case key == "КонецДокумента":
+			if document != nil {
+				rows = append(rows, p.parseDocument(document, documentLine, ownAccounts))
+			}
+			document = nil
+		case document != nil:
+			document[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// parseDocument преобразует секцию документа в строку импорта
+func (ClientBankExchange) parseDocument(document map[string]string, line int, ownAccounts map[string]bool) models.ImportRow {
+	row := models.ImportRow{Line: line, Currency: models.DefaultCurrency}
+
+	field := func(names ...string) string {
+		for _, name := range names {
+			if value := document[name]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	payer := field("Плательщик1", "Плательщик")
+	payerAccount := field("ПлательщикСчет", "ПлательщикРасчСчет")
+	recipient := field("Получатель1", "Получатель")
+	recipientAccount := field("ПолучательСчет", "ПолучательРасчСчет")
+
+	dateValue := field("Дата")
+	switch {
+	case field("ДатаСписано") != "":
+		row.Type = models.Expense
+		dateValue = field("ДатаСписано")
+	case field("ДатаПоступило") != "":
+		row.Type = models.Income
+		dateValue = field("ДатаПоступило")
+	case ownAccounts[payerAccount]:
+		row.Type = models.Expense
+	case ownAccounts[recipientAccount]:
+		row.Type = models.Income
+	default:
+		row.Error = "не удалось определить направление платежа"
+		return row
+	}
+
+	counterparty := recipient
+	if row.Type == models.Income {
+		counterparty = payer
+	}
+	row.Counterparty = strings.TrimSpace(innPrefix.ReplaceAllString(counterparty, ""))
+	row.Description = row.Counterparty
+	if purpose := field("НазначениеПлатежа"); purpose != "" {
+		row.Description = strings.TrimSpace(row.Counterparty + " — " + purpose)
+	}
+
+	date, err := time.Parse("02.01.2006", dateValue)
+	if err != nil {
+		row.Error = fmt.Sprintf("некорректная дата: %q", dateValue)
+		return row
+	}
+	row.Date = date
+
+	// Сумма в формате обмена всегда с точкой
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(field("Сумма"), ",", "."), 64)
+	if err != nil || amount <= 0 {
+		row.Error = fmt.Sprintf("некорректная сумма: %q", field("Сумма"))
+		return row
+	}
+	row.Amount = amount
+
+	return row
+}
@@ -0,0 +1,93 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestClientBankExchangeParse(t *testing.T) {
+	// Файл в кодировке windows-1251: направление определяется по дате списания,
+	// по счету владельца выписки, а для чужих счетов возвращается ошибка
+	rows, err := ClientBankExchange{}.Parse(readFixture(t, "clientbank.txt"))
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	checkRows(t, rows, []wantRow{
+		{line: 13, date: "2024-03-04 00:00", amount: 12500, typ: models.Expense, currency: "RUB", description: `ООО "Поставщик" — Оплата по счету 42, без НДС`},
+		{line: 24, date: "2024-03-05 00:00", amount: 30000.5, typ: models.Income, currency: "RUB", description: `ООО "Клиент" — Оплата по договору 1`},
+		{line: 34, err: "не удалось определить направление платежа"},
+		{line: 42, err: `некорректная сумма: "-5"`},
+	})
+	if rows[0].Counterparty != `ООО "Поставщик"` {
+		t.Errorf("контрагент %q, ИНН должен быть отброшен", rows[0].Counterparty)
+	}
+}
+
+func TestClientBankExchangeEncodings(t *testing.T) {
+	text := strings.Join([]string{
+		"1CClientBankExchange",
+		"Кодировка=DOS",
+		"РасчСчет=40702810900000000001",
+		"СекцияДокумент=Платежное поручение",
+		"Дата=01.02.2024",
+		"Сумма=10.00",
+		"ПлательщикСчет=40702810900000000001",
+		"Получатель=Оператор связи",
+		"КонецДокумента",
+		"КонецФайла",
+	}, "\r\n")
+	dos, err := charmap.CodePage866.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"UTF-8", text},
+		{"UTF-8 с BOM", "\xef\xbb\xbf" + text},
+		{"DOS (866)", dos},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ClientBankExchange{}.Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ошибка разбора: %v", err)
+			}
+			checkRows(t, rows, []wantRow{
+				{line: 4, date: "2024-02-01 00:00", amount: 10, typ: models.Expense, currency: "RUB", description: "Оператор связи"},
+			})
+		})
+	}
+}
+
+func TestClientBankExchangeParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		rows    int
+	}{
+		{"нет сигнатуры", "СекцияДокумент=Платежное поручение\nКонецДокумента\n", true, 0},
+		{"пустой файл", "", true, 0},
+		{"слишком длинная строка", "1CClientBankExchange\nНазначениеПлатежа=" + strings.Repeat("x", 2*1024*1024) + "\n", true, 0},
+		{"документ без конца", "1CClientBankExchange\nСекцияДокумент=Платежное поручение\nСумма=10\n", false, 0},
+		{"конец без документа", "1CClientBankExchange\nКонецДокумента\nКонецДокумента\n", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseWithin(t, ClientBankExchange{}, []byte(tt.data), 5*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if len(rows) != tt.rows {
+				t.Errorf("получено %d строк, ожидалось %d", len(rows), tt.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Package importers содержит разборщики банковских выписок и файлов обмена
+// с другими финансовыми программами (OFX, QIF). Каждый разборщик
+// приводит операции своего формата к строкам импорта models.ImportRow, которые затем
+// сопоставляются с категориями пользователя и сохраняются общим путем массового создания транзакций.
+package importers
+
+import (
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// Parser разборщик выписки одного формата
+type Parser interface {
+	// Name идентификатор формата, который передается в параметрах импорта
+	Name() string
+	// Title название формата для пользователя
+	Title() string
+	// Detect проверяет, что файл похож на выписку этого формата
+	Detect(data []byte) bool
+	// Parse разбирает операции выписки
+	Parse(data []byte) ([]models.ImportRow, error)
+}
+
+// parsers зарегистрированные разборщики. Новый формат подключается добавлением разборщика в список.
+var parsers = []Parser{
+	TinkoffCSV{},
+	SberbankPDF{},
+	ClientBankExchange{},
+	OFX{},
+	QIF{},
+}
+
+// List возвращает все поддерживаемые форматы выписок
+func List() []Parser {
+	return parsers
+}
+
+// Get возвращает разборщик по идентификатору формата
+func Get(name string) (Parser, bool) {
+	for _, parser := range parsers {
+		if parser.Name() == name {
+			return parser, true
+		}
+	}
+	return nil, false
+}
+
+// Detect определяет формат выписки по содержимому файла
+func Detect(data []byte) (Parser, bool) {
+	for _, parser := range parsers {
+		if parser.Detect(data) {
+			return parser, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,127 @@
+package importers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// wantRow ожидаемые поля строки импорта
+type wantRow struct {
+	line        int
+	date        string // в формате 2006-01-02 15:04
+	amount      float64
+	typ         models.CategoryType
+	currency    string
+	category    string
+	description string
+	err         string
+}
+
+// readFixture читает файл из testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("не удалось прочитать %s: %v", name, err)
+	}
+	return data
+}
+
+// checkRows сравнивает разобранные строки с ожидаемыми
+func checkRows(t *testing.T, got []models.ImportRow, want []wantRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("получено %d строк, ожидалось %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Error != w.err {
+			t.Errorf("строка %d: ошибка %q, ожидалась %q", i, g.Error, w.err)
+		}
+		if g.Line != w.line {
+			t.Errorf("строка %d: номер строки %d, ожидался %d", i, g.Line, w.line)
+		}
+		if w.err != "" {
+			continue
+		}
+		if date := g.Date.Format("2006-01-02 15:04"); date != w.date {
+			t.Errorf("строка %d: дата %s, ожидалась %s", i, date, w.date)
+		}
+		if g.Amount != w.amount || g.Type != w.typ || g.Currency != w.currency {
+			t.Errorf("строка %d: %v %s %s, ожидалось %v %s %s", i, g.Amount, g.Type, g.Currency, w.amount, w.typ, w.currency)
+		}
+		if g.Category != w.category {
+			t.Errorf("строка %d: категория %q, ожидалась %q", i, g.Category, w.category)
+		}
+		if g.Description != w.description {
+			t.Errorf("строка %d: описание %q, ожидалось %q", i, g.Description, w.description)
+		}
+	}
+}
+
+// parseWithin разбирает данные и завершает тест, если разбор не уложился во время:
+// поврежденный файл не должен приводить к зависанию
+func parseWithin(t *testing.T, parser Parser, data []byte, limit time.Duration) ([]models.ImportRow, error) {
+	t.Helper()
+	type result struct {
+		rows []models.ImportRow
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rows, err := parser.Parse(data)
+		done <- result{rows, err}
+	}()
+	select {
+	case r := <-done:
+		return r.rows, r.err
+	case <-time.After(limit):
+		t.Fatalf("разбор %s не завершился за %s", parser.Name(), limit)
+		return nil, nil
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"tinkoff.csv", "tinkoff_csv"},
+		{"clientbank.txt", "1c_client_bank"},
+		{"sberbank.pdf", "sberbank_pdf"},
+		{"sgml.ofx", "ofx"},
+		{"xml.ofx", "ofx"},
+		{"bank.qif", "qif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			parser, ok := Detect(readFixture(t, tt.fixture))
+			if !ok {
+				t.Fatalf("формат не определен")
+			}
+			if parser.Name() != tt.want {
+				t.Errorf("определен формат %s, ожидался %s", parser.Name(), tt.want)
+			}
+		})
+	}
+
+	if parser, ok := Detect([]byte("просто текст")); ok {
+		t.Errorf("для произвольного текста определен формат %s", parser.Name())
+	}
+}
+
+func TestGet(t *testing.T) {
+	for _, parser := range List() {
+		found, ok := Get(parser.Name())
+		if !ok || found.Name() != parser.Name() {
+			t.Errorf("разборщик %s не найден по идентификатору", parser.Name())
+		}
+	}
+	if _, ok := Get("unknown"); ok {
+		t.Errorf("найден разборщик для неизвестного формата")
+	}
+}
@@ -0,0 +1,381 @@
+package importers
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// OFX выписка в формате Open Financial Exchange версий 1.x (SGML) и 2.x (XML).
+// Элементы с префиксом FH. - расширения, которыми экспорт приложения сохраняет
+// категории, разбивку и регулярные платежи.
+type OFX struct{}
+
+// Name идентификатор формата
+func (OFX) Name() string { return "ofx" }
+
+// Title название формата
+func (OFX) Title() string { return "OFX (Open Financial Exchange)" }
+
+// Detect проверяет заголовок OFX или корневой элемент в начале файла
+func (OFX) Detect(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	head = bytes.ToUpper(head)
+	return bytes.Contains(head, []byte("OFXHEADER")) || bytes.Contains(head, []byte("<OFX>"))
+}
+
+// ofxNode элемент OFX. У простых элементов есть только значение, у агрегатов - только дочерние элементы.
+type ofxNode struct {
+	name     string
+	value    string
+	line     int
+	children []*ofxNode
+}
+
+// child возвращает первый дочерний элемент с указанным именем
+func (n *ofxNode) child(name string) *ofxNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// path возвращает значение элемента по цепочке имен, например "CURRENCY", "CURSYM"
+func (n *ofxNode) path(names ...string) string {
+	node := n
+	for _, name := range names {
+		if node = node.child(name); node == nil {
+			return ""
+		}
+	}
+	return node.value
+}
+
+// findAll собирает все вложенные элементы с указанным именем
+func (n *ofxNode) findAll(name string, result []*ofxNode) []*ofxNode {
+	for _, child := range n.children {
+		if child.name == name {
+			result = append(result, child)
+			continue
+		}
+		result = child.findAll(name, result)
+	}
+	return result
+}
+
+// decode перекодирует файл в UTF-8. Файлы OFX 1.x из российских банков часто
+// выгружаются в Windows-1251, что указывается в заголовке CHARSET:1251.
+func (OFX) decode(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	return charmap.Windows1251.NewDecoder().String(string(data))
+}
+
+// parseOFXTree строит дерево элементов. В SGML закрывающие теги простых элементов
+// необязательны: элементы, которые ни разу не закрываются в файле, считаются простыми,
+// а элемент со значением без закрывающего тега закрывается следующим тегом.
+func parseOFXTree(text string) (*ofxNode, error) {
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("не найден элемент <OFX>")
+	}
+	line := 1 + strings.Count(text[:start], "\n")
+	text = text[start:]
+
+	closed := make(map[string]bool)
+	for _, part := range strings.Split(text, "</")[1:] {
+		if end := strings.IndexByte(part, '>'); end > 0 {
+			closed[strings.ToUpper(strings.TrimSpace(part[:end]))] = true
+		}
+	}
+
+	root := &ofxNode{}
+	stack := []*ofxNode{root}
+	var leaf *ofxNode // простой элемент без закрывающего тега, значение которого идет следующим
+	top := func() *ofxNode { return stack[len(stack)-1] }
+	// closeLeaf закрывает простой элемент, у которого нет закрывающего тега
+	closeLeaf := func() {
+		if node := top(); len(stack) > 1 && node.value != "" && len(node.children) == 0 {
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for len(text) > 0 {
+		open := strings.IndexByte(text, '<')
+		if open < 0 {
+			break
+		}
+		if value := strings.TrimSpace(html.UnescapeString(text[:open])); value != "" {
+			if leaf != nil {
+				leaf.value = value
+			} else if len(stack) > 1 {
+				top().value = value
+			}
+		}
+		leaf = nil
+		line += strings.Count(text[:open], "\n")
+		text = text[open:]
+
+		end := strings.IndexByte(text, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("строка %d: незакрытый тег", line)
+		}
+		tag := strings.TrimSpace(text[1:end])
+		line += strings.Count(text[:end], "\n")
+		text = text[end+1:]
+
+		switch {
+		case tag == "" || strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!"):
+			// Инструкции обработки и комментарии XML
+		case strings.HasPrefix(tag, "/"):
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			if node := top(); node.name != name {
+				closeLeaf()
+			}
+			// Закрываем все элементы до найденного; тег без пары игнорируется
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		default:
+			closeLeaf()
+			name := strings.ToUpper(strings.Fields(strings.TrimSuffix(tag, "/"))[0])
+			node := &ofxNode{name: name, line: line}
+			top().children = append(top().children, node)
+			if closed[name] && !strings.HasSuffix(tag, "/") {
+				stack = append(stack, node)
+			} else {
+				leaf = node
+			}
+		}
+	}
+
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("файл не содержит элементов OFX")
+	}
+	return root.children[0], nil
+}
+
+// Parse разбирает операции всех выписок файла: банковских счетов (STMTRS) и карт (CCSTMTRS).
+// Внешний идентификатор операции составляется из номера счета и FITID.
+func (p OFX) Parse(data []byte) ([]models.ImportRow, error) {
+	text, err := p.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось перекодировать файл: %w", err)
+	}
+	root, err := parseOFXTree(text)
+	if err != nil {
+		return nil, fmt.Errorf("файл не похож на выписку OFX: %w", err)
+	}
+
+	statements := root.findAll("STMTRS", nil)
+	statements = root.findAll("CCSTMTRS", statements)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("файл не содержит выписок по счетам")
+	}
+
+	var rows []models.ImportRow
+	for _, statement := range statements {
+		currency := strings.ToUpper(statement.path("CURDEF"))
+		account := statement.path("BANKACCTFROM", "ACCTID")
+		if account == "" {
+			account = statement.path("CCACCTFROM", "ACCTID")
+		}
+
+		list := statement.child("BANKTRANLIST")
+		if list == nil {
+			continue
+		}
+		for _, item := range list.children {
+			if item.name == "STMTTRN" {
+				rows = append(rows, parseOFXTransaction(item, currency, account))
+			}
+		}
+	}
+	return rows, nil
+}
+
+// parseOFXTransaction разбирает одну операцию выписки
+func parseOFXTransaction(item *ofxNode, currency, account string) models.ImportRow {
+	name := item.path("NAME")
+	if name == "" {
+		name = item.path("PAYEE", "NAME")
+	}
+	memo := item.path("MEMO")
+
+	row := models.ImportRow{
+		Line:         item.line,
+		Currency:     currency,
+		Description:  ofxDescription(name, memo),
+		Category:     item.path("FH.CATEGORY"),
+		Counterparty: name,
+	}
+	// CURRENCY означает, что сумма указана в другой валюте, ORIGCURRENCY - что она уже пересчитана в CURDEF
+	if symbol := item.path("CURRENCY", "CURSYM"); symbol != "" {
+		row.Currency = strings.ToUpper(symbol)
+	}
+	if fitID := item.path("FITID"); fitID != "" {
+		row.ExternalID = fitID
+		if account != "" {
+			row.ExternalID = account + ":" + fitID
+		}
+	}
+
+	date, err := parseOFXDate(item.path("DTPOSTED"))
+	if err != nil {
+		row.Error = fmt.Sprintf("некорректная дата: %q", item.path("DTPOSTED"))
+		return row
+	}
+	row.Date = date
+
+	amount, err := parseOFXAmount(item.path("TRNAMT"))
+	if err != nil || amount == 0 {
+		row.Error = fmt.Sprintf("некорректная сумма: %q", item.path("TRNAMT"))
+		return row
+	}
+	row.Type = models.Income
+	if amount < 0 {
+		row.Type = models.Expense
+	}
+	row.Amount = math.Abs(amount)
+
+	for _, split := range item.children {
+		if split.name != "FH.SPLIT" {
+			continue
+		}
+		splitAmount, err := parseOFXAmount(split.path("TRNAMT"))
+		if err != nil {
+			row.Error = fmt.Sprintf("некорректная сумма части: %q", split.path("TRNAMT"))
+			return row
+		}
+		row.Splits = append(row.Splits, models.ImportSplit{
+			Category: split.path("FH.CATEGORY"),
+			Amount:   math.Abs(splitAmount),
+			Note:     split.path("MEMO"),
+		})
+	}
+	if err := checkImportSplits(&row); err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	if recurring := item.child("FH.RECURRING"); recurring != nil {
+		row.Recurring = parseOFXRecurring(recurring)
+	}
+	return row
+}
+
+// parseOFXRecurring разбирает правило регулярного платежа из расширения FH.RECURRING.
+// Неполное правило игнорируется, а сама операция импортируется.
+func parseOFXRecurring(node *ofxNode) *models.ImportRecurring {
+	rule := models.ImportRecurring{
+		Key:         node.path("FH.RULEID"),
+		Frequency:   models.RecurringFrequency(strings.ToLower(node.path("FH.FREQUENCY"))),
+		IsActive:    node.path("FH.ACTIVE") != "N",
+		Description: node.path("FH.DESCRIPTION"),
+	}
+
+	var err error
+	if rule.StartDate, err = parseOFXDate(node.path("FH.STARTDATE")); err != nil {
+		return nil
+	}
+	if rule.NextExecuteDate, err = parseOFXDate(node.path("FH.NEXTDATE")); err != nil {
+		return nil
+	}
+	if value := node.path("FH.ENDDATE"); value != "" {
+		endDate, err := parseOFXDate(value)
+		if err != nil {
+			return nil
+		}
+		rule.EndDate = &endDate
+	}
+	amount, err := parseOFXAmount(node.path("FH.AMOUNT"))
+	if err != nil {
+		return nil
+	}
+	rule.Amount = math.Abs(amount)
+
+	if rule.Key == "" || !validRecurringFrequency(rule.Frequency) {
+		return nil
+	}
+	return &rule
+}
+
+// ofxDescription составляет описание из получателя и примечания. Экспорт записывает
+// в NAME сокращенное описание, а полное - в MEMO, поэтому такое примечание заменяет NAME.
+func ofxDescription(name, memo string) string {
+	switch {
+	case memo == "" || memo == name:
+		return name
+	case name == "" || strings.HasPrefix(memo, name):
+		return memo
+	}
+	return name + " — " + memo
+}
+
+// parseOFXDate разбирает дату вида YYYYMMDD[HHMMSS[.XXX]][[gmt offset:tz name]].
+// Время не учитывается, так как транзакции хранят только дату.
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("некорректная дата: %q", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// parseOFXAmount разбирает сумму. Стандарт требует точку, но часть банков пишет запятую.
+func parseOFXAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, ",") && !strings.Contains(value, ".") {
+		return utils.ParseImportAmount(value, ",")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// checkImportSplits проверяет разбивку операции: одна часть заменяет категорию операции,
+// а сумма нескольких частей должна совпадать с суммой операции
+func checkImportSplits(row *models.ImportRow) error {
+	switch len(row.Splits) {
+	case 0:
+		return nil
+	case 1:
+		row.Category = row.Splits[0].Category
+		row.Splits = nil
+		return nil
+	}
+
+	var total float64
+	for _, split := range row.Splits {
+		total += split.Amount
+	}
+	if math.Round(total*100) != math.Round(row.Amount*100) {
+		return fmt.Errorf("сумма частей (%.2f) не совпадает с суммой операции (%.2f)", total, row.Amount)
+	}
+	return nil
+}
+
+// validRecurringFrequency проверяет, что частота регулярного платежа поддерживается
+func validRecurringFrequency(frequency models.RecurringFrequency) bool {
+	switch frequency {
+	case models.RecurringDaily, models.RecurringWeekly, models.RecurringMonthly, models.RecurringYearly:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package importers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+func TestOFXParse(t *testing.T) {
+	// Одна и та же выписка в SGML (OFX 1.x, Windows-1251, без закрывающих тегов)
+	// и в XML (OFX 2.x) разбирается одинаково
+	tests := []struct {
+		fixture string
+		lines   []int
+	}{
+		{"sgml.ofx", []int{35, 43, 50, 61, 85, 94}},
+		{"xml.ofx", []int{24, 32, 39, 47, 66, 73}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			rows, err := OFX{}.Parse(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("ошибка разбора: %v", err)
+			}
+			checkRows(t, rows, []wantRow{
+				{line: tt.lines[0], date: "2024-03-01 00:00", amount: 1250.5, typ: models.Expense, currency: "RUB", description: "Пятёрочка — Покупка продуктов"},
+				{line: tt.lines[1], date: "2024-03-05 00:00", amount: 50000, typ: models.Income, currency: "RUB", description: "ООО Работодатель & Ко"},
+				{line: tt.lines[2], date: "2024-03-06 00:00", amount: 15.99, typ: models.Expense, currency: "USD", description: "Spotify"},
+				{line: tt.lines[3], err: `некорректная дата: "2024"`},
+				{line: tt.lines[4], date: "2024-03-07 00:00", amount: 300, typ: models.Expense, currency: "RUB", description: "Кофейня"},
+				{line: tt.lines[5], err: `некорректная сумма: "0"`},
+			})
+
+			// FITID дополняется номером счета: одинаковые FITID разных счетов не совпадают
+			wantIDs := []string{
+				"40817810000000000001:A-1001",
+				"40817810000000000001:A-1002",
+				"40817810000000000001:A-1003",
+				"40817810000000000001:A-1004",
+				"5536XXXXXXXX1234:C-1",
+				"5536XXXXXXXX1234:C-2",
+			}
+			for i, row := range rows {
+				if row.ExternalID != wantIDs[i] {
+					t.Errorf("строка %d: внешний идентификатор %q, ожидался %q", i, row.ExternalID, wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOFXExternalID(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			"номер счета и FITID",
+			"<OFX><STMTRS><CURDEF>RUB<BANKACCTFROM><ACCTID>123</BANKACCTFROM><BANKTRANLIST>" +
+				"<STMTTRN><DTPOSTED>20240101<TRNAMT>-1<FITID>X1</STMTTRN></BANKTRANLIST></STMTRS></OFX>",
+			"123:X1",
+		},
+		{
+			"без номера счета",
+			"<OFX><STMTRS><CURDEF>RUB<BANKTRANLIST>" +
+				"<STMTTRN><DTPOSTED>20240101<TRNAMT>-1<FITID>X1</STMTTRN></BANKTRANLIST></STMTRS></OFX>",
+			"X1",
+		},
+		{
+			"без FITID",
+			"<OFX><STMTRS><CURDEF>RUB<BANKACCTFROM><ACCTID>123</BANKACCTFROM><BANKTRANLIST>" +
+				"<STMTTRN><DTPOSTED>20240101<TRNAMT>-1</STMTTRN></BANKTRANLIST></STMTRS></OFX>",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := OFX{}.Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ошибка разбора: %v", err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("получено %d строк, ожидалась 1", len(rows))
+			}
+			if rows[0].ExternalID != tt.want {
+				t.Errorf("внешний идентификатор %q, ожидался %q", rows[0].ExternalID, tt.want)
+			}
+		})
+	}
+}
+
+func TestOFXParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"нет элемента OFX", "OFXHEADER:100\n", "файл не похож на выписку OFX: не найден элемент <OFX>"},
+		{"незакрытый тег", "<OFX>\n<STMTRS\n", "файл не похож на выписку OFX: строка 2: незакрытый тег"},
+		{"нет выписок", "<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>", "файл не содержит выписок по счетам"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWithin(t, OFX{}, []byte(tt.data), 5*time.Second)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ошибка %v, ожидалась %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOFXDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"20240131", "2024-01-31", false},
+		{"20240131235959", "2024-01-31", false},
+		{"20240131120000.000[-5:EST]", "2024-01-31", false},
+		{"2024013", "", true},
+		{"20240231", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			date, err := parseOFXDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && date.Format("2006-01-02") != tt.want {
+				t.Errorf("дата %s, ожидалась %s", date.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+// exportSample транзакции для проверки выгрузки и повторной загрузки: расход с длинным
+// описанием, доход по регулярному правилу, разделенный расход и перевод с комиссией
+func exportSample() []models.Transaction {
+	account := &models.Account{ID: 1, Name: "Основной счет", Type: models.AccountDebitCard, Currency: "RUB"}
+	savings := &models.Account{ID: 2, Name: "Копилка", Type: models.AccountSavings, Currency: "RUB"}
+	food := &models.Category{ID: 1, Name: "Продукты", Type: models.Expense}
+	home := &models.Category{ID: 2, Name: "Дом", Type: models.Expense}
+	salary := &models.Category{ID: 3, Name: "Зарплата", Type: models.Income}
+	rule := &models.RecurringRule{
+		ID:              7,
+		Amount:          50000,
+		Description:     "Зарплата",
+		Category:        *salary,
+		Frequency:       models.RecurringMonthly,
+		StartDate:       time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		NextExecuteDate: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+		IsActive:        true,
+	}
+
+	return []models.Transaction{
+		{ID: 10, Kind: models.KindRegular, Amount: 1250.5, Currency: "RUB", Description: "Пятёрочка, покупка продуктов на неделю для всей семьи",
+			Date: time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC), Account: account, Category: food},
+		{ID: 11, Kind: models.KindRegular, Amount: 50000, Currency: "RUB", Description: "Зарплата",
+			Date: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), Account: account, Category: salary, RecurringRule: rule},
+		{ID: 12, Kind: models.KindRegular, Amount: 3000, Currency: "RUB", Description: "Гипермаркет",
+			Date: time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC), Account: account, Splits: []models.TransactionSplit{
+				{Category: food, Amount: 2000, Note: "Еда"},
+				{Category: home, Amount: 1000},
+			}},
+		{ID: 13, Kind: models.KindTransfer, Amount: 500, Fee: 10, Currency: "RUB",
+			Date: time.Date(2024, 1, 21, 12, 0, 0, 0, time.UTC), Account: account, ToAccount: savings},
+	}
+}
+
+func TestOFXExportRoundTrip(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		data, err := utils.ExportTransactionsToOFX(exportSample(), version)
+		if err != nil {
+			t.Fatalf("OFX %d: ошибка выгрузки: %v", version, err)
+		}
+		if !(OFX{}).Detect(data) {
+			t.Fatalf("OFX %d: выгрузка не определяется как OFX", version)
+		}
+		rows, err := OFX{}.Parse(data)
+		if err != nil {
+			t.Fatalf("OFX %d: ошибка разбора: %v", version, err)
+		}
+		if len(rows) != 5 {
+			t.Fatalf("OFX %d: получено %d строк, ожидалось 5", version, len(rows))
+		}
+
+		want := []struct {
+			externalID  string
+			amount      float64
+			typ         models.CategoryType
+			description string
+			category    string
+		}{
+			{"1:FH10", 1250.5, models.Expense, "Пятёрочка, покупка продуктов на неделю для всей семьи", "Продукты"},
+			{"1:FH11", 50000, models.Income, "Зарплата", "Зарплата"},
+			{"1:FH12", 3000, models.Expense, "Гипермаркет", ""},
+			{"1:FH13", 510, models.Expense, "Перевод: Копилка", ""},
+			{"2:FH13T", 500, models.Income, "Перевод: Основной счет", ""},
+		}
+		for i, w := range want {
+			row := rows[i]
+			if row.Error != "" {
+				t.Errorf("OFX %d, строка %d: ошибка %q", version, i, row.Error)
+			}
+			if row.ExternalID != w.externalID || row.Amount != w.amount || row.Type != w.typ ||
+				row.Description != w.description || row.Category != w.category || row.Currency != "RUB" {
+				t.Errorf("OFX %d, строка %d: %s %v %s %q %q, ожидалось %s %v %s %q %q", version, i,
+					row.ExternalID, row.Amount, row.Type, row.Description, row.Category,
+					w.externalID, w.amount, w.typ, w.description, w.category)
+			}
+		}
+
+		split := rows[2].Splits
+		if len(split) != 2 || split[0] != (models.ImportSplit{Category: "Продукты", Amount: 2000, Note: "Еда"}) ||
+			split[1] != (models.ImportSplit{Category: "Дом", Amount: 1000}) {
+			t.Errorf("OFX %d: разбивка %+v", version, split)
+		}
+
+		recurring := rows[1].Recurring
+		if recurring == nil {
+			t.Fatalf("OFX %d: правило регулярного платежа не загружено", version)
+		}
+		if recurring.Key != "7" || recurring.Frequency != models.RecurringMonthly || recurring.Amount != 50000 ||
+			!recurring.IsActive || recurring.EndDate != nil ||
+			recurring.StartDate.Format("2006-01-02") != "2024-01-15" || recurring.NextExecuteDate.Format("2006-01-02") != "2024-02-15" {
+			t.Errorf("OFX %d: правило %+v", version, recurring)
+		}
+	}
+}
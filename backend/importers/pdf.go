@@ -0,0 +1,883 @@
+package importers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Минимальное извлечение текста из PDF для разбора банковских выписок.
+// Поддерживаются потоки без сжатия и со сжатием FlateDecode, потоки объектов (ObjStm)
+// и шрифты с таблицей ToUnicode, которые используют банки при формировании выписок.
+// Текст собирается построчно по координатам, чего достаточно для табличных выписок.
+
+// pdfName имя PDF (/Name)
+type pdfName string
+
+// pdfRef ссылка на косвенный объект
+type pdfRef struct {
+	num int
+	gen int
+}
+
+// pdfKeyword ключевое слово или оператор потока содержимого
+type pdfKeyword string
+
+// pdfObject косвенный объект документа
+type pdfObject struct {
+	value  interface{}
+	stream []byte // сырые данные потока, если объект является потоком
+}
+
+// maxPDFDecodedSize наибольший суммарный объем распакованных потоков одного документа.
+// Небольшой файл со специально подготовленным сжатым потоком может распаковываться в гигабайты.
+const maxPDFDecodedSize = 64 << 20
+
+// errPDFTooLarge ошибка превышения объема распакованных данных документа
+var errPDFTooLarge = fmt.Errorf("PDF содержит слишком много сжатых данных: больше %d МБ после распаковки", maxPDFDecodedSize>>20)
+
+// pdfDocument загруженный документ
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	decoded int   // объем уже распакованных потоков
+	err     error // ошибка, после которой разбор документа прекращается
+}
+
+// pdfLexer разбирает лексемы PDF
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace пропускает пробелы и комментарии
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// next возвращает следующую лексему: число, имя, строку, ключевое слово
+// или разделитель ("<<", ">>", "[", "]"). В конце данных возвращает io.EOF.
+func (l *pdfLexer) next() (interface{}, error) {
+	// Одиночные «>» и неизвестные символы пропускаются в цикле, а не рекурсией,
+	// чтобы длинная последовательность таких символов не переполняла стек
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil, io.EOF
+		}
+
+		c := l.data[l.pos]
+		switch {
+		case c == '/':
+			l.pos++
+			start := l.pos
+			for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			return pdfName(decodeNameEscapes(string(l.data[start:l.pos]))), nil
+		case c == '(':
+			return l.literalString(), nil
+		case c == '<':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+				l.pos += 2
+				return pdfKeyword("<<"), nil
+			}
+			return l.hexString(), nil
+		case c == '>':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return pdfKeyword(">>"), nil
+			}
+			l.pos++
+			continue
+		case c == '[' || c == ']' || c == '{' || c == '}':
+			l.pos++
+			return pdfKeyword(string(c)), nil
+		}
+
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		if l.pos == start {
+			// Неизвестный символ, пропускаем его
+			l.pos++
+			continue
+		}
+		word := string(l.data[start:l.pos])
+		if number, err := strconv.ParseFloat(word, 64); err == nil {
+			return number, nil
+		}
+		return pdfKeyword(word), nil
+	}
+}
+
+// literalString читает строку в круглых скобках с учетом вложенных скобок и экранирования
+func (l *pdfLexer) literalString() string {
+	l.pos++ // (
+	var b bytes.Buffer
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			b.WriteByte(c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String()
+			}
+			b.WriteByte(c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b.String()
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b.WriteByte(byte(value))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// hexString читает строку в угловых скобках
+func (l *pdfLexer) hexString() string {
+	l.pos++ // <
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		end = len(l.data) - l.pos
+	}
+	raw := l.data[l.pos : l.pos+end]
+	// Незакрытая строка заканчивается вместе с данными
+	l.pos = min(l.pos+end+1, len(l.data))
+	return string(decodeHex(raw))
+}
+
+// decodeHex декодирует шестнадцатеричные цифры, пропуская пробелы
+func decodeHex(raw []byte) []byte {
+	var digits []byte
+	for _, c := range raw {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	result := make([]byte, len(digits)/2)
+	for i := range result {
+		value, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		result[i] = byte(value)
+	}
+	return result
+}
+
+// decodeNameEscapes раскрывает последовательности #xx в именах
+func decodeNameEscapes(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if value, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// parseValue читает объект PDF, начиная с уже прочитанной лексемы
+func (l *pdfLexer) parseValue(token interface{}) (interface{}, error) {
+	switch t := token.(type) {
+	case pdfKeyword:
+		switch t {
+		case "<<":
+			dict := make(map[string]interface{})
+			for {
+				key, err := l.next()
+				if err != nil {
+					return dict, err
+				}
+				if key == pdfKeyword(">>") {
+					return dict, nil
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue
+				}
+				valueToken, err := l.next()
+				if err != nil {
+					return dict, err
+				}
+				value, err := l.parseValue(valueToken)
+				if err != nil {
+					return dict, err
+				}
+				dict[string(name)] = value
+			}
+		case "[":
+			var array []interface{}
+			for {
+				itemToken, err := l.next()
+				if err != nil {
+					return array, err
+				}
+				if itemToken == pdfKeyword("]") {
+					return array, nil
+				}
+				item, err := l.parseValue(itemToken)
+				if err != nil {
+					return array, err
+				}
+				array = append(array, item)
+			}
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t, nil
+	case float64:
+		// Два целых числа и R образуют ссылку на объект
+		saved := l.pos
+		if gen, err := l.next(); err == nil {
+			if genNumber, ok := gen.(float64); ok {
+				if keyword, err := l.next(); err == nil && keyword == pdfKeyword("R") {
+					return pdfRef{num: int(t), gen: int(genNumber)}, nil
+				}
+			}
+		}
+		l.pos = saved
+		return t, nil
+	}
+	return token, nil
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// loadPDF находит все косвенные объекты документа. Таблица ссылок не используется:
+// объекты ищутся по заголовкам "N G obj", поэтому поврежденная таблица не мешает разбору.
+// При инкрементальных обновлениях более поздние определения объектов заменяют ранние.
+func loadPDF(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\r\n\t "), []byte("%PDF")) {
+		return nil, fmt.Errorf("файл не является PDF")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]*pdfObject)}
+	for _, match := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		lexer := &pdfLexer{data: data, pos: match[1]}
+		token, err := lexer.next()
+		if err != nil {
+			continue
+		}
+		value, err := lexer.parseValue(token)
+		if err != nil {
+			continue
+		}
+		object := &pdfObject{value: value}
+
+		// Поток следует сразу за словарем
+		lexer.skipSpace()
+		if bytes.HasPrefix(data[lexer.pos:], []byte("stream")) {
+			start := lexer.pos + len("stream")
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			end := -1
+			if dict, ok := value.(map[string]interface{}); ok {
+				// Длина из поврежденного словаря может быть отрицательной или больше файла
+				if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(data)-start) {
+					if bytes.HasPrefix(bytes.TrimLeft(data[start+int(length):], "\r\n "), []byte("endstream")) {
+						end = start + int(length)
+					}
+				}
+			}
+			if end < 0 {
+				if index := bytes.Index(data[start:], []byte("endstream")); index >= 0 {
+					end = start + index
+				} else {
+					end = len(data)
+				}
+			}
+			object.stream = data[start:end]
+		}
+		doc.objects[num] = object
+	}
+
+	doc.loadObjectStreams()
+	if len(doc.objects) == 0 {
+		return nil, fmt.Errorf("в PDF не найдены объекты")
+	}
+	return doc, nil
+}
+
+// loadObjectStreams добавляет объекты, упакованные в потоки объектов (PDF 1.5+)
+func (doc *pdfDocument) loadObjectStreams() {
+	var streams []*pdfObject
+	for _, object := range doc.objects {
+		if dict, ok := object.value.(map[string]interface{}); ok && dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, object)
+		}
+	}
+
+	for _, object := range streams {
+		dict := object.value.(map[string]interface{})
+		data, err := doc.decodeStream(object)
+		if err != nil {
+			continue
+		}
+		count, _ := dict["N"].(float64)
+		first, _ := dict["First"].(float64)
+
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(count); i++ {
+			numToken, err1 := header.next()
+			offsetToken, err2 := header.next()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			num, ok1 := numToken.(float64)
+			offset, ok2 := offsetToken.(float64)
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, exists := doc.objects[int(num)]; exists {
+				continue
+			}
+			position := int(first) + int(offset)
+			if first < 0 || offset < 0 || position < 0 || position >= len(data) {
+				continue
+			}
+			lexer := &pdfLexer{data: data, pos: position}
+			token, err := lexer.next()
+			if err != nil {
+				continue
+			}
+			if value, err := lexer.parseValue(token); err == nil {
+				doc.objects[int(num)] = &pdfObject{value: value}
+			}
+		}
+	}
+}
+
+// resolve раскрывает ссылку на объект
+func (doc *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < 10; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		object, ok := doc.objects[ref.num]
+		if !ok {
+			return nil
+		}
+		value = object.value
+	}
+	return value
+}
+
+// resolveDict раскрывает ссылку и возвращает словарь
+func (doc *pdfDocument) resolveDict(value interface{}) map[string]interface{} {
+	dict, _ := doc.resolve(value).(map[string]interface{})
+	return dict
+}
+
+// streamOf возвращает объект-поток по ссылке
+func (doc *pdfDocument) streamOf(value interface{}) *pdfObject {
+	if ref, ok := value.(pdfRef); ok {
+		if object, ok := doc.objects[ref.num]; ok && object.stream != nil {
+			return object
+		}
+	}
+	return nil
+}
+
+// decodeStream распаковывает данные потока
+func (doc *pdfDocument) decodeStream(object *pdfObject) ([]byte, error) {
+	dict, _ := object.value.(map[string]interface{})
+	var filters []interface{}
+	switch filter := doc.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{filter}
+	case []interface{}:
+		filters = filter
+	}
+
+	data := object.stream
+	for _, filter := range filters {
+		switch doc.resolve(filter) {
+		case pdfName("FlateDecode"):
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Читаем на байт больше оставшегося лимита, чтобы отличить превышение от точного совпадения
+			remaining := maxPDFDecodedSize - doc.decoded
+			decoded, err := io.ReadAll(io.LimitReader(reader, int64(remaining)+1))
+			if len(decoded) > remaining {
+				doc.err = errPDFTooLarge
+				return nil, doc.err
+			}
+			// Часть генераторов PDF обрезает контрольную сумму, поэтому ошибку в конце игнорируем
+			if len(decoded) == 0 && err != nil {
+				return nil, err
+			}
+			doc.decoded += len(decoded)
+			data = decoded
+		default:
+			return nil, fmt.Errorf("неподдерживаемый фильтр потока: %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// pdfFont преобразует коды символов строки в текст
+type pdfFont struct {
+	codeBytes int
+	toUnicode map[int]string
+}
+
+// decode преобразует строку, показанную шрифтом, в текст
+func (f *pdfFont) decode(s string) string {
+	if f == nil || f.toUnicode == nil {
+		// Без таблицы ToUnicode считаем текст однобайтовым в кодировке windows-1251
+		decoded, err := charmap.Windows1251.NewDecoder().String(s)
+		if err != nil {
+			return s
+		}
+		return decoded
+	}
+
+	var b strings.Builder
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		code := 0
+		for j := 0; j < f.codeBytes; j++ {
+			code = code<<8 | int(s[i+j])
+		}
+		if text, ok := f.toUnicode[code]; ok {
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// loadFont загружает шрифт и его таблицу ToUnicode
+func (doc *pdfDocument) loadFont(value interface{}) *pdfFont {
+	dict := doc.resolveDict(value)
+	if dict == nil {
+		return nil
+	}
+	font := &pdfFont{codeBytes: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeBytes = 2
+	}
+
+	object := doc.streamOf(dict["ToUnicode"])
+	if object == nil {
+		return font
+	}
+	data, err := doc.decodeStream(object)
+	if err != nil {
+		return font
+	}
+	font.toUnicode = parseToUnicode(data)
+	return font
+}
+
+// parseToUnicode разбирает таблицы bfchar и bfrange из CMap ToUnicode
+func parseToUnicode(data []byte) map[int]string {
+	result := make(map[int]string)
+	lexer := &pdfLexer{data: data}
+
+	var operands []interface{}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			break
+		}
+		if token == pdfKeyword("[") {
+			value, _ := lexer.parseValue(token)
+			operands = append(operands, value)
+			continue
+		}
+		keyword, ok := token.(pdfKeyword)
+		if !ok {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch keyword {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					result[bytesToCode(src)] = utf16BEToString(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesToCode(lo), bytesToCode(hi)
+				switch dst := operands[i+2].(type) {
+				case string:
+					// Последний символ назначения увеличивается для каждого кода диапазона
+					base := utf16.Decode(utf16BE(dst))
+					for code := start; code <= end && code-start < 65536; code++ {
+						if len(base) == 0 {
+							break
+						}
+						runes := append([]rune(nil), base...)
+						runes[len(runes)-1] += rune(code - start)
+						result[code] = string(runes)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if text, ok := item.(string); ok && start+j <= end {
+							result[start+j] = utf16BEToString(text)
+						}
+					}
+				}
+			}
+		}
+		if strings.HasPrefix(string(keyword), "end") || strings.HasPrefix(string(keyword), "begin") {
+			operands = operands[:0]
+		}
+	}
+	return result
+}
+
+// bytesToCode преобразует байты кода символа в число
+func bytesToCode(s string) int {
+	code := 0
+	for i := 0; i < len(s); i++ {
+		code = code<<8 | int(s[i])
+	}
+	return code
+}
+
+// utf16BE преобразует байты UTF-16BE в кодовые единицы
+func utf16BE(s string) []uint16 {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return units
+}
+
+// utf16BEToString декодирует строку UTF-16BE
+func utf16BEToString(s string) string {
+	return string(utf16.Decode(utf16BE(s)))
+}
+
+// pdfTextSegment фрагмент текста с координатами на странице
+type pdfTextSegment struct {
+	x, y float64
+	text string
+}
+
+// pages возвращает страницы документа в порядке следования
+func (doc *pdfDocument) pages() []map[string]interface{} {
+	var pages []map[string]interface{}
+	// Узел дерева страниц обходится один раз: ссылки Kids на уже пройденные узлы
+	// в поврежденном документе иначе приводят к экспоненциальному обходу
+	visited := make(map[pdfRef]bool)
+
+	var walk func(node map[string]interface{}, resources interface{}, depth int)
+	walk = func(node map[string]interface{}, resources interface{}, depth int) {
+		if node == nil || depth > 32 {
+			return
+		}
+		if r, ok := node["Resources"]; ok {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			page := make(map[string]interface{}, len(node)+1)
+			for key, value := range node {
+				page[key] = value
+			}
+			page["Resources"] = resources
+			pages = append(pages, page)
+			return
+		}
+		kids, _ := doc.resolve(node["Kids"]).([]interface{})
+		for _, kid := range kids {
+			if ref, ok := kid.(pdfRef); ok {
+				if visited[ref] {
+					continue
+				}
+				visited[ref] = true
+			}
+			walk(doc.resolveDict(kid), resources, depth+1)
+		}
+	}
+
+	for _, object := range doc.objects {
+		if dict, ok := object.value.(map[string]interface{}); ok && dict["Type"] == pdfName("Catalog") {
+			walk(doc.resolveDict(dict["Pages"]), nil, 0)
+			break
+		}
+	}
+	return pages
+}
+
+// extractPDFText извлекает текст документа построчно
+func extractPDFText(data []byte) ([]string, error) {
+	doc, err := loadPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.err != nil {
+		return nil, doc.err
+	}
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("в PDF не найдены страницы")
+	}
+
+	var lines []string
+	for _, page := range pages {
+		lines = append(lines, doc.pageLines(page)...)
+		if doc.err != nil {
+			return nil, doc.err
+		}
+	}
+	return lines, nil
+}
+
+// pageLines собирает текст страницы в строки по вертикальной координате
+func (doc *pdfDocument) pageLines(page map[string]interface{}) []string {
+	fonts := make(map[string]*pdfFont)
+	if resources := doc.resolveDict(page["Resources"]); resources != nil {
+		for name, value := range doc.resolveDict(resources["Font"]) {
+			fonts[name] = doc.loadFont(value)
+		}
+	}
+
+	var content []byte
+	var contents []interface{}
+	switch value := page["Contents"].(type) {
+	case pdfRef:
+		if array, ok := doc.resolve(value).([]interface{}); ok {
+			contents = array
+		} else {
+			contents = []interface{}{value}
+		}
+	case []interface{}:
+		contents = value
+	}
+	for _, ref := range contents {
+		if object := doc.streamOf(ref); object != nil {
+			if data, err := doc.decodeStream(object); err == nil {
+				content = append(content, data...)
+				content = append(content, '\n')
+			}
+		}
+	}
+
+	segments := interpretContent(content, fonts)
+
+	// Сверху вниз, слева направо; фрагменты с близкой вертикальной координатой - одна строка
+	sort.SliceStable(segments, func(i, j int) bool {
+		if diff := segments[i].y - segments[j].y; diff > 2 || diff < -2 {
+			return segments[i].y > segments[j].y
+		}
+		return segments[i].x < segments[j].x
+	})
+
+	var lines []string
+	var current []string
+	lastY := 0.0
+	for i, segment := range segments {
+		if i > 0 && (lastY-segment.y > 2 || segment.y-lastY > 2) {
+			lines = append(lines, joinSegments(current))
+			current = nil
+		}
+		current = append(current, segment.text)
+		lastY = segment.y
+	}
+	if len(current) > 0 {
+		lines = append(lines, joinSegments(current))
+	}
+	return lines
+}
+
+// joinSegments соединяет фрагменты строки и нормализует пробелы
+func joinSegments(segments []string) string {
+	return strings.Join(strings.Fields(strings.Join(segments, " ")), " ")
+}
+
+// interpretContent выполняет операторы текста потока содержимого страницы
+func interpretContent(content []byte, fonts map[string]*pdfFont) []pdfTextSegment {
+	var segments []pdfTextSegment
+	var operands []interface{}
+	var font *pdfFont
+	var x, y, lineX, lineY, leading float64
+
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if value, ok := operands[i].(float64); ok {
+				return value
+			}
+		}
+		return 0
+	}
+	show := func(text string) {
+		if text = strings.TrimSpace(text); text != "" {
+			segments = append(segments, pdfTextSegment{x: x, y: y, text: text})
+		}
+	}
+	newLine := func(tx, ty float64) {
+		lineX += tx
+		lineY += ty
+		x, y = lineX, lineY
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			break
+		}
+		keyword, isKeyword := token.(pdfKeyword)
+		if !isKeyword || keyword == "<<" || keyword == "[" {
+			value, _ := lexer.parseValue(token)
+			operands = append(operands, value)
+			continue
+		}
+
+		switch keyword {
+		case "BT":
+			x, y, lineX, lineY = 0, 0, 0, 0
+		case "Tf":
+			if len(operands) > 0 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "TL":
+			leading = number(0)
+		case "Td":
+			newLine(number(0), number(1))
+		case "TD":
+			leading = -number(1)
+			newLine(number(0), number(1))
+		case "Tm":
+			lineX, lineY = number(4), number(5)
+			x, y = lineX, lineY
+		case "T*":
+			newLine(0, -leading)
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[0].(string); ok {
+					show(font.decode(s))
+				}
+			}
+		case "'", "\"":
+			newLine(0, -leading)
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(string); ok {
+					show(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if array, ok := operands[0].([]interface{}); ok {
+					var b strings.Builder
+					for _, item := range array {
+						switch v := item.(type) {
+						case string:
+							b.WriteString(font.decode(v))
+						case float64:
+							// Большой отступ между частями означает пробел
+							if v < -200 {
+								b.WriteByte(' ')
+							}
+						}
+					}
+					show(b.String())
+				}
+			}
+		case "BI":
+			// Встроенное изображение пропускаем целиком
+			if index := bytes.Index(content[lexer.pos:], []byte("EI")); index >= 0 {
+				lexer.pos += index + 2
+			}
+		}
+		operands = operands[:0]
+	}
+	return segments
+}
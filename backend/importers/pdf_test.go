@@ -0,0 +1,199 @@
+package importers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildPDF собирает документ из тел объектов, которые нумеруются с 1 по порядку.
+// Таблица ссылок не записывается: разборщик находит объекты по заголовкам.
+func buildPDF(objects ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	b.WriteString("%%EOF\n")
+	return []byte(b.String())
+}
+
+// pdfStream тело объекта-потока с корректной длиной
+func pdfStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// deflate сжимает данные для фильтра FlateDecode
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// extractWithin извлекает текст и завершает тест, если разбор завис
+func extractWithin(t *testing.T, data []byte, limit time.Duration) ([]string, error) {
+	t.Helper()
+	type result struct {
+		lines []string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		lines, err := extractPDFText(data)
+		done <- result{lines, err}
+	}()
+	select {
+	case r := <-done:
+		return r.lines, r.err
+	case <-time.After(limit):
+		t.Fatalf("извлечение текста не завершилось за %s", limit)
+		return nil, nil
+	}
+}
+
+func TestExtractPDFText(t *testing.T) {
+	// Шрифт Type0 с CMap ToUnicode, текст и CMap сжаты FlateDecode
+	lines, err := extractPDFText(readFixture(t, "sberbank.pdf"))
+	if err != nil {
+		t.Fatalf("ошибка извлечения текста: %v", err)
+	}
+	want := []string{
+		"ПАО Сбербанк",
+		"Выписка по счёту дебетовой карты",
+		"03.03.2024 12:34 Супермаркеты 1 234,56 10 000,00",
+		"04.03.2024 / 123456 PYATEROCHKA Moscow RUS. Операция по карте ****1234",
+		"05.03.2024 09:00 Прочие операции +5 000,00 15 000,00",
+		"05.03.2024 / 654321 Перевод от И. Иванов. Операция по карте ****1234",
+		"Страница 1 из 1",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("получены строки:\n%s\nожидались:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExtractPDFTextSimpleFont(t *testing.T) {
+	content := []byte("BT /F1 12 Tf 1 0 0 1 50 700 Tm (Hello) Tj 1 0 0 1 50 680 Tm [(Wor) -250 (ld)] TJ ET")
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		pdfStream("/Filter /FlateDecode", deflate(t, content)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	lines, err := extractPDFText(data)
+	if err != nil {
+		t.Fatalf("ошибка извлечения текста: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "Hello" || !strings.HasPrefix(lines[1], "Wor") {
+		t.Errorf("получены строки %q", lines)
+	}
+}
+
+func TestParseToUnicode(t *testing.T) {
+	cmap := []byte(`/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0041>
+<0002> <D83DDE00>
+endbfchar
+2 beginbfrange
+<0010> <0012> <0430>
+<0020> <0021> [<0078> <00790079>]
+endbfrange
+endcmap`)
+	want := map[int]string{
+		0x01: "A",
+		0x02: "😀",
+		0x10: "а",
+		0x11: "б",
+		0x12: "в",
+		0x20: "x",
+		0x21: "yy",
+	}
+	got := parseToUnicode(cmap)
+	if len(got) != len(want) {
+		t.Errorf("получено %d кодов, ожидалось %d: %v", len(got), len(want), got)
+	}
+	for code, text := range want {
+		if got[code] != text {
+			t.Errorf("код %#x: %q, ожидалось %q", code, got[code], text)
+		}
+	}
+}
+
+func TestExtractPDFTextMalformed(t *testing.T) {
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"не PDF", []byte("просто текст"), "файл не является PDF"},
+		{"только заголовок", []byte("%PDF-1.4\n"), "в PDF не найдены объекты"},
+		{"нет каталога", buildPDF("<< /Type /Pages /Kids [] >>"), "в PDF не найдены страницы"},
+		{"цикл в дереве страниц", buildPDF(catalog, "<< /Type /Pages /Kids [2 0 R 2 0 R] >>"), "в PDF не найдены страницы"},
+		{"ссылка на себя", buildPDF("1 0 R"), "в PDF не найдены страницы"},
+		{"глубокая вложенность массивов", []byte("%PDF-1.4\n1 0 obj\n" + strings.Repeat("[", 100000)), "в PDF не найдены объекты"},
+		{"глубокая вложенность словарей", []byte("%PDF-1.4\n1 0 obj\n" + strings.Repeat("<< /A ", 100000)), "в PDF не найдены объекты"},
+		{"незакрытая строка", []byte("%PDF-1.4\n1 0 obj\n(abc"), ""},
+		{"незакрытая шестнадцатеричная строка", []byte("%PDF-1.4\n1 0 obj\n<4142"), ""},
+		{"длина потока больше файла", []byte("%PDF-1.4\n1 0 obj\n<< /Length 999999 >>\nstream\nabc"), ""},
+		{"отрицательная длина потока", []byte("%PDF-1.4\n1 0 obj\n<< /Length -100 >>\nstream\nabc\nendstream\nendobj\n"), ""},
+		{"огромная длина потока", []byte("%PDF-1.4\n1 0 obj\n<< /Length 1e300 >>\nstream\nabc\nendstream\nendobj\n"), ""},
+		{"смещение потока объектов вне данных", buildPDF(pdfStream("/Type /ObjStm /N 1 /First -5", []byte("2 100000"))), "в PDF не найдены страницы"},
+		{"неподдерживаемый фильтр", buildPDF(
+			catalog,
+			"<< /Type /Pages /Kids [3 0 R] >>",
+			"<< /Type /Page /Contents 4 0 R >>",
+			pdfStream("/Filter /JBIG2Decode", []byte("xx")),
+		), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := extractWithin(t, tt.data, 5*time.Second)
+			if err == nil {
+				if tt.wantErr != "" {
+					t.Fatalf("ожидалась ошибка %q", tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("ошибка %q, ожидалась %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextTruncated(t *testing.T) {
+	// Обрезанный в любом месте файл разбирается без паники и зависания
+	data := readFixture(t, "sberbank.pdf")
+	for size := 0; size < len(data); size += 13 {
+		extractWithin(t, data[:size], 5*time.Second)
+	}
+}
+
+func TestExtractPDFTextDecompressionLimit(t *testing.T) {
+	// Несколько десятков килобайт сжатых нулей распаковываются больше чем в лимит документа
+	bomb := deflate(t, make([]byte, maxPDFDecodedSize+1))
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		pdfStream("/Filter /FlateDecode", bomb),
+	)
+	if _, err := extractWithin(t, data, 30*time.Second); !errors.Is(err, errPDFTooLarge) {
+		t.Errorf("ошибка %v, ожидалась %v", err, errPDFTooLarge)
+	}
+}
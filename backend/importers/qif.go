@@ -0,0 +1,359 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// QIF текстовый формат Quicken Interchange Format. Поддерживаются счета типов Bank, Cash,
+// CCard, Oth A и Oth L, списки категорий и запомненных операций. Экспорт приложения сохраняет
+// регулярные платежи запомненными операциями с описанием правила в примечании, а операции
+// ссылаются на них номером вида FH-R<ключ правила>.
+type QIF struct{}
+
+// qifRulePrefix префикс описания правила в примечании запомненной операции
+const qifRulePrefix = "FH:"
+
+// qifRuleReference ссылка операции на правило регулярного платежа в поле номера
+var qifRuleReference = regexp.MustCompile(`^FH-R(\S+)$`)
+
+// qifCurrency код валюты в описании счета
+var qifCurrency = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// qifTransactionSections разделы с операциями по счетам
+var qifTransactionSections = map[string]bool{
+	"!type:bank":  true,
+	"!type:cash":  true,
+	"!type:ccard": true,
+	"!type:oth a": true,
+	"!type:oth l": true,
+}
+
+// Name идентификатор формата
+func (QIF) Name() string { return "qif" }
+
+// Title название формата
+func (QIF) Title() string { return "QIF (Quicken Interchange Format)" }
+
+// Detect проверяет, что файл начинается с заголовка раздела QIF
+func (QIF) Detect(data []byte) bool {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	for _, prefix := range []string{"!type:", "!account", "!option:", "!clear:"} {
+		if len(data) >= len(prefix) && strings.EqualFold(string(data[:len(prefix)]), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// qifRecord запись QIF: поля до завершающей строки «^»
+type qifRecord struct {
+	line   int
+	fields [][2]string // код поля и значение в порядке следования
+}
+
+// get возвращает значение первого поля с указанным кодом
+func (r qifRecord) get(code string) string {
+	for _, field := range r.fields {
+		if field[0] == code {
+			return field[1]
+		}
+	}
+	return ""
+}
+
+// Parse разбирает операции всех счетов файла. Формат дат (день или месяц первым)
+// определяется по всем датам файла, так как QIF не задает его явно.
+func (p QIF) Parse(data []byte) ([]models.ImportRow, error) {
+	if !p.Detect(data) {
+		return nil, fmt.Errorf("файл не является выгрузкой QIF")
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось перекодировать файл: %w", err)
+		}
+		data = decoded
+	}
+
+	type sectionRecord struct {
+		section  string
+		currency string
+		record   qifRecord
+	}
+
+	var records []sectionRecord
+	section, currency := "", ""
+	record := qifRecord{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "!"):
+			header := strings.ToLower(strings.TrimSpace(text))
+			// Переключатели режимов не меняют текущий раздел
+			if !strings.HasPrefix(header, "!option:") && !strings.HasPrefix(header, "!clear:") {
+				section = header
+			}
+			record = qifRecord{}
+		case text == "^":
+			if section == "!account" {
+				// Описание счета задает валюту операций следующих разделов
+				currency = ""
+				if value := strings.TrimSpace(record.get("D")); qifCurrency.MatchString(value) {
+					currency = value
+				}
+			} else {
+				records = append(records, sectionRecord{section, currency, record})
+			}
+			record = qifRecord{}
+		default:
+			if len(record.fields) == 0 {
+				record.line = line
+			}
+			record.fields = append(record.fields, [2]string{text[:1], strings.TrimSpace(text[1:])})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var dates []string
+	rules := make(map[string]*models.ImportRecurring)
+	for _, item := range records {
+		if qifTransactionSections[item.section] {
+			dates = append(dates, item.record.get("D"))
+		}
+	}
+	dayFirst := qifDayFirst(dates)
+
+	for _, item := range records {
+		if item.section == "!type:memorized" {
+			if rule := parseQIFRule(item.record, dayFirst); rule != nil {
+				rules[rule.Key] = rule
+			}
+		}
+	}
+
+	var rows []models.ImportRow
+	for _, item := range records {
+		if !qifTransactionSections[item.section] {
+			continue
+		}
+		row := parseQIFTransaction(item.record, dayFirst)
+		if row.Currency == "" {
+			row.Currency = item.currency
+		}
+		if match := qifRuleReference.FindStringSubmatch(item.record.get("N")); match != nil && row.Error == "" {
+			row.Recurring = rules[match[1]]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseQIFTransaction разбирает одну операцию. Категория в квадратных скобках
+// означает перевод на другой счет и не импортируется как категория.
+func parseQIFTransaction(record qifRecord, dayFirst bool) models.ImportRow {
+	payee := record.get("P")
+	memo := record.get("M")
+
+	row := models.ImportRow{
+		Line:         record.line,
+		Description:  ofxDescription(payee, memo),
+		Category:     qifCategory(record.get("L")),
+		Counterparty: payee,
+	}
+
+	date, err := parseQIFDate(record.get("D"), dayFirst)
+	if err != nil {
+		row.Error = fmt.Sprintf("некорректная дата: %q", record.get("D"))
+		return row
+	}
+	row.Date = date
+
+	value := record.get("T")
+	if value == "" {
+		value = record.get("U")
+	}
+	amount, err := parseQIFAmount(value)
+	if err != nil || amount == 0 {
+		row.Error = fmt.Sprintf("некорректная сумма: %q", value)
+		return row
+	}
+	row.Type = models.Income
+	if amount < 0 {
+		row.Type = models.Expense
+	}
+	row.Amount = math.Abs(amount)
+
+	// Части операции задаются тройками полей S (категория), E (примечание) и $ (сумма)
+	for _, field := range record.fields {
+		switch field[0] {
+		case "S":
+			row.Splits = append(row.Splits, models.ImportSplit{Category: qifCategory(field[1])})
+		case "E":
+			if len(row.Splits) > 0 {
+				row.Splits[len(row.Splits)-1].Note = field[1]
+			}
+		case "$":
+			if len(row.Splits) == 0 {
+				continue
+			}
+			splitAmount, err := parseQIFAmount(field[1])
+			if err != nil {
+				row.Error = fmt.Sprintf("некорректная сумма части: %q", field[1])
+				return row
+			}
+			if (splitAmount < 0) != (amount < 0) {
+				row.Error = "части операции должны иметь тот же знак, что и операция"
+				return row
+			}
+			row.Splits[len(row.Splits)-1].Amount = math.Abs(splitAmount)
+		}
+	}
+	if err := checkImportSplits(&row); err != nil {
+		row.Error = err.Error()
+	}
+	return row
+}
+
+// parseQIFRule разбирает правило регулярного платежа из запомненной операции.
+// Описание правила записывается в примечание как FH:rule=1;frequency=monthly;start=...;
+// запомненные операции без такого описания пропускаются.
+func parseQIFRule(record qifRecord, dayFirst bool) *models.ImportRecurring {
+	memo := record.get("M")
+	if !strings.HasPrefix(memo, qifRulePrefix) {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(memo, qifRulePrefix), ";") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	rule := models.ImportRecurring{
+		Key:         values["rule"],
+		Frequency:   models.RecurringFrequency(values["frequency"]),
+		IsActive:    values["active"] != "0",
+		Description: record.get("P"),
+	}
+
+	var err error
+	if rule.StartDate, err = time.Parse("2006-01-02", values["start"]); err != nil {
+		return nil
+	}
+	if rule.NextExecuteDate, err = time.Parse("2006-01-02", values["next"]); err != nil {
+		return nil
+	}
+	if values["end"] != "" {
+		endDate, err := time.Parse("2006-01-02", values["end"])
+		if err != nil {
+			return nil
+		}
+		rule.EndDate = &endDate
+	}
+	amount, err := parseQIFAmount(record.get("T"))
+	if err != nil {
+		return nil
+	}
+	rule.Amount = math.Abs(amount)
+
+	if rule.Key == "" || !validRecurringFrequency(rule.Frequency) {
+		return nil
+	}
+	return &rule
+}
+
+// qifCategory возвращает категорию из поля L или S без класса (часть после «/»).
+// Переводы на счета записываются в квадратных скобках и категории не имеют.
+func qifCategory(value string) string {
+	if strings.HasPrefix(value, "[") {
+		return ""
+	}
+	category, _, _ := strings.Cut(value, "/")
+	return strings.TrimSpace(category)
+}
+
+// qifDateParts разбивает дату на числа. Quicken записывает год после апострофа (1/31'24).
+func qifDateParts(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+}
+
+// qifDayFirst определяет, что в датах файла день стоит перед месяцем: либо первое число
+// где-то больше 12, либо даты записаны через точку, как принято в России
+func qifDayFirst(dates []string) bool {
+	for _, date := range dates {
+		parts := qifDateParts(date)
+		if len(parts) != 3 || len(parts[0]) == 4 {
+			continue
+		}
+		if strings.Contains(date, ".") {
+			return true
+		}
+		if first, _ := strconv.Atoi(parts[0]); first > 12 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQIFDate разбирает дату в форматах M/D/Y, D.M.Y или Y-M-D с двух- или четырехзначным годом
+func parseQIFDate(value string, dayFirst bool) (time.Time, error) {
+	parts := qifDateParts(value)
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("некорректная дата: %q", value)
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		numbers[i], _ = strconv.Atoi(part)
+	}
+
+	var year, month, day int
+	switch {
+	case len(parts[0]) == 4:
+		year, month, day = numbers[0], numbers[1], numbers[2]
+	case dayFirst:
+		day, month, year = numbers[0], numbers[1], numbers[2]
+	default:
+		month, day, year = numbers[0], numbers[1], numbers[2]
+	}
+	if len(parts[2]) <= 2 && len(parts[0]) != 4 {
+		// Двузначный год: апостроф у Quicken означает 2000-е годы
+		if year < 70 || strings.Contains(value, "'") {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, fmt.Errorf("некорректная дата: %q", value)
+	}
+	return date, nil
+}
+
+// parseQIFAmount разбирает сумму. Разделитель тысяч - запятая, десятичный - точка,
+// но если в сумме есть только запятая с двумя знаками после нее, она считается десятичной.
+func parseQIFAmount(value string) (float64, error) {
+	return utils.ParseImportAmount(value, utils.DetectDecimalSeparator([]string{value}))
+}
@@ -0,0 +1,176 @@
+package importers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+func TestQIFParse(t *testing.T) {
+	rows, err := QIF{}.Parse(readFixture(t, "bank.qif"))
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	// Даты через точку записаны днем вперед, валюта берется из описания счета,
+	// класс категории после «/» и переводы в квадратных скобках не импортируются как категории
+	checkRows(t, rows, []wantRow{
+		{line: 14, date: "2024-01-03 00:00", amount: 1250.5, typ: models.Expense, currency: "RUB", category: "Продукты", description: "Пятёрочка — Покупка продуктов"},
+		{line: 20, date: "2024-01-15 00:00", amount: 50000, typ: models.Income, currency: "RUB", category: "Зарплата", description: "Зарплата"},
+		{line: 26, date: "2024-01-20 00:00", amount: 3000, typ: models.Expense, currency: "RUB", description: "Гипермаркет"},
+		{line: 35, date: "2024-01-21 00:00", amount: 500, typ: models.Expense, currency: "RUB", description: "Перевод"},
+		{line: 40, err: `некорректная дата: "31.02.2024"`},
+		{line: 44, err: `некорректная сумма: "abc"`},
+	})
+
+	split := rows[2].Splits
+	if len(split) != 2 || split[0] != (models.ImportSplit{Category: "Продукты", Amount: 2000, Note: "Еда"}) ||
+		split[1] != (models.ImportSplit{Category: "Бытовая химия", Amount: 1000}) {
+		t.Errorf("разбивка %+v", split)
+	}
+
+	// Запомненная операция без описания правила пропускается
+	if rows[0].Recurring != nil {
+		t.Errorf("операция без ссылки на правило получила правило %+v", rows[0].Recurring)
+	}
+	recurring := rows[1].Recurring
+	if recurring == nil || recurring.Key != "7" || recurring.Frequency != models.RecurringMonthly || recurring.Amount != 50000 ||
+		recurring.StartDate.Format("2006-01-02") != "2024-01-15" || recurring.NextExecuteDate.Format("2006-01-02") != "2024-02-15" {
+		t.Errorf("правило %+v", recurring)
+	}
+}
+
+func TestQIFParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"нет заголовка", "D01/31/2024\nT-10\n^\n"},
+		{"пустой файл", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseWithin(t, QIF{}, []byte(tt.data), 5*time.Second); err == nil {
+				t.Errorf("ожидалась ошибка разбора")
+			}
+		})
+	}
+}
+
+func TestParseQIFDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		dayFirst bool
+		want     string // пустая строка означает ошибку
+	}{
+		{"01/31/2024", false, "2024-01-31"},
+		{"1/31'24", false, "2024-01-31"},
+		{"1/31' 5", false, "2005-01-31"},
+		{"1/31/99", false, "1999-01-31"},
+		{"1/31/05", false, "2005-01-31"},
+		{"31.01.2024", true, "2024-01-31"},
+		{"31/01/24", true, "2024-01-31"},
+		{"2024-01-31", false, "2024-01-31"},
+		{"2024-01-31", true, "2024-01-31"},
+		{"31/01/2024", false, ""},
+		{"02/30/2024", false, ""},
+		{"01/2024", false, ""},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			date, err := parseQIFDate(tt.value, tt.dayFirst)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получена дата %s", date.Format("2006-01-02"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ошибка разбора: %v", err)
+			}
+			if date.Format("2006-01-02") != tt.want {
+				t.Errorf("дата %s, ожидалась %s", date.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestQIFDayFirst(t *testing.T) {
+	tests := []struct {
+		name  string
+		dates []string
+		want  bool
+	}{
+		{"месяц первым", []string{"01/02/2024", "03/12/2024"}, false},
+		{"день больше 12", []string{"01/02/2024", "25/01/2024"}, true},
+		{"через точку", []string{"01.02.2024"}, true},
+		{"год первым", []string{"2024-01-25"}, false},
+		{"некорректные даты пропускаются", []string{"", "abc", "01/02/2024"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qifDayFirst(tt.dates); got != tt.want {
+				t.Errorf("qifDayFirst(%q) = %v, ожидалось %v", tt.dates, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQIFExportRoundTrip(t *testing.T) {
+	data, err := utils.ExportTransactionsToQIF(exportSample())
+	if err != nil {
+		t.Fatalf("ошибка выгрузки: %v", err)
+	}
+	if !(QIF{}).Detect(data) {
+		t.Fatalf("выгрузка не определяется как QIF")
+	}
+	rows, err := QIF{}.Parse(data)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("получено %d строк, ожидалось 5", len(rows))
+	}
+
+	// Перевод выгружается в выписки обоих счетов, комиссия списывается со счета списания
+	want := []struct {
+		date        string
+		amount      float64
+		typ         models.CategoryType
+		description string
+		category    string
+	}{
+		{"2024-01-03", 1250.5, models.Expense, "Пятёрочка, покупка продуктов на неделю для всей семьи", "Продукты"},
+		{"2024-01-15", 50000, models.Income, "Зарплата", "Зарплата"},
+		{"2024-01-20", 3000, models.Expense, "Гипермаркет", ""},
+		{"2024-01-21", 510, models.Expense, "", ""},
+		{"2024-01-21", 500, models.Income, "", ""},
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.Error != "" {
+			t.Errorf("строка %d: ошибка %q", i, row.Error)
+		}
+		if row.Date.Format("2006-01-02") != w.date || row.Amount != w.amount || row.Type != w.typ ||
+			row.Description != w.description || row.Category != w.category || row.Currency != "RUB" {
+			t.Errorf("строка %d: %s %v %s %q %q, ожидалось %s %v %s %q %q", i,
+				row.Date.Format("2006-01-02"), row.Amount, row.Type, row.Description, row.Category,
+				w.date, w.amount, w.typ, w.description, w.category)
+		}
+	}
+
+	split := rows[2].Splits
+	if len(split) != 2 || split[0] != (models.ImportSplit{Category: "Продукты", Amount: 2000, Note: "Еда"}) ||
+		split[1] != (models.ImportSplit{Category: "Дом", Amount: 1000}) {
+		t.Errorf("разбивка %+v", split)
+	}
+
+	recurring := rows[1].Recurring
+	if recurring == nil || recurring.Key != "7" || recurring.Frequency != models.RecurringMonthly || recurring.Amount != 50000 ||
+		!recurring.IsActive || recurring.EndDate != nil || recurring.Description != "Зарплата" ||
+		recurring.StartDate.Format("2006-01-02") != "2024-01-15" || recurring.NextExecuteDate.Format("2006-01-02") != "2024-02-15" {
+		t.Errorf("правило %+v", recurring)
+	}
+}
@@ -0,0 +1,132 @@
+package importers
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// SberbankPDF выписка по счету дебетовой карты СберБанка в PDF.
+// Каждая операция занимает две строки: в первой дата и время, категория, сумма и остаток,
+// во второй дата обработки, код авторизации и описание операции.
+type SberbankPDF struct{}
+
+var (
+	// sberOperationLine первая строка операции: «03.03.2024 12:34 Супермаркеты 1 234,56 10 000,00»
+	sberOperationLine = regexp.MustCompile(`^(\d{2}\.\d{2}\.\d{4}) (\d{2}:\d{2}) (.+?) ([+]?\d{1,3}(?: \d{3})*,\d{2}) (-?\d{1,3}(?: \d{3})*,\d{2})$`)
+	// sberDetailsLine вторая строка операции: «04.03.2024 / 123456 PYATEROCHKA Moscow RUS. Операция по карте ****1234»
+	sberDetailsLine = regexp.MustCompile(`^\d{2}\.\d{2}\.\d{4}(?: / \d+)? (.+)$`)
+	// sberCardSuffix окончание описания с номером карты
+	sberCardSuffix = regexp.MustCompile(`\.?\s*Операция по (?:карте|счету|счёту).*$`)
+	// sberFooterLine строки колонтитулов и итогов, которые не относятся к описанию операции
+	sberFooterLine = regexp.MustCompile(`^(Продолжение на следующей странице|Страница \d+|Выписка по счёту|Выписка по счету|ДАТА ОПЕРАЦИИ|Дата обработки|Остаток на|Итого|Реквизиты|Для проверки|ПАО Сбербанк|Дата формирования)`)
+)
+
+// Name идентификатор формата
+func (SberbankPDF) Name() string { return "sberbank_pdf" }
+
+// Title название формата
+func (SberbankPDF) Title() string { return "СберБанк, выписка по карте (PDF)" }
+
+// Detect проверяет, что PDF содержит операции в формате выписки СберБанка
+func (p SberbankPDF) Detect(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("%PDF")) {
+		return false
+	}
+	lines, err := extractPDFText(data)
+	if err != nil {
+		return false
+	}
+	text := strings.Join(lines, "\n")
+	if !strings.Contains(text, "Сбер") && !strings.Contains(text, "СБЕР") {
+		return false
+	}
+	for _, line := range lines {
+		if sberOperationLine.MatchString(normalizeSpaces(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse разбирает операции выписки. Поступления в выписке отмечены знаком «+»,
+// списания указаны без знака.
+func (SberbankPDF) Parse(data []byte) ([]models.ImportRow, error) {
+	lines, err := extractPDFText(data)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать PDF: %w", err)
+	}
+
+	var rows []models.ImportRow
+	var current *models.ImportRow
+	detailLines := 0
+	for i, line := range lines {
+		line = normalizeSpaces(line)
+
+		if match := sberOperationLine.FindStringSubmatch(line); match != nil {
+			rows = append(rows, models.ImportRow{Line: i + 1, Currency: models.DefaultCurrency, Category: match[3]})
+			current = &rows[len(rows)-1]
+			detailLines = 0
+
+			date, err := time.Parse("02.01.2006 15:04", match[1]+" "+match[2])
+			if err != nil {
+				current.Error = fmt.Sprintf("некорректная дата: %q", match[1])
+				continue
+			}
+			current.Date = date
+
+			amount, err := utils.ParseImportAmount(strings.TrimPrefix(match[4], "+"), ",")
+			if err != nil {
+				current.Error = err.Error()
+				continue
+			}
+			current.Amount = amount
+			current.Type = models.Expense
+			if strings.HasPrefix(match[4], "+") {
+				current.Type = models.Income
+			}
+			continue
+		}
+
+		if current == nil || sberFooterLine.MatchString(line) {
+			continue
+		}
+
+		// Описание операции начинается во второй строке и может переноситься на следующую
+		if detailLines == 0 {
+			if match := sberDetailsLine.FindStringSubmatch(line); match != nil {
+				current.Description = match[1]
+				detailLines++
+			}
+		} else if detailLines < 3 {
+			current.Description += " " + line
+			detailLines++
+		}
+	}
+
+	for i := range rows {
+		description := strings.TrimSpace(rows[i].Description)
+		counterparty := strings.TrimSpace(sberCardSuffix.ReplaceAllString(description, ""))
+		if counterparty == "" {
+			counterparty = rows[i].Category
+		}
+		rows[i].Counterparty = counterparty
+		rows[i].Description = counterparty
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("в выписке не найдены операции")
+	}
+	return rows, nil
+}
+
+// normalizeSpaces заменяет неразрывные и повторяющиеся пробелы обычными
+func normalizeSpaces(line string) string {
+	line = strings.NewReplacer(" ", " ", " ", " ", " ", " ").Replace(line)
+	return strings.Join(strings.Fields(line), " ")
+}
@@ -0,0 +1,54 @@
+package importers
+
+import (
+	"testing"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+func TestSberbankPDFParse(t *testing.T) {
+	rows, err := SberbankPDF{}.Parse(readFixture(t, "sberbank.pdf"))
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	// Номер карты отбрасывается из описания, поступления отмечены знаком «+»
+	checkRows(t, rows, []wantRow{
+		{line: 3, date: "2024-03-03 12:34", amount: 1234.56, typ: models.Expense, currency: "RUB", category: "Супермаркеты", description: "PYATEROCHKA Moscow RUS"},
+		{line: 5, date: "2024-03-05 09:00", amount: 5000, typ: models.Income, currency: "RUB", category: "Прочие операции", description: "Перевод от И. Иванов"},
+	})
+	for _, row := range rows {
+		if row.Counterparty != row.Description {
+			t.Errorf("строка %d: контрагент %q, ожидался %q", row.Line, row.Counterparty, row.Description)
+		}
+	}
+}
+
+func TestSberbankPDFParseInvalid(t *testing.T) {
+	page := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] >>",
+		"<< /Type /Page /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		pdfStream("", []byte("BT /F1 12 Tf (Hello) Tj ET")),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"не PDF", []byte("просто текст"), "не удалось прочитать PDF: файл не является PDF"},
+		{"нет страниц", buildPDF("<< /Type /Catalog >>"), "не удалось прочитать PDF: в PDF не найдены страницы"},
+		{"нет операций", page, "в выписке не найдены операции"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (SberbankPDF{}).Detect(tt.data) {
+				t.Errorf("файл определен как выписка СберБанка")
+			}
+			_, err := SberbankPDF{}.Parse(tt.data)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ошибка %v, ожидалась %q", err, tt.wantErr)
+			}
+		})
+	}
+}
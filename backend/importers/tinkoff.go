@@ -0,0 +1,112 @@
+package importers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
+)
+
+// TinkoffCSV выгрузка операций из интернет-банка Т-Банка (Тинькофф) в CSV
+type TinkoffCSV struct{}
+
+// tinkoffColumns обязательные колонки выгрузки
+var tinkoffColumns = []string{"Дата операции", "Статус", "Сумма операции", "Валюта операции", "Категория", "Описание"}
+
+// Name идентификатор формата
+func (TinkoffCSV) Name() string { return "tinkoff_csv" }
+
+// Title название формата
+func (TinkoffCSV) Title() string { return "Т-Банк (Тинькофф), CSV" }
+
+// Detect проверяет наличие колонок выгрузки Т-Банка
+func (p TinkoffCSV) Detect(data []byte) bool {
+	_, err := p.read(data)
+	return err == nil
+}
+
+// read разбирает CSV и находит номера обязательных колонок
+func (TinkoffCSV) read(data []byte) (*tinkoffFile, error) {
+	hasHeader := true
+	file, err := utils.ReadCSVFile(data, models.ImportSettings{Delimiter: ";", HasHeader: &hasHeader})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range file.Header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range tinkoffColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("нет колонки %q", name)
+		}
+	}
+	return &tinkoffFile{rows: file.Rows, columns: columns}, nil
+}
+
+// tinkoffFile выгрузка с номерами колонок
+type tinkoffFile struct {
+	rows    [][]string
+	columns map[string]int
+}
+
+// Parse разбирает операции. Неуспешные операции (статус, отличный от OK) не импортируются.
+func (p TinkoffCSV) Parse(data []byte) ([]models.ImportRow, error) {
+	file, err := p.read(data)
+	if err != nil {
+		return nil, fmt.Errorf("файл не похож на выгрузку Т-Банка: %w", err)
+	}
+
+	rows := make([]models.ImportRow, 0, len(file.rows))
+	for i, record := range file.rows {
+		cell := func(name string) string {
+			if index := file.columns[name]; index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		if status := cell("Статус"); status != "" && status != "OK" {
+			continue
+		}
+
+		description := cell("Описание")
+		row := models.ImportRow{
+			Line:         i + 2,
+			Currency:     strings.ToUpper(cell("Валюта операции")),
+			Description:  description,
+			Category:     cell("Категория"),
+			Counterparty: description,
+		}
+
+		date, err := time.Parse("02.01.2006 15:04:05", cell("Дата операции"))
+		if err != nil {
+			if date, err = time.Parse("02.01.2006", cell("Дата операции")); err != nil {
+				row.Error = fmt.Sprintf("некорректная дата: %q", cell("Дата операции"))
+				rows = append(rows, row)
+				continue
+			}
+		}
+		row.Date = date
+
+		amount, err := utils.ParseImportAmount(cell("Сумма операции"), ",")
+		if err != nil || amount == 0 {
+			row.Error = fmt.Sprintf("некорректная сумма: %q", cell("Сумма операции"))
+			rows = append(rows, row)
+			continue
+		}
+		// Расходы в выгрузке отрицательные, поступления - положительные
+		row.Type = models.Income
+		if amount < 0 {
+			row.Type = models.Expense
+		}
+		row.Amount = math.Abs(amount)
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,63 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+func TestTinkoffCSVParse(t *testing.T) {
+	// Неуспешная операция (строка 3) пропускается, строки с ошибками возвращаются с описанием ошибки
+	rows, err := TinkoffCSV{}.Parse(readFixture(t, "tinkoff.csv"))
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	checkRows(t, rows, []wantRow{
+		{line: 2, date: "2024-03-01 19:25", amount: 1250.5, typ: models.Expense, currency: "RUB", category: "Супермаркеты", description: "Пятёрочка"},
+		{line: 4, date: "2024-03-05 08:15", amount: 50000, typ: models.Income, currency: "RUB", category: "Пополнения", description: "Зарплата"},
+		{line: 5, date: "2024-03-06 00:00", amount: 15.99, typ: models.Expense, currency: "USD", category: "Сервис", description: "Spotify"},
+		{line: 6, err: `некорректная сумма: "abc"`},
+		{line: 7, err: `некорректная дата: "32.03.2024 12:00:00"`},
+	})
+}
+
+func TestTinkoffCSVParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"пустой файл", ""},
+		{"нет обязательных колонок", "Дата;Сумма;Описание\n01.03.2024;-100;Кафе\n"},
+		{"другой разделитель", "Дата операции,Статус,Сумма операции,Валюта операции,Категория,Описание\n"},
+		{"двоичные данные", "\x00\x01\x02\xff\xfe\"\"\"\n;;;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (TinkoffCSV{}).Detect([]byte(tt.data)) {
+				t.Errorf("файл определен как выгрузка Т-Банка")
+			}
+			if _, err := parseWithin(t, TinkoffCSV{}, []byte(tt.data), 5*time.Second); err == nil {
+				t.Errorf("ожидалась ошибка разбора")
+			}
+		})
+	}
+}
+
+func TestTinkoffCSVParseShortRecords(t *testing.T) {
+	// Строки с недостающими колонками не должны приводить к панике
+	data := strings.Join([]string{
+		`"Дата операции";"Статус";"Сумма операции";"Валюта операции";"Категория";"Описание"`,
+		`"01.03.2024 10:00:00";"OK"`,
+		`"01.03.2024 10:00:00";"OK";"-100,00";"RUB";"Кафе";"Кофейня"`,
+	}, "\n")
+	rows, err := parseWithin(t, TinkoffCSV{}, []byte(data), 5*time.Second)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	checkRows(t, rows, []wantRow{
+		{line: 2, err: `некорректная сумма: ""`},
+		{line: 3, date: "2024-03-01 10:00", amount: 100, typ: models.Expense, currency: "RUB", category: "Кафе", description: "Кофейня"},
+	})
+}
@@ -10,10 +10,12 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/nikitagorchakov/finance-hub/backend/config"
+	"github.com/nikitagorchakov/finance-hub/backend/controllers"
 	"github.com/nikitagorchakov/finance-hub/backend/db"
 	"github.com/nikitagorchakov/finance-hub/backend/middlewares"
 	"github.com/nikitagorchakov/finance-hub/backend/models"
 	"github.com/nikitagorchakov/finance-hub/backend/routes"
+	"github.com/nikitagorchakov/finance-hub/backend/storage"
 	"github.com/nikitagorchakov/finance-hub/backend/telegram"
 	"github.com/nikitagorchakov/finance-hub/backend/utils"
 )
@@ -28,6 +30,16 @@ func main() {
 	// Выполняем миграции
 	db.MigrateDB()
 
+	// Загружаем курсы валют из локального файла, если он указан
+	if cfg.ExchangeRatesFile != "" {
+		loadExchangeRates(cfg.ExchangeRatesFile)
+	}
+
+	// Подключаем хранилище вложений
+	if err := storage.Init(cfg); err != nil {
+		log.Fatalf("Ошибка инициализации хранилища файлов: %v", err)
+	}
+
 	// Заполняем тестовыми данными в режиме разработки
 	if cfg.Env == "development" {
 		db.SeedDefaultData()
@@ -85,6 +97,9 @@ func main() {
 	// Запускаем background процесс для проверки превышения бюджетов
 	go startBudgetThresholdChecker()
 
+	// Запускаем очистку корзины от записей старше срока хранения
+	go startTrashPurger(time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour)
+
 	// Запуск сервера
 	port := fmt.Sprintf(":%s", cfg.Port)
 	log.Printf("Server starting on port %s", cfg.Port)
@@ -101,6 +116,20 @@ func getAllowedOrigins(cfg *config.Config) string {
 	return cfg.FrontendURL
 }
 
+// loadExchangeRates загружает курсы валют из файла в формате ежедневных курсов ЦБ РФ
+func loadExchangeRates(path string) {
+	rates, err := utils.LoadCBRRatesFile(path)
+	if err != nil {
+		log.Printf("Ошибка чтения файла с курсами валют %s: %v", path, err)
+		return
+	}
+	if err := utils.SaveExchangeRates(rates); err != nil {
+		log.Printf("Ошибка сохранения курсов валют: %v", err)
+		return
+	}
+	log.Printf("Загружено курсов валют: %d", len(rates))
+}
+
 // startRecurringProcessor запускает периодическую обработку recurring транзакций
 func startRecurringProcessor() {
 	ticker := time.NewTicker(1 * time.Hour) // Проверяем каждый час
@@ -136,9 +165,12 @@ func processRecurringTransactions() {
 			// Создаем транзакцию
 			transaction := models.Transaction{
 				Amount:          rule.Amount,
+				Currency:        rule.Currency,
 				Description:     rule.Description,
 				Date:            rule.NextExecuteDate,
-				CategoryID:      rule.CategoryID,
+				Kind:            models.KindRegular,
+				CategoryID:      &rule.CategoryID,
+				AccountID:       rule.AccountID,
 				UserID:          rule.UserID,
 				RecurringRuleID: &rule.ID,
 				IsRecurring:     true,
@@ -148,6 +180,7 @@ func processRecurringTransactions() {
 				log.Printf("Ошибка создания recurring транзакции: %v", err)
 				continue
 			}
+			utils.CreateChangeLog(rule.UserID, models.EntityTransaction, transaction.ID, models.ActionCreate, nil, transaction)
 
 			// Обновляем следующую дату выполнения
 			rule.NextExecuteDate = rule.CalculateNextExecuteDate()
@@ -198,10 +231,31 @@ func checkBudgetThresholds() {
 	}
 
 	for _, user := range users {
-		if err := utils.CheckBudgetThresholds(user.ID); err != nil {
+		if err := controllers.CheckBudgetThresholds(user.ID); err != nil {
 			log.Printf("Ошибка проверки бюджетов пользователя %d: %v", user.ID, err)
 		}
 	}
 	
 	log.Println("Проверка превышения бюджетов завершена")
 }
+
+// startTrashPurger запускает периодическое окончательное удаление записей из корзины
+func startTrashPurger(retention time.Duration) {
+	ticker := time.NewTicker(1 * time.Hour) // Проверяем каждый час
+	defer ticker.Stop()
+
+	log.Println("Запущена очистка корзины")
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := controllers.PurgeTrash(retention)
+			if err != nil {
+				log.Printf("Ошибка очистки корзины: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("Из корзины окончательно удалено записей: %d", purged)
+			}
+		}
+	}
+}
@@ -44,6 +44,12 @@ func CheckActiveSubscription() fiber.Handler {
 	}
 }
 
+// GetSubscriptionPlan возвращает план подписки, сохраненный middleware CheckActiveSubscription
+func GetSubscriptionPlan(c *fiber.Ctx) models.SubscriptionPlan {
+	plan, _ := c.Locals("subscription_plan").(models.SubscriptionPlan)
+	return plan
+}
+
 // RequiresPlan проверяет, что у пользователя есть подписка заданного или более высокого уровня
 func RequiresPlan(minimumPlan models.SubscriptionPlan) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -115,14 +121,14 @@ func CheckResourceLimits(resourceType string) fiber.Handler {
 			var transactionCount int64
 			db.DB.Model(&models.Transaction{}).Where("user_id = ?", userID).Count(&transactionCount)
 
-			if userPlan == models.Basic && transactionCount >= 100 && c.Method() == "POST" {
+			if userPlan == models.Basic && transactionCount >= models.TransactionLimits[models.Basic] && c.Method() == "POST" {
 				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 					"status":  "error",
 					"message": "Достигнут лимит транзакций для базового плана. Перейдите на премиум план для создания большего количества транзакций.",
 				})
 			}
 
-			if userPlan == models.Premium && transactionCount >= 1000 && c.Method() == "POST" {
+			if userPlan == models.Premium && transactionCount >= models.TransactionLimits[models.Premium] && c.Method() == "POST" {
 				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 					"status":  "error",
 					"message": "Достигнут лимит транзакций для премиум плана. Перейдите на профессиональный план для создания неограниченного количества транзакций.",
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// AccountType тип счета (кошелька)
+type AccountType string
+
+const (
+	// AccountCash наличные
+	AccountCash AccountType = "cash"
+	// AccountDebitCard дебетовая карта
+	AccountDebitCard AccountType = "debit_card"
+	// AccountCreditCard кредитная карта
+	AccountCreditCard AccountType = "credit_card"
+	// AccountSavings накопительный счет
+	AccountSavings AccountType = "savings"
+)
+
+// Account модель счета, через который проходят транзакции
+type Account struct {
+	ID             uint        `gorm:"primaryKey" json:"id"`
+	UserID         uint        `gorm:"not null" json:"userId"`
+	User           User        `gorm:"foreignKey:UserID" json:"-"`
+	Name           string      `gorm:"not null" json:"name"`
+	Type           AccountType `gorm:"not null" json:"type"`
+	Currency       string      `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
+	OpeningBalance float64     `gorm:"default:0" json:"openingBalance"` // остаток на момент начала учета
+	Description    string      `json:"description"`
+	Color          string      `json:"color"`
+	Icon           string      `json:"icon"`
+	IsArchived     bool        `gorm:"default:false" json:"isArchived"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+}
+
+// AccountDTO структура для создания/обновления счета
+type AccountDTO struct {
+	Name           string      `json:"name" validate:"required"`
+	Type           AccountType `json:"type" validate:"required,oneof=cash debit_card credit_card savings"`
+	Currency       string      `json:"currency" validate:"omitempty,len=3,currency"`
+	OpeningBalance float64     `json:"openingBalance"`
+	Description    string      `json:"description"`
+	Color          string      `json:"color"`
+	Icon           string      `json:"icon"`
+	IsArchived     bool        `json:"isArchived"`
+}
+
+// AccountBalance остаток по счету на дату
+type AccountBalance struct {
+	AccountID      uint      `json:"accountId"`
+	OpeningBalance float64   `json:"openingBalance"`
+	TotalIncome    float64   `json:"totalIncome"`
+	TotalExpense   float64   `json:"totalExpense"`
+	TransfersIn    float64   `json:"transfersIn"`  // поступления переводами с других счетов
+	TransfersOut   float64   `json:"transfersOut"` // списания переводами, включая комиссии
+	Balance        float64   `json:"balance"`
+	Currency       string    `json:"currency"`
+	Date           time.Time `json:"date"`
+}
+
+// AccountHistoryEntry транзакция по счету с остатком после ее проведения
+type AccountHistoryEntry struct {
+	Transaction Transaction `json:"transaction"`
+	Change      float64     `json:"change"`  // изменение остатка (со знаком)
+	Balance     float64     `json:"balance"` // остаток после транзакции
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// MaxAttachmentSize максимальный размер одного вложения в байтах. Вместе с остальными полями
+// формы файл укладывается в стандартное ограничение размера запроса (4 МБ).
+const MaxAttachmentSize int64 = 3 * 1024 * 1024
+
+// AttachmentContentTypes допустимые типы вложений: фотографии чеков и документы PDF.
+// Тип определяется по содержимому файла, а не по расширению или заголовку запроса.
+var AttachmentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/heic":      ".heic",
+	"application/pdf": ".pdf",
+}
+
+// AttachmentQuotas суммарный объем вложений пользователя в байтах для каждого плана подписки
+var AttachmentQuotas = map[SubscriptionPlan]int64{
+	Basic:   50 * 1024 * 1024,
+	Premium: 1024 * 1024 * 1024,
+	Pro:     10 * 1024 * 1024 * 1024,
+}
+
+// Attachment файл, прикрепленный к транзакции (чек, счет, гарантийный талон)
+type Attachment struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	UserID        uint         `gorm:"not null;index" json:"userId"`
+	User          User         `gorm:"foreignKey:UserID" json:"-"`
+	TransactionID uint         `gorm:"not null;index" json:"transactionId"`
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"-"`
+	FileName      string       `gorm:"not null" json:"fileName"` // исходное имя файла
+	ContentType   string       `gorm:"not null" json:"contentType"`
+	Size          int64        `gorm:"not null" json:"size"`
+	StorageKey    string       `gorm:"not null;uniqueIndex" json:"-"` // ключ файла в хранилище
+	CreatedAt     time.Time    `json:"createdAt"`
+}
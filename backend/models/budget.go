@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // BudgetPeriod период действия бюджета
@@ -18,25 +20,28 @@ const (
 
 // Budget модель бюджета
 type Budget struct {
-	ID         uint         `gorm:"primaryKey" json:"id"`
-	Name       string       `gorm:"not null" json:"name"`
-	Amount     float64      `gorm:"not null" json:"amount"`
-	Period     BudgetPeriod `gorm:"not null" json:"period"`
-	Spent      float64      `gorm:"default:0" json:"spent"` // сумма потраченных средств
-	StartDate  time.Time    `gorm:"not null" json:"startDate"`
-	EndDate    time.Time    `gorm:"not null" json:"endDate"`
-	CategoryID *uint        `json:"categoryId"`
-	Category   *Category    `gorm:"foreignKey:CategoryID" json:"category"`
-	UserID     uint         `gorm:"not null" json:"userId"`
-	User       User         `gorm:"foreignKey:UserID" json:"-"`
-	CreatedAt  time.Time    `json:"createdAt"`
-	UpdatedAt  time.Time    `json:"updatedAt"`
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Name       string         `gorm:"not null" json:"name"`
+	Amount     float64        `gorm:"not null" json:"amount"`
+	Currency   string         `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"` // валюта лимита и потраченной суммы
+	Period     BudgetPeriod   `gorm:"not null" json:"period"`
+	Spent      float64        `gorm:"default:0" json:"spent"` // сумма потраченных средств
+	StartDate  time.Time      `gorm:"not null" json:"startDate"`
+	EndDate    time.Time      `gorm:"not null" json:"endDate"`
+	CategoryID *uint          `json:"categoryId"`
+	Category   *Category      `gorm:"foreignKey:CategoryID" json:"category"`
+	UserID     uint           `gorm:"not null" json:"userId"`
+	User       User           `gorm:"foreignKey:UserID" json:"-"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"` // время перемещения в корзину
 }
 
 // BudgetDTO структура для создания/обновления бюджета
 type BudgetDTO struct {
 	Name       string       `json:"name" validate:"required"`
 	Amount     float64      `json:"amount" validate:"required,gt=0"`
+	Currency   string       `json:"currency" validate:"omitempty,len=3,currency"`
 	Period     BudgetPeriod `json:"period" validate:"required,oneof=monthly weekly yearly"`
 	StartDate  time.Time    `json:"startDate" validate:"required"`
 	EndDate    time.Time    `json:"endDate" validate:"required,gtfield=StartDate"`
@@ -60,12 +65,12 @@ func (b *Budget) HasExceededThreshold(threshold float64) bool {
 func (b *Budget) GetThresholdStatus() []float64 {
 	var exceeded []float64
 	thresholds := []float64{80, 100, 120}
-	
+
 	for _, threshold := range thresholds {
 		if b.HasExceededThreshold(threshold) {
 			exceeded = append(exceeded, threshold)
 		}
 	}
-	
+
 	return exceeded
 }
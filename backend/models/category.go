@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // CategoryType тип категории (расход или доход)
@@ -77,18 +79,22 @@ var DefaultCategories = []struct {
 	},
 }
 
-// Category модель категории
+// Category модель категории. Категории образуют дерево произвольной глубины: подкатегория
+// ссылается на родительскую категорию того же типа, корневые категории родителя не имеют.
 type Category struct {
-	ID          uint         `gorm:"primaryKey" json:"id"`
-	Name        string       `gorm:"not null" json:"name"`
-	Description string       `json:"description"`
-	Type        CategoryType `gorm:"not null" json:"type"`
-	UserID      uint         `gorm:"not null" json:"userId"`
-	User        User         `gorm:"foreignKey:UserID" json:"-"`
-	Color       string       `json:"color"`
-	Icon        string       `json:"icon"`
-	CreatedAt   time.Time    `json:"createdAt"`
-	UpdatedAt   time.Time    `json:"updatedAt"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description"`
+	Type        CategoryType   `gorm:"not null" json:"type"`
+	ParentID    *uint          `gorm:"index" json:"parentId"`
+	Parent      *Category      `gorm:"foreignKey:ParentID;constraint:OnDelete:SET NULL" json:"-"`
+	UserID      uint           `gorm:"not null" json:"userId"`
+	User        User           `gorm:"foreignKey:UserID" json:"-"`
+	Color       string         `json:"color"`
+	Icon        string         `json:"icon"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"` // время перемещения в корзину
 }
 
 // CategoryDTO структура для создания/обновления категории
@@ -96,6 +102,7 @@ type CategoryDTO struct {
 	Name        string       `json:"name" validate:"required"`
 	Description string       `json:"description"`
 	Type        CategoryType `json:"type" validate:"required,oneof=expense income"`
+	ParentID    *uint        `json:"parentId"`
 	Color       string       `json:"color"`
 	Icon        string       `json:"icon"`
 }
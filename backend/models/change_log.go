@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"reflect"
 	"time"
 )
 
@@ -9,47 +10,89 @@ import (
 type ChangeLogAction string
 
 const (
-	ActionCreate ChangeLogAction = "create"
-	ActionUpdate ChangeLogAction = "update"
-	ActionDelete ChangeLogAction = "delete"
-	ActionArchive ChangeLogAction = "archive"
+	ActionCreate    ChangeLogAction = "create"
+	ActionUpdate    ChangeLogAction = "update"
+	ActionDelete    ChangeLogAction = "delete"
+	ActionArchive   ChangeLogAction = "archive"
 	ActionUnarchive ChangeLogAction = "unarchive"
-	ActionComplete ChangeLogAction = "complete"
+	ActionComplete  ChangeLogAction = "complete"
+	ActionRestore   ChangeLogAction = "restore" // восстановление из корзины
+	ActionRevert    ChangeLogAction = "revert"  // отмена изменения из истории
 )
 
 // ChangeLogEntityType тип сущности
 type ChangeLogEntityType string
 
 const (
-	EntityProject ChangeLogEntityType = "project"
-	EntityInvestment ChangeLogEntityType = "investment"
-	EntityProjectPayment ChangeLogEntityType = "project_payment"
+	EntityProject             ChangeLogEntityType = "project"
+	EntityInvestment          ChangeLogEntityType = "investment"
+	EntityProjectPayment      ChangeLogEntityType = "project_payment"
 	EntityInvestmentOperation ChangeLogEntityType = "investment_operation"
+	EntityTransaction         ChangeLogEntityType = "transaction" // в том числе переводы между счетами
+	EntityCategory            ChangeLogEntityType = "category"
+	EntityBudget              ChangeLogEntityType = "budget"
+	EntityRecurringRule       ChangeLogEntityType = "recurring_rule"
+	EntityAccount             ChangeLogEntityType = "account"
+	EntityTag                 ChangeLogEntityType = "tag"
+	EntityPayee               ChangeLogEntityType = "payee"
+	EntityTransactionRule     ChangeLogEntityType = "transaction_rule"
+	EntityAttachment          ChangeLogEntityType = "attachment"
 )
 
+// ChangeLogActions допустимые действия для фильтрации истории
+var ChangeLogActions = map[ChangeLogAction]bool{
+	ActionCreate:    true,
+	ActionUpdate:    true,
+	ActionDelete:    true,
+	ActionArchive:   true,
+	ActionUnarchive: true,
+	ActionComplete:  true,
+	ActionRestore:   true,
+	ActionRevert:    true,
+}
+
+// ChangeLogEntityTypes допустимые типы сущностей для фильтрации истории
+var ChangeLogEntityTypes = map[ChangeLogEntityType]bool{
+	EntityProject:             true,
+	EntityInvestment:          true,
+	EntityProjectPayment:      true,
+	EntityInvestmentOperation: true,
+	EntityTransaction:         true,
+	EntityCategory:            true,
+	EntityBudget:              true,
+	EntityRecurringRule:       true,
+	EntityAccount:             true,
+	EntityTag:                 true,
+	EntityPayee:               true,
+	EntityTransactionRule:     true,
+	EntityAttachment:          true,
+}
+
 // ChangeLog модель истории изменений
 type ChangeLog struct {
 	ID         uint                `gorm:"primaryKey" json:"id"`
-	UserID     uint                `gorm:"not null" json:"userId"`
+	UserID     uint                `gorm:"not null;index:idx_change_logs_user_created,priority:1" json:"userId"`
 	User       User                `gorm:"foreignKey:UserID" json:"-"`
-	EntityType ChangeLogEntityType `gorm:"not null" json:"entityType"`
-	EntityID   uint                `gorm:"not null" json:"entityId"`
+	EntityType ChangeLogEntityType `gorm:"not null;index:idx_change_logs_entity,priority:1" json:"entityType"`
+	EntityID   uint                `gorm:"not null;index:idx_change_logs_entity,priority:2" json:"entityId"`
 	Action     ChangeLogAction     `gorm:"not null" json:"action"`
 	OldData    string              `gorm:"type:text" json:"oldData"` // JSON строка с предыдущими данными
 	NewData    string              `gorm:"type:text" json:"newData"` // JSON строка с новыми данными
 	Changes    string              `gorm:"type:text" json:"changes"` // JSON строка с описанием изменений
-	CreatedAt  time.Time           `json:"createdAt"`
+	RevertedID *uint               `json:"revertedId,omitempty"`     // отмененная запись истории (только для отмены изменения)
+	CreatedAt  time.Time           `gorm:"index:idx_change_logs_user_created,priority:2" json:"createdAt"`
 }
 
 // ChangeLogEntry структура для отображения записи истории
 type ChangeLogEntry struct {
-	ID         uint                `json:"id"`
-	EntityType ChangeLogEntityType `json:"entityType"`
-	EntityID   uint                `json:"entityId"`
-	EntityName string              `json:"entityName"` // Название связанного объекта
-	Action     ChangeLogAction     `json:"action"`
+	ID         uint                   `json:"id"`
+	EntityType ChangeLogEntityType    `json:"entityType"`
+	EntityID   uint                   `json:"entityId"`
+	EntityName string                 `json:"entityName"` // Название связанного объекта
+	Action     ChangeLogAction        `json:"action"`
 	Changes    map[string]interface{} `json:"changes"`
-	CreatedAt  time.Time           `json:"createdAt"`
+	RevertedID *uint                  `json:"revertedId,omitempty"` // отмененная запись истории
+	CreatedAt  time.Time              `json:"createdAt"`
 }
 
 // excludedFields список полей, которые не нужно отслеживать в истории изменений
@@ -62,6 +105,7 @@ var excludedFields = map[string]bool{
 	"CreatedAt": true, // Дата создания не изменяется (в разных форматах)
 	"updatedAt": true, // Дата обновления изменяется автоматически
 	"UpdatedAt": true, // Дата обновления изменяется автоматически (в разных форматах)
+	"deletedAt": true, // Удаление и восстановление записываются отдельными действиями
 }
 
 // getFieldDisplayName возвращает читаемое название поля
@@ -84,8 +128,34 @@ func getFieldDisplayName(field string) string {
 		"ProjectID":       "ID проекта",
 		"investmentId":    "ID инвестиции",
 		"InvestmentID":    "ID инвестиции",
+		"description":     "Описание",
+		"note":            "Заметка",
+		"currency":        "Валюта",
+		"categoryId":      "Категория",
+		"parentId":        "Родительская категория",
+		"accountId":       "Счет",
+		"toAccountId":     "Счет зачисления",
+		"refundOfId":      "Возврат по расходу",
+		"payeeId":         "Получатель",
+		"fee":             "Комиссия",
+		"reviewed":        "Проверено",
+		"clearedStatus":   "Сверка",
+		"splits":          "Разбивка",
+		"kind":            "Вид",
+		"color":           "Цвет",
+		"icon":            "Иконка",
+		"period":          "Период",
+		"spent":           "Потрачено",
+		"frequency":       "Частота",
+		"nextExecuteDate": "Следующее выполнение",
+		"isActive":        "Активно",
+		"isArchived":      "Архивный",
+		"openingBalance":  "Начальный остаток",
+		"priority":        "Приоритет",
+		"aliases":         "Псевдонимы",
+		"fileName":        "Имя файла",
 	}
-	
+
 	if displayName, exists := fieldNames[field]; exists {
 		return displayName
 	}
@@ -95,7 +165,7 @@ func getFieldDisplayName(field string) string {
 // CalculateChanges вычисляет изменения между старыми и новыми данными
 func CalculateChanges(oldData, newData interface{}, action ChangeLogAction) map[string]interface{} {
 	changes := make(map[string]interface{})
-	
+
 	switch action {
 	case ActionCreate:
 		changes["action"] = "Создано"
@@ -110,57 +180,71 @@ func CalculateChanges(oldData, newData interface{}, action ChangeLogAction) map[
 		changes["action"] = "Разархивировано"
 	case ActionComplete:
 		changes["action"] = "Завершено"
+	case ActionRestore:
+		changes["action"] = "Восстановлено"
 	case ActionUpdate:
 		changes["action"] = "Обновлено"
-		if oldData != nil && newData != nil {
-			// Простое сравнение через JSON
-			oldJSON, _ := json.Marshal(oldData)
-			newJSON, _ := json.Marshal(newData)
-			
-			var oldMap, newMap map[string]interface{}
-			json.Unmarshal(oldJSON, &oldMap)
-			json.Unmarshal(newJSON, &newMap)
-			
-			changedFields := make(map[string]interface{})
-			for key, newValue := range newMap {
-				// Пропускаем исключенные поля
-				if excludedFields[key] {
-					continue
-				}
-				
-				if oldValue, exists := oldMap[key]; !exists || oldValue != newValue {
-					// Проверяем, что изменение действительно значимое
-					if isSignificantChange(oldValue, newValue) {
-						changedFields[getFieldDisplayName(key)] = map[string]interface{}{
-							"old": oldValue,
-							"new": newValue,
-						}
-					}
+		if fields := changedFields(oldData, newData); len(fields) > 0 {
+			changes["fields"] = fields
+		}
+	case ActionRevert:
+		changes["action"] = "Изменение отменено"
+		if fields := changedFields(oldData, newData); len(fields) > 0 {
+			changes["fields"] = fields
+		}
+	}
+
+	return changes
+}
+
+// changedFields возвращает значимые изменения полей между старыми и новыми данными
+func changedFields(oldData, newData interface{}) map[string]interface{} {
+	if oldData == nil || newData == nil {
+		return nil
+	}
+
+	// Простое сравнение через JSON
+	oldJSON, _ := json.Marshal(oldData)
+	newJSON, _ := json.Marshal(newData)
+
+	var oldMap, newMap map[string]interface{}
+	json.Unmarshal(oldJSON, &oldMap)
+	json.Unmarshal(newJSON, &newMap)
+	oldMap, _ = normalizeChangeValue(oldMap).(map[string]interface{})
+	newMap, _ = normalizeChangeValue(newMap).(map[string]interface{})
+
+	fields := make(map[string]interface{})
+	for key, newValue := range newMap {
+		// Пропускаем исключенные поля
+		if excludedFields[key] {
+			continue
+		}
+
+		if oldValue, exists := oldMap[key]; !exists || !reflect.DeepEqual(oldValue, newValue) {
+			// Проверяем, что изменение действительно значимое
+			if isSignificantChange(oldValue, newValue) {
+				fields[getFieldDisplayName(key)] = map[string]interface{}{
+					"old": oldValue,
+					"new": newValue,
 				}
 			}
-			
-			// Добавляем изменения только если есть значимые изменения
-			if len(changedFields) > 0 {
-				changes["fields"] = changedFields
-			}
 		}
 	}
-	
-	return changes
+	return fields
 }
 
 // isSignificantChange проверяет, является ли изменение значимым
 func isSignificantChange(oldValue, newValue interface{}) bool {
 	// Если значения одинаковые, изменение незначимое
-	if oldValue == newValue {
+	if reflect.DeepEqual(oldValue, newValue) {
 		return false
 	}
-	
+
 	// Проверяем нулевые значения
 	if oldValue == nil && newValue == nil {
 		return false
 	}
-	
+
 	// Для строк проверяем, что это не просто пустые значения
 	if oldStr, ok := oldValue.(string); ok {
 		if newStr, ok := newValue.(string); ok {
@@ -170,7 +254,7 @@ func isSignificantChange(oldValue, newValue interface{}) bool {
 			}
 		}
 	}
-	
+
 	// Для чисел проверяем, что это не просто переход от 0 к 0.0
 	if oldNum, ok := oldValue.(float64); ok {
 		if newNum, ok := newValue.(float64); ok {
@@ -179,6 +263,67 @@ func isSignificantChange(oldValue, newValue interface{}) bool {
 			}
 		}
 	}
-	
+
 	return true
-} 
\ No newline at end of file
+}
+
+// normalizeChangeValue подготавливает данные к сравнению: связанные объекты (категория, счет и т.д.)
+// не сравниваются, так как их изменение видно по ID, а во вложенных записях (частях транзакции,
+// метках) не учитываются служебные поля, которые меняются при каждом сохранении
+func normalizeChangeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if excludedFields[key] {
+				continue
+			}
+			if _, nested := item.(map[string]interface{}); nested {
+				continue
+			}
+			result[key] = normalizeChangeValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeChangeValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// NewChangeLog формирует запись истории изменений: снимки данных до и после изменения
+// и описание изменившихся полей
+func NewChangeLog(userID uint, entityType ChangeLogEntityType, entityID uint, action ChangeLogAction, oldData, newData interface{}) ChangeLog {
+	var oldDataJSON, newDataJSON, changesJSON string
+
+	if oldData != nil {
+		if data, err := json.Marshal(oldData); err == nil {
+			oldDataJSON = string(data)
+		}
+	}
+
+	if newData != nil {
+		if data, err := json.Marshal(newData); err == nil {
+			newDataJSON = string(data)
+		}
+	}
+
+	changes := CalculateChanges(oldData, newData, action)
+	if changesData, err := json.Marshal(changes); err == nil {
+		changesJSON = string(changesData)
+	}
+
+	return ChangeLog{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		OldData:    oldDataJSON,
+		NewData:    newDataJSON,
+		Changes:    changesJSON,
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultCurrency валюта по умолчанию для пользователей, счетов и транзакций
+const DefaultCurrency = "RUB"
+
+// ExchangeRate курс валюты к рублю на дату (в формате ежедневных курсов ЦБ РФ)
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rates_currency_date" json:"currency"`
+	Date      time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rates_currency_date" json:"date"`
+	Rate      float64   `gorm:"not null" json:"rate"` // стоимость одной единицы валюты в рублях
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ConversionResult результат пересчета суммы из одной валюты в другую
+type ConversionResult struct {
+	Amount          float64   `json:"amount"`
+	From            string    `json:"from"`
+	To              string    `json:"to"`
+	Date            time.Time `json:"date"`
+	Rate            float64   `json:"rate"`
+	ConvertedAmount float64   `json:"convertedAmount"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// DuplicateDismissal пара транзакций, которую пользователь отметил как разные операции.
+// Такая пара больше не предлагается к объединению. Меньший ID всегда хранится в TransactionID.
+type DuplicateDismissal struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	UserID        uint         `gorm:"not null;uniqueIndex:idx_duplicate_dismissals_pair,priority:1" json:"userId"`
+	User          User         `gorm:"foreignKey:UserID" json:"-"`
+	TransactionID uint         `gorm:"not null;uniqueIndex:idx_duplicate_dismissals_pair,priority:2" json:"transactionId"`
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"-"`
+	DuplicateID   uint         `gorm:"not null;uniqueIndex:idx_duplicate_dismissals_pair,priority:3" json:"duplicateId"`
+	Duplicate     *Transaction `gorm:"foreignKey:DuplicateID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt     time.Time    `json:"createdAt"`
+}
+
+// DuplicateGroup группа транзакций, которые, вероятно, являются одной и той же операцией
+type DuplicateGroup struct {
+	Transactions []Transaction `json:"transactions"`
+}
+
+// DuplicateMergeDTO структура для объединения дубликатов: остается транзакция KeepID,
+// остальные удаляются, а их метки и внешние идентификаторы переносятся на оставшуюся
+type DuplicateMergeDTO struct {
+	KeepID       uint   `json:"keepId" validate:"required"`
+	DuplicateIDs []uint `json:"duplicateIds" validate:"required,min=1,dive,required"`
+}
+
+// DuplicateDismissDTO структура для отклонения группы: транзакции не являются дубликатами друг друга
+type DuplicateDismissDTO struct {
+	TransactionIDs []uint `json:"transactionIds" validate:"required,min=2,dive,required"`
+}
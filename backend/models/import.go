@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+)
+
+// MaxImportFileSize максимальный размер импортируемого файла в байтах: файл целиком
+// читается в память и разбирается, поэтому его размер ограничен отдельно от вложений
+const MaxImportFileSize int64 = 2 * 1024 * 1024
+
+// ImportRow транзакция, разобранная из импортируемого файла, до сохранения в базу
+type ImportRow struct {
+	Line                int              `json:"line"` // номер строки в файле
+	Date                time.Time        `json:"date"`
+	Amount              float64          `json:"amount"` // всегда положительная, направление задает Type
+	Type                CategoryType     `json:"type"`
+	Currency            string           `json:"currency,omitempty"`
+	Description         string           `json:"description"`
+	Category            string           `json:"category,omitempty"`            // категория, указанная в файле (например, категория банка)
+	Counterparty        string           `json:"counterparty,omitempty"`        // контрагент или название магазина
+	CategoryID          *uint            `json:"categoryId,omitempty"`          // категория, назначенная при импорте
+	PayeeID             *uint            `json:"payeeId,omitempty"`             // получатель, найденный по контрагенту или описанию
+	Note                string           `json:"note,omitempty"`                // заметка из сработавшего правила
+	Reviewed            bool             `json:"reviewed,omitempty"`            // отмечена правилом как проверенная
+	ExternalID          string           `json:"externalId,omitempty"`          // идентификатор операции в файле (FITID), по нему пропускаются уже импортированные
+	Splits              []ImportSplit    `json:"splits,omitempty"`              // части разделенной транзакции
+	Recurring           *ImportRecurring `json:"recurring,omitempty"`           // правило регулярного платежа, по которому создана операция
+	Duplicate           bool             `json:"duplicate,omitempty"`           // операция уже была импортирована раньше
+	PossibleDuplicateOf *uint            `json:"possibleDuplicateOf,omitempty"` // похожая существующая транзакция: та же сумма, близкая дата, описание и категория
+	Error               string           `json:"error,omitempty"`               // причина, по которой строку нельзя импортировать
+}
+
+// ImportSplit часть разделенной транзакции в импортируемом файле
+type ImportSplit struct {
+	Category   string  `json:"category"`
+	CategoryID *uint   `json:"categoryId,omitempty"`
+	Amount     float64 `json:"amount"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// ImportRecurring правило регулярного платежа в импортируемом файле.
+// Операции с одинаковым ключом относятся к одному правилу.
+type ImportRecurring struct {
+	Key             string             `json:"key"`
+	Frequency       RecurringFrequency `json:"frequency"`
+	StartDate       time.Time          `json:"startDate"`
+	EndDate         *time.Time         `json:"endDate,omitempty"`
+	NextExecuteDate time.Time          `json:"nextExecuteDate"`
+	IsActive        bool               `json:"isActive"`
+	Amount          float64            `json:"amount"`
+	Description     string             `json:"description"`
+}
+
+// ImportSettings параметры разбора CSV-файла.
+// Незаполненные параметры определяются автоматически по содержимому файла.
+type ImportSettings struct {
+	Encoding         string `json:"encoding" validate:"omitempty,oneof=utf-8 windows-1251"`
+	Delimiter        string `json:"delimiter" validate:"omitempty,len=1"`
+	DateFormat       string `json:"dateFormat"`       // например, DD.MM.YYYY или YYYY-MM-DD
+	DecimalSeparator string `json:"decimalSeparator"` // точка или запятая
+	HasHeader        *bool  `json:"hasHeader"`
+}
+
+// ImportColumnMapping номера колонок CSV-файла (с нуля) для полей транзакции.
+// Сумма задается либо одной колонкой Amount (со знаком или вместе с колонкой Type),
+// либо парой колонок Income и Expense.
+type ImportColumnMapping struct {
+	Date        *int `json:"date"`
+	Amount      *int `json:"amount"`
+	Income      *int `json:"income"`
+	Expense     *int `json:"expense"`
+	Type        *int `json:"type"`
+	Description *int `json:"description"`
+	Category    *int `json:"category"`
+	Currency    *int `json:"currency"`
+}
+
+// ImportOptions параметры сохранения импортируемых транзакций
+type ImportOptions struct {
+	AccountID                *uint           `json:"accountId"`
+	Currency                 string          `json:"currency" validate:"omitempty,len=3,currency"` // валюта строк, для которых она не указана в файле
+	CategoryMapping          map[string]uint `json:"categoryMapping"`                              // категория или контрагент из файла -> ID категории пользователя
+	DefaultIncomeCategoryID  *uint           `json:"defaultIncomeCategoryId"`
+	DefaultExpenseCategoryID *uint           `json:"defaultExpenseCategoryId"`
+	SkipInvalid              bool            `json:"skipInvalid"`            // пропускать строки с ошибками вместо отмены импорта
+	SkipPossibleDuplicates   bool            `json:"skipPossibleDuplicates"` // пропускать строки, похожие на существующие транзакции
+}
+
+// CSVImportDTO параметры импорта CSV-файла, передаются JSON-строкой в поле options
+type CSVImportDTO struct {
+	Settings ImportSettings      `json:"settings"`
+	Mapping  ImportColumnMapping `json:"mapping"`
+	ImportOptions
+}
+
+// BankImportDTO параметры импорта банковской выписки, передаются JSON-строкой в поле options
+type BankImportDTO struct {
+	Format string `json:"format"` // формат выписки; если не указан, определяется по содержимому файла
+	ImportOptions
+}
+
+// ImportCategory категория, встреченная в импортируемом файле
+type ImportCategory struct {
+	Name       string       `json:"name"`
+	Type       CategoryType `json:"type"`
+	Count      int          `json:"count"`
+	CategoryID *uint        `json:"categoryId"` // назначенная или предложенная категория пользователя
+}
@@ -34,6 +34,7 @@ type Investment struct {
 	Type           InvestmentType   `gorm:"not null" json:"type"`
 	Status         InvestmentStatus `gorm:"not null;default:'open'" json:"status"`
 	Amount         float64          `json:"amount"`
+	Currency       string           `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
 	StartDate      time.Time        `json:"startDate"`
 	EndDate        *time.Time       `json:"endDate"`
 	Capitalization float64          `json:"capitalization"`
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Payee модель получателя платежа или продавца (например, "Яндекс Такси").
+// Транзакции связываются с получателем по описанию: оно нормализуется и сравнивается
+// с псевдонимами получателя, поэтому "YANDEX*TAXI 1234" и "Yandex Taxi" относятся к одному получателю.
+type Payee struct {
+	ID                uint         `gorm:"primaryKey" json:"id"`
+	UserID            uint         `gorm:"not null;uniqueIndex:idx_payees_user_name" json:"userId"`
+	User              User         `gorm:"foreignKey:UserID" json:"-"`
+	Name              string       `gorm:"not null;uniqueIndex:idx_payees_user_name" json:"name"`
+	DefaultCategoryID *uint        `json:"defaultCategoryId"` // категория для импортируемых операций без категории
+	DefaultCategory   *Category    `gorm:"foreignKey:DefaultCategoryID;constraint:OnDelete:SET NULL" json:"defaultCategory,omitempty"`
+	Aliases           []PayeeAlias `gorm:"foreignKey:PayeeID;constraint:OnDelete:CASCADE" json:"aliases"`
+	CreatedAt         time.Time    `json:"createdAt"`
+	UpdatedAt         time.Time    `json:"updatedAt"`
+}
+
+// PayeeAlias вариант написания получателя в описаниях операций.
+// Название получателя всегда входит в его псевдонимы.
+type PayeeAlias struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	PayeeID uint   `gorm:"not null;index" json:"payeeId"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_payee_aliases_user_pattern" json:"-"`
+	Alias   string `gorm:"not null" json:"alias"`                                              // псевдоним в том виде, в котором его ввел пользователь
+	Pattern string `gorm:"not null;uniqueIndex:idx_payee_aliases_user_pattern" json:"pattern"` // нормализованный псевдоним, с которым сравниваются описания
+}
+
+// PayeeDTO структура для создания/обновления получателя
+type PayeeDTO struct {
+	Name              string   `json:"name" validate:"required,max=100"`
+	DefaultCategoryID *uint    `json:"defaultCategoryId"`
+	Aliases           []string `json:"aliases" validate:"omitempty,dive,required,max=100"` // дополнительные варианты написания
+}
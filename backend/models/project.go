@@ -34,6 +34,7 @@ type Project struct {
 	Status        ProjectStatus `gorm:"not null;default:'open'" json:"status"`
 	TargetAmount  float64       `json:"targetAmount"`
 	CurrentAmount float64       `json:"currentAmount"`
+	Currency      string        `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
 	StartDate     time.Time     `json:"startDate"`
 	EndDate       *time.Time    `json:"endDate"`
 	Comments      string        `json:"comments"`
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// ClearedStatus состояние транзакции при сверке с банковской выпиской
+type ClearedStatus string
+
+const (
+	// ClearedNone транзакция еще не сверялась
+	ClearedNone ClearedStatus = "uncleared"
+	// ClearedPending транзакция отмечена в незавершенной сверке
+	ClearedPending ClearedStatus = "cleared"
+	// ClearedReconciled транзакция сверена, ее изменение заблокировано
+	ClearedReconciled ClearedStatus = "reconciled"
+)
+
+// ReconciliationStatus состояние сверки
+type ReconciliationStatus string
+
+const (
+	// ReconciliationOpen сверка в процессе: транзакции можно отмечать
+	ReconciliationOpen ReconciliationStatus = "open"
+	// ReconciliationCompleted сверка завершена, отмеченные транзакции сверены
+	ReconciliationCompleted ReconciliationStatus = "completed"
+)
+
+// Reconciliation сверка счета с банковской выпиской за период. Пользователь отмечает
+// транзакции, которые есть в выписке, пока остаток по отмеченным и ранее сверенным
+// транзакциям не совпадет с остатком на конец выписки. У счета может быть только одна
+// незавершенная сверка.
+type Reconciliation struct {
+	ID             uint                 `gorm:"primaryKey" json:"id"`
+	UserID         uint                 `gorm:"not null;index" json:"userId"`
+	User           User                 `gorm:"foreignKey:UserID" json:"-"`
+	AccountID      uint                 `gorm:"not null;index" json:"accountId"`
+	Account        *Account             `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	StartDate      time.Time            `gorm:"not null" json:"startDate"`
+	EndDate        time.Time            `gorm:"not null" json:"endDate"`
+	ClosingBalance float64              `gorm:"not null" json:"closingBalance"` // остаток на конец выписки в валюте счета
+	Status         ReconciliationStatus `gorm:"type:varchar(20);not null;default:'open'" json:"status"`
+	CompletedAt    *time.Time           `json:"completedAt"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	UpdatedAt      time.Time            `json:"updatedAt"`
+}
+
+// ReconciliationDTO структура для начала и изменения сверки
+type ReconciliationDTO struct {
+	AccountID      uint      `json:"accountId" validate:"required"` // при изменении сверки не меняется
+	StartDate      time.Time `json:"startDate" validate:"required"`
+	EndDate        time.Time `json:"endDate" validate:"required"`
+	ClosingBalance float64   `json:"closingBalance"`
+}
+
+// ReconciliationMarkDTO структура для отметки транзакций в сверке
+type ReconciliationMarkDTO struct {
+	TransactionIDs []uint `json:"transactionIds" validate:"required,min=1,dive,required"`
+	Cleared        bool   `json:"cleared"` // false снимает отметку
+}
+
+// ReconciliationSummary сверка с остатками и транзакциями, которые можно отметить
+type ReconciliationSummary struct {
+	Reconciliation
+	ClearedBalance float64               `json:"clearedBalance"` // остаток по сверенным и отмеченным транзакциям
+	Difference     float64               `json:"difference"`     // остаток по выписке минус остаток по отмеченным транзакциям
+	Transactions   []ReconciliationEntry `json:"transactions"`   // транзакции, которые можно отметить, а в завершенной сверке - сверенные в ней
+}
+
+// ReconciliationEntry транзакция в сверке с изменением остатка счета
+type ReconciliationEntry struct {
+	Transaction Transaction `json:"transaction"`
+	Change      float64     `json:"change"` // изменение остатка в валюте счета (со знаком)
+}
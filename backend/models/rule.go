@@ -0,0 +1,157 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TransactionRule пользовательское правило автоматической обработки транзакций.
+// Правило срабатывает, если выполнены все заданные условия, и применяет свои действия.
+// Правила выполняются по возрастанию приоритета при создании транзакций (в том числе
+// через Telegram-бот и импорт), и каждое действие берется из первого сработавшего правила.
+// Категория правила назначается только операциям того же типа, что и она.
+type TransactionRule struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	UserID   uint   `gorm:"not null;index" json:"userId"`
+	User     User   `gorm:"foreignKey:UserID" json:"-"`
+	Name     string `gorm:"not null" json:"name"`
+	Priority int    `gorm:"not null;default:0" json:"priority"` // меньшее значение выполняется раньше
+	IsActive bool   `gorm:"not null" json:"isActive"`
+
+	// Условия
+	DescriptionContains string   `json:"descriptionContains"` // подстрока описания без учета регистра
+	DescriptionRegex    string   `json:"descriptionRegex"`    // регулярное выражение для описания без учета регистра
+	MinAmount           *float64 `json:"minAmount"`
+	MaxAmount           *float64 `json:"maxAmount"`
+	Weekdays            []int    `gorm:"serializer:json;type:jsonb" json:"weekdays"` // дни недели от 1 (понедельник) до 7 (воскресенье)
+
+	// Действия
+	CategoryID   *uint     `json:"categoryId"`
+	Category     *Category `gorm:"foreignKey:CategoryID;constraint:OnDelete:SET NULL" json:"category,omitempty"`
+	Note         string    `json:"note"`         // заметка, которая записывается в транзакцию
+	MarkReviewed bool      `json:"markReviewed"` // отметить транзакцию как проверенную
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	pattern *regexp.Regexp // скомпилированное DescriptionRegex
+}
+
+// TransactionRuleDTO структура для создания/обновления правила
+type TransactionRuleDTO struct {
+	Name                string   `json:"name" validate:"required,max=100"`
+	Priority            int      `json:"priority"`
+	IsActive            *bool    `json:"isActive"` // по умолчанию правило активно
+	DescriptionContains string   `json:"descriptionContains" validate:"max=255"`
+	DescriptionRegex    string   `json:"descriptionRegex" validate:"max=255"`
+	MinAmount           *float64 `json:"minAmount" validate:"omitempty,gte=0"`
+	MaxAmount           *float64 `json:"maxAmount" validate:"omitempty,gte=0"`
+	Weekdays            []int    `json:"weekdays" validate:"omitempty,unique,dive,min=1,max=7"`
+	CategoryID          *uint    `json:"categoryId"`
+	Note                string   `json:"note" validate:"max=1000"`
+	MarkReviewed        bool     `json:"markReviewed"`
+}
+
+// ApplyRulesDTO структура запроса повторного применения правил к существующим транзакциям
+type ApplyRulesDTO struct {
+	RuleIDs         []uint     `json:"ruleIds"` // по умолчанию все активные правила
+	StartDate       *time.Time `json:"startDate"`
+	EndDate         *time.Time `json:"endDate"`
+	IncludeReviewed bool       `json:"includeReviewed"` // изменять и уже проверенные транзакции
+	DryRun          bool       `json:"dryRun"`          // только показать изменения, не сохраняя их
+}
+
+// RuleResult итог применения правил к одной транзакции
+type RuleResult struct {
+	CategoryID *uint
+	Note       *string
+	Reviewed   bool
+	RuleIDs    []uint // сработавшие правила
+}
+
+// Compile проверяет и компилирует регулярное выражение правила
+func (r *TransactionRule) Compile() error {
+	r.pattern = nil
+	if r.DescriptionRegex == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile("(?i)" + r.DescriptionRegex)
+	if err != nil {
+		return err
+	}
+	r.pattern = pattern
+	return nil
+}
+
+// Matches проверяет условия правила для операции. Сумма сравнивается по модулю.
+// У правила с некорректным регулярным выражением условие по описанию не выполняется.
+func (r *TransactionRule) Matches(description string, amount float64, date time.Time) bool {
+	if !r.IsActive {
+		return false
+	}
+	if r.DescriptionContains != "" && !strings.Contains(strings.ToLower(description), strings.ToLower(r.DescriptionContains)) {
+		return false
+	}
+	if r.DescriptionRegex != "" {
+		if r.pattern == nil && r.Compile() != nil {
+			return false
+		}
+		if !r.pattern.MatchString(description) {
+			return false
+		}
+	}
+	if amount < 0 {
+		amount = -amount
+	}
+	if r.MinAmount != nil && amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && amount > *r.MaxAmount {
+		return false
+	}
+	if len(r.Weekdays) > 0 {
+		weekday := int(date.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		found := false
+		for _, day := range r.Weekdays {
+			if day == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// EvaluateRules применяет правила, отсортированные по приоритету, к операции.
+// Каждое действие берется из первого сработавшего правила, которое его задает.
+// Категория правила применяется только к операции того же типа categoryType, чтобы правило
+// не превращало расход в доход, поэтому у правил должна быть загружена Category.
+func EvaluateRules(rules []TransactionRule, description string, amount float64, date time.Time, categoryType CategoryType) RuleResult {
+	var result RuleResult
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(description, amount, date) {
+			continue
+		}
+		result.RuleIDs = append(result.RuleIDs, rule.ID)
+		if result.CategoryID == nil && rule.CategoryID != nil && rule.Category != nil && rule.Category.Type == categoryType {
+			id := *rule.CategoryID
+			result.CategoryID = &id
+		}
+		if result.Note == nil && rule.Note != "" {
+			note := rule.Note
+			result.Note = &note
+		}
+		if rule.MarkReviewed {
+			result.Reviewed = true
+		}
+	}
+	return result
+}
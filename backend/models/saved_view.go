@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+)
+
+// RelativeDateRange период сохраненного представления относительно текущей даты
+type RelativeDateRange string
+
+const (
+	// RangeLast7Days последние 7 дней, включая сегодня
+	RangeLast7Days RelativeDateRange = "last_7_days"
+	// RangeLast30Days последние 30 дней, включая сегодня
+	RangeLast30Days RelativeDateRange = "last_30_days"
+	// RangeLast90Days последние 90 дней, включая сегодня
+	RangeLast90Days RelativeDateRange = "last_90_days"
+	// RangeThisMonth текущий календарный месяц
+	RangeThisMonth RelativeDateRange = "this_month"
+	// RangeLastMonth предыдущий календарный месяц
+	RangeLastMonth RelativeDateRange = "last_month"
+	// RangeThisYear текущий календарный год
+	RangeThisYear RelativeDateRange = "this_year"
+	// RangeLastYear предыдущий календарный год
+	RangeLastYear RelativeDateRange = "last_year"
+)
+
+// SavedView именованный набор фильтров списка транзакций. Представление можно передать
+// параметром view_id в список транзакций, статистику и экспорт: явно указанные параметры
+// запроса имеют приоритет над сохраненными.
+type SavedView struct {
+	ID                 uint              `gorm:"primaryKey" json:"id"`
+	UserID             uint              `gorm:"not null;uniqueIndex:idx_saved_views_user_name" json:"userId"`
+	User               User              `gorm:"foreignKey:UserID" json:"-"`
+	Name               string            `gorm:"not null;uniqueIndex:idx_saved_views_user_name" json:"name"`
+	CategoryIDs        []uint            `gorm:"serializer:json;type:jsonb" json:"categoryIds"`
+	ExcludeCategoryIDs []uint            `gorm:"serializer:json;type:jsonb" json:"excludeCategoryIds"`
+	AccountID          *uint             `json:"accountId"`
+	Account            *Account          `gorm:"foreignKey:AccountID;constraint:OnDelete:SET NULL" json:"-"`
+	Type               CategoryType      `gorm:"type:varchar(20)" json:"type"` // income, expense или пусто - все
+	Tags               []string          `gorm:"serializer:json;type:jsonb" json:"tags"`
+	TagMode            string            `gorm:"type:varchar(10)" json:"tagMode"` // any или all
+	Search             string            `json:"search"`
+	MinAmount          *float64          `json:"minAmount"`
+	MaxAmount          *float64          `json:"maxAmount"`
+	StartDate          *time.Time        `json:"startDate"`
+	EndDate            *time.Time        `json:"endDate"`
+	RelativeRange      RelativeDateRange `gorm:"type:varchar(20)" json:"relativeRange"` // используется вместо StartDate и EndDate
+	CreatedAt          time.Time         `json:"createdAt"`
+	UpdatedAt          time.Time         `json:"updatedAt"`
+}
+
+// SavedViewDTO структура для создания/обновления сохраненного представления
+type SavedViewDTO struct {
+	Name               string            `json:"name" validate:"required,max=100"`
+	CategoryIDs        []uint            `json:"categoryIds" validate:"omitempty,dive,required"`
+	ExcludeCategoryIDs []uint            `json:"excludeCategoryIds" validate:"omitempty,dive,required"`
+	AccountID          *uint             `json:"accountId"`
+	Type               CategoryType      `json:"type" validate:"omitempty,oneof=income expense"`
+	Tags               []string          `json:"tags" validate:"omitempty,dive,required,max=50"`
+	TagMode            string            `json:"tagMode" validate:"omitempty,oneof=any all"`
+	Search             string            `json:"search" validate:"max=255"`
+	MinAmount          *float64          `json:"minAmount" validate:"omitempty,gte=0"`
+	MaxAmount          *float64          `json:"maxAmount" validate:"omitempty,gte=0"`
+	StartDate          *time.Time        `json:"startDate"`
+	EndDate            *time.Time        `json:"endDate"`
+	RelativeRange      RelativeDateRange `json:"relativeRange" validate:"omitempty,oneof=last_7_days last_30_days last_90_days this_month last_month this_year last_year"`
+}
+
+// Period возвращает период представления на указанный момент: относительный период
+// пересчитывается от текущей даты, иначе возвращаются сохраненные даты (nil - без ограничения)
+func (v *SavedView) Period(now time.Time) (*time.Time, *time.Time) {
+	if v.RelativeRange == "" {
+		return v.StartDate, v.EndDate
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var start, end time.Time
+	switch v.RelativeRange {
+	case RangeLast7Days:
+		start, end = today.AddDate(0, 0, -6), today.AddDate(0, 0, 1)
+	case RangeLast30Days:
+		start, end = today.AddDate(0, 0, -29), today.AddDate(0, 0, 1)
+	case RangeLast90Days:
+		start, end = today.AddDate(0, 0, -89), today.AddDate(0, 0, 1)
+	case RangeThisMonth:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 1, 0)
+	case RangeLastMonth:
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		start = end.AddDate(0, -1, 0)
+	case RangeThisYear:
+		start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(1, 0, 0)
+	case RangeLastYear:
+		end = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		start = end.AddDate(-1, 0, 0)
+	default:
+		return v.StartDate, v.EndDate
+	}
+	// Конец периода включительно - последняя наносекунда последнего дня
+	end = end.Add(-time.Nanosecond)
+	return &start, &end
+}
@@ -16,6 +16,13 @@ const (
 	Pro SubscriptionPlan = "pro"
 )
 
+// TransactionLimits максимальное количество транзакций пользователя для каждого плана подписки.
+// Для планов, которых нет в списке, количество не ограничено.
+var TransactionLimits = map[SubscriptionPlan]int64{
+	Basic:   100,
+	Premium: 1000,
+}
+
 // SubscriptionPeriod период подписки
 type SubscriptionPeriod string
 
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag модель произвольной метки транзакций (например, "vacation-2026" или "business").
+// В отличие от категории, к одной транзакции можно привязать несколько меток.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_tags_user_name" json:"userId"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_tags_user_name" json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TagDTO структура для создания/обновления метки
+type TagDTO struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color"`
+}
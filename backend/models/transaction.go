@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // RecurringFrequency частота повторения
@@ -18,58 +20,153 @@ const (
 	RecurringYearly RecurringFrequency = "yearly"
 )
 
+// TransactionKind вид транзакции
+type TransactionKind string
+
+const (
+	// KindRegular обычная транзакция (доход или расход по категории)
+	KindRegular TransactionKind = "regular"
+	// KindTransfer перевод между счетами, не является ни доходом, ни расходом
+	KindTransfer TransactionKind = "transfer"
+	// KindRefund полный или частичный возврат по расходу, уменьшает расход по его категории
+	KindRefund TransactionKind = "refund"
+)
+
 // RecurringRule модель правила регулярного платежа
 type RecurringRule struct {
 	ID              uint               `gorm:"primaryKey" json:"id"`
 	UserID          uint               `gorm:"not null" json:"userId"`
 	User            User               `gorm:"foreignKey:UserID" json:"-"`
 	Amount          float64            `gorm:"not null" json:"amount"`
+	Currency        string             `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
 	Description     string             `json:"description"`
 	CategoryID      uint               `gorm:"not null" json:"categoryId"`
 	Category        Category           `gorm:"foreignKey:CategoryID" json:"category"`
+	AccountID       *uint              `json:"accountId"` // счет, на котором создаются транзакции
+	Account         *Account           `gorm:"foreignKey:AccountID" json:"account,omitempty"`
 	Frequency       RecurringFrequency `gorm:"not null" json:"frequency"`
 	StartDate       time.Time          `gorm:"not null" json:"startDate"`
-	EndDate         *time.Time         `json:"endDate"`                           // null для бессрочных
-	NextExecuteDate time.Time          `gorm:"not null" json:"nextExecuteDate"`   // следующая дата создания транзакции
-	IsActive        bool               `gorm:"default:true" json:"isActive"`      // активно ли правило
+	EndDate         *time.Time         `json:"endDate"`                         // null для бессрочных
+	NextExecuteDate time.Time          `gorm:"not null" json:"nextExecuteDate"` // следующая дата создания транзакции
+	IsActive        bool               `gorm:"default:true" json:"isActive"`    // активно ли правило
 	CreatedAt       time.Time          `json:"createdAt"`
 	UpdatedAt       time.Time          `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt     `gorm:"index" json:"deletedAt,omitempty"` // время перемещения в корзину
 }
 
 // Transaction модель транзакции
 type Transaction struct {
-	ID               uint           `gorm:"primaryKey" json:"id"`
-	Amount           float64        `gorm:"not null" json:"amount"`
-	Description      string         `json:"description"`
-	Date             time.Time      `gorm:"not null" json:"date"`
-	CategoryID       uint           `gorm:"not null" json:"categoryId"`
-	Category         Category       `gorm:"foreignKey:CategoryID" json:"category"`
-	UserID           uint           `gorm:"not null" json:"userId"`
-	User             User           `gorm:"foreignKey:UserID" json:"-"`
-	RecurringRuleID  *uint          `json:"recurringRuleId"`                      // ссылка на правило, если транзакция создана автоматически
-	RecurringRule    *RecurringRule `gorm:"foreignKey:RecurringRuleID" json:"-"` // загружается по требованию
-	IsRecurring      bool           `gorm:"default:false" json:"isRecurring"`     // создана ли автоматически
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
+	ID               uint               `gorm:"primaryKey;index:idx_transactions_user_date_id,priority:3" json:"id"`
+	Amount           float64            `gorm:"not null" json:"amount"`
+	Currency         string             `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
+	BaseAmount       *float64           `gorm:"->;-:migration" json:"baseAmount,omitempty"` // сумма в базовой валюте пользователя, заполняется только при выборке с пересчетом
+	Rank             *float64           `gorm:"->;-:migration" json:"rank,omitempty"`       // релевантность, заполняется только при полнотекстовом поиске
+	Highlight        *string            `gorm:"->;-:migration" json:"highlight,omitempty"`  // описание с выделенными совпадениями, заполняется только при полнотекстовом поиске
+	Description      string             `json:"description"`
+	Note             string             `json:"note"`                                                               // заметка пользователя или правила
+	Reviewed         bool               `gorm:"default:false" json:"reviewed"`                                      // транзакция проверена пользователем или правилом
+	ClearedStatus    ClearedStatus      `gorm:"type:varchar(20);not null;default:'uncleared'" json:"clearedStatus"` // состояние сверки с банковской выпиской
+	ReconciliationID *uint              `gorm:"index" json:"reconciliationId,omitempty"`                            // сверка, в которой транзакция отмечена
+	Reconciliation   *Reconciliation    `gorm:"foreignKey:ReconciliationID" json:"-"`
+	Date             time.Time          `gorm:"not null;index:idx_transactions_user_date_id,priority:2" json:"date"`
+	Kind             TransactionKind    `gorm:"type:varchar(20);not null;default:'regular'" json:"kind"`
+	CategoryID       *uint              `json:"categoryId"` // для переводов между счетами - категория расходов, в которой учитывается комиссия
+	Category         *Category          `gorm:"foreignKey:CategoryID" json:"category"`
+	AccountID        *uint              `gorm:"index" json:"accountId"` // счет, через который прошли деньги (для перевода - счет списания)
+	Account          *Account           `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	ToAccountID      *uint              `gorm:"index" json:"toAccountId"` // счет зачисления (только для переводов)
+	ToAccount        *Account           `gorm:"foreignKey:ToAccountID" json:"toAccount,omitempty"`
+	RefundOfID       *uint              `gorm:"index" json:"refundOfId,omitempty"` // исходный расход (только для возвратов)
+	RefundOf         *Transaction       `gorm:"foreignKey:RefundOfID;constraint:OnDelete:SET NULL" json:"-"`
+	RefundedAmount   *float64           `gorm:"->;-:migration" json:"refundedAmount,omitempty"` // сумма возвратов по расходу, заполняется только у расходов с возвратами
+	NetAmount        *float64           `gorm:"->;-:migration" json:"netAmount,omitempty"`      // сумма расхода за вычетом возвратов, заполняется вместе с RefundedAmount
+	PayeeID          *uint              `gorm:"index" json:"payeeId"`                           // получатель платежа, определяется по описанию, если не указан явно
+	Payee            *Payee             `gorm:"foreignKey:PayeeID;constraint:OnDelete:SET NULL" json:"payee,omitempty"`
+	Fee              float64            `gorm:"default:0" json:"fee"`                                                         // комиссия за перевод, списывается со счета списания
+	Splits           []TransactionSplit `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"splits,omitempty"` // части разделенной транзакции
+	Tags             []Tag              `gorm:"many2many:transaction_tags;constraint:OnDelete:CASCADE" json:"tags,omitempty"`
+	Attachments      []Attachment       `gorm:"foreignKey:TransactionID" json:"attachments,omitempty"`                                                                          // чеки и документы
+	UserID           uint               `gorm:"not null;index:idx_transactions_user_date_id,priority:1;uniqueIndex:idx_transactions_user_external_id,priority:1" json:"userId"` // индекс (user_id, date, id) нужен для курсорной пагинации
+	User             User               `gorm:"foreignKey:UserID" json:"-"`
+	RecurringRuleID  *uint              `json:"recurringRuleId"`                                                                               // ссылка на правило, если транзакция создана автоматически
+	RecurringRule    *RecurringRule     `gorm:"foreignKey:RecurringRuleID" json:"-"`                                                           // загружается по требованию
+	IsRecurring      bool               `gorm:"default:false" json:"isRecurring"`                                                              // создана ли автоматически
+	ExternalID       *string            `gorm:"size:255;uniqueIndex:idx_transactions_user_external_id,priority:2" json:"externalId,omitempty"` // идентификатор операции во внешней системе (FITID из OFX), защищает от повторного импорта
+	CreatedAt        time.Time          `json:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt     `gorm:"index" json:"deletedAt,omitempty"` // время перемещения в корзину
+}
+
+// TransactionSplit часть разделенной транзакции со своей категорией.
+// Сумма всех частей равна сумме родительской транзакции.
+type TransactionSplit struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"not null;index" json:"transactionId"`
+	CategoryID    uint      `gorm:"not null" json:"categoryId"`
+	Category      *Category `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
 
 // TransactionDTO структура для создания/обновления транзакции
 type TransactionDTO struct {
-	Amount      float64   `json:"amount" validate:"required,gt=0"`
-	Description string    `json:"description"`
-	Date        time.Time `json:"date" validate:"required"`
-	CategoryID  uint      `json:"categoryId" validate:"required"`
+	Amount      float64               `json:"amount" validate:"required,gt=0"`
+	Currency    string                `json:"currency" validate:"omitempty,len=3,currency"` // по умолчанию валюта счета или базовая валюта пользователя
+	Description string                `json:"description"`
+	Date        time.Time             `json:"date" validate:"required"`
+	CategoryID  uint                  `json:"categoryId" validate:"required_without=Splits"`
+	AccountID   *uint                 `json:"accountId"`
+	PayeeID     *uint                 `json:"payeeId"` // если не указан, получатель определяется по описанию
+	Note        string                `json:"note" validate:"max=1000"`
+	Reviewed    bool                  `json:"reviewed"`
+	SkipRules   bool                  `json:"skipRules"`                                      // не применять правила автоматической обработки
+	Splits      []TransactionSplitDTO `json:"splits" validate:"omitempty,min=2,dive"`         // разбивка суммы по категориям
+	Tags        []string              `json:"tags" validate:"omitempty,dive,required,max=50"` // названия меток, отсутствующие создаются автоматически
+	ExternalID  *string               `json:"externalId" validate:"omitempty,max=255"`        // идентификатор во внешней системе, повторно с тем же идентификатором транзакция не создается
 	// Поля для создания регулярного платежа
 	CreateRecurring bool                `json:"createRecurring"`
 	Frequency       *RecurringFrequency `json:"frequency"`
 	EndDate         *time.Time          `json:"endDate"`
 }
 
+// TransactionSplitDTO структура части разделенной транзакции
+type TransactionSplitDTO struct {
+	CategoryID uint    `json:"categoryId" validate:"required"`
+	Amount     float64 `json:"amount" validate:"required,gt=0"`
+	Note       string  `json:"note"`
+}
+
+// TransferDTO структура для создания/обновления перевода между счетами
+type TransferDTO struct {
+	FromAccountID uint      `json:"fromAccountId" validate:"required"`
+	ToAccountID   uint      `json:"toAccountId" validate:"required,nefield=FromAccountID"`
+	Amount        float64   `json:"amount" validate:"required,gt=0"`
+	Fee           float64   `json:"fee" validate:"gte=0"`
+	FeeCategoryID *uint     `json:"feeCategoryId"` // категория расходов для комиссии, обязательна при ненулевой комиссии
+	Date          time.Time `json:"date" validate:"required"`
+	Description   string    `json:"description"`
+}
+
+// RefundDTO структура для создания/обновления возврата по расходу
+type RefundDTO struct {
+	TransactionID uint      `json:"transactionId" validate:"required"` // исходный расход, при изменении возврата не меняется
+	Amount        float64   `json:"amount" validate:"required,gt=0"`   // в валюте исходного расхода, в сумме с другими возвратами не больше расхода
+	Date          time.Time `json:"date" validate:"required"`
+	Description   string    `json:"description"`
+	AccountID     *uint     `json:"accountId"`  // по умолчанию счет исходного расхода
+	CategoryID    uint      `json:"categoryId"` // обязательна для разделенного расхода, по умолчанию категория расхода
+	Note          string    `json:"note" validate:"max=1000"`
+}
+
 // RecurringRuleDTO структура для создания/обновления правила
 type RecurringRuleDTO struct {
 	Amount      float64            `json:"amount" validate:"required,gt=0"`
+	Currency    string             `json:"currency" validate:"omitempty,len=3,currency"`
 	Description string             `json:"description"`
 	CategoryID  uint               `json:"categoryId" validate:"required"`
+	AccountID   *uint              `json:"accountId"`
 	Frequency   RecurringFrequency `json:"frequency" validate:"required,oneof=daily weekly monthly yearly"`
 	StartDate   time.Time          `json:"startDate" validate:"required"`
 	EndDate     *time.Time         `json:"endDate"`
@@ -77,7 +174,8 @@ type RecurringRuleDTO struct {
 
 // BulkTransactionDTO структура для массового создания транзакций
 type BulkTransactionDTO struct {
-	Transactions []TransactionDTO `json:"transactions" validate:"required,min=1,dive"`
+	Transactions   []TransactionDTO `json:"transactions" validate:"required,min=1,dive"`
+	SkipDuplicates bool             `json:"skipDuplicates"` // не создавать транзакции, похожие на существующие или на предыдущие в запросе
 }
 
 // BulkDeleteDTO структура для массового удаления транзакций
@@ -85,6 +183,32 @@ type BulkDeleteDTO struct {
 	TransactionIDs []uint `json:"transactionIds" validate:"required,min=1"`
 }
 
+// BulkUpdateDTO структура для массового изменения транзакций. Транзакции выбираются по списку ID
+// или по фильтру из параметров запроса (те же параметры, что у списка транзакций).
+type BulkUpdateDTO struct {
+	TransactionIDs []uint `json:"transactionIds" validate:"omitempty,dive,required"`
+	UseFilter      bool   `json:"useFilter"`                                   // выбрать транзакции по фильтру вместо списка ID
+	CategoryID     *uint  `json:"categoryId"`                                  // новая категория
+	DateShiftDays  int    `json:"dateShiftDays" validate:"gte=-3660,lte=3660"` // сдвиг даты в днях (может быть отрицательным)
+	Find           string `json:"find" validate:"max=255"`                     // подстрока описания для замены
+	Replace        string `json:"replace" validate:"max=255"`                  // замена для find
+}
+
+// IsSplit проверяет, разделена ли транзакция на части по категориям
+func (t *Transaction) IsSplit() bool {
+	return len(t.Splits) > 0
+}
+
+// IsTransfer проверяет, является ли транзакция переводом между счетами
+func (t *Transaction) IsTransfer() bool {
+	return t.Kind == KindTransfer
+}
+
+// IsRefund проверяет, является ли транзакция возвратом по расходу
+func (t *Transaction) IsRefund() bool {
+	return t.Kind == KindRefund
+}
+
 // CalculateNextExecuteDate вычисляет следующую дату выполнения
 func (r *RecurringRule) CalculateNextExecuteDate() time.Time {
 	switch r.Frequency {
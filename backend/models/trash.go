@@ -0,0 +1,16 @@
+package models
+
+// TrashRestoreDTO структура для восстановления записей из корзины.
+// Вместе с транзакциями, бюджетами и регулярными платежами восстанавливаются
+// и удаленные категории, на которые они ссылаются.
+type TrashRestoreDTO struct {
+	TransactionIDs   []uint `json:"transactionIds" validate:"omitempty,dive,required"`
+	CategoryIDs      []uint `json:"categoryIds" validate:"omitempty,dive,required"`
+	BudgetIDs        []uint `json:"budgetIds" validate:"omitempty,dive,required"`
+	RecurringRuleIDs []uint `json:"recurringRuleIds" validate:"omitempty,dive,required"`
+}
+
+// IsEmpty проверяет, что в запросе не указано ни одной записи
+func (dto *TrashRestoreDTO) IsEmpty() bool {
+	return len(dto.TransactionIDs) == 0 && len(dto.CategoryIDs) == 0 && len(dto.BudgetIDs) == 0 && len(dto.RecurringRuleIDs) == 0
+}
@@ -26,6 +26,7 @@ type User struct {
 	LastName       string    `json:"lastName"`
 	Role           UserRole  `gorm:"type:varchar(10);default:'user'" json:"role"`
 	TelegramChatID string    `json:"telegramChatId"`
+	BaseCurrency   string    `gorm:"type:varchar(3);not null;default:'RUB'" json:"baseCurrency"` // валюта, в которой считается статистика
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedUt"`
 }
@@ -38,6 +39,7 @@ type UserResponse struct {
 	LastName       string    `json:"lastName"`
 	Role           UserRole  `json:"role"`
 	TelegramChatID string    `json:"telegramChatId"`
+	BaseCurrency   string    `json:"baseCurrency"`
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedUt"`
 }
@@ -62,6 +64,7 @@ type UpdateUserDTO struct {
 	FirstName      string `json:"firstName" validate:"required"`
 	LastName       string `json:"lastName" validate:"required"`
 	TelegramChatID string `json:"telegramChatId"`
+	BaseCurrency   string `json:"baseCurrency" validate:"omitempty,len=3,currency"`
 }
 
 // UpdateRoleDTO структура для обновления роли пользователя
@@ -123,6 +126,7 @@ func (u *User) ToUserResponse() UserResponse {
 		LastName:       u.LastName,
 		Role:           u.Role,
 		TelegramChatID: u.TelegramChatID,
+		BaseCurrency:   u.BaseCurrency,
 		CreatedAt:      u.CreatedAt,
 		UpdatedAt:      u.UpdatedAt,
 	}
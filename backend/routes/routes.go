@@ -14,6 +14,18 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	authController := controllers.NewAuthController(config)
 	categoryController := controllers.NewCategoryController()
 	transactionController := controllers.NewTransactionController()
+	accountController := controllers.NewAccountController()
+	transferController := controllers.NewTransferController()
+	refundController := controllers.NewRefundController()
+	savedViewController := controllers.NewSavedViewController()
+	reconciliationController := controllers.NewReconciliationController()
+	tagController := controllers.NewTagController()
+	payeeController := controllers.NewPayeeController()
+	ruleController := controllers.NewRuleController()
+	importController := controllers.NewImportController()
+	duplicateController := controllers.NewDuplicateController()
+	attachmentController := controllers.NewAttachmentController()
+	currencyController := controllers.NewCurrencyController()
 	recurringController := controllers.NewRecurringController()
 	budgetController := controllers.NewBudgetController()
 	statsController := controllers.NewStatsController()
@@ -27,6 +39,7 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	projectPaymentController := controllers.NewProjectPaymentController()
 	investmentOperationController := controllers.NewInvestmentOperationController()
 	changeLogController := controllers.NewChangeLogController()
+	trashController := controllers.NewTrashController(config)
 
 	// Группа API v1
 	api := app.Group("/api/v1")
@@ -66,6 +79,12 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	admin.Get("/users", authController.GetUsers)
 	admin.Put("/users/:id/role", authController.UpdateUserRole)
 	admin.Get("/categories", categoryController.GetAllUsersCategories)
+	admin.Post("/exchange-rates/import", currencyController.ImportRates)
+
+	// Валюты и курсы (доступны всем с JWT)
+	currencies := protected.Group("/currencies")
+	currencies.Get("/rates", currencyController.GetRates)
+	currencies.Get("/convert", currencyController.Convert)
 
 	// Отзывы (доступны всем с JWT)
 	reviews := protected.Group("/reviews")
@@ -101,28 +120,121 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 
 	// Категории
 	categories := subscribedOnly.Group("/categories")
-	categories.Use(middlewares.CheckResourceLimits("categories"))
 	categories.Get("/", categoryController.GetAllCategories)
+	categories.Get("/tree", categoryController.GetCategoryTree)
 	categories.Get("/:id", categoryController.GetCategoryByID)
-	categories.Post("/", categoryController.CreateCategory)
+	categories.Post("/", middlewares.CheckResourceLimits("categories"), categoryController.CreateCategory)
 	categories.Put("/:id", categoryController.UpdateCategory)
 	categories.Delete("/:id", categoryController.DeleteCategory)
+	categories.Post("/:id/merge-into/:targetId", categoryController.MergeCategory)
+
+	// Счета (кошельки)
+	accounts := subscribedOnly.Group("/accounts")
+	accounts.Get("/", accountController.GetAllAccounts)
+	accounts.Get("/:id", accountController.GetAccountByID)
+	accounts.Get("/:id/balance", accountController.GetAccountBalance)
+	accounts.Get("/:id/history", accountController.GetAccountHistory)
+	accounts.Post("/", accountController.CreateAccount)
+	accounts.Put("/:id", accountController.UpdateAccount)
+	accounts.Delete("/:id", accountController.DeleteAccount)
+
+	// Лимит количества транзакций проверяется только на маршрутах, которые создают транзакции
+	transactionLimit := middlewares.CheckResourceLimits("transactions")
 
 	// Транзакции
 	transactions := subscribedOnly.Group("/transactions")
-	transactions.Use(middlewares.CheckResourceLimits("transactions"))
 	transactions.Get("/", transactionController.GetAllTransactions)
+	// Дубликаты: маршрут регистрируется до /:id, чтобы не совпасть с ID транзакции
+	transactions.Get("/duplicates", duplicateController.GetDuplicates)
+	transactions.Post("/duplicates/merge", duplicateController.MergeDuplicates)
+	transactions.Post("/duplicates/dismiss", duplicateController.DismissDuplicates)
+	transactions.Get("/suggest-category", transactionController.SuggestCategory)
 	transactions.Get("/:id", transactionController.GetTransactionByID)
-	transactions.Post("/", transactionController.CreateTransaction)
-	transactions.Post("/bulk", transactionController.CreateBulkTransactions)
+	transactions.Post("/", transactionLimit, transactionController.CreateTransaction)
+	transactions.Post("/bulk", transactionLimit, transactionController.CreateBulkTransactions)
 	transactions.Put("/:id", transactionController.UpdateTransaction)
+	transactions.Patch("/bulk", transactionController.UpdateBulkTransactions)
 	transactions.Delete("/bulk", transactionController.DeleteBulkTransactions)
 	transactions.Delete("/:id", transactionController.DeleteTransaction)
 
+	// Вложения транзакций (чеки, счета); размер файла и квота тарифа проверяются при загрузке
+	attachments := transactions.Group("/:id/attachments")
+	attachments.Get("/", attachmentController.GetAttachments)
+	attachments.Post("/", attachmentController.UploadAttachment)
+	attachments.Get("/:attachmentId", attachmentController.DownloadAttachment)
+	attachments.Delete("/:attachmentId", attachmentController.DeleteAttachment)
+
 	// Экспорт транзакций (доступен только для Pro)
 	exportsGroup := transactions.Group("/export", middlewares.RequiresPlan(models.Pro))
 	exportsGroup.Get("/csv", transactionController.ExportTransactionsToCSV)
 	exportsGroup.Get("/excel", transactionController.ExportTransactionsToExcel)
+	exportsGroup.Get("/ofx", transactionController.ExportTransactionsToOFX)
+	exportsGroup.Get("/qif", transactionController.ExportTransactionsToQIF)
+
+	// Сохраненные представления: наборы фильтров для списка транзакций, статистики и экспорта (параметр view_id)
+	savedViews := subscribedOnly.Group("/saved-views")
+	savedViews.Get("/", savedViewController.GetSavedViews)
+	savedViews.Get("/:id", savedViewController.GetSavedView)
+	savedViews.Post("/", savedViewController.CreateSavedView)
+	savedViews.Put("/:id", savedViewController.UpdateSavedView)
+	savedViews.Delete("/:id", savedViewController.DeleteSavedView)
+
+	// Импорт транзакций из файлов: предпросмотр без сохранения и импорт после подтверждения
+	imports := subscribedOnly.Group("/imports")
+	imports.Post("/csv/preview", importController.PreviewCSV)
+	imports.Post("/csv", transactionLimit, importController.ImportCSV)
+	imports.Get("/bank/formats", importController.GetBankFormats)
+	imports.Post("/bank/preview", importController.PreviewBankStatement)
+	imports.Post("/bank", transactionLimit, importController.ImportBankStatement)
+
+	// Метки транзакций
+	tags := subscribedOnly.Group("/tags")
+	tags.Get("/", tagController.GetAllTags)
+	tags.Post("/", tagController.CreateTag)
+	tags.Put("/:id", tagController.UpdateTag)
+	tags.Delete("/:id", tagController.DeleteTag)
+
+	// Получатели платежей и их псевдонимы
+	payees := subscribedOnly.Group("/payees")
+	payees.Get("/", payeeController.GetAllPayees)
+	payees.Post("/link", payeeController.LinkTransactions)
+	payees.Get("/:id", payeeController.GetPayeeByID)
+	payees.Post("/", payeeController.CreatePayee)
+	payees.Put("/:id", payeeController.UpdatePayee)
+	payees.Delete("/:id", payeeController.DeletePayee)
+
+	// Правила автоматической обработки транзакций
+	rules := subscribedOnly.Group("/rules")
+	rules.Get("/", ruleController.GetAllRules)
+	rules.Post("/apply", ruleController.ApplyRules)
+	rules.Get("/:id", ruleController.GetRuleByID)
+	rules.Post("/", ruleController.CreateRule)
+	rules.Put("/:id", ruleController.UpdateRule)
+	rules.Delete("/:id", ruleController.DeleteRule)
+
+	// Переводы между счетами
+	transfers := subscribedOnly.Group("/transfers")
+	transfers.Get("/", transferController.GetAllTransfers)
+	transfers.Post("/", transactionLimit, transferController.CreateTransfer)
+	transfers.Put("/:id", transferController.UpdateTransfer)
+	transfers.Delete("/:id", transferController.DeleteTransfer)
+
+	// Возвраты по расходам
+	refunds := subscribedOnly.Group("/refunds")
+	refunds.Get("/", refundController.GetAllRefunds)
+	refunds.Post("/", transactionLimit, refundController.CreateRefund)
+	refunds.Put("/:id", refundController.UpdateRefund)
+	refunds.Delete("/:id", refundController.DeleteRefund)
+
+	// Сверка счетов с банковскими выписками
+	reconciliations := subscribedOnly.Group("/reconciliations")
+	reconciliations.Get("/", reconciliationController.GetReconciliations)
+	reconciliations.Get("/:id", reconciliationController.GetReconciliation)
+	reconciliations.Post("/", reconciliationController.CreateReconciliation)
+	reconciliations.Put("/:id", reconciliationController.UpdateReconciliation)
+	reconciliations.Post("/:id/transactions", reconciliationController.MarkTransactions)
+	reconciliations.Post("/:id/finish", reconciliationController.FinishReconciliation)
+	reconciliations.Delete("/:id", reconciliationController.DeleteReconciliation)
 
 	// Регулярные платежи (доступны только для Premium и Pro)
 	recurring := subscribedOnly.Group("/recurring", middlewares.RequiresPlan(models.Premium))
@@ -155,6 +267,8 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	advancedStats := stats.Group("", middlewares.RequiresPlan(models.Premium))
 	advancedStats.Get("/categories", statsController.GetCategorySummary)
 	advancedStats.Get("/budgets", statsController.GetBudgetProgress)
+	advancedStats.Get("/tags", statsController.GetTagSummary)
+	advancedStats.Get("/payees", statsController.GetPayeeSummary)
 
 	// Экспорт статистики (доступен только для Pro)
 	statsExport := stats.Group("/export", middlewares.RequiresPlan(models.Pro))
@@ -202,4 +316,10 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	changeLogs := subscribedOnly.Group("/change-logs", middlewares.RequiresPlan(models.Premium))
 	changeLogs.Get("/", changeLogController.GetUserHistory)
 	changeLogs.Get("/:entityType/:entityId", changeLogController.GetEntityHistory)
+	changeLogs.Post("/:id/revert", changeLogController.RevertChange)
+
+	// Корзина: удаленные транзакции, категории, бюджеты и регулярные платежи
+	trash := subscribedOnly.Group("/trash")
+	trash.Get("/", trashController.GetTrash)
+	trash.Post("/restore", trashController.RestoreTrash)
 }
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local хранилище в каталоге локальной файловой системы
+type Local struct {
+	root string
+}
+
+// NewLocal создает хранилище в каталоге root, создавая каталог при необходимости
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог хранилища: %w", err)
+	}
+	return &Local{root: root}, nil
+}
+
+// path возвращает путь к файлу, не допуская выхода за пределы каталога хранилища
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) || strings.Contains(key, "..") {
+		return "", fmt.Errorf("некорректный ключ файла: %q", key)
+	}
+	return filepath.Join(l.root, clean), nil
+}
+
+// Save сохраняет файл через временный файл, чтобы при ошибке не оставить его частично записанным
+func (l *Local) Save(key string, content io.Reader) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open открывает файл для чтения
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Delete удаляет файл
+func (l *Local) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package storage хранит файлы пользователей (например, вложения транзакций).
+// Приложение работает с хранилищем только через интерфейс Storage, поэтому к локальной
+// файловой системе можно добавить S3-совместимые хранилища, не меняя контроллеры.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nikitagorchakov/finance-hub/backend/config"
+)
+
+// ErrNotFound файл с указанным ключом отсутствует в хранилище
+var ErrNotFound = errors.New("файл не найден в хранилище")
+
+// Storage хранилище файлов. Ключ - относительный путь вида "user/transaction/file.pdf",
+// по которому файл сохраняется и затем читается или удаляется.
+type Storage interface {
+	// Save сохраняет содержимое под ключом, заменяя существующий файл
+	Save(key string, content io.Reader) error
+	// Open открывает файл для чтения; если файла нет, возвращает ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete удаляет файл; отсутствие файла ошибкой не считается
+	Delete(key string) error
+}
+
+// Files хранилище, выбранное в конфигурации при запуске
+var Files Storage
+
+// Init создает хранилище по настройке STORAGE_DRIVER
+func Init(cfg *config.Config) error {
+	switch cfg.StorageDriver {
+	case "local", "":
+		local, err := NewLocal(cfg.StoragePath)
+		if err != nil {
+			return err
+		}
+		Files = local
+		return nil
+	}
+	// Для S3-совместимого хранилища достаточно реализовать Storage и добавить его сюда
+	return fmt.Errorf("неизвестное хранилище файлов: %s", cfg.StorageDriver)
+}
@@ -8,10 +8,18 @@ import (
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitagorchakov/finance-hub/backend/classifier"
 	"github.com/nikitagorchakov/finance-hub/backend/db"
 	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"github.com/nikitagorchakov/finance-hub/backend/utils"
 )
 
+// minSuggestionHistory минимальное количество операций пользователя, после которого бот предлагает категории
+const minSuggestionHistory = 20
+
+// minSuggestionConfidence минимальная уверенность классификатора для предложения категории
+const minSuggestionConfidence = 0.15
+
 // Bot представляет Telegram-бота для создания транзакций
 type Bot struct {
 	api            *tgbotapi.BotAPI
@@ -418,6 +426,21 @@ func (b *Bot) sendCategoriesKeyboard(chatID int64, state *UserState) {
 		}
 	}
 	
+	// Сначала показываем категории, которые пользователь обычно выбирает для похожих операций
+	if suggested := b.suggestCategories(state); len(suggested) > 0 {
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("⭐ ПРЕДЛАГАЕМЫЕ", "header:suggested"),
+		})
+		for _, suggestion := range suggested {
+			keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s (%.0f%%)", suggestion.name, suggestion.confidence*100),
+					fmt.Sprintf("cat:%d", suggestion.categoryID),
+				),
+			})
+		}
+	}
+
 	// Добавляем заголовок для расходов, если есть
 	if len(expenseCategories) > 0 {
 		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
@@ -483,6 +506,42 @@ func (b *Bot) sendCategoriesKeyboard(chatID int64, state *UserState) {
 	}
 }
 
+// categorySuggestion категория, предложенная классификатором
+type categorySuggestion struct {
+	categoryID uint
+	name       string
+	confidence float64
+}
+
+// suggestCategories предлагает категории по истории пользователя. На этапе выбора категории
+// описание еще не введено, поэтому предложения основаны в основном на сумме.
+// Если истории мало или уверенность низкая, категории не предлагаются.
+func (b *Bot) suggestCategories(state *UserState) []categorySuggestion {
+	model, err := classifier.TrainForUser(state.UserID, "")
+	if err != nil {
+		log.Printf("Ошибка обучения классификатора категорий: %v", err)
+		return nil
+	}
+	if model.Examples() < minSuggestionHistory {
+		return nil
+	}
+
+	names := make(map[uint]string, len(state.Categories))
+	for _, cat := range state.Categories {
+		names[cat.ID] = cat.Name
+	}
+
+	var result []categorySuggestion
+	for _, suggestion := range model.Suggest(state.Description, state.Amount, 3) {
+		name, ok := names[suggestion.CategoryID]
+		if !ok || suggestion.Confidence < minSuggestionConfidence {
+			continue
+		}
+		result = append(result, categorySuggestion{categoryID: suggestion.CategoryID, name: name, confidence: suggestion.Confidence})
+	}
+	return result
+}
+
 // sendConfirmKeyboard отправляет клавиатуру для подтверждения
 func (b *Bot) sendConfirmKeyboard(chatID int64, text string) {
 	keyboard := [][]tgbotapi.InlineKeyboardButton{
@@ -509,17 +568,59 @@ func (b *Bot) createTransaction(chatID int64, state *UserState) error {
 	now := time.Now()
 	date := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.UTC)
 
-	transaction := models.Transaction{
+	// Транзакции из бота создаются в базовой валюте пользователя
+	var user models.User
+	if err := db.DB.Select("base_currency").First(&user, state.UserID).Error; err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	input := models.TransactionDTO{
 		Amount:      state.Amount,
 		Description: state.Description,
 		Date:        date,
 		CategoryID:  state.CategoryID,
+	}
+
+	// Правила пользователя применяются так же, как при создании транзакции через API:
+	// могут заменить выбранную категорию на категорию того же типа, заполнить заметку и отметить проверку
+	rules, err := utils.LoadTransactionRules(state.UserID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения правил: %w", err)
+	}
+	var categoryType models.CategoryType
+	for _, cat := range state.Categories {
+		if cat.ID == state.CategoryID {
+			categoryType = cat.Type
+		}
+	}
+	utils.ApplyTransactionRules(rules, &input, categoryType)
+
+	// Получатель определяется по описанию, как при создании транзакции через API.
+	// Сопоставление не обязательно для сохранения транзакции, поэтому ошибка только логируется.
+	payeeID, err := utils.MatchUserPayee(input.Description, state.UserID)
+	if err != nil {
+		log.Printf("Ошибка при загрузке получателей: %v", err)
+	}
+
+	transaction := models.Transaction{
+		Amount:      input.Amount,
+		Currency:    user.BaseCurrency,
+		Description: input.Description,
+		Note:        input.Note,
+		Reviewed:    input.Reviewed,
+		Date:        input.Date,
+		Kind:        models.KindRegular,
+		CategoryID:  &input.CategoryID,
+		PayeeID:     payeeID,
 		UserID:      state.UserID,
 	}
 
 	if err := db.DB.Create(&transaction).Error; err != nil {
 		return fmt.Errorf("ошибка создания транзакции: %w", err)
 	}
+	if err := utils.CreateChangeLog(state.UserID, models.EntityTransaction, transaction.ID, models.ActionCreate, nil, transaction); err != nil {
+		log.Printf("Ошибка записи истории изменений: %v", err)
+	}
 
 	return nil
 }
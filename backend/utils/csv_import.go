@@ -0,0 +1,531 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ImportDateFormats поддерживаемые форматы дат в импортируемых файлах.
+// Порядок важен: при автоопределении выбирается первый формат, подходящий ко всем значениям,
+// поэтому для неоднозначных дат вида 01/02/2024 предпочитается день перед месяцем.
+var ImportDateFormats = []struct {
+	Name   string
+	Layout string
+}{
+	{"DD.MM.YYYY", "02.01.2006"},
+	{"DD.MM.YYYY HH:mm", "02.01.2006 15:04"},
+	{"DD.MM.YYYY HH:mm:ss", "02.01.2006 15:04:05"},
+	{"DD.MM.YY", "02.01.06"},
+	{"YYYY-MM-DD", "2006-01-02"},
+	{"YYYY-MM-DD HH:mm:ss", "2006-01-02 15:04:05"},
+	{"YYYY-MM-DDTHH:mm:ssZ", time.RFC3339},
+	{"DD/MM/YYYY", "02/01/2006"},
+	{"MM/DD/YYYY", "01/02/2006"},
+	{"DD-MM-YYYY", "02-01-2006"},
+}
+
+// CSVFile содержимое CSV-файла после определения кодировки и разделителя
+type CSVFile struct {
+	Settings models.ImportSettings
+	Header   []string
+	Rows     [][]string
+}
+
+// ReadCSVFile разбирает CSV-файл. Параметры, не заданные в settings,
+// определяются автоматически: кодировка (UTF-8 или windows-1251), разделитель,
+// наличие заголовка, формат дат и десятичный разделитель (последние два - по колонкам mapping).
+func ReadCSVFile(data []byte, settings models.ImportSettings) (*CSVFile, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	if settings.Encoding == "" {
+		settings.Encoding = "utf-8"
+		if !utf8.Valid(data) {
+			settings.Encoding = "windows-1251"
+		}
+	}
+	if settings.Encoding == "windows-1251" {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось перекодировать файл из windows-1251: %w", err)
+		}
+		data = decoded
+	}
+
+	if settings.Delimiter == "" {
+		settings.Delimiter = detectDelimiter(data)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma, _ = utf8.DecodeRuneInString(settings.Delimiter)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать CSV: %w", err)
+	}
+
+	// Пропускаем пустые строки
+	rows := records[:0]
+	for _, record := range records {
+		if strings.TrimSpace(strings.Join(record, "")) != "" {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("файл не содержит данных")
+	}
+
+	if settings.HasHeader == nil {
+		hasHeader := !rowLooksLikeData(rows[0])
+		settings.HasHeader = &hasHeader
+	}
+
+	file := &CSVFile{Settings: settings, Rows: rows}
+	if *settings.HasHeader {
+		file.Header = rows[0]
+		file.Rows = rows[1:]
+	}
+	return file, nil
+}
+
+// detectDelimiter выбирает разделитель, который дает одинаковое и наибольшее
+// количество колонок в первых строках файла
+func detectDelimiter(data []byte) string {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+	sample := strings.Join(lines, "\n")
+
+	best, bestFields := ";", 1
+	for _, delimiter := range []rune{';', ',', '\t', '|'} {
+		reader := csv.NewReader(strings.NewReader(sample))
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+
+		records, _ := reader.ReadAll()
+		if len(records) == 0 {
+			continue
+		}
+		// Последняя строка образца могла обрезаться, поэтому считаем по первым
+		fields := len(records[0])
+		consistent := true
+		for _, record := range records[:len(records)-1] {
+			if len(record) != fields && strings.TrimSpace(strings.Join(record, "")) != "" {
+				consistent = false
+				break
+			}
+		}
+		if consistent && fields > bestFields {
+			best, bestFields = string(delimiter), fields
+		}
+	}
+	return best
+}
+
+// rowLooksLikeData проверяет, похожа ли строка на данные, а не на заголовок
+func rowLooksLikeData(row []string) bool {
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if _, ok := detectDateLayout([]string{cell}); ok {
+			return true
+		}
+		if _, err := ParseImportAmount(cell, DetectDecimalSeparator([]string{cell})); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// columnKeywords ключевые слова в заголовках колонок для предложения сопоставления.
+// Точное совпадение названия приоритетнее вхождения.
+var columnKeywords = []struct {
+	field    string
+	keywords []string
+}{
+	{"date", []string{"дата операции", "дата", "date", "transaction date"}},
+	{"amount", []string{"сумма операции", "сумма", "amount", "sum"}},
+	{"income", []string{"приход", "поступление", "зачисление", "credit"}},
+	{"expense", []string{"расход", "списание", "debit"}},
+	{"type", []string{"тип", "type"}},
+	{"description", []string{"описание", "назначение платежа", "назначение", "комментарий", "description", "memo", "details"}},
+	{"category", []string{"категория", "category"}},
+	{"currency", []string{"валюта", "currency"}},
+}
+
+// SuggestCSVMapping предлагает сопоставление колонок по заголовкам или, если заголовка нет,
+// по содержимому первых строк
+func SuggestCSVMapping(file *CSVFile) models.ImportColumnMapping {
+	var mapping models.ImportColumnMapping
+	fields := map[string]**int{
+		"date":        &mapping.Date,
+		"amount":      &mapping.Amount,
+		"income":      &mapping.Income,
+		"expense":     &mapping.Expense,
+		"type":        &mapping.Type,
+		"description": &mapping.Description,
+		"category":    &mapping.Category,
+		"currency":    &mapping.Currency,
+	}
+
+	used := make(map[int]bool)
+	if len(file.Header) > 0 {
+		header := make([]string, len(file.Header))
+		for i, name := range file.Header {
+			header[i] = strings.ToLower(strings.TrimSpace(name))
+		}
+
+		for _, column := range columnKeywords {
+			index := -1
+			for _, keyword := range column.keywords {
+				for i, name := range header {
+					if !used[i] && name == keyword {
+						index = i
+						break
+					}
+				}
+				if index >= 0 {
+					break
+				}
+			}
+			if index < 0 {
+				for _, keyword := range column.keywords {
+					for i, name := range header {
+						if !used[i] && strings.Contains(name, keyword) {
+							index = i
+							break
+						}
+					}
+					if index >= 0 {
+						break
+					}
+				}
+			}
+			if index >= 0 {
+				used[index] = true
+				i := index
+				*fields[column.field] = &i
+			}
+		}
+
+		// Колонки прихода и расхода нужны, только если нет общей колонки суммы
+		if mapping.Amount != nil {
+			mapping.Income, mapping.Expense = nil, nil
+		}
+		return mapping
+	}
+
+	// Без заголовка: первая колонка с датами, первая с числами и самая длинная текстовая
+	sample := file.Rows
+	if len(sample) > 20 {
+		sample = sample[:20]
+	}
+	columns := 0
+	for _, row := range sample {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	longest, longestLength := -1, 0
+	for i := 0; i < columns; i++ {
+		values := columnValues(sample, i)
+		if len(values) == 0 {
+			continue
+		}
+		if _, ok := detectDateLayout(values); ok && mapping.Date == nil {
+			index := i
+			mapping.Date = &index
+			continue
+		}
+		if isAmountColumn(values) {
+			if mapping.Amount == nil {
+				index := i
+				mapping.Amount = &index
+			}
+			continue
+		}
+		length := 0
+		for _, value := range values {
+			length += utf8.RuneCountInString(value)
+		}
+		if length > longestLength {
+			longest, longestLength = i, length
+		}
+	}
+	if longest >= 0 {
+		mapping.Description = &longest
+	}
+	return mapping
+}
+
+// isAmountColumn проверяет, что все значения колонки являются суммами
+func isAmountColumn(values []string) bool {
+	separator := DetectDecimalSeparator(values)
+	for _, value := range values {
+		if _, err := ParseImportAmount(value, separator); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// columnValues возвращает непустые значения колонки
+func columnValues(rows [][]string, index int) []string {
+	var values []string
+	for _, row := range rows {
+		if index < len(row) {
+			if value := strings.TrimSpace(row[index]); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// detectDateLayout подбирает формат, которому соответствуют все значения
+func detectDateLayout(values []string) (string, bool) {
+	for _, format := range ImportDateFormats {
+		matches := true
+		for _, value := range values {
+			if _, err := time.Parse(format.Layout, strings.TrimSpace(value)); err != nil {
+				matches = false
+				break
+			}
+		}
+		if matches && len(values) > 0 {
+			return format.Name, true
+		}
+	}
+	return "", false
+}
+
+// importDateLayout возвращает Go-формат по названию формата даты
+func importDateLayout(name string) (string, bool) {
+	for _, format := range ImportDateFormats {
+		if format.Name == name {
+			return format.Layout, true
+		}
+	}
+	return "", false
+}
+
+// DetectDecimalSeparator определяет десятичный разделитель по значениям сумм.
+// Если в числе есть и точка, и запятая, десятичным считается последний из них.
+func DetectDecimalSeparator(values []string) string {
+	commas, dots := 0, 0
+	for _, value := range values {
+		lastComma := strings.LastIndex(value, ",")
+		lastDot := strings.LastIndex(value, ".")
+		switch {
+		case lastComma > lastDot && len(value)-lastComma-1 <= 2:
+			commas++
+		case lastDot > lastComma:
+			dots++
+		}
+	}
+	if commas > dots {
+		return ","
+	}
+	return "."
+}
+
+// ParseImportAmount разбирает сумму с учетом десятичного разделителя.
+// Пробелы и разделители тысяч, а также символы валют игнорируются.
+// Минус, скобки или знак в конце означают отрицательную сумму.
+func ParseImportAmount(value, decimalSeparator string) (float64, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case string(r) == decimalSeparator:
+			b.WriteRune('.')
+		case r == '-' || r == '−' || r == '–':
+			negative = true
+		}
+	}
+
+	number := b.String()
+	if number == "" || strings.Count(number, ".") > 1 {
+		return 0, fmt.Errorf("некорректная сумма: %q", value)
+	}
+	// Строка не должна содержать посторонний текст, кроме символов валют
+	for _, r := range value {
+		if unicode.IsLetter(r) && !strings.ContainsRune("руб.RUBUSDEURрР$€₽", r) {
+			return 0, fmt.Errorf("некорректная сумма: %q", value)
+		}
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная сумма: %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// incomeTypeKeywords и expenseTypeKeywords значения колонки типа операции
+var (
+	incomeTypeKeywords  = []string{"доход", "income", "приход", "поступ", "зачисл", "пополн", "credit", "+"}
+	expenseTypeKeywords = []string{"расход", "expense", "списан", "покупка", "оплата", "debit", "-"}
+)
+
+// ParseCSVRows преобразует строки файла в транзакции для импорта по сопоставлению колонок.
+// Формат дат и десятичный разделитель, если они не заданы, определяются по колонкам
+// и сохраняются в file.Settings. Строки, которые не удалось разобрать, содержат описание ошибки.
+func ParseCSVRows(file *CSVFile, mapping models.ImportColumnMapping) ([]models.ImportRow, error) {
+	if mapping.Date == nil {
+		return nil, fmt.Errorf("не указана колонка с датой")
+	}
+	if mapping.Amount == nil && mapping.Income == nil && mapping.Expense == nil {
+		return nil, fmt.Errorf("не указана колонка с суммой")
+	}
+
+	if file.Settings.DateFormat == "" {
+		name, ok := detectDateLayout(columnValues(file.Rows, *mapping.Date))
+		if !ok {
+			return nil, fmt.Errorf("не удалось определить формат даты")
+		}
+		file.Settings.DateFormat = name
+	}
+	layout, ok := importDateLayout(file.Settings.DateFormat)
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый формат даты: %s", file.Settings.DateFormat)
+	}
+
+	if file.Settings.DecimalSeparator == "" {
+		var values []string
+		for _, index := range []*int{mapping.Amount, mapping.Income, mapping.Expense} {
+			if index != nil {
+				values = append(values, columnValues(file.Rows, *index)...)
+			}
+		}
+		file.Settings.DecimalSeparator = DetectDecimalSeparator(values)
+	}
+	if file.Settings.DecimalSeparator != "." && file.Settings.DecimalSeparator != "," {
+		return nil, fmt.Errorf("десятичный разделитель должен быть точкой или запятой")
+	}
+
+	firstLine := 1
+	if file.Settings.HasHeader != nil && *file.Settings.HasHeader {
+		firstLine = 2
+	}
+
+	rows := make([]models.ImportRow, 0, len(file.Rows))
+	for i, record := range file.Rows {
+		row := models.ImportRow{Line: firstLine + i}
+		cell := func(index *int) string {
+			if index == nil || *index < 0 || *index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[*index])
+		}
+
+		row.Description = cell(mapping.Description)
+		row.Category = cell(mapping.Category)
+		row.Currency = strings.ToUpper(cell(mapping.Currency))
+		if len(row.Currency) != 3 {
+			row.Currency = ""
+		}
+
+		date, err := time.Parse(layout, cell(mapping.Date))
+		if err != nil {
+			row.Error = fmt.Sprintf("некорректная дата: %q", cell(mapping.Date))
+			rows = append(rows, row)
+			continue
+		}
+		row.Date = date
+
+		if err := parseRowAmount(&row, cell, mapping, file.Settings.DecimalSeparator); err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseRowAmount заполняет сумму и тип операции строки
+func parseRowAmount(row *models.ImportRow, cell func(*int) string, mapping models.ImportColumnMapping, separator string) error {
+	var amount float64
+	if mapping.Amount != nil {
+		value, err := ParseImportAmount(cell(mapping.Amount), separator)
+		if err != nil {
+			return err
+		}
+		amount = value
+	} else {
+		// Приход и расход в отдельных колонках, заполнена одна из них
+		if value := cell(mapping.Income); value != "" {
+			income, err := ParseImportAmount(value, separator)
+			if err != nil {
+				return err
+			}
+			amount += math.Abs(income)
+		}
+		if value := cell(mapping.Expense); value != "" {
+			expense, err := ParseImportAmount(value, separator)
+			if err != nil {
+				return err
+			}
+			amount -= math.Abs(expense)
+		}
+	}
+
+	if amount == 0 {
+		return fmt.Errorf("нулевая сумма")
+	}
+
+	row.Type = models.Expense
+	if amount > 0 {
+		row.Type = models.Income
+	}
+
+	// Явно указанный тип операции важнее знака суммы
+	if mapping.Type != nil {
+		value := strings.ToLower(cell(mapping.Type))
+		switch {
+		case value == "":
+		case containsAny(value, incomeTypeKeywords):
+			row.Type = models.Income
+		case containsAny(value, expenseTypeKeywords):
+			row.Type = models.Expense
+		default:
+			return fmt.Errorf("неизвестный тип операции: %q", value)
+		}
+	}
+
+	row.Amount = math.Abs(amount)
+	return nil
+}
+
+// containsAny проверяет, содержит ли строка одно из слов
+func containsAny(value string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(value, keyword) {
+			return true
+		}
+	}
+	return false
+}
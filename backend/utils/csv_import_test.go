@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestReadCSVFileDetection(t *testing.T) {
+	cp1251, err := charmap.Windows1251.NewEncoder().String("Дата;Сумма;Описание\n01.03.2024;-100,50;Кофейня\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      string
+		encoding  string
+		delimiter string
+		hasHeader bool
+		rows      int
+		firstCell string
+	}{
+		{"UTF-8 с точкой с запятой", "Дата;Сумма;Описание\n01.03.2024;-100,50;Кофейня\n", "utf-8", ";", true, 1, "Дата"},
+		{"UTF-8 с BOM", "\xef\xbb\xbfDate,Amount,Memo\n2024-03-01,-100.50,Coffee\n", "utf-8", ",", true, 1, "Date"},
+		{"windows-1251", cp1251, "windows-1251", ";", true, 1, "Дата"},
+		{"табуляция", "Дата\tСумма\tОписание\n01.03.2024\t-100\tКофейня\n", "utf-8", "\t", true, 1, "Дата"},
+		{"вертикальная черта", "Дата|Сумма|Описание\n01.03.2024|-100|Кофейня\n", "utf-8", "|", true, 1, "Дата"},
+		{"разделитель в кавычках", "Дата,Сумма,Описание\n01.03.2024,-100,\"Кофе; пирожное\"\n02.03.2024,-50,Чай\n", "utf-8", ",", true, 2, "Дата"},
+		{"без заголовка", "01.03.2024;-100,50;Кофейня\n02.03.2024;200;Возврат\n", "utf-8", ";", false, 2, "01.03.2024"},
+		{"пустые строки пропускаются", "Дата;Сумма\n\n01.03.2024;-100\n;\n", "utf-8", ";", true, 1, "Дата"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ReadCSVFile([]byte(tt.data), models.ImportSettings{})
+			if err != nil {
+				t.Fatalf("ошибка разбора: %v", err)
+			}
+			if file.Settings.Encoding != tt.encoding {
+				t.Errorf("кодировка %s, ожидалась %s", file.Settings.Encoding, tt.encoding)
+			}
+			if file.Settings.Delimiter != tt.delimiter {
+				t.Errorf("разделитель %q, ожидался %q", file.Settings.Delimiter, tt.delimiter)
+			}
+			if *file.Settings.HasHeader != tt.hasHeader {
+				t.Errorf("заголовок %v, ожидался %v", *file.Settings.HasHeader, tt.hasHeader)
+			}
+			if len(file.Rows) != tt.rows {
+				t.Errorf("получено %d строк, ожидалось %d", len(file.Rows), tt.rows)
+			}
+			first := file.Rows[0][0]
+			if tt.hasHeader {
+				first = file.Header[0]
+			}
+			if first != tt.firstCell {
+				t.Errorf("первая ячейка %q, ожидалась %q", first, tt.firstCell)
+			}
+		})
+	}
+}
+
+func TestReadCSVFileSettings(t *testing.T) {
+	// Заданные параметры не переопределяются автоопределением
+	hasHeader := false
+	file, err := ReadCSVFile([]byte("a,b;c\n"), models.ImportSettings{Delimiter: ";", HasHeader: &hasHeader})
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	if len(file.Rows) != 1 || len(file.Rows[0]) != 2 || file.Rows[0][0] != "a,b" {
+		t.Errorf("строки %q", file.Rows)
+	}
+
+	if _, err := ReadCSVFile([]byte("\n \n"), models.ImportSettings{}); err == nil {
+		t.Errorf("для файла без данных ожидалась ошибка")
+	}
+}
+
+func TestDetectDateLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string // пустая строка означает, что формат не определен
+	}{
+		{"день и месяц через точку", []string{"01.03.2024", "31.12.2024"}, "DD.MM.YYYY"},
+		{"со временем", []string{"01.03.2024 19:25"}, "DD.MM.YYYY HH:mm"},
+		{"с секундами", []string{"01.03.2024 19:25:41"}, "DD.MM.YYYY HH:mm:ss"},
+		{"двузначный год", []string{"01.03.24"}, "DD.MM.YY"},
+		{"ISO", []string{"2024-03-01"}, "YYYY-MM-DD"},
+		{"RFC 3339", []string{"2024-03-01T10:00:00Z"}, "YYYY-MM-DDTHH:mm:ssZ"},
+		{"неоднозначная дата через косую черту", []string{"01/02/2024"}, "DD/MM/YYYY"},
+		{"месяц первым", []string{"01/02/2024", "12/31/2024"}, "MM/DD/YYYY"},
+		{"смешанные форматы", []string{"01.03.2024", "2024-03-01"}, ""},
+		{"не даты", []string{"вчера"}, ""},
+		{"нет значений", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := detectDateLayout(tt.values)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("формат %q (%v), ожидался %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"1 234,56", 1234.56, false},
+		{"-1 234,56 ₽", -1234.56, false},
+		{"(100,00)", -100, false},
+		{"100,00-", -100, false},
+		{"−15,5", -15.5, false},
+		{"1.234,56", 1234.56, false},
+		{"руб. 10,00", 10, false},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"1,2,3", 0, true},
+		{"10 штук", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseImportAmount(tt.value, ",")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("сумма %v, ожидалась %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDecimalSeparator(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"запятая", []string{"100,50", "-3,1"}, ","},
+		{"точка", []string{"100.50", "-3.1"}, "."},
+		{"запятая с разделителем тысяч", []string{"1.234,56"}, ","},
+		{"точка с разделителем тысяч", []string{"1,234.56"}, "."},
+		{"запятая как разделитель тысяч", []string{"1,234"}, "."},
+		{"целые числа", []string{"100", "200"}, "."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectDecimalSeparator(tt.values); got != tt.want {
+				t.Errorf("разделитель %q, ожидался %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestCSVMapping(t *testing.T) {
+	file, err := ReadCSVFile([]byte("Дата операции;Сумма;Описание;Категория;Валюта\n01.03.2024;-100;Кофейня;Кафе;RUB\n"), models.ImportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := SuggestCSVMapping(file)
+	if mapping.Date == nil || *mapping.Date != 0 || mapping.Amount == nil || *mapping.Amount != 1 ||
+		mapping.Description == nil || *mapping.Description != 2 || mapping.Category == nil || *mapping.Category != 3 ||
+		mapping.Currency == nil || *mapping.Currency != 4 || mapping.Income != nil || mapping.Expense != nil {
+		t.Errorf("сопоставление по заголовку %+v", mapping)
+	}
+
+	// Без заголовка колонки определяются по содержимому
+	file, err = ReadCSVFile([]byte("Кофейня у дома;01.03.2024;-100,50\nАптека;02.03.2024;-20\n"), models.ImportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping = SuggestCSVMapping(file)
+	if mapping.Date == nil || *mapping.Date != 1 || mapping.Amount == nil || *mapping.Amount != 2 ||
+		mapping.Description == nil || *mapping.Description != 0 {
+		t.Errorf("сопоставление по содержимому %+v", mapping)
+	}
+}
+
+func TestParseCSVRows(t *testing.T) {
+	data := "Дата;Приход;Расход;Тип;Описание\n" +
+		"01.03.2024;;1 250,50;;Пятёрочка\n" +
+		"02.03.2024;50 000,00;;;Зарплата\n" +
+		"03.03.2024;;100;возврат;Непонятный тип\n" +
+		"31.02.2024;;100;;Ошибка даты\n" +
+		"04.03.2024;;;;Нулевая сумма\n"
+	file, err := ReadCSVFile([]byte(data), models.ImportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Формат даты задан явно: некорректная дата в колонке не дает его определить
+	file.Settings.DateFormat = "DD.MM.YYYY"
+	index := func(i int) *int { return &i }
+	rows, err := ParseCSVRows(file, models.ImportColumnMapping{
+		Date: index(0), Income: index(1), Expense: index(2), Type: index(3), Description: index(4),
+	})
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	if file.Settings.DecimalSeparator != "," {
+		t.Errorf("определен десятичный разделитель %q", file.Settings.DecimalSeparator)
+	}
+
+	want := []struct {
+		line   int
+		amount float64
+		typ    models.CategoryType
+		err    string
+	}{
+		{2, 1250.5, models.Expense, ""},
+		{3, 50000, models.Income, ""},
+		{4, 0, "", `неизвестный тип операции: "возврат"`},
+		{5, 0, "", `некорректная дата: "31.02.2024"`},
+		{6, 0, "", "нулевая сумма"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("получено %d строк, ожидалось %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.Line != w.line || row.Error != w.err {
+			t.Errorf("строка %d: номер %d, ошибка %q, ожидались %d и %q", i, row.Line, row.Error, w.line, w.err)
+		}
+		if w.err == "" && (row.Amount != w.amount || row.Type != w.typ) {
+			t.Errorf("строка %d: %v %s, ожидалось %v %s", i, row.Amount, row.Type, w.amount, w.typ)
+		}
+	}
+
+	if _, err := ParseCSVRows(file, models.ImportColumnMapping{Amount: index(1)}); err == nil {
+		t.Errorf("без колонки даты ожидалась ошибка")
+	}
+	if _, err := ParseCSVRows(file, models.ImportColumnMapping{Date: index(0)}); err == nil {
+		t.Errorf("без колонки суммы ожидалась ошибка")
+	}
+}
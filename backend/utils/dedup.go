@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DuplicateDateWindow сколько дней между датами операций допускается для вероятного дубликата.
+// Банки и пользователи часто записывают одну покупку датой операции и датой списания.
+const DuplicateDateWindow = 3
+
+// minDuplicateDescription минимальная длина описания, при которой вхождение одного описания
+// в другое считается совпадением
+const minDuplicateDescription = 4
+
+// TransactionFingerprint признаки операции, по которым она сравнивается с другими при поиске дубликатов
+type TransactionFingerprint struct {
+	Amount      float64
+	Currency    string // пустая валюта совпадает с любой
+	Date        time.Time
+	Description string
+	CategoryID  *uint // отсутствующая категория совпадает с любой
+}
+
+// NormalizeDescription приводит описание к виду для сравнения: нижний регистр, ё как е,
+// без цифр (номера карт, даты и суммы в описаниях банков) и знаков препинания
+func NormalizeDescription(description string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(description) {
+		switch {
+		case r == 'ё':
+			b.WriteRune('е')
+		case unicode.IsLetter(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// IsLikelyDuplicate проверяет, что две операции, вероятно, одна и та же покупка:
+// совпадают сумма до копейки, валюта и категория, даты отличаются не больше чем на window дней,
+// а нормализованные описания совпадают или одно содержит другое (банки обрезают описания)
+func IsLikelyDuplicate(a, b TransactionFingerprint, window int) bool {
+	if math.Round(a.Amount*100) != math.Round(b.Amount*100) {
+		return false
+	}
+	if a.Currency != "" && b.Currency != "" && !strings.EqualFold(a.Currency, b.Currency) {
+		return false
+	}
+	if a.CategoryID != nil && b.CategoryID != nil && *a.CategoryID != *b.CategoryID {
+		return false
+	}
+
+	days := math.Abs(dateOnly(a.Date).Sub(dateOnly(b.Date)).Hours() / 24)
+	if days > float64(window) {
+		return false
+	}
+
+	descriptionA := NormalizeDescription(a.Description)
+	descriptionB := NormalizeDescription(b.Description)
+	switch {
+	case descriptionA == descriptionB:
+		return true
+	case len([]rune(descriptionA)) < minDuplicateDescription || len([]rune(descriptionB)) < minDuplicateDescription:
+		return false
+	}
+	return strings.Contains(descriptionA, descriptionB) || strings.Contains(descriptionB, descriptionA)
+}
+
+// dateOnly отбрасывает время, чтобы сравнивать календарные дни
+func dateOnly(date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
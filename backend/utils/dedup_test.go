@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeDescription(t *testing.T) {
+	// Варианты одного описания из разных источников дают одинаковый отпечаток
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"Пятёрочка", "ПЯТЕРОЧКА", "  пятерочка  ", "Пятерочка #1234", "ПЯТЁРОЧКА, 01.03.2024"}, "пятерочка"},
+		{[]string{"YANDEX*GO", "Yandex Go", "yandex.go 5411"}, "yandex go"},
+		{[]string{"Оплата по карте ****1234", "оплата по карте *5678"}, "оплата по карте"},
+		{[]string{"", "1234", "  ", "***"}, ""},
+	}
+	for _, tt := range tests {
+		for _, value := range tt.values {
+			if got := NormalizeDescription(value); got != tt.want {
+				t.Errorf("NormalizeDescription(%q) = %q, ожидалось %q", value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestIsLikelyDuplicate(t *testing.T) {
+	food, cafe := uint(1), uint(2)
+	base := TransactionFingerprint{
+		Amount:      1250.5,
+		Currency:    "RUB",
+		Date:        time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Description: "Пятёрочка",
+		CategoryID:  &food,
+	}
+	with := func(change func(f *TransactionFingerprint)) TransactionFingerprint {
+		f := base
+		change(&f)
+		return f
+	}
+
+	tests := []struct {
+		name  string
+		other TransactionFingerprint
+		want  bool
+	}{
+		{"та же операция", base, true},
+		{"сумма с погрешностью вычислений", with(func(f *TransactionFingerprint) { f.Amount = 1250.1 + 0.4 }), true},
+		{"другая сумма", with(func(f *TransactionFingerprint) { f.Amount = 1250.51 }), false},
+		{"валюта в другом регистре", with(func(f *TransactionFingerprint) { f.Currency = "rub" }), true},
+		{"валюта не указана", with(func(f *TransactionFingerprint) { f.Currency = "" }), true},
+		{"другая валюта", with(func(f *TransactionFingerprint) { f.Currency = "USD" }), false},
+		{"категория не указана", with(func(f *TransactionFingerprint) { f.CategoryID = nil }), true},
+		{"другая категория", with(func(f *TransactionFingerprint) { f.CategoryID = &cafe }), false},
+		{"другое время того же дня", with(func(f *TransactionFingerprint) { f.Date = f.Date.Add(11 * time.Hour) }), true},
+		{"дата списания через 3 дня", with(func(f *TransactionFingerprint) { f.Date = f.Date.AddDate(0, 0, 3) }), true},
+		{"через 4 дня", with(func(f *TransactionFingerprint) { f.Date = f.Date.AddDate(0, 0, 4).Add(-11 * time.Hour) }), false},
+		{"описание банка с номером карты и городом", with(func(f *TransactionFingerprint) { f.Description = "ПЯТЕРОЧКА 1234 MOSCOW" }), true},
+		{"обрезанное описание", with(func(f *TransactionFingerprint) { f.Description = "Пятёрочка Москва Тверская" }), true},
+		{"короткое описание не сравнивается по вхождению", with(func(f *TransactionFingerprint) { f.Description = "Пят" }), false},
+		{"разные описания", with(func(f *TransactionFingerprint) { f.Description = "Магнит" }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Сравнение симметрично: порядок операций не меняет результат
+			if got := IsLikelyDuplicate(base, tt.other, DuplicateDateWindow); got != tt.want {
+				t.Errorf("IsLikelyDuplicate(a, b) = %v, ожидалось %v", got, tt.want)
+			}
+			if got := IsLikelyDuplicate(tt.other, base, DuplicateDateWindow); got != tt.want {
+				t.Errorf("IsLikelyDuplicate(b, a) = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+	"golang.org/x/text/encoding/charmap"
+	"gorm.io/gorm/clause"
+)
+
+// cbrValCurs корневой элемент XML с ежедневными курсами ЦБ РФ (XML_daily.asp)
+type cbrValCurs struct {
+	XMLName xml.Name    `xml:"ValCurs"`
+	Date    string      `xml:"Date,attr"`
+	Valutes []cbrValute `xml:"Valute"`
+}
+
+// cbrValute курс одной валюты в XML ЦБ РФ
+type cbrValute struct {
+	CharCode string `xml:"CharCode"`
+	Nominal  string `xml:"Nominal"`
+	Value    string `xml:"Value"`
+}
+
+// ParseCBRRates разбирает XML с ежедневными курсами ЦБ РФ и возвращает курсы валют к рублю
+func ParseCBRRates(r io.Reader) ([]models.ExchangeRate, error) {
+	decoder := xml.NewDecoder(r)
+	// ЦБ РФ отдает файлы в кодировке windows-1251
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(label) {
+		case "windows-1251", "cp1251":
+			return charmap.Windows1251.NewDecoder().Reader(input), nil
+		case "utf-8", "utf8":
+			return input, nil
+		}
+		return nil, fmt.Errorf("неподдерживаемая кодировка: %s", label)
+	}
+
+	var valCurs cbrValCurs
+	if err := decoder.Decode(&valCurs); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать XML: %w", err)
+	}
+
+	date, err := time.Parse("02.01.2006", valCurs.Date)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная дата курсов %q: %w", valCurs.Date, err)
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(valCurs.Valutes))
+	for _, valute := range valCurs.Valutes {
+		// В XML ЦБ РФ дробная часть отделяется запятой
+		value, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(valute.Value), ",", ".", 1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный курс валюты %s: %w", valute.CharCode, err)
+		}
+		nominal, err := strconv.Atoi(strings.TrimSpace(valute.Nominal))
+		if err != nil || nominal <= 0 {
+			return nil, fmt.Errorf("некорректный номинал валюты %s: %q", valute.CharCode, valute.Nominal)
+		}
+
+		rates = append(rates, models.ExchangeRate{
+			Currency: strings.ToUpper(strings.TrimSpace(valute.CharCode)),
+			Date:     date,
+			Rate:     value / float64(nominal),
+		})
+	}
+
+	return rates, nil
+}
+
+// LoadCBRRatesFile загружает курсы из локального файла в формате ежедневных курсов ЦБ РФ
+func LoadCBRRatesFile(path string) ([]models.ExchangeRate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseCBRRates(file)
+}
+
+// SaveExchangeRates сохраняет курсы валют, обновляя уже загруженные курсы на те же даты
+func SaveExchangeRates(rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&rates).Error
+}
+
+// IsKnownCurrency проверяет, что суммы в валюте можно пересчитать: рубль известен всегда,
+// для остальных валют должен быть загружен хотя бы один курс
+func IsKnownCurrency(code string) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == models.DefaultCurrency {
+		return true
+	}
+	if len(code) != 3 {
+		return false
+	}
+	var count int64
+	if err := db.DB.Model(&models.ExchangeRate{}).Where("currency = ?", code).Limit(1).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
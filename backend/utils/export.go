@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gocarina/gocsv"
@@ -16,6 +18,8 @@ import (
 type TransactionExport struct {
 	ID           uint      `csv:"ID"`
 	Amount       float64   `csv:"Сумма"`
+	Currency     string    `csv:"Валюта"`
+	BaseAmount   float64   `csv:"Сумма в базовой валюте"`
 	Description  string    `csv:"Описание"`
 	Date         string    `csv:"Дата"`
 	CategoryName string    `csv:"Категория"`
@@ -28,6 +32,7 @@ type StatsSummary struct {
 	TotalIncome  float64
 	TotalExpense float64
 	Balance      float64
+	Currency     string
 	StartDate    time.Time
 	EndDate      time.Time
 	Categories   []CategorySummary
@@ -41,27 +46,73 @@ type CategorySummary struct {
 	Type       string
 }
 
-// ConvertTransactionsToExport конвертирует транзакции в формат для экспорта
+// ConvertTransactionsToExport конвертирует транзакции в формат для экспорта.
+// Разделенная транзакция выгружается отдельной строкой для каждой своей части.
 func ConvertTransactionsToExport(transactions []models.Transaction) []TransactionExport {
-	result := make([]TransactionExport, len(transactions))
-	for i, t := range transactions {
-		categoryType := "Расход"
-		if t.Category.Type == models.Income {
-			categoryType = "Доход"
+	result := make([]TransactionExport, 0, len(transactions))
+	for _, t := range transactions {
+		// Сумма в базовой валюте заполняется, если транзакции выбраны с пересчетом
+		baseAmount := t.Amount
+		if t.BaseAmount != nil {
+			baseAmount = *t.BaseAmount
 		}
-		result[i] = TransactionExport{
-			ID:           t.ID,
-			Amount:       t.Amount,
-			Description:  t.Description,
-			Date:         t.Date.Format("02.01.2006"),
-			CategoryName: t.Category.Name,
-			CategoryType: categoryType,
-			CreatedAt:    t.CreatedAt,
+		row := TransactionExport{
+			ID:          t.ID,
+			Amount:      t.Amount,
+			Currency:    t.Currency,
+			BaseAmount:  baseAmount,
+			Description: t.Description,
+			Date:        t.Date.Format("02.01.2006"),
+			CreatedAt:   t.CreatedAt,
+		}
+
+		if t.IsTransfer() {
+			row.CategoryType = "Перевод"
+			result = append(result, row)
+			continue
+		}
+
+		if t.IsRefund() {
+			row.CategoryName, _ = exportCategory(t.Category)
+			row.CategoryType = "Возврат"
+			result = append(result, row)
+			continue
+		}
+
+		if !t.IsSplit() {
+			row.CategoryName, row.CategoryType = exportCategory(t.Category)
+			result = append(result, row)
+			continue
+		}
+
+		for _, split := range t.Splits {
+			splitRow := row
+			splitRow.Amount = split.Amount
+			// Сумма части в базовой валюте пересчитывается по тому же курсу, что и вся транзакция
+			if t.Amount != 0 {
+				splitRow.BaseAmount = baseAmount * split.Amount / t.Amount
+			}
+			if split.Note != "" {
+				splitRow.Description = strings.TrimSpace(t.Description + " (" + split.Note + ")")
+			}
+			splitRow.CategoryName, splitRow.CategoryType = exportCategory(split.Category)
+			result = append(result, splitRow)
 		}
 	}
 	return result
 }
 
+// exportCategory возвращает название и тип категории для экспорта
+func exportCategory(category *models.Category) (string, string) {
+	if category == nil {
+		return "", "Расход"
+	}
+	if category.Type == models.Income {
+		return category.Name, "Доход"
+	}
+	return category.Name, "Расход"
+}
+
 // ExportTransactionsToCSV экспортирует транзакции в CSV
 func ExportTransactionsToCSV(transactions []models.Transaction) ([]byte, error) {
 	exportData := ConvertTransactionsToExport(transactions)
@@ -96,7 +147,7 @@ func ExportTransactionsToExcel(transactions []models.Transaction) ([]byte, error
 	f.SetActiveSheet(index)
 	
 	// Устанавливаем заголовки
-	headers := []string{"ID", "Сумма", "Описание", "Дата", "Категория", "Тип", "Дата создания"}
+	headers := []string{"ID", "Сумма", "Валюта", "Сумма в базовой валюте", "Описание", "Дата", "Категория", "Тип", "Дата создания"}
 	for i, header := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheet, cell, header)
@@ -107,11 +158,13 @@ func ExportTransactionsToExcel(transactions []models.Transaction) ([]byte, error
 		row := i + 2 // +2 потому что нумерация начинается с 1 и первая строка - заголовки
 		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), t.ID)
 		f.SetCellValue(sheet, fmt.Sprintf("B%d", row), t.Amount)
-		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), t.Description)
-		f.SetCellValue(sheet, fmt.Sprintf("D%d", row), t.Date)
-		f.SetCellValue(sheet, fmt.Sprintf("E%d", row), t.CategoryName)
-		f.SetCellValue(sheet, fmt.Sprintf("F%d", row), t.CategoryType)
-		f.SetCellValue(sheet, fmt.Sprintf("G%d", row), t.CreatedAt.Format("02.01.2006 15:04:05"))
+		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), t.Currency)
+		f.SetCellValue(sheet, fmt.Sprintf("D%d", row), t.BaseAmount)
+		f.SetCellValue(sheet, fmt.Sprintf("E%d", row), t.Description)
+		f.SetCellValue(sheet, fmt.Sprintf("F%d", row), t.Date)
+		f.SetCellValue(sheet, fmt.Sprintf("G%d", row), t.CategoryName)
+		f.SetCellValue(sheet, fmt.Sprintf("H%d", row), t.CategoryType)
+		f.SetCellValue(sheet, fmt.Sprintf("I%d", row), t.CreatedAt.Format("02.01.2006 15:04:05"))
 	}
 	
 	// Устанавливаем стили для заголовков
@@ -134,7 +187,7 @@ func ExportTransactionsToExcel(transactions []models.Transaction) ([]byte, error
 	
 	// Автоматическая ширина столбцов
 	for i := range headers {
-		colName := string('A' + i)
+		colName := string(rune('A' + i))
 		f.SetColWidth(sheet, colName, colName, 15)
 	}
 	
@@ -179,15 +232,15 @@ func ExportStatsToPDF(stats StatsSummary, userName string) ([]byte, error) {
 	
 	pdf.SetFont("DejaVu", "", 12)
 	pdf.Cell(95, 10, "Общий доход:")
-	pdf.Cell(95, 10, fmt.Sprintf("%.2f руб.", stats.TotalIncome))
+	pdf.Cell(95, 10, fmt.Sprintf("%.2f %s", stats.TotalIncome, stats.Currency))
 	pdf.Ln(8)
 	
 	pdf.Cell(95, 10, "Общий расход:")
-	pdf.Cell(95, 10, fmt.Sprintf("%.2f руб.", stats.TotalExpense))
+	pdf.Cell(95, 10, fmt.Sprintf("%.2f %s", stats.TotalExpense, stats.Currency))
 	pdf.Ln(8)
 	
 	pdf.Cell(95, 10, "Баланс:")
-	pdf.Cell(95, 10, fmt.Sprintf("%.2f руб.", stats.Balance))
+	pdf.Cell(95, 10, fmt.Sprintf("%.2f %s", stats.Balance, stats.Currency))
 	pdf.Ln(15)
 	
 	// Категории расходов
@@ -199,7 +252,7 @@ func ExportStatsToPDF(stats StatsSummary, userName string) ([]byte, error) {
 	pdf.SetFont("DejaVu", "", 12)
 	pdf.SetFillColor(200, 220, 255)
 	pdf.CellFormat(95, 10, "Категория", "1", 0, "", true, 0, "")
-	pdf.CellFormat(35, 10, "Сумма (руб.)", "1", 0, "", true, 0, "")
+	pdf.CellFormat(35, 10, fmt.Sprintf("Сумма (%s)", stats.Currency), "1", 0, "", true, 0, "")
 	pdf.CellFormat(60, 10, "Процент от общего", "1", 1, "", true, 0, "")
 	
 	// Данные по категориям расходов
@@ -234,7 +287,7 @@ func ExportStatsToPDF(stats StatsSummary, userName string) ([]byte, error) {
 	pdf.SetFont("DejaVu", "", 12)
 	pdf.SetFillColor(200, 220, 255)
 	pdf.CellFormat(95, 10, "Категория", "1", 0, "", true, 0, "")
-	pdf.CellFormat(35, 10, "Сумма (руб.)", "1", 0, "", true, 0, "")
+	pdf.CellFormat(35, 10, fmt.Sprintf("Сумма (%s)", stats.Currency), "1", 0, "", true, 0, "")
 	pdf.CellFormat(60, 10, "Процент от общего", "1", 1, "", true, 0, "")
 	
 	// Данные по категориям доходов
@@ -270,4 +323,82 @@ func ExportStatsToPDF(stats StatsSummary, userName string) ([]byte, error) {
 	}
 	
 	return buf.Bytes(), nil
-} 
\ No newline at end of file
+} 
+// exportStatement операции одного счета в одной валюте для выгрузки в OFX и QIF
+type exportStatement struct {
+	Account  *models.Account // nil для транзакций без счета
+	Currency string
+	Entries  []exportEntry
+}
+
+// exportEntry операция в выписке счета. Перевод попадает в выписки обоих счетов.
+type exportEntry struct {
+	Transaction models.Transaction
+	ID          string          // идентификатор операции в выписке
+	Amount      float64         // сумма со знаком: поступления положительные, списания отрицательные
+	Counterpart *models.Account // второй счет перевода
+}
+
+// groupExportStatements раскладывает транзакции по выпискам счетов и валют.
+// Выписки упорядочены по счету и валюте, операции в них - по дате.
+func groupExportStatements(transactions []models.Transaction) []*exportStatement {
+	var statements []*exportStatement
+	index := make(map[string]*exportStatement)
+	add := func(account *models.Account, currency string, entry exportEntry) {
+		var accountID uint
+		if account != nil {
+			accountID = account.ID
+		}
+		key := fmt.Sprintf("%d:%s", accountID, currency)
+		statement, ok := index[key]
+		if !ok {
+			statement = &exportStatement{Account: account, Currency: currency}
+			index[key] = statement
+			statements = append(statements, statement)
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	for _, t := range transactions {
+		id := fmt.Sprintf("FH%d", t.ID)
+		switch {
+		case t.IsTransfer():
+			// Комиссия списывается вместе с переводом со счета списания
+			add(t.Account, t.Currency, exportEntry{Transaction: t, ID: id, Amount: -(t.Amount + t.Fee), Counterpart: t.ToAccount})
+			add(t.ToAccount, t.Currency, exportEntry{Transaction: t, ID: id + "T", Amount: t.Amount, Counterpart: t.Account})
+		case t.IsRefund(), t.Category != nil && t.Category.Type == models.Income:
+			add(t.Account, t.Currency, exportEntry{Transaction: t, ID: id, Amount: t.Amount})
+		default:
+			add(t.Account, t.Currency, exportEntry{Transaction: t, ID: id, Amount: -t.Amount})
+		}
+	}
+
+	sort.SliceStable(statements, func(i, j int) bool {
+		a, b := statements[i], statements[j]
+		if (a.Account == nil) != (b.Account == nil) {
+			return a.Account == nil
+		}
+		if a.Account != nil && a.Account.ID != b.Account.ID {
+			return a.Account.ID < b.Account.ID
+		}
+		return a.Currency < b.Currency
+	})
+	for _, statement := range statements {
+		sort.SliceStable(statement.Entries, func(i, j int) bool {
+			a, b := statement.Entries[i].Transaction, statement.Entries[j].Transaction
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+			return a.ID < b.ID
+		})
+	}
+	return statements
+}
+
+// exportSplitAmount возвращает сумму части со знаком всей операции
+func exportSplitAmount(entry exportEntry, split models.TransactionSplit) float64 {
+	if entry.Amount < 0 {
+		return -split.Amount
+	}
+	return split.Amount
+}
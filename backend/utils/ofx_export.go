@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// ofxNameLength максимальная длина поля NAME по стандарту OFX
+const ofxNameLength = 32
+
+// ofxEscaper экранирует символы, недопустимые в значениях OFX
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// ofxWriter записывает элементы OFX. В SGML (OFX 1.x) у простых элементов нет закрывающих тегов.
+type ofxWriter struct {
+	buf bytes.Buffer
+	xml bool
+}
+
+// open открывает агрегат
+func (w *ofxWriter) open(name string) {
+	fmt.Fprintf(&w.buf, "<%s>\n", name)
+}
+
+// close закрывает агрегат
+func (w *ofxWriter) close(name string) {
+	fmt.Fprintf(&w.buf, "</%s>\n", name)
+}
+
+// leaf записывает простой элемент. Пустые значения пропускаются.
+func (w *ofxWriter) leaf(name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(&w.buf, "<%s>%s", name, ofxEscaper.Replace(value))
+	if w.xml {
+		fmt.Fprintf(&w.buf, "</%s>", name)
+	}
+	w.buf.WriteByte('\n')
+}
+
+// ofxDate форматирует дату OFX
+func ofxDate(date time.Time) string {
+	return date.Format("20060102150405")
+}
+
+// ofxAmount форматирует сумму с точкой в качестве десятичного разделителя
+func ofxAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// ofxAccountType возвращает тип банковского счета OFX
+func ofxAccountType(account *models.Account) string {
+	if account == nil {
+		return "CHECKING"
+	}
+	switch account.Type {
+	case models.AccountSavings:
+		return "SAVINGS"
+	case models.AccountCreditCard:
+		return "CREDITLINE"
+	}
+	return "CHECKING"
+}
+
+// ExportTransactionsToOFX экспортирует транзакции в OFX: версия 1 - SGML (OFX 1.0.2),
+// версия 2 - XML (OFX 2.2). Для каждого счета и валюты формируется отдельная выписка.
+// Категории, разбивка и правила регулярных платежей сохраняются в элементах
+// расширения с префиксом FH., которые другие программы пропускают.
+// Для правил транзакции должны быть загружены вместе с RecurringRule.
+func ExportTransactionsToOFX(transactions []models.Transaction, version int) ([]byte, error) {
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("неподдерживаемая версия OFX: %d", version)
+	}
+
+	w := &ofxWriter{xml: version == 2}
+	if w.xml {
+		w.buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+		w.buf.WriteString("<?OFX OFXHEADER=\"200\" VERSION=\"220\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	} else {
+		w.buf.WriteString("OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:UTF-8\nCHARSET:NONE\nCOMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	}
+
+	now := time.Now()
+	w.open("OFX")
+	w.open("SIGNONMSGSRSV1")
+	w.open("SONRS")
+	w.open("STATUS")
+	w.leaf("CODE", "0")
+	w.leaf("SEVERITY", "INFO")
+	w.close("STATUS")
+	w.leaf("DTSERVER", ofxDate(now))
+	w.leaf("LANGUAGE", "RUS")
+	w.close("SONRS")
+	w.close("SIGNONMSGSRSV1")
+
+	w.open("BANKMSGSRSV1")
+	for i, statement := range groupExportStatements(transactions) {
+		accountID := "0"
+		if statement.Account != nil {
+			accountID = strconv.FormatUint(uint64(statement.Account.ID), 10)
+		}
+
+		w.open("STMTTRNRS")
+		w.leaf("TRNUID", strconv.Itoa(i+1))
+		w.open("STATUS")
+		w.leaf("CODE", "0")
+		w.leaf("SEVERITY", "INFO")
+		w.close("STATUS")
+		w.open("STMTRS")
+		w.leaf("CURDEF", statement.Currency)
+		w.open("BANKACCTFROM")
+		w.leaf("BANKID", "FINANCEHUB")
+		w.leaf("ACCTID", accountID)
+		w.leaf("ACCTTYPE", ofxAccountType(statement.Account))
+		w.close("BANKACCTFROM")
+
+		w.open("BANKTRANLIST")
+		w.leaf("DTSTART", ofxDate(statement.Entries[0].Transaction.Date))
+		w.leaf("DTEND", ofxDate(statement.Entries[len(statement.Entries)-1].Transaction.Date))
+		balance := 0.0
+		for _, entry := range statement.Entries {
+			writeOFXTransaction(w, entry)
+			balance += entry.Amount
+		}
+		w.close("BANKTRANLIST")
+
+		// Остаток считается только по выгруженным операциям
+		w.open("LEDGERBAL")
+		w.leaf("BALAMT", ofxAmount(balance))
+		w.leaf("DTASOF", ofxDate(now))
+		w.close("LEDGERBAL")
+		w.close("STMTRS")
+		w.close("STMTTRNRS")
+	}
+	w.close("BANKMSGSRSV1")
+	w.close("OFX")
+
+	return w.buf.Bytes(), nil
+}
+
+// writeOFXTransaction записывает операцию выписки с расширениями FH.
+func writeOFXTransaction(w *ofxWriter, entry exportEntry) {
+	t := entry.Transaction
+
+	trnType := "DEBIT"
+	switch {
+	case t.IsTransfer():
+		trnType = "XFER"
+	case entry.Amount > 0:
+		trnType = "CREDIT"
+	}
+
+	description := t.Description
+	if t.IsTransfer() && description == "" && entry.Counterpart != nil {
+		description = "Перевод: " + entry.Counterpart.Name
+	}
+	name := description
+	if runes := []rune(name); len(runes) > ofxNameLength {
+		name = string(runes[:ofxNameLength])
+	}
+
+	w.open("STMTTRN")
+	w.leaf("TRNTYPE", trnType)
+	w.leaf("DTPOSTED", ofxDate(t.Date))
+	w.leaf("TRNAMT", ofxAmount(entry.Amount))
+	w.leaf("FITID", entry.ID)
+	w.leaf("NAME", name)
+	// Полное описание, если оно не поместилось в NAME
+	if name != description {
+		w.leaf("MEMO", description)
+	}
+
+	if !t.IsTransfer() && !t.IsSplit() && t.Category != nil {
+		w.leaf("FH.CATEGORY", t.Category.Name)
+		w.leaf("FH.CATEGORYTYPE", string(t.Category.Type))
+	}
+	for _, split := range t.Splits {
+		w.open("FH.SPLIT")
+		if split.Category != nil {
+			w.leaf("FH.CATEGORY", split.Category.Name)
+			w.leaf("FH.CATEGORYTYPE", string(split.Category.Type))
+		}
+		w.leaf("TRNAMT", ofxAmount(exportSplitAmount(entry, split)))
+		w.leaf("MEMO", split.Note)
+		w.close("FH.SPLIT")
+	}
+
+	if rule := t.RecurringRule; rule != nil {
+		w.open("FH.RECURRING")
+		w.leaf("FH.RULEID", strconv.FormatUint(uint64(rule.ID), 10))
+		w.leaf("FH.FREQUENCY", string(rule.Frequency))
+		w.leaf("FH.STARTDATE", ofxDate(rule.StartDate))
+		if rule.EndDate != nil {
+			w.leaf("FH.ENDDATE", ofxDate(*rule.EndDate))
+		}
+		w.leaf("FH.NEXTDATE", ofxDate(rule.NextExecuteDate))
+		if rule.IsActive {
+			w.leaf("FH.ACTIVE", "Y")
+		} else {
+			w.leaf("FH.ACTIVE", "N")
+		}
+		w.leaf("FH.AMOUNT", ofxAmount(rule.Amount))
+		w.leaf("FH.DESCRIPTION", rule.Description)
+		w.close("FH.RECURRING")
+	}
+	w.close("STMTTRN")
+}
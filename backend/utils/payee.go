@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// payeeNoiseWords слова, которые банки и продавцы добавляют к названию и которые
+// не помогают отличить одного получателя от другого: организационно-правовые формы
+// и служебные пометки эквайринга
+var payeeNoiseWords = map[string]bool{
+	"ооо": true, "оао": true, "зао": true, "пао": true, "ао": true, "ип": true,
+	"llc": true, "ltd": true, "inc": true, "gmbh": true,
+	"pos": true, "www": true, "com": true, "ru": true,
+}
+
+// PayeePattern нормализованный псевдоним получателя для сопоставления с описаниями операций
+type PayeePattern struct {
+	PayeeID uint
+	Pattern string
+}
+
+// NormalizePayeeName приводит название получателя или описание операции к виду для сравнения:
+// кроме нормализации описания убирает организационно-правовые формы и служебные слова.
+// "YANDEX*TAXI 1234" и "Yandex Taxi" дают одинаковый результат "yandex taxi".
+func NormalizePayeeName(name string) string {
+	words := strings.Fields(NormalizeDescription(name))
+	result := words[:0]
+	for _, word := range words {
+		if !payeeNoiseWords[word] {
+			result = append(result, word)
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// MatchPayee находит получателя, псевдоним которого содержится в описании операции целыми словами.
+// Если подходят несколько псевдонимов, выбирается самый длинный как наиболее точный.
+func MatchPayee(description string, patterns []PayeePattern) (uint, bool) {
+	normalized := " " + NormalizePayeeName(description) + " "
+	if normalized == "  " {
+		return 0, false
+	}
+
+	var best PayeePattern
+	bestLength := 0
+	for _, p := range patterns {
+		length := utf8.RuneCountInString(p.Pattern)
+		if p.Pattern == "" || length <= bestLength {
+			continue
+		}
+		if strings.Contains(normalized, " "+p.Pattern+" ") {
+			best = p
+			bestLength = length
+		}
+	}
+	return best.PayeeID, bestLength > 0
+}
+
+// LoadPayees загружает получателей пользователя и их псевдонимы для сопоставления с описаниями операций
+func LoadPayees(userID uint) (map[uint]models.Payee, []PayeePattern, error) {
+	var payees []models.Payee
+	if err := db.DB.Where("user_id = ?", userID).Preload("Aliases").Find(&payees).Error; err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[uint]models.Payee, len(payees))
+	var patterns []PayeePattern
+	for _, payee := range payees {
+		byID[payee.ID] = payee
+		for _, alias := range payee.Aliases {
+			patterns = append(patterns, PayeePattern{PayeeID: payee.ID, Pattern: alias.Pattern})
+		}
+	}
+	return byID, patterns, nil
+}
+
+// MatchUserPayee определяет получателя пользователя по описанию операции.
+// Общий путь для создания транзакций через API и Telegram-бот.
+func MatchUserPayee(description string, userID uint) (*uint, error) {
+	_, patterns, err := LoadPayees(userID)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := MatchPayee(description, patterns); ok {
+		return &id, nil
+	}
+	return nil, nil
+}
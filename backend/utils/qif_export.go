@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// qifLine записывает поле QIF. Переводы строк в значении заменяются пробелами,
+// так как каждое поле занимает одну строку.
+func qifLine(buf *bytes.Buffer, code, value string) {
+	value = strings.Join(strings.Fields(value), " ")
+	fmt.Fprintf(buf, "%s%s\n", code, value)
+}
+
+// qifDate форматирует дату QIF в порядке месяц/день/год, как у Quicken
+func qifDate(date time.Time) string {
+	return date.Format("01/02/2006")
+}
+
+// qifAccountType возвращает тип счета QIF
+func qifAccountType(account *models.Account) string {
+	if account == nil {
+		return "Bank"
+	}
+	switch account.Type {
+	case models.AccountCash:
+		return "Cash"
+	case models.AccountCreditCard:
+		return "CCard"
+	}
+	return "Bank"
+}
+
+// qifAccountName возвращает название счета. Название в квадратных скобках в поле
+// категории означает перевод на этот счет.
+func qifAccountName(account *models.Account) string {
+	if account == nil {
+		return "Без счета"
+	}
+	return account.Name
+}
+
+// ExportTransactionsToQIF экспортирует транзакции в QIF: список категорий с типами,
+// операции каждого счета и валюты под своим заголовком !Account (код валюты записывается
+// в описание счета) и правила регулярных платежей в виде запомненных операций.
+// Правило описывается в примечании запомненной операции, а операции ссылаются
+// на него номером FH-R<ID правила>.
+// Для правил транзакции должны быть загружены вместе с RecurringRule.
+func ExportTransactionsToQIF(transactions []models.Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+
+	categories := make(map[string]models.CategoryType)
+	rules := make(map[uint]*models.RecurringRule)
+	for _, t := range transactions {
+		if t.Category != nil {
+			categories[t.Category.Name] = t.Category.Type
+		}
+		for _, split := range t.Splits {
+			if split.Category != nil {
+				categories[split.Category.Name] = split.Category.Type
+			}
+		}
+		if t.RecurringRule != nil {
+			rules[t.RecurringRule.ID] = t.RecurringRule
+		}
+	}
+
+	if len(categories) > 0 {
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		buf.WriteString("!Type:Cat\n")
+		for _, name := range names {
+			qifLine(&buf, "N", name)
+			if categories[name] == models.Income {
+				buf.WriteString("I\n")
+			} else {
+				buf.WriteString("E\n")
+			}
+			buf.WriteString("^\n")
+		}
+	}
+
+	for _, statement := range groupExportStatements(transactions) {
+		buf.WriteString("!Account\n")
+		qifLine(&buf, "N", qifAccountName(statement.Account))
+		qifLine(&buf, "T", qifAccountType(statement.Account))
+		qifLine(&buf, "D", statement.Currency)
+		buf.WriteString("^\n")
+
+		fmt.Fprintf(&buf, "!Type:%s\n", qifAccountType(statement.Account))
+		for _, entry := range statement.Entries {
+			writeQIFTransaction(&buf, entry)
+		}
+	}
+
+	if len(rules) > 0 {
+		ids := make([]uint, 0, len(rules))
+		for id := range rules {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		buf.WriteString("!Type:Memorized\n")
+		for _, id := range ids {
+			writeQIFRule(&buf, rules[id])
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeQIFTransaction записывает операцию счета. Разбивка записывается полями S, E и $.
+func writeQIFTransaction(buf *bytes.Buffer, entry exportEntry) {
+	t := entry.Transaction
+
+	qifLine(buf, "D", qifDate(t.Date))
+	qifLine(buf, "T", ofxAmount(entry.Amount))
+	buf.WriteString("C*\n")
+	if t.RecurringRule != nil {
+		qifLine(buf, "N", fmt.Sprintf("FH-R%d", t.RecurringRule.ID))
+	}
+	qifLine(buf, "P", t.Description)
+
+	switch {
+	case t.IsTransfer():
+		qifLine(buf, "L", "["+qifAccountName(entry.Counterpart)+"]")
+	case t.IsSplit():
+		for _, split := range t.Splits {
+			if split.Category != nil {
+				qifLine(buf, "S", split.Category.Name)
+			}
+			if split.Note != "" {
+				qifLine(buf, "E", split.Note)
+			}
+			qifLine(buf, "$", ofxAmount(exportSplitAmount(entry, split)))
+		}
+	case t.Category != nil:
+		qifLine(buf, "L", t.Category.Name)
+	}
+	buf.WriteString("^\n")
+}
+
+// writeQIFRule записывает правило регулярного платежа запомненной операцией
+func writeQIFRule(buf *bytes.Buffer, rule *models.RecurringRule) {
+	amount := -rule.Amount
+	kind := "P"
+	if rule.Category.Type == models.Income {
+		amount = rule.Amount
+		kind = "D"
+	}
+
+	endDate := ""
+	if rule.EndDate != nil {
+		endDate = rule.EndDate.Format("2006-01-02")
+	}
+	active := "0"
+	if rule.IsActive {
+		active = "1"
+	}
+
+	qifLine(buf, "K", kind)
+	qifLine(buf, "T", ofxAmount(amount))
+	qifLine(buf, "P", rule.Description)
+	if rule.Category.Name != "" {
+		qifLine(buf, "L", rule.Category.Name)
+	}
+	qifLine(buf, "M", fmt.Sprintf("FH:rule=%s;frequency=%s;start=%s;end=%s;next=%s;active=%s",
+		strconv.FormatUint(uint64(rule.ID), 10), rule.Frequency,
+		rule.StartDate.Format("2006-01-02"), endDate, rule.NextExecuteDate.Format("2006-01-02"), active))
+	buf.WriteString("^\n")
+}
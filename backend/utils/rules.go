@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"github.com/nikitagorchakov/finance-hub/backend/db"
+	"github.com/nikitagorchakov/finance-hub/backend/models"
+)
+
+// LoadTransactionRules загружает активные правила пользователя в порядке выполнения.
+// Категории правил загружаются вместе с ними, чтобы сравнивать их тип с типом операции.
+func LoadTransactionRules(userID uint) ([]models.TransactionRule, error) {
+	var rules []models.TransactionRule
+	err := db.DB.Where("user_id = ? AND is_active = ?", userID, true).Preload("Category").Order("priority, id").Find(&rules).Error
+	return rules, err
+}
+
+// ApplyTransactionRules применяет правила к создаваемой транзакции с категорией типа categoryType.
+// Категория правила того же типа заменяет указанную в запросе (кроме разделенных транзакций),
+// заметка записывается, только если заметка не указана явно, а отметка о проверке только добавляется.
+// Общий путь для всех способов создания транзакций, включая Telegram-бот.
+func ApplyTransactionRules(rules []models.TransactionRule, input *models.TransactionDTO, categoryType models.CategoryType) {
+	if input.SkipRules || len(rules) == 0 {
+		return
+	}
+	result := models.EvaluateRules(rules, input.Description, input.Amount, input.Date, categoryType)
+	if result.CategoryID != nil && len(input.Splits) == 0 {
+		input.CategoryID = *result.CategoryID
+	}
+	if result.Note != nil && input.Note == "" {
+		input.Note = *result.Note
+	}
+	if result.Reviewed {
+		input.Reviewed = true
+	}
+}
@@ -5,7 +5,17 @@ import (
 )
 
 // Validator инстанс валидатора
-var Validator = validator.New()
+var Validator = newValidator()
+
+// newValidator создает валидатор с дополнительными правилами:
+// currency - код валюты, для которой загружены курсы (см. IsKnownCurrency)
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return IsKnownCurrency(fl.Field().String())
+	})
+	return v
+}
 
 // ValidationError структура для ошибок валидации
 type ValidationError struct {
@@ -35,16 +45,28 @@ func getErrorMsg(err validator.FieldError) string {
 	switch err.Tag() {
 	case "required":
 		return "Это поле обязательно для заполнения"
+	case "required_without":
+		return "Это поле обязательно, если не указано поле " + err.Param()
 	case "email":
 		return "Некорректный email"
 	case "min":
 		return "Длина должна быть не менее " + err.Param()
+	case "max":
+		return "Длина должна быть не более " + err.Param()
+	case "len":
+		return "Длина должна быть равна " + err.Param()
 	case "gt":
 		return "Значение должно быть больше " + err.Param()
+	case "gte":
+		return "Значение должно быть не меньше " + err.Param()
 	case "gtfield":
 		return "Значение должно быть больше поля " + err.Param()
+	case "nefield":
+		return "Значение не должно совпадать с полем " + err.Param()
 	case "oneof":
 		return "Значение должно быть одним из: " + err.Param()
+	case "currency":
+		return "Неизвестная валюта: для нее не загружены курсы"
 	}
 	return "Некорректное значение"
 }